@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"path/filepath"
 
 	"cloud.google.com/go/storage"
 	"github.com/alexflint/doc-publisher/googledoc"
@@ -42,6 +45,47 @@ func publish(ctx context.Context, creds *google.Credentials, docID string, lwID
 		return nil, fmt.Errorf("error fetching google doc: %w", err)
 	}
 
+	// match images to object IDs before private content is removed
+	var filenames []string
+	for _, image := range d.Images {
+		filenames = append(filenames, image.Filename)
+	}
+	imageFilenamesByObjectID, err := googledoc.MatchObjectIDsToImages(d, filenames)
+	if err != nil {
+		return nil, fmt.Errorf("error matching images to object IDs: %w", err)
+	}
+
+	// remove content that is for editors only
+	exclusions, err := googledoc.RemovePrivateContent(d.Doc, &googledoc.PrivateContentRules{
+		NamedRangePrefix: "private:",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error removing private content: %w", err)
+	}
+	for _, e := range exclusions {
+		log.Printf("excluded private content: %v", e)
+	}
+
+	// parse and remove the settings table at the top of the document
+	settings, err := googledoc.ExtractSettings(d.Doc)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing settings table: %w", err)
+	}
+	if settings == nil {
+		settings = &googledoc.Settings{}
+	}
+
+	// lesswrong takes the title separately from the content
+	title, _ := googledoc.ExtractTitle(d.Doc)
+	if settings.Title == "" {
+		settings.Title = title
+	}
+
+	// unwrap redirects and remove tracking parameters from links
+	for _, l := range googledoc.RewriteLinks(d.Doc, nil) {
+		log.Printf("warning: %v", l)
+	}
+
 	// create a cloud storage client
 	storageClient, err := storage.NewClient(ctx,
 		option.WithCredentialsJSON(storageServiceAccount))
@@ -49,16 +93,23 @@ func publish(ctx context.Context, creds *google.Credentials, docID string, lwID
 		return nil, fmt.Errorf("error creating cloud storage client")
 	}
 
-	// upload images
-	imageURLs, err := googledoc.UploadImages(ctx, d.Images, storageClient.Bucket("doc-publisher-images"))
+	// upload the images that are still in the document
+	images := googledoc.ReferencedImages(d, imageFilenamesByObjectID)
+	imageURLs, err := uploadImages(ctx, images, storageClient.Bucket("doc-publisher-images"))
 	if err != nil {
 		return nil, fmt.Errorf("error uploading images: %w", err)
 	}
 
 	// match image URLs to object IDs
-	imageURLsByObjectID, err := googledoc.MatchObjectIDsToImages(d, imageURLs)
-	if err != nil {
-		return nil, fmt.Errorf("error matching image URLs to object IDs: %w", err)
+	imageURLsByFilename := make(map[string]string)
+	for i, image := range images {
+		imageURLsByFilename[image.Filename] = imageURLs[i]
+	}
+	imageURLsByObjectID := make(map[string]string)
+	for id, filename := range imageFilenamesByObjectID {
+		if url, ok := imageURLsByFilename[filename]; ok {
+			imageURLsByObjectID[id] = url
+		}
 	}
 
 	// convert to markdown
@@ -74,9 +125,21 @@ func publish(ctx context.Context, creds *google.Credentials, docID string, lwID
 	}
 
 	// create the post
+	title = settings.Title
+	if title == "" {
+		title = d.Doc.Title
+	}
 	resp, err := lw.CreatePost(ctx, lesswrong.CreatePostRequest{
-		Title:   "brand new post",
-		Content: md,
+		Title:             title,
+		Content:           md,
+		Draft:             settings.Draft,
+		SubmitToFrontpage: settings.Frontpage,
+		ModerationStyle:   settings.Moderation,
+		CanonicalSource:   settings.Canonical,
+		Tags:              settings.Tags,
+		Coauthors:         settings.Coauthors,
+		Sequence:          settings.Sequence,
+		Slug:              settings.Slug,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating lesswrong post: %w", err)
@@ -88,3 +151,27 @@ func publish(ctx context.Context, creds *google.Credentials, docID string, lwID
 		URL: resp.URL,
 	}, nil
 }
+
+// uploadImages uploads images to a cloud storage bucket, naming each one
+// after a hash of its content, and returns their URLs in the same order
+func uploadImages(ctx context.Context, images []*googledoc.Image, bucket *storage.BucketHandle) ([]string, error) {
+	var urls []string
+	for _, image := range images {
+		hash := sha256.Sum256(image.Content)
+		obj := bucket.Object(hex.EncodeToString(hash[:8]) + filepath.Ext(image.Filename))
+
+		wr := obj.NewWriter(ctx)
+		_, err := wr.Write(image.Content)
+		if err != nil {
+			wr.Close()
+			return nil, fmt.Errorf("error writing %s to cloud storage: %w", image.Filename, err)
+		}
+		err = wr.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error writing %s to cloud storage: %w", image.Filename, err)
+		}
+
+		urls = append(urls, fmt.Sprintf("https://storage.googleapis.com/%s/%s", obj.BucketName(), obj.ObjectName()))
+	}
+	return urls, nil
+}
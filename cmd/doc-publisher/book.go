@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/alexflint/doc-publisher/epub"
+	"github.com/alexflint/doc-publisher/googledoc"
+	dphtml "github.com/alexflint/doc-publisher/html"
+	"github.com/alexflint/doc-publisher/latex"
+	"github.com/alexflint/doc-publisher/markdown"
+	"github.com/alexflint/doc-publisher/pandoc"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for books
+const (
+	latexBook = "latex"
+	htmlBook  = "html"
+	epubBook  = "epub"
+)
+
+type bookArgs struct {
+	Manifest    string `arg:"positional,required" help:"YAML file listing the chapters of the book"`
+	Output      string `arg:"-o,--output,required"`
+	Format      string `help:"output format. Possible values: latex, html, epub. If empty, the format is chosen from the extension of the output"`
+	Template    string `help:"template for latex or html output. Latex output uses tex/template.tex if empty"`
+	Suggestions string `help:"how to deal with suggested edits. Possible values: accept, reject"`
+	Math        string `help:"how to render latex math in html output. Possible values: katex, mathml" default:"katex"`
+	ChartTables bool   `help:"render linked charts as tables of their data, which must be fetched with --charts"`
+	filterArgs
+	privateContentArgs
+	styleArgs
+	linkArgs
+}
+
+// bookManifest lists the documents in a book
+type bookManifest struct {
+	Title    string         `yaml:"title"`
+	Subtitle string         `yaml:"subtitle"`
+	Authors  []string       `yaml:"authors"`
+	Language string         `yaml:"language"`
+	Cover    string         `yaml:"cover"` // image file for the cover of EPUB books
+	Chapters []*bookChapter `yaml:"chapters"`
+}
+
+// bookChapter is a document in a book
+type bookChapter struct {
+	Archive string `yaml:"archive"` // path to the archive, relative to the manifest
+	Title   string `yaml:"title"`   // if empty, the title of the document is used
+	Part    string `yaml:"part"`    // if not empty, a part with this title begins before the chapter
+
+	doc      *googledoc.Archive
+	settings *googledoc.Settings
+	images   map[string]string // image paths relative to the output, keyed by inline object ID
+}
+
+// loadBookManifest loads a manifest and makes the paths in it relative to
+// the working directory
+func loadBookManifest(path string) (*bookManifest, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading book manifest: %w", err)
+	}
+
+	var m bookManifest
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	dec.KnownFields(true)
+	err = dec.Decode(&m)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing book manifest in %s: %w", path, err)
+	}
+	if len(m.Chapters) == 0 {
+		return nil, fmt.Errorf("book manifest %s lists no chapters", path)
+	}
+
+	dir := filepath.Dir(path)
+	for i, c := range m.Chapters {
+		if c.Archive == "" {
+			return nil, fmt.Errorf("chapter %d in %s has no archive", i+1, path)
+		}
+		if !filepath.IsAbs(c.Archive) {
+			c.Archive = filepath.Join(dir, c.Archive)
+		}
+	}
+	if m.Cover != "" && !filepath.IsAbs(m.Cover) {
+		m.Cover = filepath.Join(dir, m.Cover)
+	}
+	return &m, nil
+}
+
+func buildBook(ctx context.Context, args *bookArgs) error {
+	if args.Suggestions == "markup" {
+		return fmt.Errorf("suggestions cannot be marked up in books")
+	}
+	format := args.Format
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(args.Output)) {
+		case ".tex":
+			format = latexBook
+		case ".html", ".htm":
+			format = htmlBook
+		case ".epub":
+			format = epubBook
+		default:
+			return fmt.Errorf("cannot tell the format from %s, use --format", args.Output)
+		}
+	}
+	if format != latexBook && format != htmlBook && format != epubBook {
+		return fmt.Errorf("invalid book format %q", format)
+	}
+
+	rules, err := loadStyles(&args.styleArgs)
+	if err != nil {
+		return err
+	}
+	m, err := loadBookManifest(args.Manifest)
+	if err != nil {
+		return err
+	}
+
+	// load every document first, so that links between them can be found
+	book := make(map[string]bool)
+	for _, c := range m.Chapters {
+		c.doc, err = googledoc.ReadFile(c.Archive)
+		if err != nil {
+			return err
+		}
+		if book[c.doc.Doc.DocumentId] {
+			return fmt.Errorf("%s appears in the book more than once", c.Archive)
+		}
+		book[c.doc.Doc.DocumentId] = true
+	}
+
+	// chapter titles are the top level in html and epub, so headings
+	// within chapters begin at the second level
+	topHeading := 2
+	if format == latexBook {
+		topHeading = 1
+	}
+
+	outDir := filepath.Dir(args.Output)
+	for i, c := range m.Chapters {
+		var filenames []string
+		for _, image := range c.doc.Images {
+			filenames = append(filenames, image.Filename)
+		}
+		imageFilenamesByObjectID, err := googledoc.MatchObjectIDsToImages(c.doc, filenames)
+		if err != nil {
+			return err
+		}
+
+		_, err = applySuggestionsArg(c.doc, args.Suggestions)
+		if err != nil {
+			return err
+		}
+		err = removePrivateContent(c.doc, &args.privateContentArgs)
+		if err != nil {
+			return err
+		}
+
+		// keep only the images that remain, so that they are all that is
+		// written out or packed into the book. Images for each chapter go in
+		// a directory of their own, since image filenames are only unique
+		// within a document.
+		c.doc.Images = googledoc.ReferencedImages(c.doc, imageFilenamesByObjectID)
+		srcsByFilename := make(map[string]string)
+		for _, image := range c.doc.Images {
+			src := path.Join(fmt.Sprintf("ch%d", i+1), filepath.ToSlash(image.Filename))
+			srcsByFilename[image.Filename] = src
+			if format != epubBook {
+				err = writeImage(filepath.Join(outDir, filepath.FromSlash(src)), image.Content)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		c.images = mapImageSrcs(imageFilenamesByObjectID, srcsByFilename)
+		err = rewriteBookLinks(c.doc, &args.linkArgs, book)
+		if err != nil {
+			return err
+		}
+		c.settings, err = extractMetadata(c.doc, topHeading)
+		if err != nil {
+			return err
+		}
+		_, err = applyFilters(ctx, c.doc, c.settings, c.images, rules, &args.filterArgs, format)
+		if err != nil {
+			return err
+		}
+		if c.Title == "" {
+			c.Title = c.settings.Title
+		}
+	}
+	if m.Title == "" {
+		m.Title = m.Chapters[0].settings.Title
+	}
+
+	// every chapter has been filtered, so they all have the styles that
+	// filtered documents have
+	if len(args.Filters) > 0 {
+		rules = pandoc.WithRoleRules(rules)
+	}
+
+	switch format {
+	case latexBook:
+		err = writeLatexBook(m, args, rules)
+	case htmlBook:
+		err = writeHTMLBook(m, args, rules)
+	case epubBook:
+		err = writeEPUBBook(m, args, rules)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d chapters to %s\n", len(m.Chapters), args.Output)
+	return nil
+}
+
+// writeImage writes an image file, creating its directory if necessary
+func writeImage(path string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return fmt.Errorf("error creating image directory: %w", err)
+	}
+	err = ioutil.WriteFile(path, content, 0666)
+	if err != nil {
+		return fmt.Errorf("error writing image to %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeLatexBook writes a book as a single latex file, with a \chapter for
+// each document
+func writeLatexBook(m *bookManifest, args *bookArgs, rules *googledoc.StyleRules) error {
+	// links between documents become references to labels
+	docLabels := make(map[string]string)
+	headingLabels := make(map[string]string)
+	for i, c := range m.Chapters {
+		docLabels[c.doc.Doc.DocumentId] = fmt.Sprintf("ch%d", i+1)
+		for id, anchor := range markdown.HeadingAnchors(c.doc.Doc.Body.Content) {
+			headingLabels[id] = fmt.Sprintf("ch%d:%s", i+1, anchor)
+		}
+	}
+
+	var content strings.Builder
+	for i, c := range m.Chapters {
+		opts := latex.Options{
+			ImageFilenameByObjectID: c.images,
+			StyleRules:              rules,
+			HeadingLabels:           headingLabels,
+			DocLabels:               docLabels,
+			RedefineMacros:          true, // several documents may define the same macro
+		}
+		if args.ChartTables {
+			opts.ChartTables = chartTables(c.doc)
+		}
+		tex, err := latex.FromGoogleDoc(c.doc.Doc, &opts)
+		if err != nil {
+			return fmt.Errorf("error converting %s: %w", c.Archive, err)
+		}
+
+		if c.Part != "" {
+			fmt.Fprintf(&content, "\\part{%s}\n\n", latex.Escape(c.Part))
+		}
+		fmt.Fprintf(&content, "\\chapter{%s}\\label{ch%d}\n\n", latex.Escape(c.Title), i+1)
+		content.WriteString(tex)
+		content.WriteString("\n")
+	}
+
+	templatePath := args.Template
+	if templatePath == "" {
+		templatePath = "tex/template.tex"
+	}
+	tpl, err := texttemplate.ParseFiles(templatePath)
+	if err != nil {
+		return fmt.Errorf("error parsing latex template: %w", err)
+	}
+
+	var out bytes.Buffer
+	err = tpl.Execute(&out, latexInputs{
+		Title:    latex.Escape(m.Title),
+		Subtitle: latex.Escape(m.Subtitle),
+		Author:   latex.Escape(strings.Join(m.Authors, ", ")),
+		Content:  content.String(),
+		Book:     true,
+	})
+	if err != nil {
+		return fmt.Errorf("error executing latex template: %w", err)
+	}
+	err = ioutil.WriteFile(args.Output, out.Bytes(), 0666)
+	if err != nil {
+		return fmt.Errorf("error writing to %s: %w", args.Output, err)
+	}
+	return nil
+}
+
+// bookHTMLOptions gets the options for converting each chapter of a book to
+// html, given the URL of each chapter and the prefix of the ids of
+// headings in each chapter
+func bookHTMLOptions(m *bookManifest, args *bookArgs, rules *googledoc.StyleRules, chapterURLs, idPrefixes []string) []*dphtml.Options {
+	docURLs := make(map[string]string)
+	headingURLs := make(map[string]string)
+	for i, c := range m.Chapters {
+		docURLs[c.doc.Doc.DocumentId] = chapterURLs[i]
+		for id, anchor := range markdown.HeadingAnchors(c.doc.Doc.Body.Content) {
+			headingURLs[id] = strings.SplitN(chapterURLs[i], "#", 2)[0] + "#" + idPrefixes[i] + anchor
+		}
+	}
+
+	// footnotes are numbered continuously through the book
+	var footnotes int
+	var opts []*dphtml.Options
+	for i, c := range m.Chapters {
+		o := dphtml.Options{
+			ImageURLByObjectID: c.images,
+			StyleRules:         rules,
+			HeadingURLs:        headingURLs,
+			DocURLs:            docURLs,
+			HeadingIDPrefix:    idPrefixes[i],
+			FootnoteOffset:     footnotes,
+		}
+		if args.ChartTables {
+			o.ChartTables = chartTables(c.doc)
+		}
+		footnotes += len(googledoc.FootnoteIDs(c.doc.Doc, c.doc.Doc.Body.Content))
+		opts = append(opts, &o)
+	}
+	return opts
+}
+
+// writeHTMLBook writes a book as a single html page, with a section for
+// each document
+func writeHTMLBook(m *bookManifest, args *bookArgs, rules *googledoc.StyleRules) error {
+	var urls, prefixes []string
+	for i := range m.Chapters {
+		urls = append(urls, fmt.Sprintf("#chapter-%d", i+1))
+		prefixes = append(prefixes, fmt.Sprintf("ch%d-", i+1))
+	}
+
+	var content strings.Builder
+	for i, opts := range bookHTMLOptions(m, args, rules, urls, prefixes) {
+		c := m.Chapters[i]
+		if args.Math != "katex" {
+			opts.MathStyle = args.Math
+		}
+		s, err := dphtml.FromGoogleDoc(c.doc.Doc, opts)
+		if err != nil {
+			return fmt.Errorf("error converting %s: %w", c.Archive, err)
+		}
+
+		if c.Part != "" {
+			fmt.Fprintf(&content, "<h1 class=\"part\">%s</h1>\n", html.EscapeString(c.Part))
+		}
+		fmt.Fprintf(&content, "<section class=\"chapter\" id=\"chapter-%d\">\n<h1 class=\"chapter\">%s</h1>\n%s</section>\n", i+1, html.EscapeString(c.Title), s)
+	}
+
+	out := []byte(content.String())
+	if args.Template != "" {
+		tpl, err := template.ParseFiles(args.Template)
+		if err != nil {
+			return fmt.Errorf("error parsing html template: %w", err)
+		}
+		var buf bytes.Buffer
+		err = tpl.Execute(&buf, htmlInputs{
+			Title:    m.Title,
+			Subtitle: m.Subtitle,
+			Settings: &googledoc.Settings{Title: m.Title, Subtitle: m.Subtitle, Coauthors: m.Authors},
+			Content:  template.HTML(content.String()),
+		})
+		if err != nil {
+			return fmt.Errorf("error executing html template: %w", err)
+		}
+		out = buf.Bytes()
+	}
+
+	err := ioutil.WriteFile(args.Output, out, 0666)
+	if err != nil {
+		return fmt.Errorf("error writing to %s: %w", args.Output, err)
+	}
+	return nil
+}
+
+// writeEPUBBook writes a book as an EPUB, with an XHTML file for each
+// document and for each part
+func writeEPUBBook(m *bookManifest, args *bookArgs, rules *googledoc.StyleRules) error {
+	b := epub.Book{
+		Title:      m.Title,
+		Subtitle:   m.Subtitle,
+		Creators:   m.Authors,
+		Language:   m.Language,
+		Stylesheet: defaultEPUBStylesheet,
+	}
+	if args.Template != "" {
+		return fmt.Errorf("templates are not supported for epub books")
+	}
+
+	var docIDs, urls, prefixes []string
+	for i, c := range m.Chapters {
+		docIDs = append(docIDs, c.doc.Doc.DocumentId)
+		urls = append(urls, fmt.Sprintf("chapter%d.xhtml", i+1))
+		prefixes = append(prefixes, "")
+		for _, image := range c.doc.Images {
+			b.Images = append(b.Images, &epub.Image{
+				Filename: path.Join(fmt.Sprintf("ch%d", i+1), filepath.ToSlash(image.Filename)),
+				Content:  image.Content,
+			})
+		}
+	}
+	b.Identifier = bookIdentifier(docIDs)
+
+	var parts int
+	for i, opts := range bookHTMLOptions(m, args, rules, urls, prefixes) {
+		c := m.Chapters[i]
+		opts.FootnoteStyle = dphtml.PopupFootnotes
+		opts.MathStyle = dphtml.MathMLMath
+		opts.XHTML = true
+		s, err := dphtml.FromGoogleDoc(c.doc.Doc, opts)
+		if err != nil {
+			return fmt.Errorf("error converting %s: %w", c.Archive, err)
+		}
+
+		if c.Part != "" {
+			parts++
+			b.Chapters = append(b.Chapters, &epub.Chapter{
+				Filename: fmt.Sprintf("part%d.xhtml", parts),
+				Title:    c.Part,
+				Content:  fmt.Sprintf("<h1 class=\"part\">%s</h1>\n", html.EscapeString(c.Part)),
+			})
+		}
+		b.Chapters = append(b.Chapters, &epub.Chapter{
+			Filename: urls[i],
+			Title:    c.Title,
+			Content:  fmt.Sprintf("<h1 class=\"chapter\">%s</h1>\n%s", html.EscapeString(c.Title), s),
+			Headings: epubHeadings(c.doc.Doc.Body.Content, markdown.HeadingAnchors(c.doc.Doc.Body.Content)),
+		})
+	}
+
+	// the cover is either a file or the first image that remains in the book
+	switch {
+	case m.Cover != "":
+		buf, err := ioutil.ReadFile(m.Cover)
+		if err != nil {
+			return fmt.Errorf("error reading cover image: %w", err)
+		}
+		b.Cover = &epub.Image{Filename: "cover" + strings.ToLower(filepath.Ext(m.Cover)), Content: buf}
+	case len(b.Images) > 0:
+		b.Cover = b.Images[0]
+		b.Images = b.Images[1:]
+	}
+
+	var buf bytes.Buffer
+	err := b.Write(&buf)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(args.Output, buf.Bytes(), 0666)
+	if err != nil {
+		return fmt.Errorf("error writing to %s: %w", args.Output, err)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+)
+
+// chartTables gets the chart data to render as tables, warning if none was fetched
+func chartTables(d *googledoc.Archive) map[string]*googledoc.Chart {
+	if len(d.Charts) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: no chart data in archive, fetch with --charts to include it")
+	}
+	return d.Charts
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/linkcheck"
+)
+
+type checkLinksArgs struct {
+	Input       string        `arg:"positional"`
+	Offline     bool          `help:"only check links to headings within the document"`
+	Concurrency int           `help:"maximum number of requests at once" default:"8"`
+	Delay       time.Duration `help:"minimum time between requests to the same host" default:"1s"`
+	Timeout     time.Duration `help:"time limit for each request" default:"10s"`
+	All         bool          `help:"print every link, not just those with problems"`
+}
+
+func checkLinks(ctx context.Context, args *checkLinksArgs) error {
+	d, err := googledoc.ReadFile(args.Input)
+	if err != nil {
+		return err
+	}
+
+	checker := linkcheck.Checker{
+		Concurrency: args.Concurrency,
+		HostDelay:   args.Delay,
+		Timeout:     args.Timeout,
+		Offline:     args.Offline,
+	}
+	results := checker.Check(ctx, d.Doc)
+
+	var problems int
+	for _, r := range results {
+		if r.Problem() {
+			problems++
+		}
+		if args.All || r.Status != linkcheck.OK {
+			fmt.Println(r)
+		}
+	}
+
+	fmt.Printf("checked %d links\n", len(results))
+	if problems > 0 {
+		return fmt.Errorf("found %d broken links", problems)
+	}
+	return nil
+}
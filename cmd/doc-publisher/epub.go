@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	_ "embed"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexflint/doc-publisher/epub"
+	"github.com/alexflint/doc-publisher/googledoc"
+	dphtml "github.com/alexflint/doc-publisher/html"
+	"github.com/alexflint/doc-publisher/markdown"
+	"google.golang.org/api/docs/v1"
+)
+
+//go:embed epub/style.css
+var defaultEPUBStylesheet string
+
+type exportEPUBArgs struct {
+	Inputs      []string `arg:"positional,required" help:"archives to include, in order"`
+	Output      string   `arg:"-o,--output,required"`
+	Title       string   `help:"title of the book. If empty, the title of the first document is used"`
+	Authors     []string `arg:"--author,separate" help:"author of the book, which may be repeated"`
+	Language    string   `help:"language of the book, as a BCP 47 tag" default:"en"`
+	Identifier  string   `help:"unique identifier for the book. If empty, one is derived from the document IDs"`
+	Cover       string   `help:"image file for the cover. If empty, the first image in the first document is used"`
+	NoCover     bool     `help:"do not include a cover"`
+	Stylesheet  string   `help:"CSS file to use in place of the default stylesheet"`
+	Split       int      `help:"start a new chapter at each heading of this level or higher. If zero, each document is one chapter"`
+	Suggestions string   `help:"how to deal with suggested edits. Possible values: accept, reject"`
+	TopHeading  int      `help:"renumber headings so that the highest level is this one, with no skipped levels"`
+	ChartTables bool     `help:"render linked charts as tables of their data, which must be fetched with --charts"`
+	filterArgs
+	privateContentArgs
+	styleArgs
+	linkArgs
+}
+
+func exportEPUB(ctx context.Context, args *exportEPUBArgs) error {
+	if args.Suggestions == "markup" {
+		return fmt.Errorf("suggestions cannot be marked up in epub exports")
+	}
+	rules, err := loadStyles(&args.styleArgs)
+	if err != nil {
+		return err
+	}
+
+	book := epub.Book{
+		Title:      args.Title,
+		Language:   args.Language,
+		Identifier: args.Identifier,
+		Stylesheet: defaultEPUBStylesheet,
+	}
+	if args.Stylesheet != "" {
+		buf, err := ioutil.ReadFile(args.Stylesheet)
+		if err != nil {
+			return fmt.Errorf("error reading stylesheet: %w", err)
+		}
+		book.Stylesheet = string(buf)
+	}
+
+	var docIDs []string
+	creators := append([]string(nil), args.Authors...)
+	for i, input := range args.Inputs {
+		d, err := googledoc.ReadFile(input)
+		if err != nil {
+			return err
+		}
+		docIDs = append(docIDs, d.Doc.DocumentId)
+
+		var filenames []string
+		for _, image := range d.Images {
+			filenames = append(filenames, image.Filename)
+		}
+		imageFilenamesByObjectID, err := googledoc.MatchObjectIDsToImages(d, filenames)
+		if err != nil {
+			return err
+		}
+
+		// prepare the document as for the html export
+		_, err = applySuggestionsArg(d, args.Suggestions)
+		if err != nil {
+			return err
+		}
+		err = removePrivateContent(d, &args.privateContentArgs)
+		if err != nil {
+			return err
+		}
+
+		// images for each document go in a directory of their own, since
+		// image filenames are only unique within a document
+		dir := fmt.Sprintf("doc%d", i+1)
+		srcsByFilename := make(map[string]string)
+		for _, image := range googledoc.ReferencedImages(d, imageFilenamesByObjectID) {
+			src := path.Join(dir, filepath.ToSlash(image.Filename))
+			srcsByFilename[image.Filename] = src
+			book.Images = append(book.Images, &epub.Image{Filename: src, Content: image.Content})
+		}
+		imageSrcsByObjectID := mapImageSrcs(imageFilenamesByObjectID, srcsByFilename)
+		err = rewriteLinks(d, &args.linkArgs)
+		if err != nil {
+			return err
+		}
+		settings, err := extractMetadata(d, args.TopHeading)
+		if err != nil {
+			return err
+		}
+		docRules, err := applyFilters(ctx, d, settings, imageSrcsByObjectID, rules, &args.filterArgs, "epub")
+		if err != nil {
+			return err
+		}
+
+		// the first document provides the metadata for the book
+		if i == 0 {
+			if book.Title == "" {
+				book.Title = settings.Title
+			}
+			if len(args.Inputs) == 1 {
+				book.Subtitle = settings.Subtitle
+			}
+			book.Subjects = settings.Tags
+		}
+		for _, name := range settings.Coauthors {
+			if !contains(creators, name) {
+				creators = append(creators, name)
+			}
+		}
+
+		opts := dphtml.Options{
+			ImageURLByObjectID: imageSrcsByObjectID,
+			FootnoteStyle:      dphtml.PopupFootnotes,
+			StyleRules:         docRules,
+			MathStyle:          dphtml.MathMLMath,
+			XHTML:              true,
+		}
+		if args.ChartTables {
+			opts.ChartTables = chartTables(d)
+		}
+
+		chapters, err := epubChapters(d, settings, args.Split, len(book.Chapters), &opts)
+		if err != nil {
+			return fmt.Errorf("error converting %s: %w", input, err)
+		}
+		book.Chapters = append(book.Chapters, chapters...)
+	}
+	book.Creators = creators
+
+	if book.Identifier == "" {
+		book.Identifier = bookIdentifier(docIDs)
+	}
+
+	// the cover is either a file or the first image that remains in the book
+	switch {
+	case args.NoCover:
+	case args.Cover != "":
+		buf, err := ioutil.ReadFile(args.Cover)
+		if err != nil {
+			return fmt.Errorf("error reading cover image: %w", err)
+		}
+		book.Cover = &epub.Image{Filename: "cover" + strings.ToLower(filepath.Ext(args.Cover)), Content: buf}
+	case len(book.Images) > 0 && strings.HasPrefix(book.Images[0].Filename, "doc1/"):
+		book.Cover = book.Images[0]
+		book.Images = book.Images[1:]
+	}
+
+	var buf bytes.Buffer
+	err = book.Write(&buf)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(args.Output, buf.Bytes(), 0666)
+	if err != nil {
+		return fmt.Errorf("error writing to %s: %w", args.Output, err)
+	}
+	fmt.Printf("wrote %d chapters to %s\n", len(book.Chapters), args.Output)
+	return nil
+}
+
+// epubChapters converts a document to one chapter, or to one chapter per
+// section if split is nonzero. Chapters are numbered after the given
+// number of earlier chapters.
+func epubChapters(d *googledoc.Archive, settings *googledoc.Settings, split int, earlier int, opts *dphtml.Options) ([]*epub.Chapter, error) {
+	sections := []*googledoc.Section{{Content: d.Doc.Body.Content}}
+	if split > 0 {
+		sections = googledoc.SplitAtHeadings(d.Doc.Body.Content, split)
+	}
+
+	// links to headings may point into other chapters
+	anchors := markdown.HeadingAnchors(d.Doc.Body.Content)
+	filenames := make([]string, len(sections))
+	opts.HeadingURLs = make(map[string]string)
+	for i, section := range sections {
+		filenames[i] = fmt.Sprintf("chapter%d.xhtml", earlier+i+1)
+		for _, id := range googledoc.HeadingIDs(section.Content) {
+			opts.HeadingURLs[id] = filenames[i] + "#" + anchors[id]
+		}
+	}
+
+	var chapters []*epub.Chapter
+	for i, section := range sections {
+		content, err := dphtml.Convert(d.Doc, section.Content, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		chapter := epub.Chapter{
+			Filename: filenames[i],
+			Title:    section.Heading,
+			Headings: epubHeadings(section.Content, anchors),
+		}
+
+		// the heading that begins a section is the chapter itself in the
+		// table of contents
+		if section.Heading != "" && len(chapter.Headings) > 0 {
+			chapter.Headings = chapter.Headings[1:]
+		}
+
+		// the title of the document goes at the start of its first chapter
+		if i == 0 {
+			var title strings.Builder
+			if settings.Title != "" {
+				fmt.Fprintf(&title, "<h1 class=\"title\">%s</h1>\n", html.EscapeString(settings.Title))
+			}
+			if settings.Subtitle != "" {
+				fmt.Fprintf(&title, "<p class=\"subtitle\">%s</p>\n", html.EscapeString(settings.Subtitle))
+			}
+			content = title.String() + content
+			if chapter.Title == "" {
+				chapter.Title = settings.Title
+			}
+		}
+
+		chapter.Content = content
+		chapters = append(chapters, &chapter)
+	}
+	return chapters, nil
+}
+
+// epubHeadings gets the headings in a sequence of elements for the table
+// of contents
+func epubHeadings(content []*docs.StructuralElement, anchors map[string]string) []*epub.Heading {
+	var headings []*epub.Heading
+	for _, elem := range content {
+		p := elem.Paragraph
+		if p == nil || p.ParagraphStyle == nil || p.ParagraphStyle.HeadingId == "" {
+			continue
+		}
+		level := googledoc.HeadingLevel(p.ParagraphStyle.NamedStyleType)
+		if level == 0 {
+			continue
+		}
+
+		var text strings.Builder
+		for _, el := range p.Elements {
+			if el.TextRun != nil {
+				text.WriteString(el.TextRun.Content)
+			}
+		}
+		title := strings.Join(strings.Fields(text.String()), " ")
+		if title == "" {
+			continue
+		}
+		headings = append(headings, &epub.Heading{
+			Level: level,
+			Title: title,
+			ID:    anchors[p.ParagraphStyle.HeadingId],
+		})
+	}
+	return headings
+}
+
+// bookIdentifier derives a urn:uuid identifier from the IDs of the
+// documents in a book, so that re-exporting the same documents gives the
+// same identifier
+func bookIdentifier(docIDs []string) string {
+	sum := sha1.Sum([]byte(strings.Join(docIDs, "\n")))
+	sum[6] = (sum[6] & 0x0f) | 0x50 // version 5
+	sum[8] = (sum[8] & 0x3f) | 0x80 // variant
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
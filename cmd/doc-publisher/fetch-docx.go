@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexflint/doc-publisher/docx"
+	"github.com/alexflint/doc-publisher/googledoc"
+)
+
+type fetchDocxArgs struct {
+	Input  string `arg:"positional,required"`
+	Output string `arg:"-o,--output" help:"defaults to the input path with a .googledoc extension"`
+}
+
+func fetchDocx(ctx context.Context, args *fetchDocxArgs) error {
+	// parse the word document
+	d, err := docx.ReadFile(args.Input)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("loaded a docx with %d images\n", len(d.Images))
+
+	output := args.Output
+	if output == "" {
+		output = strings.TrimSuffix(args.Input, filepath.Ext(args.Input)) + ".googledoc"
+	}
+
+	// write to file
+	err = googledoc.WriteFile(d, output)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote googledoc to %s\n", output)
+	return nil
+}
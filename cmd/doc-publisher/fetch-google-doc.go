@@ -16,18 +16,32 @@ import (
 	"google.golang.org/api/docs/v1"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
 )
 
 type fetchGoogleDocArgs struct {
-	Document string `arg:"positional"`
-	Output   string `arg:"-o,--output"`
+	Document    string `arg:"positional"`
+	Output      string `arg:"-o,--output"`
+	Comments    bool   `help:"also fetch comments and replies"`
+	Suggestions string `help:"how exports should deal with suggested edits by default. Possible values: accept, reject, markup"`
+	Charts      bool   `help:"also fetch the data behind charts linked from google sheets"`
 }
 
 func fetchGoogleDoc(ctx context.Context, args *fetchGoogleDocArgs) error {
-	const tokFile = ".cache/google-pull-token.json"
-	googleToken, err := GoogleAuth(ctx, tokFile,
+	tokFile := ".cache/google-pull-token.json"
+	scopes := []string{
 		"https://www.googleapis.com/auth/documents.readonly",
-		"https://www.googleapis.com/auth/drive.readonly")
+		"https://www.googleapis.com/auth/drive.readonly",
+	}
+
+	// only ask for access to spreadsheets when fetching charts, and keep that
+	// token separate so that cached tokens always have the scopes they need
+	if args.Charts {
+		tokFile = ".cache/google-pull-charts-token.json"
+		scopes = append(scopes, "https://www.googleapis.com/auth/spreadsheets.readonly")
+	}
+
+	googleToken, err := GoogleAuth(ctx, tokFile, scopes...)
 	if err != nil {
 		return fmt.Errorf("error authenticating with google: %w", err)
 	}
@@ -45,11 +59,34 @@ func fetchGoogleDoc(ctx context.Context, args *fetchGoogleDocArgs) error {
 	}
 
 	// fetch the document
-	d, err := googledoc.Fetch(ctx, args.Document, docsClient, driveClient)
+	d, err := googledoc.FetchWithSuggestions(ctx, args.Document, args.Suggestions, docsClient, driveClient)
 	if err != nil {
 		return err
 	}
 
+	// fetch the comments
+	if args.Comments {
+		d.Comments, err = googledoc.FetchComments(ctx, args.Document, d.Doc, driveClient)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("fetched %d comments\n", len(d.Comments))
+	}
+
+	// fetch the data behind linked charts
+	if args.Charts {
+		sheetsClient, err := sheets.NewService(ctx, option.WithTokenSource(googleToken))
+		if err != nil {
+			return fmt.Errorf("error creating sheets client: %w", err)
+		}
+
+		d.Charts, err = googledoc.FetchCharts(ctx, d.Doc, sheetsClient)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("fetched data for %d charts\n", len(d.Charts))
+	}
+
 	// write to file
 	err = googledoc.WriteFile(d, args.Output)
 	if err != nil {
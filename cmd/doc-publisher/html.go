@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/html"
+)
+
+type exportHTMLArgs struct {
+	Input        string `arg:"positional"`
+	Output       string `arg:"-o,--output"`
+	Template     string `help:"html/template file to wrap the content in, such as html/template.html. If empty, only the content is written"`
+	InlineImages bool   `help:"embed images as data URIs to make a single self-contained file, which is always done when writing to stdout"`
+	Suggestions  string `help:"how to deal with suggested edits. Possible values: accept, reject, markup"`
+	TopHeading   int    `help:"renumber headings so that the highest level is this one, with no skipped levels"`
+	Footnotes    string `help:"how to render footnotes. Possible values: endnotes, aside" default:"endnotes"`
+	ChartTables  bool   `help:"render linked charts as tables of their data, which must be fetched with --charts"`
+	Math         string `help:"how to render latex math. Possible values: katex, mathml" default:"katex"`
+	filterArgs
+	privateContentArgs
+	styleArgs
+	linkArgs
+}
+
+// htmlInputs are the inputs to the html template
+type htmlInputs struct {
+	Title    string
+	Subtitle string
+	Settings *googledoc.Settings
+	Content  template.HTML
+}
+
+func exportHTML(ctx context.Context, args *exportHTMLArgs) error {
+	if args.Suggestions == "markup" && len(args.Filters) > 0 {
+		return fmt.Errorf("suggestions cannot be marked up when running filters")
+	}
+	rules, err := loadStyles(&args.styleArgs)
+	if err != nil {
+		return err
+	}
+
+	// load the input document
+	d, err := googledoc.ReadFile(args.Input)
+	if err != nil {
+		return err
+	}
+
+	var filenames []string
+	for _, image := range d.Images {
+		filenames = append(filenames, image.Filename)
+	}
+	imageFilenamesByObjectID, err := googledoc.MatchObjectIDsToImages(d, filenames)
+	if err != nil {
+		return err
+	}
+
+	// accept or reject suggested edits, which must happen after matching
+	// images because suggested images are present in the HTML export
+	markup, err := applySuggestionsArg(d, args.Suggestions)
+	if err != nil {
+		return err
+	}
+
+	// remove content that is for editors only
+	err = removePrivateContent(d, &args.privateContentArgs)
+	if err != nil {
+		return err
+	}
+
+	// embed the images that remain, or write them alongside the output. There
+	// is nowhere to write images for output on stdout so they are embedded.
+	srcsByFilename := make(map[string]string)
+	for _, image := range googledoc.ReferencedImages(d, imageFilenamesByObjectID) {
+		if args.InlineImages || args.Output == "" {
+			srcsByFilename[image.Filename] = dataURI(image)
+			continue
+		}
+		err = writeImage(filepath.Join(filepath.Dir(args.Output), image.Filename), image.Content)
+		if err != nil {
+			return err
+		}
+		srcsByFilename[image.Filename] = filepath.ToSlash(image.Filename)
+	}
+	imageSrcsByObjectID := mapImageSrcs(imageFilenamesByObjectID, srcsByFilename)
+
+	// clean up links and point links between docs at published posts
+	err = rewriteLinks(d, &args.linkArgs)
+	if err != nil {
+		return err
+	}
+
+	// parse and remove the settings table, title, and subtitle
+	settings, err := extractMetadata(d, args.TopHeading)
+	if err != nil {
+		return err
+	}
+
+	// run the document through any pandoc filters
+	rules, err = applyFilters(ctx, d, settings, imageSrcsByObjectID, rules, &args.filterArgs, "html")
+	if err != nil {
+		return err
+	}
+
+	// convert the document to html
+	opts := html.Options{
+		ImageURLByObjectID: imageSrcsByObjectID,
+		MarkupSuggestions:  markup,
+		StyleRules:         rules,
+	}
+	if args.Footnotes != "endnotes" {
+		opts.FootnoteStyle = args.Footnotes
+	}
+	if args.Math != "katex" {
+		opts.MathStyle = args.Math
+	}
+	if args.ChartTables {
+		opts.ChartTables = chartTables(d)
+	}
+
+	content, err := html.FromGoogleDoc(d.Doc, &opts)
+	if err != nil {
+		return err
+	}
+
+	// wrap the content in a page
+	out := []byte(content)
+	if args.Template != "" {
+		tpl, err := template.ParseFiles(args.Template)
+		if err != nil {
+			return fmt.Errorf("error parsing html template: %w", err)
+		}
+
+		var buf bytes.Buffer
+		err = tpl.Execute(&buf, htmlInputs{
+			Title:    settings.Title,
+			Subtitle: settings.Subtitle,
+			Settings: settings,
+			Content:  template.HTML(content),
+		})
+		if err != nil {
+			return fmt.Errorf("error executing html template: %w", err)
+		}
+		out = buf.Bytes()
+	}
+
+	// write to output file or stdout
+	if args.Output == "" {
+		fmt.Println(string(out))
+		return nil
+	}
+	err = ioutil.WriteFile(args.Output, out, 0666)
+	if err != nil {
+		return fmt.Errorf("error writing to %s: %w", args.Output, err)
+	}
+	fmt.Printf("wrote html to %s\n", args.Output)
+	return nil
+}
+
+// dataURI encodes an image as a data URI
+func dataURI(image *googledoc.Image) string {
+	mimeType := mime.TypeByExtension(filepath.Ext(image.Filename))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(image.Content)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(image.Content)
+}
@@ -76,3 +76,16 @@ func pushImage(ctx context.Context, args *pushImageArgs) error {
 
 	return nil
 }
+
+// mapImageSrcs maps object IDs to the src of their images, given the
+// filename for each object and the src for each filename. Objects whose
+// images have no src are left out.
+func mapImageSrcs(filenamesByObjectID, srcsByFilename map[string]string) map[string]string {
+	srcs := make(map[string]string)
+	for id, filename := range filenamesByObjectID {
+		if src, ok := srcsByFilename[filename]; ok {
+			srcs[id] = src
+		}
+	}
+	return srcs
+}
@@ -5,30 +5,140 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"text/template"
 
 	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/latex"
 )
 
 type exportLatexArgs struct {
 	Input        string `arg:"positional"`
 	Output       string `arg:"-o,--output"`
 	Bibliography string
-	Template     string
+	Template     string `default:"tex/template.tex"`
+	Comments     bool   `help:"render comments as margin notes, for review copies"`
+	Suggestions  string `help:"how to deal with suggested edits. Possible values: accept, reject, markup"`
+	TopHeading   int    `help:"renumber headings so that the highest level is this one, with no skipped levels"`
+	Spoilers     string `help:"where to move spoilers. Possible values: footnote, appendix" default:"footnote"`
+	ChartTables  bool   `help:"render linked charts as tables of their data, which must be fetched with --charts"`
+	filterArgs
+	privateContentArgs
+	styleArgs
+	linkArgs
+}
+
+// latexInputs are the inputs to the latex template
+type latexInputs struct {
+	Title        string
+	Subtitle     string
+	Author       string
+	Content      string
+	Preamble     string
+	Bibliography string
+	Book         bool // whether the content is divided into chapters
 }
 
 func exportLatex(ctx context.Context, args *exportLatexArgs) error {
+	if args.Suggestions == "markup" && len(args.Filters) > 0 {
+		return fmt.Errorf("suggestions cannot be marked up when running filters")
+	}
+	rules, err := loadStyles(&args.styleArgs)
+	if err != nil {
+		return err
+	}
+
 	// load the input document
-	_, err := googledoc.ReadFile(args.Input)
+	d, err := googledoc.ReadFile(args.Input)
 	if err != nil {
 		return err
 	}
 
-	// convert the document to latex (TODO!!)
-	var tex bytes.Buffer
+	var filenames []string
+	for _, image := range d.Images {
+		filenames = append(filenames, image.Filename)
+	}
+	imageFilenamesByObjectID, err := googledoc.MatchObjectIDsToImages(d, filenames)
+	if err != nil {
+		return err
+	}
+
+	// accept or reject suggested edits, which must happen after matching
+	// images because suggested images are present in the HTML export
+	markup, err := applySuggestionsArg(d, args.Suggestions)
+	if err != nil {
+		return err
+	}
+
+	// remove content that is for editors only
+	err = removePrivateContent(d, &args.privateContentArgs)
+	if err != nil {
+		return err
+	}
+
+	// write the images that remain alongside the output so that latex can find them
+	outDir := "."
+	if args.Output != "" {
+		outDir = filepath.Dir(args.Output)
+	}
+	for _, image := range googledoc.ReferencedImages(d, imageFilenamesByObjectID) {
+		err = writeImage(filepath.Join(outDir, image.Filename), image.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	// clean up links and point links between docs at published posts
+	err = rewriteLinks(d, &args.linkArgs)
+	if err != nil {
+		return err
+	}
+
+	// parse and remove the settings table, title, and subtitle
+	settings, err := extractMetadata(d, args.TopHeading)
+	if err != nil {
+		return err
+	}
+
+	// run the document through any pandoc filters
+	rules, err = applyFilters(ctx, d, settings, imageFilenamesByObjectID, rules, &args.filterArgs, "latex")
+	if err != nil {
+		return err
+	}
+
+	// convert the document to latex
+	opts := latex.Options{
+		ImageFilenameByObjectID: imageFilenamesByObjectID,
+		MarkupSuggestions:       markup,
+		StyleRules:              rules,
+	}
+	if args.Spoilers != "footnote" {
+		opts.SpoilerStyle = args.Spoilers
+	}
+	if args.ChartTables {
+		opts.ChartTables = chartTables(d)
+	}
+	if args.Comments {
+		if len(d.Comments) == 0 {
+			fmt.Fprintln(os.Stderr, "warning: no comments in archive, fetch with --comments to include them")
+		}
+		opts.Comments = d.Comments
+	}
+
+	tex, err := latex.FromGoogleDoc(d.Doc, &opts)
+	if err != nil {
+		return err
+	}
+
+	// convert headers and footers to a page style
+	preamble, err := latex.PageStyle(d.Doc)
+	if err != nil {
+		return err
+	}
 
 	// load the tex template
-	tpl, err := template.ParseFiles("tex/template.tex")
+	tpl, err := template.ParseFiles(args.Template)
 	if err != nil {
 		return fmt.Errorf("error parsing latex template: %w", err)
 	}
@@ -40,20 +150,13 @@ func exportLatex(ctx context.Context, args *exportLatexArgs) error {
 	}
 
 	// execute the latex template
-	type inputs struct {
-		Title        string
-		Subtitle     string
-		Author       string
-		Content      string
-		Bibliography string
-	}
-
 	var out bytes.Buffer
-	err = tpl.Execute(&out, inputs{
-		Title:        "the title",
-		Subtitle:     "the subtitle",
+	err = tpl.Execute(&out, latexInputs{
+		Title:        latex.Escape(settings.Title),
+		Subtitle:     latex.Escape(settings.Subtitle),
 		Author:       "Kōshin",
-		Content:      tex.String(),
+		Content:      tex,
+		Preamble:     preamble,
 		Bibliography: args.Bibliography,
 	})
 	if err != nil {
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+)
+
+// linkArgs are the arguments for rewriting links in exports
+type linkArgs struct {
+	Published string `help:"YAML or JSON file mapping google doc IDs or URLs to their published URLs"`
+}
+
+// rewriteLinks cleans up the links in a document, points links to other
+// docs at their published URLs, and reports links to unpublished docs
+func rewriteLinks(d *googledoc.Archive, args *linkArgs) error {
+	return rewriteBookLinks(d, args, nil)
+}
+
+// rewriteBookLinks is like rewriteLinks but leaves links to the other
+// documents in a book alone, given by ID
+func rewriteBookLinks(d *googledoc.Archive, args *linkArgs, book map[string]bool) error {
+	var published map[string]string
+	if args.Published != "" {
+		var err error
+		published, err = googledoc.LoadPublishedURLs(args.Published)
+		if err != nil {
+			return err
+		}
+	}
+
+	unpublished := googledoc.RewriteBookLinks(d.Doc, published, book)
+	if len(unpublished) > 0 {
+		fmt.Fprintf(os.Stderr, "found %d links to docs that have not been published:\n", len(unpublished))
+		for _, l := range unpublished {
+			fmt.Fprintf(os.Stderr, "  %v\n", l)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/lint"
+)
+
+type lintArgs struct {
+	Input   string   `arg:"positional"`
+	Config  string   `help:"YAML or JSON file listing the checks to run or disable"`
+	Only    []string `help:"run only these checks"`
+	Disable []string `help:"do not run these checks"`
+	Format  string   `help:"output format. Possible values: text, json" default:"text"`
+	Strict  bool     `help:"fail on warnings as well as errors"`
+	List    bool     `help:"list the available checks and exit"`
+	privateContentArgs
+}
+
+func runLint(ctx context.Context, args *lintArgs) error {
+	if args.List {
+		for _, c := range lint.Checks {
+			fmt.Printf("%-18s %-8s %s\n", c.ID, c.Severity, c.Description)
+		}
+		return nil
+	}
+	if args.Format != "text" && args.Format != "json" {
+		return fmt.Errorf("invalid value for format: %q (should be text or json)", args.Format)
+	}
+
+	cfg := &lint.Config{}
+	if args.Config != "" {
+		var err error
+		cfg, err = lint.LoadConfig(args.Config)
+		if err != nil {
+			return err
+		}
+	}
+	cfg.Only = append(cfg.Only, args.Only...)
+	cfg.Disable = append(cfg.Disable, args.Disable...)
+
+	d, err := googledoc.ReadFile(args.Input)
+	if err != nil {
+		return err
+	}
+
+	// lint only the content that will be published
+	err = removePrivateContent(d, &args.privateContentArgs)
+	if err != nil {
+		return err
+	}
+
+	issues, err := lint.Run(d, cfg)
+	if err != nil {
+		return err
+	}
+
+	if args.Format == "json" {
+		if issues == nil {
+			issues = []*lint.Issue{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(issues)
+		if err != nil {
+			return fmt.Errorf("error encoding issues: %w", err)
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+	}
+
+	var failures int
+	for _, issue := range issues {
+		if issue.Severity == lint.Error || args.Strict {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("found %d problems that must be fixed before publishing", failures)
+	}
+	return nil
+}
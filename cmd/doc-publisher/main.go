@@ -10,11 +10,16 @@ import (
 
 type fetchArgs struct {
 	GoogleDoc *fetchGoogleDocArgs `arg:"subcommand"`
+	Docx      *fetchDocxArgs      `arg:"subcommand"`
 }
 
 type exportArgs struct {
-	Markdown *exportMarkdownArgs `arg:"subcommand"`
-	Latex    *exportLatexArgs    `arg:"subcommand"`
+	Markdown   *exportMarkdownArgs `arg:"subcommand"`
+	Latex      *exportLatexArgs    `arg:"subcommand"`
+	HTML       *exportHTMLArgs     `arg:"subcommand"`
+	EPUB       *exportEPUBArgs     `arg:"subcommand"`
+	Site       *exportSiteArgs     `arg:"subcommand"`
+	PandocJSON *exportPandocArgs   `arg:"subcommand:pandoc-json"`
 }
 
 type pushArgs struct {
@@ -23,10 +28,17 @@ type pushArgs struct {
 	Image     *pushImageArgs       `arg:"subcommand"`
 }
 
+type checkArgs struct {
+	Links *checkLinksArgs `arg:"subcommand"`
+}
+
 type args struct {
 	Fetch  *fetchArgs  `arg:"subcommand"`
 	Export *exportArgs `arg:"subcommand"`
 	Push   *pushArgs   `arg:"subcommand"`
+	Check  *checkArgs  `arg:"subcommand"`
+	Lint   *lintArgs   `arg:"subcommand"`
+	Book   *bookArgs   `arg:"subcommand"`
 }
 
 func main() {
@@ -42,6 +54,8 @@ func main() {
 		switch {
 		case args.Fetch.GoogleDoc != nil:
 			err = fetchGoogleDoc(ctx, args.Fetch.GoogleDoc)
+		case args.Fetch.Docx != nil:
+			err = fetchDocx(ctx, args.Fetch.Docx)
 		default:
 			p.Fail("pull requires a subcommand")
 		}
@@ -52,6 +66,14 @@ func main() {
 			err = exportMarkdown(ctx, args.Export.Markdown)
 		case args.Export.Latex != nil:
 			err = exportLatex(ctx, args.Export.Latex)
+		case args.Export.HTML != nil:
+			err = exportHTML(ctx, args.Export.HTML)
+		case args.Export.EPUB != nil:
+			err = exportEPUB(ctx, args.Export.EPUB)
+		case args.Export.Site != nil:
+			err = exportSite(ctx, args.Export.Site)
+		case args.Export.PandocJSON != nil:
+			err = exportPandoc(ctx, args.Export.PandocJSON)
 		default:
 			p.Fail("export requires a subcommand")
 		}
@@ -68,12 +90,26 @@ func main() {
 			p.Fail("push requires a subcommand")
 		}
 
+	case args.Check != nil:
+		switch {
+		case args.Check.Links != nil:
+			err = checkLinks(ctx, args.Check.Links)
+		default:
+			p.Fail("check requires a subcommand")
+		}
+
+	case args.Lint != nil:
+		err = runLint(ctx, args.Lint)
+
+	case args.Book != nil:
+		err = buildBook(ctx, args.Book)
+
 	default:
 		p.Fail("you must specify a subcommand")
 	}
 
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -18,12 +19,34 @@ import (
 )
 
 type exportMarkdownArgs struct {
-	Input      string `arg:"positional"`
-	SeparateBy string `help:"separate into multiple markdown files. Possible values: pagebreak"`
-	Output     string `arg:"-o,--output"`
+	Input           string `arg:"positional"`
+	SeparateBy      string `help:"separate into multiple markdown files. Possible values: pagebreak, heading:N"`
+	Output          string `arg:"-o,--output"`
+	Comments        string `help:"how to render comments. Possible values: criticmarkup, footnotes, strip" default:"strip"`
+	Suggestions     string `help:"how to deal with suggested edits. Possible values: accept, reject, markup"`
+	SuggestionStyle string `help:"how to mark up suggested edits. Possible values: criticmarkup, html" default:"criticmarkup"`
+	FrontMatter     bool   `help:"write settings from the settings table as YAML front matter"`
+	TopHeading      int    `help:"renumber headings so that the highest level is this one, with no skipped levels"`
+	PageBreak       string `help:"separator to write in place of page breaks, such as --- or '* * *'"`
+	Checklists      string `help:"how to render checklists. Possible values: tasklist, html" default:"tasklist"`
+	Footnotes       string `help:"how to render footnotes. Possible values: markdown, sidenotes, endnotes" default:"markdown"`
+	Spoilers        string `help:"how to render spoilers. Possible values: lesswrong, html" default:"lesswrong"`
+	ChartTables     bool   `help:"render linked charts as tables of their data, which must be fetched with --charts"`
+	filterArgs
+	privateContentArgs
+	styleArgs
+	linkArgs
 }
 
 func exportMarkdown(ctx context.Context, args *exportMarkdownArgs) error {
+	if args.Suggestions == "markup" && len(args.Filters) > 0 {
+		return fmt.Errorf("suggestions cannot be marked up when running filters")
+	}
+	rules, err := loadStyles(&args.styleArgs)
+	if err != nil {
+		return err
+	}
+
 	// load the document from a file
 	d, err := googledoc.ReadFile(args.Input)
 	if err != nil {
@@ -31,6 +54,29 @@ func exportMarkdown(ctx context.Context, args *exportMarkdownArgs) error {
 	}
 	fmt.Printf("loaded a googledoc with %d images\n", len(d.Images))
 
+	// align the images to the objects in the google doc
+	var filenames []string
+	for _, image := range d.Images {
+		filenames = append(filenames, image.Filename)
+	}
+	imageFilenamesByObjectID, err := googledoc.MatchObjectIDsToImages(d, filenames)
+	if err != nil {
+		return err
+	}
+
+	// accept or reject suggested edits, which must happen after matching
+	// images because suggested images are present in the HTML export
+	markup, err := applySuggestionsArg(d, args.Suggestions)
+	if err != nil {
+		return err
+	}
+
+	// remove content that is for editors only
+	err = removePrivateContent(d, &args.privateContentArgs)
+	if err != nil {
+		return err
+	}
+
 	// create a cloud storage client
 	storageClient, err := storage.NewClient(ctx,
 		option.WithCredentialsJSON(storageServiceAccount))
@@ -40,9 +86,9 @@ func exportMarkdown(ctx context.Context, args *exportMarkdownArgs) error {
 
 	bucket := storageClient.Bucket(imageBucket)
 
-	// upload the images to cloud storage
-	var urls []string
-	for _, image := range d.Images {
+	// upload the images that are still in the document to cloud storage
+	urlsByFilename := make(map[string]string)
+	for _, image := range googledoc.ReferencedImages(d, imageFilenamesByObjectID) {
 		extension := filepath.Ext(image.Filename)
 		if extension == "" {
 			extension = ".jpg"
@@ -53,23 +99,75 @@ func exportMarkdown(ctx context.Context, args *exportMarkdownArgs) error {
 			return fmt.Errorf("%s: %w", image.Filename, err)
 		}
 
-		urls = append(urls, url)
+		urlsByFilename[image.Filename] = url
+	}
+
+	imageURLsByObjectID := mapImageSrcs(imageFilenamesByObjectID, urlsByFilename)
+
+	// clean up links and point links between docs at published posts
+	err = rewriteLinks(d, &args.linkArgs)
+	if err != nil {
+		return err
 	}
 
-	// align the image URLs to the objects in the google doc
-	imageURLsByObjectID, err := googledoc.MatchObjectIDsToImages(d, urls)
+	// parse and remove the settings table, title, and subtitle
+	settings, err := extractMetadata(d, args.TopHeading)
 	if err != nil {
 		return err
 	}
 
+	// run the document through any pandoc filters
+	rules, err = applyFilters(ctx, d, settings, imageURLsByObjectID, rules, &args.filterArgs, "markdown")
+	if err != nil {
+		return err
+	}
+
+	var frontMatter string
+	if args.FrontMatter {
+		frontMatter, err = markdown.FrontMatter(settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := markdown.Options{
+		ImageURLByObjectID: imageURLsByObjectID,
+		Comments:           d.Comments,
+		CommentStyle:       args.Comments,
+		PageBreak:          args.PageBreak,
+		StyleRules:         rules,
+	}
+	if markup {
+		opts.SuggestionStyle = args.SuggestionStyle
+	}
+	if args.Comments == "strip" {
+		opts.CommentStyle = markdown.StripComments
+	}
+	if args.ChartTables {
+		opts.ChartTables = chartTables(d)
+	}
+	if args.Footnotes != "markdown" {
+		opts.FootnoteStyle = args.Footnotes
+	}
+	if args.Spoilers != "lesswrong" {
+		opts.SpoilerStyle = args.Spoilers
+	}
+	if args.Checklists != "tasklist" {
+		opts.ChecklistStyle = args.Checklists
+	}
+	if opts.CommentStyle != markdown.StripComments && len(d.Comments) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: no comments in archive, fetch with --comments to include them")
+	}
+
 	// convert and export
-	switch args.SeparateBy {
-	case "":
+	switch {
+	case args.SeparateBy == "":
 		// export the entire document as a single markdown file
-		md, err := markdown.FromGoogleDoc(d.Doc, imageURLsByObjectID)
+		md, err := markdown.Convert(d.Doc, d.Doc.Body.Content, &opts)
 		if err != nil {
 			return err
 		}
+		md = frontMatter + md
 
 		// write the result to output
 		if args.Output == "" {
@@ -82,53 +180,144 @@ func exportMarkdown(ctx context.Context, args *exportMarkdownArgs) error {
 			fmt.Printf("wrote markdown to %s\n", args.Output)
 		}
 
-	case "pagebreak":
+	case args.SeparateBy == "pagebreak":
 		// split the doc at page breaks into multiple markdown files
 		if !strings.Contains(args.Output, "INDEX") {
-			return errors.New("when using --separateby, output must be to a filename containing the string 'INDEX'")
+			return errors.New("when using --separateby pagebreak, output must be to a filename containing the string 'INDEX'")
 		}
 
-		var n int
-		var cur []*docs.StructuralElement
-		for i, elem := range d.Doc.Body.Content {
+		sections := googledoc.SplitAtPageBreaks(d.Doc.Body.Content)
 
-			// determine whether this element contains a page break
-			var found bool
-			if elem.Paragraph != nil {
-				for _, e := range elem.Paragraph.Elements {
-					if e.PageBreak != nil {
-						found = true
-						break
-					}
-				}
-			}
+		// the page breaks are now file boundaries
+		opts.PageBreak = ""
+		var filenames []string
+		for i := range sections {
+			filenames = append(filenames, strings.ReplaceAll(args.Output, "INDEX", strconv.Itoa(i+1)))
+		}
 
-			// if we have a page break then write out the next document
-			if found || i == len(d.Doc.Body.Content)-1 {
-				// convert segment of the google doc to markdown
-				md, err := markdown.FromGoogleDocSegment(d.Doc, cur, imageURLsByObjectID)
-				if err != nil {
-					return err
-				}
+		err = writeSections(d.Doc, sections, filenames, frontMatter, opts)
+		if err != nil {
+			return err
+		}
+
+	case strings.HasPrefix(args.SeparateBy, "heading:"):
+		// split the doc at headings into one markdown file per section in a directory
+		level, err := strconv.Atoi(strings.TrimPrefix(args.SeparateBy, "heading:"))
+		if err != nil || level < 1 || level > 6 {
+			return fmt.Errorf("invalid heading level in --separateby: %q", args.SeparateBy)
+		}
+		if args.Output == "" {
+			return errors.New("when using --separateby heading:N, output must be a directory")
+		}
+		err = os.MkdirAll(args.Output, 0777)
+		if err != nil {
+			return fmt.Errorf("error creating output directory: %w", err)
+		}
 
-				// write markdown to a file
-				filename := strings.ReplaceAll(args.Output, "INDEX", strconv.Itoa(n+1))
-				err = ioutil.WriteFile(filename, []byte(md), 0666)
-				if err != nil {
-					return fmt.Errorf("error writing to %s: %w", filename, err)
+		// name files after the section headings, keeping index.md for the
+		// index and numbering repeated names so that they do not collide with
+		// the names of other sections
+		sections := googledoc.SplitAtHeadings(d.Doc.Body.Content, level)
+		var slugs []string
+		taken := map[string]bool{"index": true}
+		for _, section := range sections {
+			slug := markdown.Slug(section.Heading)
+			if slug == "" {
+				slug = "introduction"
+			}
+			slugs = append(slugs, slug)
+			taken[slug] = true
+		}
+		var filenames []string
+		claimed := make(map[string]bool)
+		for _, slug := range slugs {
+			if slug == "index" || claimed[slug] {
+				n := 2
+				for taken[fmt.Sprintf("%s-%d", slug, n)] {
+					n++
 				}
-				fmt.Printf("wrote markdown to %s\n", filename)
+				slug = fmt.Sprintf("%s-%d", slug, n)
+				taken[slug] = true
+			}
+			claimed[slug] = true
+			filenames = append(filenames, filepath.Join(args.Output, slug+".md"))
+		}
 
-				// reset the elements and increment the counter
-				cur = make([]*docs.StructuralElement, 0, 1000)
-				n += 1
-			} else {
-				cur = append(cur, elem)
+		// the front matter goes in the index rather than the section files
+		err = writeSections(d.Doc, sections, filenames, "", opts)
+		if err != nil {
+			return err
+		}
+
+		// write an index listing the sections in order
+		var index strings.Builder
+		index.WriteString(frontMatter)
+		fmt.Fprintf(&index, "# %s\n\n", markdown.Escape(settings.Title))
+		for i, section := range sections {
+			heading := section.Heading
+			if heading == "" {
+				heading = "Introduction"
 			}
+			fmt.Fprintf(&index, "%d. [%s](%s)\n", i+1, markdown.Escape(heading), filepath.Base(filenames[i]))
 		}
+
+		indexPath := filepath.Join(args.Output, "index.md")
+		err = ioutil.WriteFile(indexPath, []byte(index.String()), 0666)
+		if err != nil {
+			return fmt.Errorf("error writing to %s: %w", indexPath, err)
+		}
+		fmt.Printf("wrote index to %s\n", indexPath)
+
 	default:
 		return fmt.Errorf("invalid value for --separateby: %q", args.SeparateBy)
 	}
 
 	return nil
 }
+
+// writeSections converts each section of a document to a separate markdown
+// file, rewriting links to headings so that they point to the right file.
+// The front matter is written at the top of the first file.
+func writeSections(doc *docs.Document, sections []*googledoc.Section, filenames []string, frontMatter string, opts markdown.Options) error {
+	// find the file that contains each heading
+	anchors := markdown.HeadingAnchors(doc.Body.Content)
+	fileByHeadingID := make(map[string]string)
+	for i, section := range sections {
+		for _, id := range googledoc.HeadingIDs(section.Content) {
+			fileByHeadingID[id] = filenames[i]
+		}
+	}
+
+	for i, section := range sections {
+		// links to headings in the same file are anchors, and other links are relative paths
+		opts.HeadingURLs = make(map[string]string)
+		for id, anchor := range anchors {
+			target, ok := fileByHeadingID[id]
+			if !ok || target == filenames[i] {
+				opts.HeadingURLs[id] = "#" + anchor
+				continue
+			}
+			rel, err := filepath.Rel(filepath.Dir(filenames[i]), target)
+			if err != nil {
+				rel = target
+			}
+			opts.HeadingURLs[id] = filepath.ToSlash(rel) + "#" + anchor
+		}
+
+		// convert the section, which includes only the footnotes that it references
+		md, err := markdown.Convert(doc, section.Content, &opts)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			md = frontMatter + md
+		}
+
+		err = ioutil.WriteFile(filenames[i], []byte(md), 0666)
+		if err != nil {
+			return fmt.Errorf("error writing to %s: %w", filenames[i], err)
+		}
+		fmt.Printf("wrote markdown to %s\n", filenames[i])
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+)
+
+// extractMetadata removes the settings table, title, and subtitle from a
+// document and returns them as settings. Values in the settings table take
+// precedence over the title and subtitle paragraphs, which take precedence
+// over the title of the google doc. If topHeading is non-zero then
+// headings are also normalized to start at that level.
+func extractMetadata(d *googledoc.Archive, topHeading int) (*googledoc.Settings, error) {
+	settings, err := googledoc.ExtractSettings(d.Doc)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &googledoc.Settings{}
+	}
+
+	title, subtitle := googledoc.ExtractTitle(d.Doc)
+	if settings.Title == "" {
+		settings.Title = title
+	}
+	if settings.Title == "" {
+		settings.Title = d.Doc.Title
+	}
+	if settings.Subtitle == "" {
+		settings.Subtitle = subtitle
+	}
+
+	if topHeading != 0 {
+		if topHeading < 1 || topHeading > 6 {
+			return nil, fmt.Errorf("top heading level must be between 1 and 6 but got %d", topHeading)
+		}
+		googledoc.NormalizeHeadings(d.Doc, topHeading)
+	}
+	return settings, nil
+}
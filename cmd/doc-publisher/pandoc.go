@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/pandoc"
+)
+
+type exportPandocArgs struct {
+	Input       string `arg:"positional"`
+	Output      string `arg:"-o,--output"`
+	Suggestions string `help:"how to deal with suggested edits. Possible values: accept, reject"`
+	TopHeading  int    `help:"renumber headings so that the highest level is this one, with no skipped levels"`
+	ChartTables bool   `help:"render linked charts as tables of their data, which must be fetched with --charts"`
+	filterArgs
+	privateContentArgs
+	styleArgs
+	linkArgs
+}
+
+// filterArgs are the arguments for running pandoc filters on a document
+// before it is exported
+type filterArgs struct {
+	Filters []string `arg:"--filter,separate" help:"path to a pandoc filter to pipe the document through before exporting it, which may be repeated. Except for pandoc-json exports, the filtered document is converted back to a google doc, which loses list styles, text fonts and colors, and raw content"`
+}
+
+func exportPandoc(ctx context.Context, args *exportPandocArgs) error {
+	if args.Suggestions == "markup" {
+		return fmt.Errorf("suggestions cannot be marked up in pandoc exports")
+	}
+	rules, err := loadStyles(&args.styleArgs)
+	if err != nil {
+		return err
+	}
+
+	// load the input document
+	d, err := googledoc.ReadFile(args.Input)
+	if err != nil {
+		return err
+	}
+
+	var filenames []string
+	for _, image := range d.Images {
+		filenames = append(filenames, image.Filename)
+	}
+	imageFilenamesByObjectID, err := googledoc.MatchObjectIDsToImages(d, filenames)
+	if err != nil {
+		return err
+	}
+
+	// prepare the document as for the html export
+	_, err = applySuggestionsArg(d, args.Suggestions)
+	if err != nil {
+		return err
+	}
+	err = removePrivateContent(d, &args.privateContentArgs)
+	if err != nil {
+		return err
+	}
+
+	// embed the images that remain, or write them alongside the output. There
+	// is nowhere to write images for output on stdout so they are embedded.
+	srcsByFilename := make(map[string]string)
+	for _, image := range googledoc.ReferencedImages(d, imageFilenamesByObjectID) {
+		if args.Output == "" {
+			srcsByFilename[image.Filename] = dataURI(image)
+			continue
+		}
+		err = writeImage(filepath.Join(filepath.Dir(args.Output), image.Filename), image.Content)
+		if err != nil {
+			return err
+		}
+		srcsByFilename[image.Filename] = filepath.ToSlash(image.Filename)
+	}
+	imageSrcsByObjectID := mapImageSrcs(imageFilenamesByObjectID, srcsByFilename)
+	err = rewriteLinks(d, &args.linkArgs)
+	if err != nil {
+		return err
+	}
+	settings, err := extractMetadata(d, args.TopHeading)
+	if err != nil {
+		return err
+	}
+
+	// convert the document and run the filters on it directly
+	opts := pandoc.Options{
+		ImageURLByObjectID: imageSrcsByObjectID,
+		StyleRules:         rules,
+	}
+	if args.ChartTables {
+		opts.ChartTables = chartTables(d)
+	}
+	doc, err := pandoc.FromGoogleDoc(d.Doc, &opts)
+	if err != nil {
+		return err
+	}
+	doc.Meta, err = pandoc.Meta(settings, d.ModifiedTime)
+	if err != nil {
+		return err
+	}
+	for _, filter := range args.Filters {
+		doc, err = pandoc.Filter(ctx, doc, filter, "json")
+		if err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	err = pandoc.Write(&buf, doc)
+	if err != nil {
+		return err
+	}
+
+	// write to output file or stdout
+	if args.Output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	err = ioutil.WriteFile(args.Output, buf.Bytes(), 0666)
+	if err != nil {
+		return fmt.Errorf("error writing to %s: %w", args.Output, err)
+	}
+	fmt.Printf("wrote pandoc json to %s\n", args.Output)
+	return nil
+}
+
+// applyFilters runs pandoc filters on a document whose metadata has been
+// extracted, and replaces the document and its settings with the output of
+// the filters. The filters are told the given output format. Images that
+// the filters add are added to imageURLs. It returns the style rules to
+// use in place of rules when exporting the document, which are rules
+// together with those for documents converted back from pandoc. See
+// pandoc.ToGoogleDoc for what is lost in the round trip.
+func applyFilters(ctx context.Context, d *googledoc.Archive, settings *googledoc.Settings, imageURLs map[string]string, rules *googledoc.StyleRules, args *filterArgs, format string) (*googledoc.StyleRules, error) {
+	if len(args.Filters) == 0 {
+		return rules, nil
+	}
+
+	doc, err := pandoc.FromGoogleDoc(d.Doc, &pandoc.Options{
+		ImageURLByObjectID: imageURLs,
+		StyleRules:         rules,
+	})
+	if err != nil {
+		return nil, err
+	}
+	doc.Meta, err = pandoc.Meta(settings, d.ModifiedTime)
+	if err != nil {
+		return nil, err
+	}
+	for _, filter := range args.Filters {
+		doc, err = pandoc.Filter(ctx, doc, filter, format)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = pandoc.ApplyMeta(doc.Meta, settings)
+	if err != nil {
+		return nil, err
+	}
+	d.Doc, err = pandoc.ToGoogleDoc(doc, d.Doc, imageURLs)
+	if err != nil {
+		return nil, err
+	}
+	return pandoc.WithRoleRules(rules), nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+)
+
+// privateContentArgs are the arguments for excluding editor-only content from exports
+type privateContentArgs struct {
+	PrivatePrefix    string   `help:"exclude named ranges whose name begins with this prefix" default:"private:"`
+	PrivateHighlight string   `help:"exclude text highlighted in this color, such as #ffff00"`
+	PrivateStyle     []string `help:"exclude paragraphs in these named styles, such as HEADING_6"`
+}
+
+// removePrivateContent removes editor-only content from a document and
+// prints a report of what was removed so that authors can check it. The
+// report goes to stderr so that it does not mix with output on stdout.
+func removePrivateContent(d *googledoc.Archive, args *privateContentArgs) error {
+	exclusions, err := googledoc.RemovePrivateContent(d.Doc, &googledoc.PrivateContentRules{
+		NamedRangePrefix: args.PrivatePrefix,
+		HighlightColor:   args.PrivateHighlight,
+		NamedStyles:      args.PrivateStyle,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(exclusions) > 0 {
+		fmt.Fprintf(os.Stderr, "excluded %d pieces of private content:\n", len(exclusions))
+		for _, e := range exclusions {
+			fmt.Fprintf(os.Stderr, "  %v\n", e)
+		}
+	}
+	return nil
+}
@@ -1,96 +1,225 @@
 package main
 
 // TODO:
-//   deal with emphasizing text runs that end with whitespace
-//   deal with tables
 //   deal with equations
-//   detect code blocks
+//   deal with horizontal rules
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
 	"strings"
-	"unicode/utf8"
 
-	"github.com/kr/pretty"
+	"cloud.google.com/go/storage"
+	"github.com/alexflint/doc-publisher/markdown"
 	"google.golang.org/api/docs/v1"
-	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/option"
 )
 
 type pushGoogleDocArgs struct {
-	Document string `arg:"positional"`
+	Input    string `arg:"positional,required" help:"markdown file to import"`
+	Document string `help:"ID of an existing google doc whose body will be replaced"`
+	Title    string `help:"title for a new google doc (defaults to the input filename)"`
+	Sync     bool   `help:"apply a minimal set of edits to --document instead of replacing its body"`
+	DryRun   bool   `help:"print the requests instead of sending them"`
 }
 
-func formatColor(c *docs.OptionalColor) string {
-	// Google docs support opaque colors, plus a special case for the
-	// transparent color. There is no support for partial transparency.
-	if c.Color == nil {
-		return "transparent"
+func pushGoogleDoc(ctx context.Context, args *pushGoogleDocArgs) error {
+	// read the markdown
+	source, err := ioutil.ReadFile(args.Input)
+	if err != nil {
+		return fmt.Errorf("error reading markdown: %w", err)
 	}
-	rgb := c.Color.RgbColor
-	return fmt.Sprintf("rgb(%.2f %.2f %.2f)", rgb.Red, rgb.Green, rgb.Blue)
-}
 
-func pushGoogleDoc(ctx context.Context, args *pushGoogleDocArgs) error {
 	const tokFile = ".cache/google-push-token.json"
 	googleToken, err := GoogleAuth(ctx, tokFile,
-		"https://www.googleapis.com/auth/documents",
-		"https://www.googleapis.com/auth/drive.readonly")
+		"https://www.googleapis.com/auth/documents")
 	if err != nil {
 		return fmt.Errorf("error authenticating with google: %w", err)
 	}
 
-	// create the drive client
-	driveClient, err := drive.NewService(ctx, option.WithTokenSource(googleToken))
+	// create the docs client
+	docsClient, err := docs.NewService(ctx, option.WithTokenSource(googleToken))
 	if err != nil {
-		return fmt.Errorf("error creating drive client: %w", err)
+		return fmt.Errorf("error creating docs client: %w", err)
 	}
 
-	revList, err := driveClient.Revisions.List(args.Document).Do()
+	// local images must be uploaded so that google can fetch them
+	resolver := imageResolver{ctx: ctx, dir: filepath.Dir(args.Input)}
+
+	if args.Sync {
+		return syncGoogleDoc(ctx, docsClient, source, resolver.resolve, args)
+	}
+
+	// convert the markdown to google docs requests
+	conv, err := markdown.ToGoogleDoc(source, 1, resolver.resolve)
 	if err != nil {
-		return fmt.Errorf("error getting revision list for document: %w", err)
+		return fmt.Errorf("error converting markdown: %w", err)
 	}
-	pretty.Println(revList)
-	return nil
 
-	// create the docs client
-	docsClient, err := docs.NewService(ctx, option.WithTokenSource(googleToken))
+	if args.DryRun {
+		var reqs []*docs.Request
+		reqs = append(reqs, conv.Body...)
+		for _, f := range conv.Footnotes {
+			reqs = append(reqs, f.Requests...)
+		}
+		return printRequests(reqs)
+	}
+
+	// create a new document or clear the body of the existing document
+	var reqs []*docs.Request
+	docID := args.Document
+	if docID == "" {
+		title := args.Title
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(args.Input), filepath.Ext(args.Input))
+		}
+
+		doc, err := docsClient.Documents.Create(&docs.Document{Title: title}).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("error creating document: %w", err)
+		}
+		docID = doc.DocumentId
+	} else {
+		existingDoc, err := docsClient.Documents.Get(docID).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("error retrieving document: %w", err)
+		}
+
+		// the final newline in the body cannot be deleted
+		content := existingDoc.Body.Content
+		end := content[len(content)-1].EndIndex
+		if end > 2 {
+			reqs = append(reqs, &docs.Request{
+				DeleteContentRange: &docs.DeleteContentRangeRequest{
+					Range: &docs.Range{StartIndex: 1, EndIndex: end - 1},
+				},
+			})
+		}
+	}
+
+	// update the body
+	offset := len(reqs)
+	reqs = append(reqs, conv.Body...)
+	resp, err := docsClient.Documents.BatchUpdate(docID, &docs.BatchUpdateDocumentRequest{
+		Requests: reqs,
+	}).Context(ctx).Do()
 	if err != nil {
-		return fmt.Errorf("error creating docs client: %w", err)
+		return fmt.Errorf("error updating document: %w", err)
+	}
+
+	// populate the footnotes now that we know their IDs
+	var footnoteReqs []*docs.Request
+	for _, f := range conv.Footnotes {
+		reply := resp.Replies[offset+f.CreateIndex]
+		if reply.CreateFootnote == nil {
+			return fmt.Errorf("no footnote ID in reply to create footnote request")
+		}
+		markdown.SetSegmentID(f.Requests, reply.CreateFootnote.FootnoteId)
+		footnoteReqs = append(footnoteReqs, f.Requests...)
+	}
+
+	if len(footnoteReqs) > 0 {
+		_, err = docsClient.Documents.BatchUpdate(docID, &docs.BatchUpdateDocumentRequest{
+			Requests: footnoteReqs,
+		}).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("error updating footnotes: %w", err)
+		}
+	}
+
+	fmt.Printf("wrote to https://docs.google.com/document/d/%s/edit\n", docID)
+	return nil
+}
+
+// syncGoogleDoc applies the minimal set of edits that bring a google doc into line with markdown
+func syncGoogleDoc(ctx context.Context, docsClient *docs.Service, source []byte, resolveImage func(string) (string, error), args *pushGoogleDocArgs) error {
+	if args.Document == "" {
+		return errors.New("--sync requires --document")
 	}
 
-	// pull the document
-	existingDoc, err := docsClient.Documents.Get(args.Document).Do()
+	// fetch the document with suggestions inline so that indices match those used by BatchUpdate
+	existingDoc, err := docsClient.Documents.Get(args.Document).Context(ctx).Do()
 	if err != nil {
 		return fmt.Errorf("error retrieving document: %w", err)
 	}
 
-	_ = existingDoc
+	reqs, err := markdown.SyncGoogleDoc(existingDoc, source, resolveImage)
+	if err != nil {
+		return fmt.Errorf("error computing edits: %w", err)
+	}
 
-	title := existingDoc.Body.Content[1]
-	titleContent := title.Paragraph.Elements[0].TextRun.Content
-	titleLength := utf8.RuneCountInString(strings.TrimSuffix(titleContent, "\n"))
+	if args.DryRun {
+		return printRequests(reqs)
+	}
 
-	update := docs.Request{
-		InsertText: &docs.InsertTextRequest{
-			Text: " FOO",
-			Location: &docs.Location{
-				Index: title.StartIndex + int64(titleLength),
-			},
-		},
+	if len(reqs) == 0 {
+		fmt.Println("document is already up to date")
+		return nil
 	}
 
-	resp, err := docsClient.Documents.BatchUpdate(args.Document, &docs.BatchUpdateDocumentRequest{
-		Requests: []*docs.Request{
-			&update,
-		},
+	// fail rather than apply edits if the document changed since we fetched it
+	_, err = docsClient.Documents.BatchUpdate(args.Document, &docs.BatchUpdateDocumentRequest{
+		Requests:     reqs,
+		WriteControl: &docs.WriteControl{RequiredRevisionId: existingDoc.RevisionId},
 	}).Context(ctx).Do()
-	_ = resp
-
 	if err != nil {
 		return fmt.Errorf("error updating document: %w", err)
 	}
 
+	fmt.Printf("applied %d edits to https://docs.google.com/document/d/%s/edit\n", len(reqs), args.Document)
+	return nil
+}
+
+// printRequests prints google docs requests as JSON
+func printRequests(reqs []*docs.Request) error {
+	for _, req := range reqs {
+		buf, err := json.MarshalIndent(req, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling request: %w", err)
+		}
+		fmt.Println(string(buf))
+	}
+	fmt.Printf("%d requests\n", len(reqs))
 	return nil
 }
+
+// imageResolver uploads local images to cloud storage so that google can fetch them
+type imageResolver struct {
+	ctx    context.Context
+	dir    string // directory relative to which image paths are resolved
+	bucket *storage.BucketHandle
+}
+
+func (r *imageResolver) resolve(dest string) (string, error) {
+	// leave remote images as they are
+	if u, err := url.Parse(dest); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return dest, nil
+	}
+
+	path := dest
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.dir, path)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	// create a cloud storage client the first time we need it
+	if r.bucket == nil {
+		storageClient, err := storage.NewClient(r.ctx,
+			option.WithCredentialsJSON(storageServiceAccount))
+		if err != nil {
+			return "", fmt.Errorf("error creating storage client: %w", err)
+		}
+		r.bucket = storageClient.Bucket(imageBucket)
+	}
+
+	return uploadImage(r.ctx, r.bucket, filepath.Ext(path), buf)
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/markdown"
+	"google.golang.org/api/docs/v1"
+)
+
+type exportSiteArgs struct {
+	Inputs      []string `arg:"positional,required" help:"archives to export, each as a page bundle"`
+	Output      string   `arg:"-o,--output,required" help:"directory in which to write the page bundles, such as content/posts"`
+	FrontMatter string   `help:"format for front matter. Possible values: yaml, toml" default:"yaml"`
+	Commit      bool     `help:"commit changed bundles to the git repository containing the output directory"`
+	Suggestions string   `help:"how to deal with suggested edits. Possible values: accept, reject"`
+	TopHeading  int      `help:"renumber headings so that the highest level is this one, with no skipped levels"`
+	Checklists  string   `help:"how to render checklists. Possible values: tasklist, html" default:"tasklist"`
+	Footnotes   string   `help:"how to render footnotes. Possible values: markdown, sidenotes, endnotes" default:"markdown"`
+	Spoilers    string   `help:"how to render spoilers. Possible values: lesswrong, html" default:"html"`
+	ChartTables bool     `help:"render linked charts as tables of their data, which must be fetched with --charts"`
+	filterArgs
+	privateContentArgs
+	styleArgs
+	linkArgs
+}
+
+// summaryLength is the maximum length of a summary taken from the first
+// paragraph of a document
+const summaryLength = 200
+
+// sitePage is a document exported as a page bundle
+type sitePage struct {
+	slug     string
+	title    string
+	docID    string
+	revision string
+	files    map[string][]byte // content of each file, by path relative to the bundle
+}
+
+func exportSite(ctx context.Context, args *exportSiteArgs) error {
+	if args.Suggestions == "markup" {
+		return fmt.Errorf("suggestions cannot be marked up in site exports")
+	}
+	if args.FrontMatter != markdown.YAMLFrontMatter && args.FrontMatter != markdown.TOMLFrontMatter {
+		return fmt.Errorf("invalid value for --frontmatter: %q", args.FrontMatter)
+	}
+	rules, err := loadStyles(&args.styleArgs)
+	if err != nil {
+		return err
+	}
+
+	// convert all documents before writing anything
+	var pages []*sitePage
+	inputBySlug := make(map[string]string)
+	for _, input := range args.Inputs {
+		page, err := sitePageFromArchive(ctx, input, args, rules)
+		if err != nil {
+			return fmt.Errorf("error converting %s: %w", input, err)
+		}
+		if other, ok := inputBySlug[page.slug]; ok {
+			return fmt.Errorf("%s and %s both have slug %q", other, input, page.slug)
+		}
+		inputBySlug[page.slug] = input
+		pages = append(pages, page)
+	}
+
+	// write each bundle, leaving unchanged files untouched
+	var changed []*sitePage
+	for _, page := range pages {
+		n, err := writeBundle(filepath.Join(args.Output, page.slug), page.files)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			fmt.Printf("%s is unchanged\n", page.slug)
+			continue
+		}
+		fmt.Printf("updated %d files in %s\n", n, filepath.Join(args.Output, page.slug))
+		changed = append(changed, page)
+	}
+
+	if args.Commit && len(changed) > 0 {
+		return commitBundles(ctx, args.Output, changed)
+	}
+	return nil
+}
+
+// sitePageFromArchive converts a document to markdown with front matter,
+// together with the images that it references
+func sitePageFromArchive(ctx context.Context, input string, args *exportSiteArgs, rules *googledoc.StyleRules) (*sitePage, error) {
+	d, err := googledoc.ReadFile(input)
+	if err != nil {
+		return nil, err
+	}
+
+	page := sitePage{
+		docID:    d.Doc.DocumentId,
+		revision: d.Doc.RevisionId,
+		files:    make(map[string][]byte),
+	}
+	var filenames []string
+	for _, image := range d.Images {
+		filenames = append(filenames, image.Filename)
+	}
+	imageFilenamesByObjectID, err := googledoc.MatchObjectIDsToImages(d, filenames)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare the document as for the markdown export
+	_, err = applySuggestionsArg(d, args.Suggestions)
+	if err != nil {
+		return nil, err
+	}
+	err = removePrivateContent(d, &args.privateContentArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the images that remain go in the bundle next to the markdown, under
+	// their names in the html export
+	urlsByFilename := make(map[string]string)
+	for _, image := range googledoc.ReferencedImages(d, imageFilenamesByObjectID) {
+		url := filepath.ToSlash(image.Filename)
+		urlsByFilename[image.Filename] = url
+		page.files[url] = image.Content
+	}
+	imageURLsByObjectID := mapImageSrcs(imageFilenamesByObjectID, urlsByFilename)
+	err = rewriteLinks(d, &args.linkArgs)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := extractMetadata(d, args.TopHeading)
+	if err != nil {
+		return nil, err
+	}
+	rules, err = applyFilters(ctx, d, settings, imageURLsByObjectID, rules, &args.filterArgs, "markdown")
+	if err != nil {
+		return nil, err
+	}
+
+	page.title = settings.Title
+	page.slug = settings.Slug
+	if page.slug == "" {
+		page.slug = markdown.Slug(settings.Title)
+	}
+	if page.slug == "" {
+		return nil, errors.New("document has no title or slug to name its bundle")
+	}
+	// the slug names a directory under the output, so it must not escape it
+	if page.slug == "." || page.slug == ".." || strings.ContainsAny(page.slug, `/\`) {
+		return nil, fmt.Errorf("invalid slug %q: slugs cannot contain path separators or be . or ..", page.slug)
+	}
+
+	// the date is when the document was last modified, so it is stable
+	// across re-exports of an unchanged document
+	fm := markdown.Page{
+		Title:   settings.Title,
+		Date:    d.ModifiedTime.UTC().Truncate(time.Second),
+		Slug:    page.slug,
+		Tags:    settings.Tags,
+		Summary: settings.Summary,
+		Draft:   settings.Draft != nil && *settings.Draft,
+	}
+	if fm.Summary == "" {
+		fm.Summary = settings.Subtitle
+	}
+	if fm.Summary == "" {
+		fm.Summary = summarize(d.Doc.Body.Content, summaryLength)
+	}
+	if d.ModifiedTime.IsZero() {
+		fmt.Printf("warning: %s has no modification time, fetch it again to include a date\n", input)
+	}
+	frontMatter, err := markdown.PageFrontMatter(&fm, args.FrontMatter)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := markdown.Options{
+		ImageURLByObjectID: imageURLsByObjectID,
+		StyleRules:         rules,
+	}
+	if args.ChartTables {
+		opts.ChartTables = chartTables(d)
+	}
+	if args.Footnotes != "markdown" {
+		opts.FootnoteStyle = args.Footnotes
+	}
+	if args.Spoilers != "lesswrong" {
+		opts.SpoilerStyle = args.Spoilers
+	}
+	if args.Checklists != "tasklist" {
+		opts.ChecklistStyle = args.Checklists
+	}
+
+	md, err := markdown.Convert(d.Doc, d.Doc.Body.Content, &opts)
+	if err != nil {
+		return nil, err
+	}
+	page.files["index.md"] = []byte(frontMatter + md)
+	return &page, nil
+}
+
+// summarize gets the text of the first paragraph of body text, truncated at
+// a word boundary to at most the given number of characters
+func summarize(content []*docs.StructuralElement, length int) string {
+	for _, elem := range content {
+		p := elem.Paragraph
+		if p == nil || p.ParagraphStyle == nil || p.ParagraphStyle.NamedStyleType != "NORMAL_TEXT" {
+			continue
+		}
+		var text strings.Builder
+		for _, el := range p.Elements {
+			if el.TextRun != nil {
+				text.WriteString(el.TextRun.Content)
+			}
+		}
+		words := strings.Fields(text.String())
+		if len(words) == 0 {
+			continue
+		}
+
+		var summary string
+		for _, word := range words {
+			next := strings.TrimSpace(summary + " " + word)
+			if len([]rune(next)) > length {
+				return summary + "…"
+			}
+			summary = next
+		}
+		return summary
+	}
+	return ""
+}
+
+// writeBundle writes files to a directory, leaving files whose content is
+// unchanged untouched, and removes any other images in the directory. It
+// returns the number of files written or removed.
+func writeBundle(dir string, files map[string][]byte) (int, error) {
+	var n int
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		existing, err := ioutil.ReadFile(path)
+		if err == nil && bytes.Equal(existing, content) {
+			continue
+		}
+		err = os.MkdirAll(filepath.Dir(path), 0777)
+		if err != nil {
+			return n, fmt.Errorf("error creating directory for %s: %w", path, err)
+		}
+		err = ioutil.WriteFile(path, content, 0666)
+		if err != nil {
+			return n, fmt.Errorf("error writing to %s: %w", path, err)
+		}
+		n++
+	}
+
+	// images that are no longer in the document are left over from earlier
+	// exports
+	imageDir := filepath.Join(dir, "images")
+	entries, err := ioutil.ReadDir(imageDir)
+	if err != nil && !os.IsNotExist(err) {
+		return n, fmt.Errorf("error listing %s: %w", imageDir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if _, ok := files["images/"+entry.Name()]; ok {
+			continue
+		}
+		err = os.Remove(filepath.Join(imageDir, entry.Name()))
+		if err != nil {
+			return n, fmt.Errorf("error removing stale image: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// commitBundles commits the bundles for the given pages to the git
+// repository containing the output directory, creating a repository there
+// if there is none. Other changes in the repository are not committed.
+func commitBundles(ctx context.Context, dir string, pages []*sitePage) error {
+	if err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--git-dir").Run(); err != nil {
+		err = runGit(ctx, dir, "init")
+		if err != nil {
+			return err
+		}
+	}
+
+	// the message names the revision of each source document
+	var paths, lines []string
+	for _, page := range pages {
+		paths = append(paths, page.slug)
+		line := fmt.Sprintf("%s from google doc %s", page.title, page.docID)
+		if page.revision != "" {
+			line += " revision " + page.revision
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(paths)
+
+	var msg string
+	if len(pages) == 1 {
+		msg = "Update " + lines[0]
+	} else {
+		msg = fmt.Sprintf("Update %d pages\n\n%s", len(pages), strings.Join(lines, "\n"))
+	}
+
+	err := runGit(ctx, dir, append([]string{"add", "-A", "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	err = runGit(ctx, dir, append([]string{"commit", "-q", "-m", msg, "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("committed %d bundles in %s\n", len(pages), dir)
+	return nil
+}
+
+// runGit runs a git command in a directory, including its output in the
+// error if it fails
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error running git %s: %w\n%s", args[0], err, out)
+	}
+	return nil
+}
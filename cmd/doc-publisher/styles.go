@@ -0,0 +1,29 @@
+package main
+
+import "github.com/alexflint/doc-publisher/googledoc"
+
+// styleArgs are the arguments for mapping document styles to output constructs
+type styleArgs struct {
+	Monospace []string `help:"treat text in these fonts as code, in addition to common monospace fonts"`
+	Rules     string   `help:"YAML or JSON file of rules that map styles to callouts, highlights, and so on"`
+}
+
+// loadStyles loads the style rules and adds the monospace fonts passed on
+// the command line to them. The rules are nil if neither was given.
+func loadStyles(args *styleArgs) (*googledoc.StyleRules, error) {
+	var rules *googledoc.StyleRules
+	if args.Rules != "" {
+		var err error
+		rules, err = googledoc.LoadStyleRules(args.Rules)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(args.Monospace) > 0 {
+		if rules == nil {
+			rules = &googledoc.StyleRules{}
+		}
+		rules.Monospace = append(rules.Monospace, args.Monospace...)
+	}
+	return rules, nil
+}
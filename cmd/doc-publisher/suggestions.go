@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+)
+
+// applySuggestionsArg deals with the --suggestions argument to the export
+// commands, and returns true if suggestions should be marked up. If the
+// argument is empty then the choice made when fetching is used.
+func applySuggestionsArg(d *googledoc.Archive, suggestions string) (bool, error) {
+	if suggestions == "" {
+		suggestions = d.Suggestions
+	}
+
+	// check that the argument is valid
+	if _, err := googledoc.SuggestionsViewMode(suggestions); err != nil {
+		return false, err
+	}
+
+	inline := d.Doc.SuggestionsViewMode == "" || d.Doc.SuggestionsViewMode == "SUGGESTIONS_INLINE"
+	switch suggestions {
+	case googledoc.AcceptSuggestions:
+		googledoc.ApplySuggestions(d.Doc, true)
+	case googledoc.RejectSuggestions:
+		googledoc.ApplySuggestions(d.Doc, false)
+	case googledoc.MarkupSuggestions:
+		if !inline {
+			fmt.Fprintf(os.Stderr, "warning: document was fetched with suggestions view mode %s, so there are no suggestions to mark up\n",
+				d.Doc.SuggestionsViewMode)
+		}
+		return true, nil
+	}
+	return false, nil
+}
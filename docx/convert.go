@@ -0,0 +1,459 @@
+package docx
+
+import (
+	"archive/zip"
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// prefixes for the IDs of footnotes and endnotes, which both become google docs footnotes
+const (
+	footnotePrefix = "docx.fn."
+	endnotePrefix  = "docx.en."
+)
+
+type converter struct {
+	files              map[string]*zip.File
+	rels               map[string]relationship
+	styles             map[string]*style
+	numbering          map[string]*abstractNumbering
+	imageByTarget      map[string]string // image filenames by relationship target
+	filenameByObjectID map[string]string // image filenames by inline object ID
+	doc                *docs.Document
+	archive            *googledoc.Archive
+	index              int64 // current position in the document, in UTF-16 code units
+}
+
+// runContext holds the state that applies to a sequence of runs
+type runContext struct {
+	link       *docs.Link
+	insertions []string // suggested insertion IDs from w:ins
+	deletions  []string // suggested deletion IDs from w:del
+}
+
+// paragraphState holds the state of a paragraph while its runs are converted
+type paragraphState struct {
+	styleID    string
+	paragraph  *docs.Paragraph
+	fieldDepth int    // depth of nested complex fields
+	fieldCode  string // instruction text of the innermost complex field
+	fieldLink  *docs.Link
+	inFieldRes bool // whether we are in the result part of a complex field
+}
+
+// loadNotes reads footnotes or endnotes into the document
+func (c *converter) loadNotes(name, elemName, prefix string) error {
+	root, err := c.parsePart(name)
+	if err != nil || root == nil {
+		return err
+	}
+
+	for _, n := range root.Children {
+		if n.Name.Local != elemName {
+			continue
+		}
+		// skip the separators that word puts at the beginning of the notes part
+		if t := n.attr("type"); t != "" && t != "normal" {
+			continue
+		}
+
+		id := prefix + n.attr("id")
+		c.index = 0
+		c.doc.Footnotes[id] = docs.Footnote{
+			FootnoteId: id,
+			Content:    c.convertBlocks(n),
+		}
+	}
+	return nil
+}
+
+// convertBlocks converts the paragraphs and tables inside a container such as w:body or w:tc
+func (c *converter) convertBlocks(container *node) []*docs.StructuralElement {
+	if container == nil {
+		return nil
+	}
+
+	var out []*docs.StructuralElement
+	for _, n := range container.Children {
+		switch n.Name.Local {
+		case "p":
+			out = append(out, c.convertParagraph(n))
+		case "tbl":
+			out = append(out, c.convertTable(n))
+		case "sdt":
+			// content controls wrap ordinary content
+			out = append(out, c.convertBlocks(n.child("sdtContent"))...)
+		case "sectPr":
+			// final section properties are represented by the initial section break
+		}
+	}
+	return out
+}
+
+// convertParagraph converts a w:p element
+func (c *converter) convertParagraph(p *node) *docs.StructuralElement {
+	pPr := p.child("pPr")
+	st := paragraphState{
+		styleID: pPr.child("pStyle").attr("val"),
+		paragraph: &docs.Paragraph{
+			ParagraphStyle: &docs.ParagraphStyle{},
+		},
+	}
+	st.paragraph.ParagraphStyle.NamedStyleType = c.paragraphNamedStyle(st.styleID)
+
+	// paragraph properties come from the style chain and then the paragraph itself
+	var props []*node
+	for _, s := range c.styleChain(st.styleID) {
+		if s.ParagraphProps != nil {
+			props = append(props, s.ParagraphProps)
+		}
+	}
+	if pPr != nil {
+		props = append(props, pPr)
+	}
+
+	var numID string
+	var ilvl int64
+	for _, pp := range props {
+		if ind := pp.child("ind"); ind != nil {
+			if d := twipsToPoints(firstNonEmpty(ind.attr("left"), ind.attr("start"))); d != nil {
+				st.paragraph.ParagraphStyle.IndentStart = d
+			}
+			if d := twipsToPoints(ind.attr("firstLine")); d != nil {
+				st.paragraph.ParagraphStyle.IndentFirstLine = d
+			}
+		}
+		if jc := pp.child("jc"); jc != nil {
+			st.paragraph.ParagraphStyle.Alignment = alignment(jc.attr("val"))
+		}
+		if numPr := pp.child("numPr"); numPr != nil {
+			if id := numPr.child("numId").attr("val"); id != "" {
+				numID = id
+			}
+			if lvl, err := strconv.ParseInt(numPr.child("ilvl").attr("val"), 10, 64); err == nil {
+				ilvl = lvl
+			}
+		}
+	}
+
+	// a numId of zero explicitly removes numbering
+	if numID != "" && numID != "0" {
+		st.paragraph.Bullet = &docs.Bullet{
+			ListId:       c.listID(numID),
+			NestingLevel: ilvl,
+		}
+		// in google docs the indent of a list item comes from the list
+		st.paragraph.ParagraphStyle.IndentStart = nil
+	}
+
+	start := c.index
+	c.convertRuns(&st, p.Children, runContext{})
+
+	// every google docs paragraph ends with a newline
+	c.appendText(st.paragraph, "\n", &docs.TextStyle{}, runContext{})
+
+	return &docs.StructuralElement{
+		StartIndex: start,
+		EndIndex:   c.index,
+		Paragraph:  st.paragraph,
+	}
+}
+
+// alignment maps a w:jc value to a google docs alignment
+func alignment(jc string) string {
+	switch jc {
+	case "center":
+		return "CENTER"
+	case "right", "end":
+		return "END"
+	case "both", "distribute":
+		return "JUSTIFIED"
+	}
+	return "START"
+}
+
+// convertRuns converts a sequence of runs and run containers such as hyperlinks
+func (c *converter) convertRuns(st *paragraphState, nodes []*node, ctx runContext) {
+	for _, n := range nodes {
+		switch n.Name.Local {
+		case "r":
+			c.convertRun(st, n, ctx)
+		case "hyperlink":
+			inner := ctx
+			if rel, ok := c.rels[n.relAttr("id")]; ok {
+				inner.link = &docs.Link{Url: rel.Target}
+			} else if anchor := n.attr("anchor"); anchor != "" {
+				inner.link = &docs.Link{BookmarkId: anchor}
+			}
+			c.convertRuns(st, n.Children, inner)
+		case "ins":
+			inner := ctx
+			inner.insertions = append(append([]string(nil), ctx.insertions...), "docx.ins."+n.attr("id"))
+			c.convertRuns(st, n.Children, inner)
+		case "del":
+			inner := ctx
+			inner.deletions = append(append([]string(nil), ctx.deletions...), "docx.del."+n.attr("id"))
+			c.convertRuns(st, n.Children, inner)
+		case "smartTag", "customXml":
+			c.convertRuns(st, n.Children, ctx)
+		case "sdt":
+			if content := n.child("sdtContent"); content != nil {
+				c.convertRuns(st, content.Children, ctx)
+			}
+		case "fldSimple":
+			inner := ctx
+			if link := hyperlinkField(n.attr("instr")); link != nil {
+				inner.link = link
+			}
+			c.convertRuns(st, n.Children, inner)
+		}
+	}
+}
+
+// regular expression for the instruction text of a hyperlink field
+var hyperlinkFieldRegexp = regexp.MustCompile(`^\s*HYPERLINK\s+(\\l\s+)?"([^"]*)"`)
+
+// hyperlinkField parses field instructions like HYPERLINK "https://example.com"
+func hyperlinkField(instr string) *docs.Link {
+	m := hyperlinkFieldRegexp.FindStringSubmatch(instr)
+	if m == nil {
+		return nil
+	}
+	if m[1] != "" {
+		return &docs.Link{BookmarkId: m[2]}
+	}
+	return &docs.Link{Url: m[2]}
+}
+
+// convertRun converts a w:r element
+func (c *converter) convertRun(st *paragraphState, r *node, ctx runContext) {
+	// in google docs the text style of a heading is part of the named
+	// style, so only custom paragraph styles contribute to the run style
+	rPr := r.child("rPr")
+	styleIDs := []string{rPr.child("rStyle").attr("val")}
+	if st.paragraph.ParagraphStyle.NamedStyleType == "NORMAL_TEXT" {
+		styleIDs = append([]string{st.styleID}, styleIDs...)
+	}
+	ts := c.textStyle(rPr, styleIDs...)
+
+	for _, n := range r.Children {
+		// complex fields consist of a begin marker, instruction text, a
+		// separator, the displayed result, and an end marker
+		switch n.Name.Local {
+		case "fldChar":
+			switch n.attr("fldCharType") {
+			case "begin":
+				st.fieldDepth++
+				st.fieldCode = ""
+				st.inFieldRes = false
+			case "separate":
+				st.fieldLink = hyperlinkField(st.fieldCode)
+				st.inFieldRes = true
+			case "end":
+				if st.fieldDepth > 0 {
+					st.fieldDepth--
+				}
+				st.fieldLink = nil
+				st.inFieldRes = false
+			}
+			continue
+		case "instrText":
+			st.fieldCode += n.Text
+			continue
+		}
+
+		// skip anything between the beginning of a field and its result
+		if st.fieldDepth > 0 && !st.inFieldRes {
+			continue
+		}
+
+		runCtx := ctx
+		if st.fieldLink != nil && runCtx.link == nil {
+			runCtx.link = st.fieldLink
+		}
+
+		switch n.Name.Local {
+		case "t", "delText":
+			c.appendText(st.paragraph, n.Text, ts, runCtx)
+		case "tab", "ptab":
+			c.appendText(st.paragraph, "\t", ts, runCtx)
+		case "br", "cr":
+			if n.attr("type") == "page" {
+				c.appendElement(st.paragraph, &docs.ParagraphElement{PageBreak: &docs.PageBreak{}})
+			} else if n.attr("type") == "column" {
+				c.appendElement(st.paragraph, &docs.ParagraphElement{ColumnBreak: &docs.ColumnBreak{}})
+			} else {
+				// google docs uses a vertical tab for line breaks within a paragraph
+				c.appendText(st.paragraph, "\v", ts, runCtx)
+			}
+		case "noBreakHyphen":
+			c.appendText(st.paragraph, "-", ts, runCtx)
+		case "footnoteReference":
+			c.appendElement(st.paragraph, &docs.ParagraphElement{
+				FootnoteReference: &docs.FootnoteReference{FootnoteId: footnotePrefix + n.attr("id")},
+			})
+		case "endnoteReference":
+			c.appendElement(st.paragraph, &docs.ParagraphElement{
+				FootnoteReference: &docs.FootnoteReference{FootnoteId: endnotePrefix + n.attr("id")},
+			})
+		case "drawing":
+			c.convertDrawing(st.paragraph, n)
+		case "pict", "object":
+			// legacy VML images
+			if imagedata := n.find("imagedata"); imagedata != nil {
+				c.addImage(st.paragraph, imagedata.relAttr("id"), "", "", nil)
+			}
+		case "rPr", "footnoteRef", "endnoteRef", "lastRenderedPageBreak", "softHyphen":
+			// nothing to do
+		default:
+			log.Printf("warning: ignoring unsupported docx run content <%s>", n.Name.Local)
+		}
+	}
+}
+
+// convertDrawing converts a w:drawing element to an inline object
+func (c *converter) convertDrawing(p *docs.Paragraph, drawing *node) {
+	blip := drawing.find("blip")
+	if blip == nil {
+		log.Println("warning: ignoring drawing that contains no image")
+		return
+	}
+
+	docPr := drawing.find("docPr")
+	var size *docs.Size
+	if extent := drawing.find("extent"); extent != nil {
+		size = &docs.Size{
+			Width:  emuToPoints(extent.attr("cx")),
+			Height: emuToPoints(extent.attr("cy")),
+		}
+	}
+
+	c.addImage(p, blip.relAttr("embed"), docPr.attr("title"), docPr.attr("descr"), size)
+}
+
+// addImage adds an inline object to a paragraph for the image with the given relationship ID
+func (c *converter) addImage(p *docs.Paragraph, relID, title, description string, size *docs.Size) {
+	filename, err := c.loadImage(relID)
+	if err != nil {
+		log.Printf("warning: ignoring image: %v", err)
+		return
+	}
+
+	id := fmt.Sprintf("docx.image.%d", len(c.doc.InlineObjects)+1)
+	c.doc.InlineObjects[id] = docs.InlineObject{
+		ObjectId: id,
+		InlineObjectProperties: &docs.InlineObjectProperties{
+			EmbeddedObject: &docs.EmbeddedObject{
+				Title:           title,
+				Description:     description,
+				Size:            size,
+				ImageProperties: &docs.ImageProperties{},
+			},
+		},
+	}
+	c.filenameByObjectID[id] = filename
+
+	c.appendElement(p, &docs.ParagraphElement{
+		InlineObjectElement: &docs.InlineObjectElement{InlineObjectId: id},
+	})
+}
+
+// appendText adds text to a paragraph, merging it with the previous text run
+// if the styles are the same, since word splits runs much more finely than
+// google docs does
+func (c *converter) appendText(p *docs.Paragraph, text string, ts *docs.TextStyle, ctx runContext) {
+	if text == "" {
+		return
+	}
+
+	style := *ts
+	if ctx.link != nil {
+		style.Link = ctx.link
+	}
+
+	length := int64(len(utf16.Encode([]rune(text))))
+	if n := len(p.Elements); n > 0 {
+		last := p.Elements[n-1]
+		if last.TextRun != nil &&
+			reflect.DeepEqual(last.TextRun.TextStyle, &style) &&
+			reflect.DeepEqual(last.TextRun.SuggestedInsertionIds, ctx.insertions) &&
+			reflect.DeepEqual(last.TextRun.SuggestedDeletionIds, ctx.deletions) {
+			last.TextRun.Content += text
+			last.EndIndex += length
+			c.index += length
+			return
+		}
+	}
+
+	p.Elements = append(p.Elements, &docs.ParagraphElement{
+		StartIndex: c.index,
+		EndIndex:   c.index + length,
+		TextRun: &docs.TextRun{
+			Content:               text,
+			TextStyle:             &style,
+			SuggestedInsertionIds: ctx.insertions,
+			SuggestedDeletionIds:  ctx.deletions,
+		},
+	})
+	c.index += length
+}
+
+// appendElement adds a non-text element to a paragraph. Each such element
+// occupies one position in the document.
+func (c *converter) appendElement(p *docs.Paragraph, e *docs.ParagraphElement) {
+	e.StartIndex = c.index
+	e.EndIndex = c.index + 1
+	c.index++
+	p.Elements = append(p.Elements, e)
+}
+
+// convertTable converts a w:tbl element
+func (c *converter) convertTable(tbl *node) *docs.StructuralElement {
+	start := c.index
+	c.index++ // the table itself occupies one position
+
+	var t docs.Table
+	for _, tr := range tbl.Children {
+		if tr.Name.Local != "tr" {
+			continue
+		}
+
+		row := docs.TableRow{StartIndex: c.index}
+		c.index++
+		for _, tc := range tr.Children {
+			if tc.Name.Local != "tc" {
+				continue
+			}
+
+			cell := docs.TableCell{StartIndex: c.index}
+			c.index++
+			if span, err := strconv.ParseInt(tc.child("tcPr").child("gridSpan").attr("val"), 10, 64); err == nil {
+				cell.TableCellStyle = &docs.TableCellStyle{ColumnSpan: span, RowSpan: 1}
+			}
+			cell.Content = c.convertBlocks(tc)
+			cell.EndIndex = c.index
+			row.TableCells = append(row.TableCells, &cell)
+		}
+		row.EndIndex = c.index
+
+		if int64(len(row.TableCells)) > t.Columns {
+			t.Columns = int64(len(row.TableCells))
+		}
+		t.TableRows = append(t.TableRows, &row)
+	}
+	t.Rows = int64(len(t.TableRows))
+	c.index++ // the end of the table occupies one position
+
+	return &docs.StructuralElement{
+		StartIndex: start,
+		EndIndex:   c.index,
+		Table:      &t,
+	}
+}
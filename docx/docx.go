@@ -0,0 +1,243 @@
+// Package docx reads Microsoft Word .docx files into the same archive
+// structure that is produced by fetching a google doc, so that all of the
+// exporters and publishers can be used without access to Google's APIs.
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// relationship is an entry in word/_rels/document.xml.rels
+type relationship struct {
+	Target   string
+	External bool
+}
+
+// ReadFile reads a .docx file from disk
+func ReadFile(p string) (*googledoc.Archive, error) {
+	buf, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", p, err)
+	}
+
+	d, err := Read(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		return nil, err
+	}
+
+	// fall back to the filename for the title
+	if d.Doc.Title == "" {
+		d.Doc.Title = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+	return d, nil
+}
+
+// Read reads a .docx file from a reader
+func Read(r io.ReaderAt, size int64) (*googledoc.Archive, error) {
+	ziprd, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding zip archive: %w", err)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range ziprd.File {
+		files[f.Name] = f
+	}
+
+	conv := converter{
+		files:              files,
+		rels:               make(map[string]relationship),
+		styles:             make(map[string]*style),
+		numbering:          make(map[string]*abstractNumbering),
+		imageByTarget:      make(map[string]string),
+		filenameByObjectID: make(map[string]string),
+		doc: &docs.Document{
+			Body:          &docs.Body{},
+			Footnotes:     make(map[string]docs.Footnote),
+			InlineObjects: make(map[string]docs.InlineObject),
+			Lists:         make(map[string]docs.List),
+		},
+		archive: &googledoc.Archive{},
+	}
+
+	// the main document is required, everything else is optional
+	body, err := conv.parsePart("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return nil, fmt.Errorf("no word/document.xml found in docx archive")
+	}
+
+	if err := conv.loadRelationships("word/_rels/document.xml.rels"); err != nil {
+		return nil, err
+	}
+	if err := conv.loadStyles("word/styles.xml"); err != nil {
+		return nil, err
+	}
+	if err := conv.loadNumbering("word/numbering.xml"); err != nil {
+		return nil, err
+	}
+	if err := conv.loadNotes("word/footnotes.xml", "footnote", footnotePrefix); err != nil {
+		return nil, err
+	}
+	if err := conv.loadNotes("word/endnotes.xml", "endnote", endnotePrefix); err != nil {
+		return nil, err
+	}
+	if err := conv.loadTitle("docProps/core.xml"); err != nil {
+		return nil, err
+	}
+
+	// convert the body
+	conv.index = 0
+	conv.doc.Body.Content = append(conv.doc.Body.Content, &docs.StructuralElement{
+		StartIndex:   0,
+		EndIndex:     1,
+		SectionBreak: &docs.SectionBreak{},
+	})
+	conv.index = 1
+	conv.doc.Body.Content = append(conv.doc.Body.Content, conv.convertBlocks(body.child("body"))...)
+
+	conv.archive.Doc = conv.doc
+	conv.archive.HTML = conv.imageHTML()
+	return conv.archive, nil
+}
+
+// parsePart parses an XML file from the docx archive, or returns nil if it does not exist
+func (c *converter) parsePart(name string) (*node, error) {
+	f, ok := c.files[name]
+	if !ok {
+		return nil, nil
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s from docx archive: %w", name, err)
+	}
+	defer r.Close()
+
+	root, err := parseXML(r)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", name, err)
+	}
+	return root, nil
+}
+
+// readPart reads the raw content of a file in the docx archive
+func (c *converter) readPart(name string) ([]byte, error) {
+	f, ok := c.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in docx archive", name)
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s from docx archive: %w", name, err)
+	}
+	defer r.Close()
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s from docx archive: %w", name, err)
+	}
+	return buf, nil
+}
+
+// loadRelationships reads the mapping from relationship IDs to images and hyperlinks
+func (c *converter) loadRelationships(name string) error {
+	root, err := c.parsePart(name)
+	if err != nil || root == nil {
+		return err
+	}
+
+	for _, rel := range root.Children {
+		if rel.Name.Local != "Relationship" {
+			continue
+		}
+		c.rels[rel.attr("Id")] = relationship{
+			Target:   rel.attr("Target"),
+			External: rel.attr("TargetMode") == "External",
+		}
+	}
+	return nil
+}
+
+// loadTitle reads the document title from the core properties
+func (c *converter) loadTitle(name string) error {
+	root, err := c.parsePart(name)
+	if err != nil || root == nil {
+		return err
+	}
+
+	if title := root.child("title"); title != nil {
+		c.doc.Title = strings.TrimSpace(title.Text)
+	}
+	return nil
+}
+
+// loadImage adds an image from the docx archive to the output archive and
+// returns its filename, re-using the existing entry if the same media file
+// is referenced more than once
+func (c *converter) loadImage(relID string) (string, error) {
+	rel, ok := c.rels[relID]
+	if !ok || rel.External {
+		return "", fmt.Errorf("no embedded image found for relationship %q", relID)
+	}
+
+	if filename, ok := c.imageByTarget[rel.Target]; ok {
+		return filename, nil
+	}
+
+	// targets are relative to the word/ directory
+	buf, err := c.readPart(path.Join("word", rel.Target))
+	if err != nil {
+		return "", err
+	}
+
+	// name images the same way as the HTML export of a google doc
+	extension := strings.ToLower(path.Ext(rel.Target))
+	if extension == ".jpeg" {
+		extension = ".jpg"
+	}
+	filename := fmt.Sprintf("images/image%d%s", len(c.archive.Images)+1, extension)
+
+	c.archive.Images = append(c.archive.Images, &googledoc.Image{
+		Filename: filename,
+		Content:  buf,
+	})
+	c.imageByTarget[rel.Target] = filename
+	return filename, nil
+}
+
+// imageHTML generates a minimal HTML document that references the images in
+// the order in which they appear in the body, which is what
+// googledoc.MatchObjectIDsToImages uses in place of a real HTML export
+func (c *converter) imageHTML() []byte {
+	var b bytes.Buffer
+	b.WriteString("<html><body>\n")
+	for _, elem := range c.doc.Body.Content {
+		if elem.Paragraph == nil {
+			continue
+		}
+		for _, e := range elem.Paragraph.Elements {
+			if e.InlineObjectElement == nil {
+				continue
+			}
+			if filename, ok := c.filenameByObjectID[e.InlineObjectElement.InlineObjectId]; ok {
+				fmt.Fprintf(&b, "<p><img src=\"%s\"></p>\n", filename)
+			}
+		}
+	}
+	b.WriteString("</body></html>\n")
+	return b.Bytes()
+}
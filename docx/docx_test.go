@@ -0,0 +1,135 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDocument = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"
+	xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing">
+<w:body>
+	<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Intro</w:t></w:r></w:p>
+	<w:p>
+		<w:r><w:t xml:space="preserve">Hello </w:t></w:r>
+		<w:r><w:rPr><w:b/></w:rPr><w:t>bold</w:t></w:r>
+		<w:r><w:rPr><w:b/></w:rPr><w:t>er</w:t></w:r>
+		<w:hyperlink r:id="rId2"><w:r><w:t>link</w:t></w:r></w:hyperlink>
+		<w:r><w:footnoteReference w:id="1"/></w:r>
+		<w:sdt><w:sdtPr/></w:sdt>
+	</w:p>
+	<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="3"/></w:numPr></w:pPr><w:r><w:t>item</w:t></w:r></w:p>
+	<w:p><w:r><w:drawing><wp:inline><wp:extent cx="127000" cy="254000"/><wp:docPr id="1" name="Picture 1" descr="a cat"/>
+		<a:graphic><a:graphicData><a:blip r:embed="rId1"/></a:graphicData></a:graphic></wp:inline></w:drawing></w:r></w:p>
+	<w:tbl><w:tr><w:tc><w:p><w:r><w:t>cell</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body>
+</w:document>`
+
+const testRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+	<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/image1.jpeg"/>
+	<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/" TargetMode="External"/>
+</Relationships>`
+
+const testStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+	<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:rPr><w:rFonts w:ascii="Calibri"/></w:rPr></w:style>
+	<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:rPr><w:b/></w:rPr></w:style>
+</w:styles>`
+
+const testNumbering = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+	<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="decimal"/><w:lvlText w:val="%1."/></w:lvl></w:abstractNum>
+	<w:num w:numId="3"><w:abstractNumId w:val="0"/></w:num>
+</w:numbering>`
+
+const testFootnotes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:footnotes xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+	<w:footnote w:type="separator" w:id="-1"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>
+	<w:footnote w:id="1"><w:p><w:r><w:footnoteRef/></w:r><w:r><w:t xml:space="preserve"> a note</w:t></w:r></w:p></w:footnote>
+</w:footnotes>`
+
+func makeDocx(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	wr := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := wr.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, wr.Close())
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	buf := makeDocx(t, map[string]string{
+		"word/document.xml":            testDocument,
+		"word/_rels/document.xml.rels": testRelationships,
+		"word/styles.xml":              testStyles,
+		"word/numbering.xml":           testNumbering,
+		"word/footnotes.xml":           testFootnotes,
+		"word/media/image1.jpeg":       "not really a jpeg",
+	})
+
+	d, err := Read(bytes.NewReader(buf), int64(len(buf)))
+	require.NoError(t, err)
+
+	content := d.Doc.Body.Content
+	require.Len(t, content, 6)
+	assert.NotNil(t, content[0].SectionBreak)
+
+	// heading, with the bold from the heading style left to the named style
+	heading := content[1].Paragraph
+	assert.Equal(t, "HEADING_1", heading.ParagraphStyle.NamedStyleType)
+	require.Len(t, heading.Elements, 1)
+	assert.Equal(t, "Intro\n", heading.Elements[0].TextRun.Content)
+	assert.False(t, heading.Elements[0].TextRun.TextStyle.Bold)
+
+	// runs with identical styles are merged
+	para := content[2].Paragraph
+	require.Len(t, para.Elements, 5)
+	assert.Equal(t, "Hello ", para.Elements[0].TextRun.Content)
+	assert.Equal(t, "bolder", para.Elements[1].TextRun.Content)
+	assert.True(t, para.Elements[1].TextRun.TextStyle.Bold)
+	assert.Equal(t, "https://example.com/", para.Elements[2].TextRun.TextStyle.Link.Url)
+	assert.Equal(t, footnotePrefix+"1", para.Elements[3].FootnoteReference.FootnoteId)
+	assert.Equal(t, content[2].StartIndex+6, para.Elements[1].StartIndex)
+
+	// footnotes
+	require.Contains(t, d.Doc.Footnotes, footnotePrefix+"1")
+	assert.Len(t, d.Doc.Footnotes, 1)
+
+	// numbered list
+	item := content[3].Paragraph
+	require.NotNil(t, item.Bullet)
+	level := d.Doc.Lists[item.Bullet.ListId].ListProperties.NestingLevels[0]
+	assert.Equal(t, "", level.GlyphSymbol)
+	assert.Equal(t, "DECIMAL", level.GlyphType)
+	assert.Equal(t, "%0.", level.GlyphFormat)
+
+	// images
+	require.Len(t, d.Images, 1)
+	assert.Equal(t, "images/image1.jpg", d.Images[0].Filename)
+	img := content[4].Paragraph.Elements[0].InlineObjectElement
+	require.NotNil(t, img)
+	emb := d.Doc.InlineObjects[img.InlineObjectId].InlineObjectProperties.EmbeddedObject
+	assert.Equal(t, "a cat", emb.Description)
+	assert.Equal(t, 10.0, emb.Size.Width.Magnitude)
+
+	urls, err := googledoc.MatchObjectIDsToImages(d, []string{"https://example.com/image1.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/image1.jpg", urls[img.InlineObjectId])
+
+	// tables
+	table := content[5].Table
+	require.NotNil(t, table)
+	assert.Equal(t, "cell\n", table.TableRows[0].TableCells[0].Content[0].Paragraph.Elements[0].TextRun.Content)
+}
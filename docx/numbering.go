@@ -0,0 +1,161 @@
+package docx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// abstractNumbering is a list definition from word/numbering.xml
+type abstractNumbering struct {
+	Levels map[int64]*node // w:lvl elements by w:ilvl
+}
+
+// loadNumbering reads list definitions, keyed by the w:numId that paragraphs refer to
+func (c *converter) loadNumbering(name string) error {
+	root, err := c.parsePart(name)
+	if err != nil || root == nil {
+		return err
+	}
+
+	abstracts := make(map[string]*abstractNumbering)
+	for _, n := range root.Children {
+		if n.Name.Local != "abstractNum" {
+			continue
+		}
+		a := abstractNumbering{Levels: make(map[int64]*node)}
+		for _, lvl := range n.Children {
+			if lvl.Name.Local != "lvl" {
+				continue
+			}
+			ilvl, err := strconv.ParseInt(lvl.attr("ilvl"), 10, 64)
+			if err != nil {
+				continue
+			}
+			a.Levels[ilvl] = lvl
+		}
+		abstracts[n.attr("abstractNumId")] = &a
+	}
+
+	for _, n := range root.Children {
+		if n.Name.Local != "num" {
+			continue
+		}
+		if a, ok := abstracts[n.child("abstractNumId").attr("val")]; ok {
+			c.numbering[n.attr("numId")] = a
+		}
+	}
+	return nil
+}
+
+// listID gets the ID of the google docs list for a word numbering ID,
+// creating the list the first time that it is referenced
+func (c *converter) listID(numID string) string {
+	id := "docx.list." + numID
+	if _, ok := c.doc.Lists[id]; ok {
+		return id
+	}
+
+	// word supports nine levels of nesting, as does google docs
+	props := docs.ListProperties{}
+	a := c.numbering[numID]
+	for i := int64(0); i < 9; i++ {
+		var lvl *node
+		if a != nil {
+			lvl = a.Levels[i]
+		}
+		props.NestingLevels = append(props.NestingLevels, nestingLevel(lvl, i))
+	}
+
+	c.doc.Lists[id] = docs.List{ListProperties: &props}
+	return id
+}
+
+// nestingLevel converts a w:lvl element to a google docs nesting level
+func nestingLevel(lvl *node, depth int64) *docs.NestingLevel {
+	level := docs.NestingLevel{
+		IndentStart: &docs.Dimension{Magnitude: float64(36 * (depth + 1)), Unit: "PT"},
+	}
+	if lvl == nil {
+		level.GlyphSymbol = "●"
+		return &level
+	}
+
+	if start, err := strconv.ParseInt(lvl.child("start").attr("val"), 10, 64); err == nil {
+		level.StartNumber = start
+	}
+	if ind := lvl.child("pPr").child("ind"); ind != nil {
+		if d := twipsToPoints(firstNonEmpty(ind.attr("left"), ind.attr("start"))); d != nil {
+			level.IndentStart = d
+		}
+	}
+
+	text := lvl.child("lvlText").attr("val")
+	switch format := lvl.child("numFmt").attr("val"); format {
+	case "bullet", "none", "":
+		// bullet glyphs are often characters in a symbol font, which
+		// are meaningless outside of word, so use a standard bullet
+		level.GlyphSymbol = text
+		if text == "" || !isPrintable(text) {
+			level.GlyphSymbol = "●"
+		}
+	default:
+		level.GlyphType = glyphType(format)
+		level.GlyphFormat = glyphFormat(text)
+	}
+
+	return &level
+}
+
+// glyphType maps a word number format to a google docs glyph type
+func glyphType(format string) string {
+	switch format {
+	case "lowerLetter":
+		return "ALPHA"
+	case "upperLetter":
+		return "UPPER_ALPHA"
+	case "lowerRoman":
+		return "ROMAN"
+	case "upperRoman":
+		return "UPPER_ROMAN"
+	case "decimalZero":
+		return "ZERO_DECIMAL"
+	}
+	return "DECIMAL"
+}
+
+// glyphFormat converts a word level text like "%1." to a google docs glyph
+// format like "%0.", since word numbers levels from one and google from zero
+func glyphFormat(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '%' && i+1 < len(text) && text[i+1] >= '1' && text[i+1] <= '9' {
+			fmt.Fprintf(&b, "%%%c", text[i+1]-1)
+			i++
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// isPrintable determines whether a string contains no private-use characters
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if r >= 0xE000 && r <= 0xF8FF {
+			return false
+		}
+	}
+	return true
+}
+
+func firstNonEmpty(ss ...string) string {
+	for _, s := range ss {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
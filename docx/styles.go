@@ -0,0 +1,217 @@
+package docx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// style is a paragraph or character style from word/styles.xml
+type style struct {
+	NamedStyleType string // the google docs equivalent, such as HEADING_1
+	Default        bool   // whether this is the default style for its type
+	BasedOn        string // ID of the parent style
+	ParagraphProps *node  // the w:pPr element, or nil
+	RunProps       *node  // the w:rPr element, or nil
+}
+
+// loadStyles reads paragraph and character styles
+func (c *converter) loadStyles(name string) error {
+	root, err := c.parsePart(name)
+	if err != nil || root == nil {
+		return err
+	}
+
+	for _, s := range root.Children {
+		if s.Name.Local != "style" {
+			continue
+		}
+		c.styles[s.attr("styleId")] = &style{
+			NamedStyleType: namedStyleType(s.child("name").attr("val")),
+			Default:        s.attr("default") == "1",
+			BasedOn:        s.child("basedOn").attr("val"),
+			ParagraphProps: s.child("pPr"),
+			RunProps:       s.child("rPr"),
+		}
+	}
+	return nil
+}
+
+// namedStyleType maps a word style name to the corresponding google docs named style
+func namedStyleType(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "title":
+		return "TITLE"
+	case "subtitle":
+		return "SUBTITLE"
+	}
+	if strings.HasPrefix(name, "heading ") {
+		level, err := strconv.Atoi(strings.TrimPrefix(name, "heading "))
+		if err == nil && level >= 1 && level <= 6 {
+			return fmt.Sprintf("HEADING_%d", level)
+		}
+	}
+	return ""
+}
+
+// styleChain gets a style and all of its ancestors, starting with the most distant ancestor
+func (c *converter) styleChain(id string) []*style {
+	var chain []*style
+	seen := make(map[string]bool)
+	for id != "" && !seen[id] {
+		seen[id] = true
+		s, ok := c.styles[id]
+		if !ok {
+			break
+		}
+		chain = append([]*style{s}, chain...)
+		id = s.BasedOn
+	}
+	return chain
+}
+
+// paragraphNamedStyle determines the named style type for a paragraph style ID
+func (c *converter) paragraphNamedStyle(id string) string {
+	chain := c.styleChain(id)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].NamedStyleType != "" {
+			return chain[i].NamedStyleType
+		}
+	}
+	return "NORMAL_TEXT"
+}
+
+// textStyle computes the text style for a run by applying the run
+// properties from each of the given style IDs, then the run's own
+// properties. As in google docs, the result only contains properties that
+// differ from the default style, so default styles are skipped.
+func (c *converter) textStyle(rPr *node, styleIDs ...string) *docs.TextStyle {
+	var ts docs.TextStyle
+	for _, id := range styleIDs {
+		for _, s := range c.styleChain(id) {
+			if !s.Default {
+				applyRunProps(&ts, s.RunProps)
+			}
+		}
+	}
+	applyRunProps(&ts, rPr)
+	return &ts
+}
+
+// applyRunProps applies the properties in a w:rPr element to a text style
+func applyRunProps(ts *docs.TextStyle, rPr *node) {
+	if rPr == nil {
+		return
+	}
+
+	for _, p := range rPr.Children {
+		switch p.Name.Local {
+		case "b":
+			ts.Bold = p.flag()
+		case "i":
+			ts.Italic = p.flag()
+		case "strike", "dstrike":
+			ts.Strikethrough = p.flag()
+		case "u":
+			ts.Underline = p.attr("val") != "none"
+		case "smallCaps":
+			ts.SmallCaps = p.flag()
+		case "vertAlign":
+			switch p.attr("val") {
+			case "superscript":
+				ts.BaselineOffset = "SUPERSCRIPT"
+			case "subscript":
+				ts.BaselineOffset = "SUBSCRIPT"
+			default:
+				ts.BaselineOffset = ""
+			}
+		case "rFonts":
+			font := p.attr("ascii")
+			if font == "" {
+				font = p.attr("hAnsi")
+			}
+			if font != "" {
+				ts.WeightedFontFamily = &docs.WeightedFontFamily{FontFamily: font, Weight: 400}
+			}
+		case "sz":
+			// sizes are given in half-points
+			if halfpts, err := strconv.Atoi(p.attr("val")); err == nil {
+				ts.FontSize = &docs.Dimension{Magnitude: float64(halfpts) / 2, Unit: "PT"}
+			}
+		case "color":
+			if color := parseHexColor(p.attr("val")); color != nil {
+				ts.ForegroundColor = color
+			}
+		case "highlight":
+			if color := parseHexColor(highlightColors[p.attr("val")]); color != nil {
+				ts.BackgroundColor = color
+			}
+		case "shd":
+			if color := parseHexColor(p.attr("fill")); color != nil {
+				ts.BackgroundColor = color
+			}
+		}
+	}
+}
+
+// highlightColors maps the named highlight colors in word to hex values
+var highlightColors = map[string]string{
+	"black":       "000000",
+	"blue":        "0000FF",
+	"cyan":        "00FFFF",
+	"darkBlue":    "000080",
+	"darkCyan":    "008080",
+	"darkGray":    "808080",
+	"darkGreen":   "008000",
+	"darkMagenta": "800080",
+	"darkRed":     "800000",
+	"darkYellow":  "808000",
+	"green":       "00FF00",
+	"lightGray":   "C0C0C0",
+	"magenta":     "FF00FF",
+	"red":         "FF0000",
+	"white":       "FFFFFF",
+	"yellow":      "FFFF00",
+}
+
+// parseHexColor parses a color like "FF0000" into a google docs color, or
+// returns nil for "auto" and other values that are not explicit colors
+func parseHexColor(s string) *docs.OptionalColor {
+	if len(s) != 6 {
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil
+	}
+	return &docs.OptionalColor{
+		Color: &docs.Color{
+			RgbColor: &docs.RgbColor{
+				Red:   float64((v>>16)&0xff) / 255,
+				Green: float64((v>>8)&0xff) / 255,
+				Blue:  float64(v&0xff) / 255,
+			},
+		},
+	}
+}
+
+// twipsToPoints converts a measurement in twentieths of a point to a google docs dimension
+func twipsToPoints(s string) *docs.Dimension {
+	twips, err := strconv.Atoi(s)
+	if err != nil {
+		return nil
+	}
+	return &docs.Dimension{Magnitude: float64(twips) / 20, Unit: "PT"}
+}
+
+// emuToPoints converts a measurement in English Metric Units to a google docs dimension
+func emuToPoints(s string) *docs.Dimension {
+	emu, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &docs.Dimension{Magnitude: float64(emu) / 12700, Unit: "PT"}
+}
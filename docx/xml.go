@@ -0,0 +1,130 @@
+package docx
+
+// This file contains a minimal generic XML tree, which is easier to walk than
+// struct-based unmarshalling for the mixed content found in word documents
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// namespace for relationship attributes such as r:id and r:embed
+const relationshipNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+// node is an element in an XML document
+type node struct {
+	Name     xml.Name
+	Attr     []xml.Attr
+	Children []*node
+	Text     string // character data directly inside this element
+}
+
+// parseXML reads an XML document into a tree of nodes
+func parseXML(r io.Reader) (*node, error) {
+	dec := xml.NewDecoder(r)
+
+	var root *node
+	var stack []*node
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding xml: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &node{Name: tok.Name, Attr: tok.Copy().Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(tok)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("xml document contained no elements")
+	}
+	return root, nil
+}
+
+// attr gets the value of an attribute by its local name, or the empty string
+func (n *node) attr(local string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// relAttr gets the value of an attribute in the relationships namespace, such as r:id
+func (n *node) relAttr(local string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.Attr {
+		if a.Name.Local == local && a.Name.Space == relationshipNamespace {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child gets the first child with the given local name, or nil
+func (n *node) child(local string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Children {
+		if c.Name.Local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+// find does a depth-first search for the first descendant with the given local name
+func (n *node) find(local string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Children {
+		if c.Name.Local == local {
+			return c
+		}
+		if found := c.find(local); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// flag interprets an element such as <w:b/> or <w:b w:val="false"/> as a boolean
+func (n *node) flag() bool {
+	if n == nil {
+		return false
+	}
+	switch n.attr("val") {
+	case "false", "0", "off", "none":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,300 @@
+// Package epub writes EPUB 3 books from chapters of XHTML
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// Book is an EPUB book
+type Book struct {
+	Title       string
+	Subtitle    string
+	Creators    []string
+	Subjects    []string
+	Description string
+	Language    string    // BCP 47 language tag, such as "en"
+	Identifier  string    // unique identifier for the book, such as a URL or urn:uuid
+	Modified    time.Time // time of last modification, which defaults to now
+	Stylesheet  string    // CSS for every chapter
+	Cover       *Image    // cover image, which may be nil
+	Chapters    []*Chapter
+	Images      []*Image
+}
+
+// Chapter is a single XHTML file in the book
+type Chapter struct {
+	Filename string // path within the book, such as "chapter1.xhtml"
+	Title    string
+	Content  string     // XHTML fragment for the body of the page
+	Headings []*Heading // headings within the chapter, for the table of contents
+}
+
+// Heading is an entry in the table of contents within a chapter
+type Heading struct {
+	Level int    // 1 for the top level
+	Title string // plain text
+	ID    string // id attribute of the heading within its chapter
+}
+
+// Image is an image in the book
+type Image struct {
+	Filename string // path within the book, such as "images/image1.png"
+	Content  []byte
+}
+
+// mediaType gets the media type of a file in the book from its extension
+func mediaType(filename string) string {
+	ext := strings.ToLower(path.Ext(filename))
+	switch ext {
+	case ".xhtml":
+		return "application/xhtml+xml"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".svg":
+		return "image/svg+xml"
+	}
+	t := mime.TypeByExtension(ext)
+	if t == "" {
+		return "application/octet-stream"
+	}
+	return strings.Split(t, ";")[0]
+}
+
+// Write writes the book as an EPUB file
+func (b *Book) Write(w io.Writer) error {
+	if b.Title == "" {
+		return errors.New("the book has no title")
+	}
+	if b.Identifier == "" {
+		return errors.New("the book has no identifier")
+	}
+	if len(b.Chapters) == 0 {
+		return errors.New("the book has no chapters")
+	}
+
+	seen := map[string]bool{"nav.xhtml": true, "style.css": true, "cover.xhtml": true}
+	for _, c := range b.Chapters {
+		if seen[c.Filename] {
+			return fmt.Errorf("more than one file in the book is named %s", c.Filename)
+		}
+		seen[c.Filename] = true
+	}
+
+	z := zip.NewWriter(w)
+
+	// the mimetype file must come first and must not be compressed
+	f, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return fmt.Errorf("error writing epub: %w", err)
+	}
+	_, err = io.WriteString(f, "application/epub+zip")
+	if err != nil {
+		return fmt.Errorf("error writing epub: %w", err)
+	}
+
+	files := []file{
+		{"META-INF/container.xml", []byte(containerXML)},
+		{"OEBPS/content.opf", b.packageDocument()},
+		{"OEBPS/nav.xhtml", b.navDocument()},
+		{"OEBPS/style.css", []byte(b.Stylesheet)},
+	}
+	if b.Cover != nil {
+		files = append(files, file{"OEBPS/cover.xhtml", b.coverPage()})
+	}
+	for _, c := range b.Chapters {
+		files = append(files, file{"OEBPS/" + c.Filename, b.chapterPage(c)})
+	}
+	images := b.Images
+	if b.Cover != nil {
+		images = append([]*Image{b.Cover}, images...)
+	}
+	for _, image := range images {
+		files = append(files, file{"OEBPS/" + image.Filename, image.Content})
+	}
+
+	for _, file := range files {
+		f, err := z.Create(file.name)
+		if err != nil {
+			return fmt.Errorf("error writing %s to epub: %w", file.name, err)
+		}
+		_, err = f.Write(file.content)
+		if err != nil {
+			return fmt.Errorf("error writing %s to epub: %w", file.name, err)
+		}
+	}
+
+	err = z.Close()
+	if err != nil {
+		return fmt.Errorf("error writing epub: %w", err)
+	}
+	return nil
+}
+
+// file is a file within the zip archive
+type file struct {
+	name    string
+	content []byte
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// packageDocument writes the metadata, manifest, and reading order
+func (b *Book) packageDocument() []byte {
+	esc := html.EscapeString
+	language := b.Language
+	if language == "" {
+		language = "en"
+	}
+	modified := b.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+
+	var out bytes.Buffer
+	fmt.Fprint(&out, `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="`+esc(language)+`">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&out, "    <dc:identifier id=\"book-id\">%s</dc:identifier>\n", esc(b.Identifier))
+	fmt.Fprintf(&out, "    <dc:title id=\"title\">%s</dc:title>\n", esc(b.Title))
+	fmt.Fprint(&out, "    <meta refines=\"#title\" property=\"title-type\">main</meta>\n")
+	if b.Subtitle != "" {
+		fmt.Fprintf(&out, "    <dc:title id=\"subtitle\">%s</dc:title>\n", esc(b.Subtitle))
+		fmt.Fprint(&out, "    <meta refines=\"#subtitle\" property=\"title-type\">subtitle</meta>\n")
+	}
+	fmt.Fprintf(&out, "    <dc:language>%s</dc:language>\n", esc(language))
+	for _, creator := range b.Creators {
+		fmt.Fprintf(&out, "    <dc:creator>%s</dc:creator>\n", esc(creator))
+	}
+	for _, subject := range b.Subjects {
+		fmt.Fprintf(&out, "    <dc:subject>%s</dc:subject>\n", esc(subject))
+	}
+	if b.Description != "" {
+		fmt.Fprintf(&out, "    <dc:description>%s</dc:description>\n", esc(b.Description))
+	}
+	fmt.Fprintf(&out, "    <meta property=\"dcterms:modified\">%s</meta>\n", modified.UTC().Format("2006-01-02T15:04:05Z"))
+	if b.Cover != nil {
+		fmt.Fprint(&out, "    <meta name=\"cover\" content=\"cover-image\"/>\n")
+	}
+	fmt.Fprint(&out, "  </metadata>\n  <manifest>\n")
+
+	fmt.Fprint(&out, "    <item id=\"nav\" href=\"nav.xhtml\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n")
+	fmt.Fprint(&out, "    <item id=\"style\" href=\"style.css\" media-type=\"text/css\"/>\n")
+	if b.Cover != nil {
+		fmt.Fprintf(&out, "    <item id=\"cover-image\" href=\"%s\" media-type=\"%s\" properties=\"cover-image\"/>\n", esc(b.Cover.Filename), mediaType(b.Cover.Filename))
+		fmt.Fprint(&out, "    <item id=\"cover\" href=\"cover.xhtml\" media-type=\"application/xhtml+xml\"/>\n")
+	}
+	for i, c := range b.Chapters {
+		var properties string
+		if strings.Contains(c.Content, "<math") {
+			properties = ` properties="mathml"`
+		}
+		fmt.Fprintf(&out, "    <item id=\"chapter-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"%s/>\n", i+1, esc(c.Filename), properties)
+	}
+	for i, image := range b.Images {
+		fmt.Fprintf(&out, "    <item id=\"image-%d\" href=\"%s\" media-type=\"%s\"/>\n", i+1, esc(image.Filename), mediaType(image.Filename))
+	}
+
+	fmt.Fprint(&out, "  </manifest>\n  <spine>\n")
+	if b.Cover != nil {
+		fmt.Fprint(&out, "    <itemref idref=\"cover\"/>\n")
+	}
+	for i := range b.Chapters {
+		fmt.Fprintf(&out, "    <itemref idref=\"chapter-%d\"/>\n", i+1)
+	}
+	fmt.Fprint(&out, "  </spine>\n</package>\n")
+	return out.Bytes()
+}
+
+// page wraps the body of a page in an XHTML document
+func (b *Book) page(title, body string) []byte {
+	var out bytes.Buffer
+	fmt.Fprint(&out, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+<meta charset="utf-8"/>
+`)
+	fmt.Fprintf(&out, "<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprint(&out, "<link rel=\"stylesheet\" type=\"text/css\" href=\"style.css\"/>\n</head>\n<body>\n")
+	fmt.Fprint(&out, body)
+	fmt.Fprint(&out, "</body>\n</html>\n")
+	return out.Bytes()
+}
+
+// chapterPage writes a chapter as an XHTML document
+func (b *Book) chapterPage(c *Chapter) []byte {
+	title := c.Title
+	if title == "" {
+		title = b.Title
+	}
+	return b.page(title, c.Content)
+}
+
+// coverPage writes a page that shows the cover image
+func (b *Book) coverPage() []byte {
+	body := fmt.Sprintf("<section epub:type=\"cover\" class=\"cover\">\n<img src=\"%s\" alt=\"%s\"/>\n</section>\n",
+		html.EscapeString(b.Cover.Filename), html.EscapeString(b.Title))
+	return b.page(b.Title, body)
+}
+
+// navDocument writes the table of contents, with an entry for each chapter
+// and nested entries for the headings within each chapter
+func (b *Book) navDocument() []byte {
+	var body strings.Builder
+	body.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<h1>Contents</h1>\n<ol>\n")
+	for _, c := range b.Chapters {
+		title := c.Title
+		if title == "" {
+			title = b.Title
+		}
+		fmt.Fprintf(&body, "<li><a href=\"%s\">%s</a>", html.EscapeString(c.Filename), html.EscapeString(title))
+		writeHeadings(&body, c.Filename, c.Headings)
+		body.WriteString("</li>\n")
+	}
+	body.WriteString("</ol>\n</nav>\n")
+	return b.page(b.Title, body.String())
+}
+
+// writeHeadings writes nested lists of links to headings. A heading that is
+// more than one level below the one before it is nested only one level
+// deeper, and a heading above the first one is treated as its sibling.
+func writeHeadings(out *strings.Builder, filename string, headings []*Heading) {
+	if len(headings) == 0 {
+		return
+	}
+
+	var levels []int // levels of the open lists
+	for _, h := range headings {
+		for len(levels) > 1 && levels[len(levels)-1] > h.Level {
+			out.WriteString("</li>\n</ol>\n")
+			levels = levels[:len(levels)-1]
+		}
+		if n := len(levels); n > 0 && levels[n-1] >= h.Level {
+			out.WriteString("</li>\n")
+			levels[n-1] = h.Level
+		} else {
+			out.WriteString("\n<ol>\n")
+			levels = append(levels, h.Level)
+		}
+		fmt.Fprintf(out, "<li><a href=\"%s#%s\">%s</a>", html.EscapeString(filename), html.EscapeString(h.ID), html.EscapeString(h.Title))
+	}
+	for range levels {
+		out.WriteString("</li>\n</ol>\n")
+	}
+}
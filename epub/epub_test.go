@@ -0,0 +1,88 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	book := Book{
+		Title:      "Essays & Notes",
+		Creators:   []string{"A. Writer"},
+		Identifier: "urn:uuid:00000000-0000-5000-8000-000000000000",
+		Modified:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Cover:      &Image{Filename: "cover.png", Content: []byte("png")},
+		Chapters: []*Chapter{
+			{Filename: "chapter1.xhtml", Title: "One", Content: "<p>x<br/></p>\n", Headings: []*Heading{
+				{Level: 2, Title: "A", ID: "a"},
+				{Level: 3, Title: "B", ID: "b"},
+				{Level: 1, Title: "C", ID: "c"},
+			}},
+			{Filename: "chapter2.xhtml", Title: "Two", Content: "<p><math xmlns=\"http://www.w3.org/1998/Math/MathML\"><mi>x</mi></math></p>\n"},
+		},
+		Images: []*Image{{Filename: "doc1/images/image1.jpg", Content: []byte("jpg")}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, book.Write(&buf))
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	// the mimetype must be the first file and must not be compressed
+	require.NotEmpty(t, z.File)
+	assert.Equal(t, "mimetype", z.File[0].Name)
+	assert.Equal(t, zip.Store, z.File[0].Method)
+
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		files[f.Name] = string(content)
+	}
+	assert.Contains(t, files, "OEBPS/cover.xhtml")
+	assert.Contains(t, files, "OEBPS/cover.png")
+	assert.Contains(t, files, "OEBPS/doc1/images/image1.jpg")
+
+	// every xml file must be well-formed
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/cover.xhtml", "OEBPS/chapter1.xhtml", "OEBPS/chapter2.xhtml"} {
+		dec := xml.NewDecoder(bytes.NewReader([]byte(files[name])))
+		dec.Strict = true
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err, name)
+		}
+	}
+
+	opf := files["OEBPS/content.opf"]
+	assert.Contains(t, opf, `<dc:title id="title">Essays &amp; Notes</dc:title>`)
+	assert.Contains(t, opf, `<meta property="dcterms:modified">2020-01-02T03:04:05Z</meta>`)
+	assert.Contains(t, opf, `href="cover.png" media-type="image/png" properties="cover-image"`)
+	assert.Contains(t, opf, `href="chapter2.xhtml" media-type="application/xhtml+xml" properties="mathml"`)
+	assert.Contains(t, opf, `href="doc1/images/image1.jpg" media-type="image/jpeg"`)
+
+	assert.Contains(t, files["OEBPS/nav.xhtml"], `<li><a href="chapter1.xhtml">One</a>
+<ol>
+<li><a href="chapter1.xhtml#a">A</a>
+<ol>
+<li><a href="chapter1.xhtml#b">B</a></li>
+</ol>
+</li>
+<li><a href="chapter1.xhtml#c">C</a></li>
+</ol>
+</li>
+<li><a href="chapter2.xhtml">Two</a></li>`)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"fmt"
 	"os"
+	"time"
 
 	"google.golang.org/api/docs/v1"
 )
@@ -15,6 +16,21 @@ type Archive struct {
 	Doc    *docs.Document
 	HTML   []byte   // html export of the google doc
 	Images []*Image // images from the html-exported google doc
+
+	// Comments are only present if they were requested when fetching
+	Comments []*Comment
+
+	// Charts holds the data behind linked charts, keyed by inline object
+	// ID, and is only present if it was requested when fetching
+	Charts map[string]*Chart
+
+	// ModifiedTime is when the document was last modified according to
+	// google drive. It is zero in archives fetched before it was recorded.
+	ModifiedTime time.Time
+
+	// Suggestions is how suggested edits were requested to be dealt with
+	// when fetching. The document itself always has suggestions inline.
+	Suggestions string
 }
 
 // Image represents an image in the HTML export of a google doc
@@ -0,0 +1,182 @@
+package googledoc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+	"google.golang.org/api/sheets/v4"
+)
+
+// Chart is the data behind a chart that is linked from a google sheet
+type Chart struct {
+	Title string
+	URL   string     // link to the spreadsheet containing the chart
+	Rows  [][]string // formatted cell values from the chart's source range
+}
+
+// ChartURL gets the URL of the spreadsheet that a linked chart comes from,
+// or an empty string if the linked content is not a chart
+func ChartURL(ref *docs.LinkedContentReference) string {
+	if ref == nil || ref.SheetsChartReference == nil {
+		return ""
+	}
+	return fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s/edit", ref.SheetsChartReference.SpreadsheetId)
+}
+
+// ChartTitle gets the text for a link to the source of a chart
+func ChartTitle(title string) string {
+	if title == "" {
+		return "Source data"
+	}
+	return title
+}
+
+// chartFields are the parts of a spreadsheet needed to find chart data
+const chartFields = "sheets(charts(chartId,spec(title,basicChart(domains,series),pieChart(domain,series))))"
+
+// FetchCharts fetches the source data for each chart that is linked from a
+// google doc, keyed by inline object ID
+func FetchCharts(ctx context.Context, doc *docs.Document, sheetsClient *sheets.Service) (map[string]*Chart, error) {
+	// visit objects in a fixed order so that errors are reproducible
+	var ids []string
+	for id := range doc.InlineObjects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	charts := make(map[string]*Chart)
+	specs := make(map[string]map[int64]*sheets.ChartSpec) // keyed by spreadsheet ID then chart ID
+	for _, id := range ids {
+		props := doc.InlineObjects[id].InlineObjectProperties
+		if props == nil || props.EmbeddedObject == nil || props.EmbeddedObject.LinkedContentReference == nil {
+			continue
+		}
+		ref := props.EmbeddedObject.LinkedContentReference.SheetsChartReference
+		if ref == nil {
+			continue
+		}
+
+		// fetch the specs for all charts in each spreadsheet just once
+		if _, ok := specs[ref.SpreadsheetId]; !ok {
+			ss, err := sheetsClient.Spreadsheets.Get(ref.SpreadsheetId).Fields(chartFields).Context(ctx).Do()
+			if err != nil {
+				return nil, fmt.Errorf("error fetching charts from spreadsheet %s: %w", ref.SpreadsheetId, err)
+			}
+			specs[ref.SpreadsheetId] = make(map[int64]*sheets.ChartSpec)
+			for _, sheet := range ss.Sheets {
+				for _, c := range sheet.Charts {
+					specs[ref.SpreadsheetId][c.ChartId] = c.Spec
+				}
+			}
+		}
+
+		spec, ok := specs[ref.SpreadsheetId][ref.ChartId]
+		if !ok || spec == nil {
+			return nil, fmt.Errorf("chart %d not found in spreadsheet %s", ref.ChartId, ref.SpreadsheetId)
+		}
+
+		chart := Chart{
+			Title: spec.Title,
+			URL:   ChartURL(props.EmbeddedObject.LinkedContentReference),
+		}
+		if chart.Title == "" {
+			chart.Title = props.EmbeddedObject.Title
+		}
+
+		if r := boundingRange(chartRanges(spec)); r != nil {
+			rows, err := fetchRange(ctx, sheetsClient, ref.SpreadsheetId, r)
+			if err != nil {
+				return nil, fmt.Errorf("error fetching data for chart %d: %w", ref.ChartId, err)
+			}
+			chart.Rows = rows
+		}
+		charts[id] = &chart
+	}
+	return charts, nil
+}
+
+// chartRanges gets the ranges that a chart takes its data from
+func chartRanges(spec *sheets.ChartSpec) []*sheets.GridRange {
+	var data []*sheets.ChartData
+	if spec.BasicChart != nil {
+		for _, d := range spec.BasicChart.Domains {
+			data = append(data, d.Domain)
+		}
+		for _, s := range spec.BasicChart.Series {
+			data = append(data, s.Series)
+		}
+	}
+	if spec.PieChart != nil {
+		data = append(data, spec.PieChart.Domain, spec.PieChart.Series)
+	}
+
+	var ranges []*sheets.GridRange
+	for _, d := range data {
+		if d != nil && d.SourceRange != nil {
+			ranges = append(ranges, d.SourceRange.Sources...)
+		}
+	}
+	return ranges
+}
+
+// boundingRange gets the smallest range containing all the given ranges
+// that are on the same sheet as the first one, or nil if there are none.
+// Zero end indices mean that a range is unbounded.
+func boundingRange(ranges []*sheets.GridRange) *sheets.GridRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	box := *ranges[0]
+	for _, r := range ranges[1:] {
+		if r.SheetId != box.SheetId {
+			continue
+		}
+		if r.StartRowIndex < box.StartRowIndex {
+			box.StartRowIndex = r.StartRowIndex
+		}
+		if r.StartColumnIndex < box.StartColumnIndex {
+			box.StartColumnIndex = r.StartColumnIndex
+		}
+		if r.EndRowIndex == 0 || (box.EndRowIndex != 0 && r.EndRowIndex > box.EndRowIndex) {
+			box.EndRowIndex = r.EndRowIndex
+		}
+		if r.EndColumnIndex == 0 || (box.EndColumnIndex != 0 && r.EndColumnIndex > box.EndColumnIndex) {
+			box.EndColumnIndex = r.EndColumnIndex
+		}
+	}
+	return &box
+}
+
+// fetchRange fetches the formatted values of the cells in a range,
+// dropping trailing empty rows
+func fetchRange(ctx context.Context, sheetsClient *sheets.Service, spreadsheetID string, r *sheets.GridRange) ([][]string, error) {
+	req := sheets.GetSpreadsheetByDataFilterRequest{
+		DataFilters:     []*sheets.DataFilter{{GridRange: r}},
+		IncludeGridData: true,
+	}
+	ss, err := sheetsClient.Spreadsheets.GetByDataFilter(spreadsheetID, &req).
+		Fields("sheets(data(rowData(values(formattedValue))))").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, sheet := range ss.Sheets {
+		for _, data := range sheet.Data {
+			for _, rowData := range data.RowData {
+				var row []string
+				for _, v := range rowData.Values {
+					row = append(row, v.FormattedValue)
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	for len(rows) > 0 && strings.Join(rows[len(rows)-1], "") == "" {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
@@ -0,0 +1,26 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestChartRanges(t *testing.T) {
+	source := func(r *sheets.GridRange) *sheets.ChartData {
+		return &sheets.ChartData{SourceRange: &sheets.ChartSourceRange{Sources: []*sheets.GridRange{r}}}
+	}
+	spec := sheets.ChartSpec{BasicChart: &sheets.BasicChartSpec{
+		Domains: []*sheets.BasicChartDomain{{Domain: source(&sheets.GridRange{SheetId: 1, StartRowIndex: 1, EndRowIndex: 5, EndColumnIndex: 1})}},
+		Series: []*sheets.BasicChartSeries{
+			{Series: source(&sheets.GridRange{SheetId: 1, StartColumnIndex: 2, EndRowIndex: 4, EndColumnIndex: 3})},
+			{Series: source(&sheets.GridRange{SheetId: 2, StartColumnIndex: 9, EndColumnIndex: 10})},
+		},
+	}}
+
+	ranges := chartRanges(&spec)
+	assert.Len(t, ranges, 3)
+	assert.Equal(t, &sheets.GridRange{SheetId: 1, EndRowIndex: 5, EndColumnIndex: 3}, boundingRange(ranges))
+	assert.Nil(t, boundingRange(nil))
+}
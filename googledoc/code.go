@@ -0,0 +1,131 @@
+package googledoc
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// IsCode determines whether a paragraph is a line of a code block, which
+// is the case when it is normal text and every run is in a monospace font
+func IsCode(p *docs.Paragraph) bool {
+	return (*StyleRules)(nil).IsCode(p)
+}
+
+// IsCode is like the IsCode function but also recognizes the extra
+// monospace fonts in the rules. Rules may be nil.
+func (rules *StyleRules) IsCode(p *docs.Paragraph) bool {
+	if p.ParagraphStyle == nil || p.ParagraphStyle.NamedStyleType != "NORMAL_TEXT" || len(p.Elements) == 0 {
+		return false
+	}
+	for _, el := range p.Elements {
+		if el.TextRun == nil || el.TextRun.TextStyle == nil || !rules.IsMonospace(el.TextRun.TextStyle.WeightedFontFamily) {
+			return false
+		}
+	}
+	return true
+}
+
+// TableCode gets the text of a table that stands in for a code block, which
+// is a table with a single cell containing only monospace text. The second
+// return value is false for other tables.
+func TableCode(t *docs.Table) (string, bool) {
+	return (*StyleRules)(nil).TableCode(t)
+}
+
+// TableCode is like the TableCode function but also recognizes the extra
+// monospace fonts in the rules. Rules may be nil.
+func (rules *StyleRules) TableCode(t *docs.Table) (string, bool) {
+	if len(t.TableRows) != 1 || len(t.TableRows[0].TableCells) != 1 {
+		return "", false
+	}
+
+	var b strings.Builder
+	for _, elem := range t.TableRows[0].TableCells[0].Content {
+		if elem.Paragraph == nil || !rules.IsCode(elem.Paragraph) {
+			return "", false
+		}
+		for _, el := range elem.Paragraph.Elements {
+			b.WriteString(el.TextRun.Content)
+		}
+	}
+	return b.String(), true
+}
+
+// a regular expression for a first line like "lang: go" that gives the
+// language of a code block
+var langRegexp = regexp.MustCompile(`^\s*(?:lang|language)\s*:\s*([\w+#.-]+)\s*$`)
+
+// interpreters that appear in shebang lines, mapped to language names
+var interpreters = map[string]string{
+	"sh":      "sh",
+	"bash":    "bash",
+	"zsh":     "zsh",
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"node":    "javascript",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"Rscript": "r",
+}
+
+// heuristics for guessing the language of a code block, tried in order
+var languageHeuristics = []struct {
+	lang    string
+	pattern *regexp.Regexp
+}{
+	{"go", regexp.MustCompile(`(?m)^package \w+$|^func (\(\w+ \*?\w+\) )?\w+\(|^\s*\w+(, \w+)* := `)},
+	{"rust", regexp.MustCompile(`(?m)^\s*(pub )?fn \w+|let mut `)},
+	{"c", regexp.MustCompile(`(?m)^#include [<"]`)},
+	{"java", regexp.MustCompile(`(?m)^\s*public (static )?(class|void) `)},
+	{"python", regexp.MustCompile(`(?m)^\s*(def \w+\(.*\):|class \w+(\(.*\))?:|from [\w.]+ import |import \w+$)`)},
+	{"javascript", regexp.MustCompile(`(?m)^\s*(const|let|var) \w+ = |function \w*\(|=> \{|console\.log\(`)},
+	{"sql", regexp.MustCompile(`(?im)^\s*(select .+ from |insert into |create table |update \w+ set )`)},
+	{"html", regexp.MustCompile(`(?i)^\s*<(!doctype|html|div|p|span|body|head)\b`)},
+	{"console", regexp.MustCompile(`^\$ `)},
+}
+
+// CodeLanguage determines the language of a code block. A first line like
+// "lang: go" gives the language explicitly and is removed from the code.
+// Otherwise the language is taken from a shebang line or guessed from the
+// content. The language is empty if it could not be determined.
+func CodeLanguage(code string) (lang string, rest string) {
+	first := code
+	if i := strings.IndexAny(code, "\n\v"); i >= 0 {
+		first = code[:i]
+		rest = code[i+1:]
+	}
+	if m := langRegexp.FindStringSubmatch(first); m != nil {
+		return strings.ToLower(m[1]), rest
+	}
+
+	// shebang lines like #!/usr/bin/env python3 or #!/bin/bash
+	if strings.HasPrefix(first, "#!") {
+		fields := strings.Fields(strings.TrimPrefix(first, "#!"))
+		if len(fields) > 0 {
+			interpreter := path.Base(fields[0])
+			if interpreter == "env" && len(fields) > 1 {
+				interpreter = fields[1]
+			}
+			if lang, ok := interpreters[interpreter]; ok {
+				return lang, code
+			}
+		}
+	}
+
+	trimmed := strings.TrimSpace(code)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return "json", code
+	}
+	for _, h := range languageHeuristics {
+		if h.pattern.MatchString(code) {
+			return h.lang, code
+		}
+	}
+	return "", code
+}
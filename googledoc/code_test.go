@@ -0,0 +1,52 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/docs/v1"
+)
+
+func TestCodeLanguage(t *testing.T) {
+	for _, c := range []struct {
+		code string
+		lang string
+		rest string
+	}{
+		{"lang: Go\nx := 1\n", "go", "x := 1\n"},
+		{"#!/usr/bin/env python3\nprint(1)\n", "python", "#!/usr/bin/env python3\nprint(1)\n"},
+		{"#!/bin/bash\necho hi\n", "bash", "#!/bin/bash\necho hi\n"},
+		{"package main\n\nfunc main() {}\n", "go", "package main\n\nfunc main() {}\n"},
+		{"x, err := f()\n", "go", "x, err := f()\n"},
+		{"def f(x):\n    return x\n", "python", "def f(x):\n    return x\n"},
+		{"if (n := len(a)) > 10:\n    print(n)\n", "", "if (n := len(a)) > 10:\n    print(n)\n"},
+		{"while chunk := f.read():\n    pass\n", "", "while chunk := f.read():\n    pass\n"},
+		{"{\"a\": [1, 2]}\n", "json", "{\"a\": [1, 2]}\n"},
+		{"SELECT name FROM users;\n", "sql", "SELECT name FROM users;\n"},
+		{"hello world\n", "", "hello world\n"},
+	} {
+		lang, rest := CodeLanguage(c.code)
+		assert.Equal(t, c.lang, lang, c.code)
+		assert.Equal(t, c.rest, rest, c.code)
+	}
+}
+
+func TestTableCode(t *testing.T) {
+	mono := &docs.TextStyle{WeightedFontFamily: &docs.WeightedFontFamily{FontFamily: "Fira Code"}}
+	cell := &docs.TableCell{Content: []*docs.StructuralElement{
+		doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "x = 1\n", TextStyle: mono}),
+		doctest.Paragraph(7, "NORMAL_TEXT", nil, &docs.TextRun{Content: "y = 2\n", TextStyle: mono}),
+	}}
+	code, ok := TableCode(&docs.Table{TableRows: []*docs.TableRow{{TableCells: []*docs.TableCell{cell}}}})
+	assert.True(t, ok)
+	assert.Equal(t, "x = 1\ny = 2\n", code)
+
+	_, ok = TableCode(&docs.Table{TableRows: []*docs.TableRow{{TableCells: []*docs.TableCell{cell, cell}}}})
+	assert.False(t, ok)
+
+	berkeley := &docs.WeightedFontFamily{FontFamily: "Berkeley Mono"}
+	rules := &StyleRules{Monospace: []string{" berkeley mono "}}
+	assert.True(t, rules.IsMonospace(berkeley))
+	assert.False(t, IsMonospace(berkeley))
+}
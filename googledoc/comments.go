@@ -0,0 +1,176 @@
+package googledoc
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf16"
+
+	"google.golang.org/api/docs/v1"
+	"google.golang.org/api/drive/v3"
+)
+
+// Comment is a comment on a google doc, together with its replies
+type Comment struct {
+	ID       string
+	Author   string
+	Content  string
+	Created  string
+	Resolved bool
+	Quote    string   // the text that the comment was attached to, if any
+	Replies  []*Reply // replies in the order they were made
+
+	// StartIndex and EndIndex give the range in the document body that the
+	// comment is anchored to. Both are zero if the quoted text could not be
+	// found, in which case the comment applies to the document as a whole.
+	StartIndex int64
+	EndIndex   int64
+}
+
+// Reply is a reply to a comment on a google doc
+type Reply struct {
+	Author  string
+	Content string
+	Created string
+}
+
+// Anchored determines whether a comment is attached to a range of text
+func (c *Comment) Anchored() bool {
+	return c.EndIndex > c.StartIndex
+}
+
+// commentFields are the fields requested from the comments API, which
+// returns nothing at all unless fields are specified
+const commentFields = "nextPageToken, comments(id, author(displayName), content, createdTime, deleted, resolved, quotedFileContent, replies(author(displayName), content, createdTime, deleted))"
+
+// FetchComments fetches the comments on a google doc and anchors them to
+// ranges of text in the document
+func FetchComments(ctx context.Context, docID string, doc *docs.Document, driveClient *drive.Service) ([]*Comment, error) {
+	var comments []*Comment
+	err := driveClient.Comments.List(docID).Fields(commentFields).Pages(ctx, func(page *drive.CommentList) error {
+		for _, c := range page.Comments {
+			if c.Deleted {
+				continue
+			}
+
+			comment := Comment{
+				ID:       c.Id,
+				Content:  c.Content,
+				Created:  c.CreatedTime,
+				Resolved: c.Resolved,
+			}
+			if c.Author != nil {
+				comment.Author = c.Author.DisplayName
+			}
+			if c.QuotedFileContent != nil {
+				comment.Quote = html.UnescapeString(c.QuotedFileContent.Value)
+			}
+
+			for _, r := range c.Replies {
+				if r.Deleted || r.Content == "" {
+					continue // replies that resolve or reopen a comment have no content
+				}
+				reply := Reply{Content: r.Content, Created: r.CreatedTime}
+				if r.Author != nil {
+					reply.Author = r.Author.DisplayName
+				}
+				comment.Replies = append(comment.Replies, &reply)
+			}
+
+			comments = append(comments, &comment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing comments: %w", err)
+	}
+
+	AnchorComments(doc, comments)
+	return comments, nil
+}
+
+// AnchorComments sets the start and end index of each comment by searching
+// for its quoted text in the document body. The anchors returned by the
+// drive API are opaque for google docs, so this is the only way to locate
+// comments.
+func AnchorComments(doc *docs.Document, comments []*Comment) {
+	var text []uint16
+	var indices []int64
+	var walk func([]*docs.StructuralElement)
+	walk = func(content []*docs.StructuralElement) {
+		for _, elem := range content {
+			switch {
+			case elem.Paragraph != nil:
+				for _, el := range elem.Paragraph.Elements {
+					if el.TextRun == nil {
+						continue
+					}
+					for i, u := range utf16.Encode([]rune(el.TextRun.Content)) {
+						text = append(text, u)
+						indices = append(indices, el.StartIndex+int64(i))
+					}
+				}
+			case elem.Table != nil:
+				for _, row := range elem.Table.TableRows {
+					for _, cell := range row.TableCells {
+						walk(cell.Content)
+					}
+				}
+			}
+		}
+	}
+	walk(doc.Body.Content)
+
+	for _, c := range comments {
+		c.StartIndex, c.EndIndex = 0, 0
+
+		// the quoted text never includes a final paragraph break that we could use
+		quote := utf16.Encode([]rune(strings.TrimRight(c.Quote, "\n")))
+		if len(quote) == 0 {
+			continue
+		}
+
+		if pos := indexUint16(text, quote); pos >= 0 {
+			c.StartIndex = indices[pos]
+			c.EndIndex = indices[pos+len(quote)-1] + 1
+		}
+	}
+}
+
+// indexUint16 finds the first occurrence of needle in haystack, or returns -1
+func indexUint16(haystack, needle []uint16) int {
+outer:
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				continue outer
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+// CommentsEndingIn gets the unresolved comments whose anchor ends within
+// the range (start, end]
+func CommentsEndingIn(comments []*Comment, start, end int64) []*Comment {
+	var out []*Comment
+	for _, c := range comments {
+		if !c.Resolved && c.Anchored() && c.EndIndex > start && c.EndIndex <= end {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// UnanchoredComments gets the unresolved comments that are not attached to any text
+func UnanchoredComments(comments []*Comment) []*Comment {
+	var out []*Comment
+	for _, c := range comments {
+		if !c.Resolved && !c.Anchored() {
+			out = append(out, c)
+		}
+	}
+	return out
+}
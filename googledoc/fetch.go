@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"time"
 
 	"google.golang.org/api/docs/v1"
 	"google.golang.org/api/drive/v3"
@@ -14,6 +15,24 @@ import (
 
 // Fetch fetches a google doc using Google's REST API
 func Fetch(ctx context.Context, docID string, docsClient *docs.Service, driveClient *drive.Service) (*Archive, error) {
+	return FetchWithSuggestions(ctx, docID, "", docsClient, driveClient)
+}
+
+// FetchWithSuggestions fetches a google doc and records that suggested edits
+// should be dealt with according to one of AcceptSuggestions,
+// RejectSuggestions, or MarkupSuggestions, or in the default way if
+// suggestions is empty. Suggestions are always fetched inline so that inline
+// objects still line up with the images in the HTML export; they are
+// accepted or rejected when the document is exported.
+func FetchWithSuggestions(ctx context.Context, docID string, suggestions string, docsClient *docs.Service, driveClient *drive.Service) (*Archive, error) {
+	viewMode, err := SuggestionsViewMode(suggestions)
+	if err != nil {
+		return nil, err
+	}
+	if suggestions != "" {
+		viewMode = "SUGGESTIONS_INLINE"
+	}
+
 	// export the document as a zip arcive
 	resp, err := driveClient.Files.Export(docID, "application/zip").Context(ctx).Download()
 	if err != nil {
@@ -31,7 +50,7 @@ func Fetch(ctx context.Context, docID string, docsClient *docs.Service, driveCli
 		return nil, fmt.Errorf("error decoding zip archive: %w", err)
 	}
 
-	var d Archive
+	d := Archive{Suggestions: suggestions}
 	for _, f := range ziprd.File {
 		if strings.HasSuffix(f.Name, ".html") {
 			r, err := f.Open()
@@ -67,8 +86,18 @@ func Fetch(ctx context.Context, docID string, docsClient *docs.Service, driveCli
 		return nil, fmt.Errorf("no html file found in downloaded zip archive")
 	}
 
+	// fetch the modification time, which is not part of the document itself
+	file, err := driveClient.Files.Get(docID).Fields("modifiedTime").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving file metadata: %w", err)
+	}
+	d.ModifiedTime, err = time.Parse(time.RFC3339, file.ModifiedTime)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing modification time %q: %w", file.ModifiedTime, err)
+	}
+
 	// fetch the document
-	d.Doc, err = docsClient.Documents.Get(docID).Context(ctx).Do()
+	d.Doc, err = docsClient.Documents.Get(docID).SuggestionsViewMode(viewMode).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving document: %w", err)
 	}
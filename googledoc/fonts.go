@@ -6,19 +6,46 @@ import (
 	"google.golang.org/api/docs/v1"
 )
 
-// determine whether a font is monospace (used for detecting code blocks)
+// monospaceFonts is the set of font families, in lower case, that are
+// treated as code in every document
+var monospaceFonts = map[string]bool{
+	"courier new":     true,
+	"consolas":        true,
+	"roboto mono":     true,
+	"source code pro": true,
+	"fira code":       true,
+	"fira mono":       true,
+	"jetbrains mono":  true,
+	"ibm plex mono":   true,
+	"inconsolata":     true,
+	"ubuntu mono":     true,
+	"space mono":      true,
+	"cousine":         true,
+}
+
+// determine whether a font is one of the common monospace fonts (used for
+// detecting code blocks)
 func IsMonospace(font *docs.WeightedFontFamily) bool {
+	return (*StyleRules)(nil).IsMonospace(font)
+}
+
+// IsMonospace determines whether a font is one of the common monospace
+// fonts or one of the extra monospace fonts in the rules. Rules may be nil.
+func (rules *StyleRules) IsMonospace(font *docs.WeightedFontFamily) bool {
 	if font == nil {
 		return false
 	}
-
-	switch strings.ToLower(font.FontFamily) {
-	case "courier new":
-		return true
-	case "consolas":
-		return true
-	case "roboto mono":
+	family := strings.ToLower(strings.TrimSpace(font.FontFamily))
+	if monospaceFonts[family] {
 		return true
 	}
+	if rules == nil {
+		return false
+	}
+	for _, f := range rules.Monospace {
+		if strings.ToLower(strings.TrimSpace(f)) == family {
+			return true
+		}
+	}
 	return false
 }
@@ -0,0 +1,96 @@
+package googledoc
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// ExtractTitle removes the first TITLE and SUBTITLE paragraphs from the
+// body of a document and returns their text. Either may be empty if the
+// document has no such paragraph.
+func ExtractTitle(doc *docs.Document) (title, subtitle string) {
+	var foundTitle, foundSubtitle bool
+	var out []*docs.StructuralElement
+	for _, elem := range doc.Body.Content {
+		if elem.Paragraph != nil {
+			switch elem.Paragraph.ParagraphStyle.NamedStyleType {
+			case "TITLE":
+				if !foundTitle {
+					foundTitle = true
+					title = strings.TrimSpace(ParagraphText(elem.Paragraph))
+					continue
+				}
+			case "SUBTITLE":
+				if !foundSubtitle {
+					foundSubtitle = true
+					subtitle = strings.TrimSpace(ParagraphText(elem.Paragraph))
+					continue
+				}
+			}
+		}
+		out = append(out, elem)
+	}
+	doc.Body.Content = out
+	return title, subtitle
+}
+
+// HeadingLevel gets the level of a heading style such as HEADING_2, or
+// zero if the style is not a heading
+func HeadingLevel(namedStyleType string) int {
+	var level int
+	if _, err := fmt.Sscanf(namedStyleType, "HEADING_%d", &level); err != nil || level < 1 || level > 6 {
+		return 0
+	}
+	return level
+}
+
+// NormalizeHeadings changes the levels of the headings in the body of a
+// document so that the highest level used becomes top, and no levels are
+// skipped. For example, a document that uses only HEADING_2 and HEADING_4
+// with top=2 ends up using HEADING_2 and HEADING_3. Levels deeper than
+// HEADING_6 become HEADING_6.
+func NormalizeHeadings(doc *docs.Document, top int) {
+	// find the distinct levels that are used
+	var used [7]bool
+	forEachHeading(doc.Body.Content, func(p *docs.Paragraph, level int) {
+		used[level] = true
+	})
+
+	// assign consecutive levels starting at top
+	var mapped [7]int
+	next := top
+	for level := 1; level <= 6; level++ {
+		if used[level] {
+			mapped[level] = next
+			next++
+		}
+	}
+
+	// a heading may be at most one level below the previous heading
+	prev := top - 1
+	forEachHeading(doc.Body.Content, func(p *docs.Paragraph, level int) {
+		level = mapped[level]
+		if level > prev+1 {
+			level = prev + 1
+		}
+		if level > 6 {
+			level = 6
+		}
+		prev = level
+		p.ParagraphStyle.NamedStyleType = fmt.Sprintf("HEADING_%d", level)
+	})
+}
+
+// forEachHeading calls fn for each heading paragraph that is not inside a table
+func forEachHeading(content []*docs.StructuralElement, fn func(p *docs.Paragraph, level int)) {
+	for _, elem := range content {
+		if elem.Paragraph == nil || elem.Paragraph.ParagraphStyle == nil {
+			continue
+		}
+		if level := HeadingLevel(elem.Paragraph.ParagraphStyle.NamedStyleType); level > 0 {
+			fn(elem.Paragraph, level)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/docs/v1"
+)
+
+func TestExtractTitle(t *testing.T) {
+	doc := docs.Document{Body: &docs.Body{Content: []*docs.StructuralElement{
+		doctest.Paragraph(0, "TITLE", nil, &docs.TextRun{Content: "The Title\n"}),
+		doctest.Paragraph(0, "SUBTITLE", nil, &docs.TextRun{Content: "The subtitle\n"}),
+		doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Body\n"}),
+	}}}
+
+	title, subtitle := ExtractTitle(&doc)
+	assert.Equal(t, "The Title", title)
+	assert.Equal(t, "The subtitle", subtitle)
+	assert.Len(t, doc.Body.Content, 1)
+}
+
+func TestNormalizeHeadings(t *testing.T) {
+	var content []*docs.StructuralElement
+	for _, style := range []string{"HEADING_1", "HEADING_3", "NORMAL_TEXT", "HEADING_3", "HEADING_1", "HEADING_5"} {
+		content = append(content, doctest.Paragraph(0, style, nil, &docs.TextRun{Content: "x\n"}))
+	}
+	doc := docs.Document{Body: &docs.Body{Content: content}}
+
+	NormalizeHeadings(&doc, 2)
+
+	var styles []string
+	for _, elem := range doc.Body.Content {
+		styles = append(styles, elem.Paragraph.ParagraphStyle.NamedStyleType)
+	}
+	assert.Equal(t, []string{"HEADING_2", "HEADING_3", "NORMAL_TEXT", "HEADING_3", "HEADING_2", "HEADING_3"}, styles)
+}
@@ -6,7 +6,8 @@ import (
 )
 
 // regular expression for finding image references in HTML-exported google docs
-var imageRegexp = regexp.MustCompile(`images\/image\d+\.(png|jpg)`)
+// (and in the HTML generated when importing word documents)
+var imageRegexp = regexp.MustCompile(`images\/image\d+\.(png|jpg|gif|bmp|svg|emf|wmf)`)
 
 // MatchOBjectIDsToImages creates a map from Google Doc object IDs to the URL of the corresponding image
 func MatchObjectIDsToImages(d *Archive, imageURLs []string) (map[string]string, error) {
@@ -38,8 +39,9 @@ func MatchObjectIDsToImages(d *Archive, imageURLs []string) (map[string]string,
 				continue
 			}
 
+			// linked charts appear as images in the HTML export
 			emb := obj.InlineObjectProperties.EmbeddedObject
-			if emb.EmbeddedDrawingProperties == nil && emb.ImageProperties == nil {
+			if emb.EmbeddedDrawingProperties == nil && emb.ImageProperties == nil && emb.LinkedContentReference == nil {
 				continue
 			}
 
@@ -61,3 +63,24 @@ func MatchObjectIDsToImages(d *Archive, imageURLs []string) (map[string]string,
 
 	return imageURLByObjectID, nil
 }
+
+// ReferencedImages returns the images whose inline objects are still
+// present in a document, such as after private content has been removed.
+// The map is from object IDs to image filenames, as returned by
+// MatchObjectIDsToImages when passed the filename of each image.
+func ReferencedImages(d *Archive, filenamesByObjectID map[string]string) []*Image {
+	referenced := make(map[string]bool)
+	for id, filename := range filenamesByObjectID {
+		if _, ok := d.Doc.InlineObjects[id]; ok {
+			referenced[filename] = true
+		}
+	}
+
+	var images []*Image
+	for _, image := range d.Images {
+		if referenced[image.Filename] {
+			images = append(images, image)
+		}
+	}
+	return images
+}
@@ -0,0 +1,20 @@
+package googledoc
+
+import "regexp"
+
+// RenameLatexSymbols renames the latex symbols in s that appear in renames.
+// Whole control sequences are matched, so renaming \T1 leaves \T12 alone.
+func RenameLatexSymbols(s string, renames map[string]string) string {
+	if len(renames) == 0 {
+		return s
+	}
+	return latexSymbolRegexp.ReplaceAllStringFunc(s, func(symbol string) string {
+		if to, ok := renames[symbol]; ok {
+			return to
+		}
+		return symbol
+	})
+}
+
+// regular expression for a latex control sequence
+var latexSymbolRegexp = regexp.MustCompile(`\\[\pL\pN]+`)
@@ -0,0 +1,13 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameLatexSymbols(t *testing.T) {
+	renames := map[string]string{`\T1`: `\Tone`, `\T12`: `\Tonetwo`}
+	assert.Equal(t, `\Tone + \Tonetwo = \T123`, RenameLatexSymbols(`\T1 + \T12 = \T123`, renames))
+	assert.Equal(t, `\T1`, RenameLatexSymbols(`\T1`, nil))
+}
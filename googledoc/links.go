@@ -0,0 +1,241 @@
+package googledoc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// UnpublishedLink is a link to a google doc that has no published URL
+type UnpublishedLink struct {
+	DocID string // ID of the google doc that is linked to
+	Text  string // text of the link
+}
+
+func (l *UnpublishedLink) String() string {
+	return fmt.Sprintf("%q links to unpublished doc %s", l.Text, l.DocID)
+}
+
+// redirector is a page that redirects to the URL in one of its query
+// parameters, and which is used to track clicks
+type redirector struct {
+	host, path string
+	params     []string
+}
+
+var redirectors = []redirector{
+	{"www.google.com", "/url", []string{"q", "url"}},
+	{"google.com", "/url", []string{"q", "url"}},
+	{"l.facebook.com", "/l.php", []string{"u"}},
+	{"lm.facebook.com", "/l.php", []string{"u"}},
+	{"out.reddit.com", "", []string{"url"}},
+	{"www.youtube.com", "/redirect", []string{"q"}},
+	{"slack-redir.net", "/link", []string{"url"}},
+}
+
+// trackingParams are query parameters that exist only for tracking clicks
+var trackingParams = map[string]bool{
+	"fbclid": true,
+	"gclid":  true,
+	"dclid":  true,
+	"mc_cid": true,
+	"mc_eid": true,
+	"usp":    true,
+}
+
+// CleanURL unwraps redirects and removes tracking parameters from a URL.
+// URLs that cannot be parsed are returned unchanged.
+func CleanURL(s string) string {
+	for i := 0; i < 5; i++ {
+		unwrapped := unwrapRedirect(s)
+		if unwrapped == s {
+			break
+		}
+		s = unwrapped
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.RawQuery == "" {
+		return s
+	}
+	// leave the query alone unless there is something to remove, since
+	// re-encoding it may reorder the parameters
+	q := u.Query()
+	var removed bool
+	for key := range q {
+		if strings.HasPrefix(key, "utm_") || trackingParams[key] {
+			q.Del(key)
+			removed = true
+		}
+	}
+	if !removed {
+		return s
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// unwrapRedirect gets the destination of a redirect URL, or returns the URL
+// unchanged if it is not a redirect
+func unwrapRedirect(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	for _, r := range redirectors {
+		if u.Host != r.host || (r.path != "" && u.Path != r.path) {
+			continue
+		}
+		for _, p := range r.params {
+			if dest := u.Query().Get(p); strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://") {
+				return dest
+			}
+		}
+	}
+	return s
+}
+
+// a regular expression for the ID of a google doc in a URL
+var docURLRegexp = regexp.MustCompile(`^https?://docs\.google\.com/document/(?:u/\d+/)?d/([\w-]+)`)
+
+// DocIDFromURL gets the ID of the google doc that a URL points to, or an
+// empty string if it does not point to a google doc
+func DocIDFromURL(s string) string {
+	m := docURLRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// LoadPublishedURLs loads a YAML or JSON file that maps google docs, given
+// by ID or URL, to the URLs where they are published
+func LoadPublishedURLs(path string) (map[string]string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading published URLs: %w", err)
+	}
+
+	var m map[string]string
+	if err := yaml.Unmarshal(buf, &m); err != nil {
+		return nil, fmt.Errorf("error parsing published URLs in %s: %w", path, err)
+	}
+
+	published := make(map[string]string)
+	for doc, u := range m {
+		if id := DocIDFromURL(doc); id != "" {
+			doc = id
+		}
+		published[doc] = u
+	}
+	return published, nil
+}
+
+// RewriteLinks cleans up every link in a document with CleanURL and points
+// links to other google docs at their published URLs, keyed by doc ID.
+// Links within the document itself are left alone so that converters can
+// resolve links to headings. Links to docs with no published URL are
+// returned so that they can be reported.
+func RewriteLinks(doc *docs.Document, published map[string]string) []*UnpublishedLink {
+	return RewriteBookLinks(doc, published, nil)
+}
+
+// RewriteBookLinks is like RewriteLinks but also leaves alone links to the
+// other documents in a book, given by ID, so that converters can point
+// them within the book
+func RewriteBookLinks(doc *docs.Document, published map[string]string, book map[string]bool) []*UnpublishedLink {
+	var unpublished []*UnpublishedLink
+	seen := make(map[string]bool)
+	ForEachTextRun(doc, func(segment string, t *docs.TextRun) {
+		if t.TextStyle == nil || t.TextStyle.Link == nil || t.TextStyle.Link.Url == "" {
+			return
+		}
+		link := t.TextStyle.Link
+		link.Url = CleanURL(link.Url)
+
+		id := DocIDFromURL(link.Url)
+		if id == "" || id == doc.DocumentId || book[id] {
+			return
+		}
+		if u, ok := published[id]; ok {
+			link.Url = u
+			return
+		}
+
+		text := strings.TrimSpace(t.Content)
+		if !seen[id+"\x00"+text] {
+			seen[id+"\x00"+text] = true
+			unpublished = append(unpublished, &UnpublishedLink{DocID: id, Text: text})
+		}
+	})
+	return unpublished
+}
+
+// ForEachTextRun calls a function for each text run in the body, footnotes,
+// headers, and footers of a document, including those inside tables. The
+// function is also given the segment that the run is in: "body",
+// "footnote", "header", or "footer". Footnotes, headers, and footers are
+// visited in order of their IDs.
+func ForEachTextRun(doc *docs.Document, f func(segment string, t *docs.TextRun)) {
+	visit := func(segment string, content []*docs.StructuralElement) {
+		forEachTextRun(content, func(t *docs.TextRun) {
+			f(segment, t)
+		})
+	}
+
+	if doc.Body != nil {
+		visit("body", doc.Body.Content)
+	}
+
+	var ids []string
+	for id := range doc.Footnotes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		visit("footnote", doc.Footnotes[id].Content)
+	}
+	ids = ids[:0]
+	for id := range doc.Headers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		visit("header", doc.Headers[id].Content)
+	}
+	ids = ids[:0]
+	for id := range doc.Footers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		visit("footer", doc.Footers[id].Content)
+	}
+}
+
+// forEachTextRun calls a function for each text run in a sequence of
+// elements, including those inside tables
+func forEachTextRun(content []*docs.StructuralElement, f func(*docs.TextRun)) {
+	for _, elem := range content {
+		switch {
+		case elem.Paragraph != nil:
+			for _, el := range elem.Paragraph.Elements {
+				if el.TextRun != nil {
+					f(el.TextRun)
+				}
+			}
+		case elem.Table != nil:
+			for _, row := range elem.Table.TableRows {
+				for _, cell := range row.TableCells {
+					forEachTextRun(cell.Content, f)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/docs/v1"
+)
+
+func TestCleanURL(t *testing.T) {
+	assert.Equal(t, "https://example.com/a?b=1",
+		CleanURL("https://www.google.com/url?q=https://example.com/a?b%3D1%26utm_source%3Dx&sa=D&ust=123"))
+	assert.Equal(t, "https://example.com/?id=2",
+		CleanURL("https://example.com/?utm_medium=email&id=2&fbclid=abc"))
+	assert.Equal(t, "https://example.com/?z=1&a=2", CleanURL("https://example.com/?z=1&a=2"))
+	assert.Equal(t, "https://www.google.com/search?q=x", CleanURL("https://www.google.com/search?q=x"))
+}
+
+func TestRewriteLinks(t *testing.T) {
+	link := func(text, url string) *docs.TextRun {
+		return &docs.TextRun{Content: text, TextStyle: &docs.TextStyle{Link: &docs.Link{Url: url}}}
+	}
+	published := link("post", "https://docs.google.com/document/d/abc123/edit?usp=sharing")
+	draft := link("draft", "https://docs.google.com/document/d/xyz789/edit")
+	self := link("below", "https://docs.google.com/document/d/self/edit#heading=h.1")
+	doc := docs.Document{
+		DocumentId: "self",
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			doctest.Paragraph(1, "NORMAL_TEXT", nil, published, draft, self, &docs.TextRun{Content: "\n"}),
+		}},
+	}
+
+	unpublished := RewriteLinks(&doc, map[string]string{"abc123": "https://www.lesswrong.com/posts/1"})
+	assert.Equal(t, "https://www.lesswrong.com/posts/1", published.TextStyle.Link.Url)
+	assert.Equal(t, "https://docs.google.com/document/d/self/edit#heading=h.1", self.TextStyle.Link.Url)
+	assert.Equal(t, []*UnpublishedLink{{DocID: "xyz789", Text: "draft"}}, unpublished)
+}
@@ -0,0 +1,60 @@
+package googledoc
+
+import (
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// Kinds of list
+const (
+	BulletList   = "bullet"
+	NumberedList = "numbered"
+	Checklist    = "checklist"
+)
+
+// checkboxGlyphs are glyph symbols that look like checkboxes
+var checkboxGlyphs = map[string]bool{
+	"☐": true,
+	"□": true,
+	"❑": true,
+	"❏": true,
+}
+
+// ListKind determines whether the nesting level of a list that a paragraph
+// belongs to is a bulleted list, a numbered list, or a checklist
+func ListKind(doc *docs.Document, bullet *docs.Bullet) string {
+	list, ok := doc.Lists[bullet.ListId]
+	if !ok || list.ListProperties == nil || int(bullet.NestingLevel) >= len(list.ListProperties.NestingLevels) {
+		return BulletList
+	}
+	level := list.ListProperties.NestingLevels[bullet.NestingLevel]
+
+	switch {
+	case checkboxGlyphs[level.GlyphSymbol]:
+		return Checklist
+	case level.GlyphSymbol != "":
+		return BulletList
+	case level.GlyphType == "" || level.GlyphType == "GLYPH_TYPE_UNSPECIFIED":
+		// checklists have neither a glyph symbol nor a glyph type
+		return Checklist
+	default:
+		return NumberedList
+	}
+}
+
+// IsChecked determines whether a checklist item has been completed, which
+// google docs indicates by striking through all of its text
+func IsChecked(p *docs.Paragraph) bool {
+	var found bool
+	for _, el := range p.Elements {
+		if el.TextRun == nil || strings.TrimSpace(el.TextRun.Content) == "" {
+			continue
+		}
+		if el.TextRun.TextStyle == nil || !el.TextRun.TextStyle.Strikethrough {
+			return false
+		}
+		found = true
+	}
+	return found
+}
@@ -0,0 +1,65 @@
+package googledoc
+
+import (
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// ParagraphText gets the text content of a paragraph, including its final
+// newline
+func ParagraphText(p *docs.Paragraph) string {
+	var b strings.Builder
+	for _, el := range p.Elements {
+		if el.TextRun != nil {
+			b.WriteString(el.TextRun.Content)
+		}
+	}
+	return b.String()
+}
+
+// IsIndented determines whether a paragraph is indented from the left margin
+func IsIndented(p *docs.Paragraph) bool {
+	return p.ParagraphStyle.IndentStart != nil && p.ParagraphStyle.IndentStart.Magnitude > 0
+}
+
+// HasObjects determines whether a paragraph contains anything besides text
+func HasObjects(p *docs.Paragraph) bool {
+	for _, el := range p.Elements {
+		if el.TextRun == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRule determines whether a paragraph contains a horizontal rule and nothing else
+func IsRule(p *docs.Paragraph) bool {
+	var found bool
+	for _, el := range p.Elements {
+		switch {
+		case el.HorizontalRule != nil:
+			found = true
+		case el.TextRun != nil && strings.TrimSpace(el.TextRun.Content) == "":
+		default:
+			return false
+		}
+	}
+	return found
+}
+
+// FigureObject gets the ID of the inline object in a paragraph that
+// contains one inline object and nothing else
+func FigureObject(p *docs.Paragraph) (string, bool) {
+	var id string
+	for _, el := range p.Elements {
+		switch {
+		case el.InlineObjectElement != nil && id == "":
+			id = el.InlineObjectElement.InlineObjectId
+		case el.TextRun != nil && strings.TrimSpace(el.TextRun.Content) == "":
+		default:
+			return "", false
+		}
+	}
+	return id, id != ""
+}
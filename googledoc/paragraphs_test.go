@@ -0,0 +1,30 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/docs/v1"
+)
+
+func TestParagraphShapes(t *testing.T) {
+	text := &docs.ParagraphElement{TextRun: &docs.TextRun{Content: "\n"}}
+	rule := &docs.Paragraph{Elements: []*docs.ParagraphElement{{HorizontalRule: &docs.HorizontalRule{}}, text}}
+	figure := &docs.Paragraph{Elements: []*docs.ParagraphElement{{InlineObjectElement: &docs.InlineObjectElement{InlineObjectId: "img"}}, text}}
+	words := &docs.Paragraph{Elements: []*docs.ParagraphElement{{TextRun: &docs.TextRun{Content: "Hello\n"}}}}
+
+	assert.True(t, IsRule(rule))
+	assert.False(t, IsRule(figure))
+	assert.False(t, IsRule(words))
+
+	id, ok := FigureObject(figure)
+	assert.True(t, ok)
+	assert.Equal(t, "img", id)
+	_, ok = FigureObject(rule)
+	assert.False(t, ok)
+
+	assert.True(t, HasObjects(figure))
+	assert.False(t, HasObjects(words))
+	assert.Equal(t, "Hello\n", ParagraphText(words))
+	assert.Equal(t, "Source data", ChartTitle(""))
+}
@@ -0,0 +1,299 @@
+package googledoc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// PrivateContentRules identifies content in a google doc that is for
+// editors only and must not be published. Empty fields are ignored.
+type PrivateContentRules struct {
+	NamedRangePrefix string   // exclude named ranges whose name begins with this prefix
+	HighlightColor   string   // exclude text highlighted in this color, such as "#ffff00"
+	NamedStyles      []string // exclude paragraphs in these named styles, such as HEADING_6
+}
+
+// Exclusion is a piece of content that was removed from a document
+type Exclusion struct {
+	Reason string // the rule that caused the content to be excluded
+	Text   string // the text that was excluded
+}
+
+func (e *Exclusion) String() string {
+	text := strings.Join(strings.Fields(e.Text), " ")
+	if len([]rune(text)) > 60 {
+		text = string([]rune(text)[:57]) + "..."
+	}
+	return fmt.Sprintf("%s: %q", e.Reason, text)
+}
+
+// RemovePrivateContent removes content matching the rules from the body
+// and footnotes of a document, and reports what was removed. Indices in the
+// document are not updated.
+func RemovePrivateContent(doc *docs.Document, rules *PrivateContentRules) ([]*Exclusion, error) {
+	var highlight *docs.RgbColor
+	if rules.HighlightColor != "" {
+		var err error
+		highlight, err = parseColor(rules.HighlightColor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// collect the ranges to exclude in each segment, keyed by segment ID
+	ranges := make(map[string][]privateRange)
+	if rules.NamedRangePrefix != "" {
+		for name, nr := range doc.NamedRanges {
+			if !strings.HasPrefix(name, rules.NamedRangePrefix) {
+				continue
+			}
+			for _, r := range nr.NamedRanges {
+				for _, rng := range r.Ranges {
+					ranges[rng.SegmentId] = append(ranges[rng.SegmentId], privateRange{
+						reason: "named range " + name,
+						start:  rng.StartIndex,
+						end:    rng.EndIndex,
+					})
+				}
+			}
+		}
+	}
+
+	r := privateContentRemover{rules: rules, highlight: highlight}
+	r.ranges = ranges[""]
+	doc.Body.Content = r.remove(doc.Body.Content)
+
+	// visit footnotes in a consistent order so that the report is stable
+	var ids []string
+	for id := range doc.Footnotes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		footnote := doc.Footnotes[id]
+		r.ranges = ranges[id]
+		footnote.Content = r.remove(footnote.Content)
+		doc.Footnotes[id] = footnote
+	}
+
+	// drop inline objects that are no longer referenced so that images
+	// belonging to private content are not published. Headers and footers
+	// are left as they are, so their objects are always kept.
+	referenced := make(map[string]bool)
+	collectObjectIDs(doc, doc.Body.Content, referenced)
+	for _, header := range doc.Headers {
+		collectObjectIDs(doc, header.Content, referenced)
+	}
+	for _, footer := range doc.Footers {
+		collectObjectIDs(doc, footer.Content, referenced)
+	}
+	for id := range doc.InlineObjects {
+		if !referenced[id] {
+			delete(doc.InlineObjects, id)
+		}
+	}
+	return r.exclusions, nil
+}
+
+// collectObjectIDs records the IDs of the inline objects in a
+// sequence of elements, including those in the footnotes that they refer to
+func collectObjectIDs(doc *docs.Document, content []*docs.StructuralElement, ids map[string]bool) {
+	for _, elem := range content {
+		switch {
+		case elem.Paragraph != nil:
+			for _, el := range elem.Paragraph.Elements {
+				switch {
+				case el.InlineObjectElement != nil:
+					ids[el.InlineObjectElement.InlineObjectId] = true
+				case el.FootnoteReference != nil:
+					if f, ok := doc.Footnotes[el.FootnoteReference.FootnoteId]; ok {
+						collectObjectIDs(doc, f.Content, ids)
+					}
+				}
+			}
+		case elem.Table != nil:
+			for _, row := range elem.Table.TableRows {
+				for _, cell := range row.TableCells {
+					collectObjectIDs(doc, cell.Content, ids)
+				}
+			}
+		}
+	}
+}
+
+// privateRange is a range of indices within a segment that is to be excluded
+type privateRange struct {
+	reason     string
+	start, end int64
+}
+
+type privateContentRemover struct {
+	rules      *PrivateContentRules
+	highlight  *docs.RgbColor
+	ranges     []privateRange
+	exclusions []*Exclusion
+	kept       bool // whether any content was kept since the last exclusion
+}
+
+func (r *privateContentRemover) exclude(reason, text string) {
+	// merge adjacent exclusions for the same reason
+	if n := len(r.exclusions); n > 0 && !r.kept && r.exclusions[n-1].Reason == reason {
+		r.exclusions[n-1].Text += text
+		return
+	}
+	r.exclusions = append(r.exclusions, &Exclusion{Reason: reason, Text: text})
+	r.kept = false
+}
+
+// remove removes private content from a sequence of structural elements
+func (r *privateContentRemover) remove(content []*docs.StructuralElement) []*docs.StructuralElement {
+	var out []*docs.StructuralElement
+	for _, elem := range content {
+		switch {
+		case elem.Paragraph != nil:
+			p := elem.Paragraph
+			if r.privateStyle(p.ParagraphStyle) {
+				r.exclude("named style "+p.ParagraphStyle.NamedStyleType, ParagraphText(p))
+				continue
+			}
+
+			var elements []*docs.ParagraphElement
+			for _, el := range p.Elements {
+				elements = append(elements, r.removeFromElement(el)...)
+			}
+			if len(elements) == 0 {
+				continue
+			}
+			p.Elements = elements
+
+		case elem.Table != nil:
+			if reason := r.rangeCovering(elem.StartIndex, elem.EndIndex); reason != "" {
+				r.exclude(reason, "(table)")
+				continue
+			}
+			for _, row := range elem.Table.TableRows {
+				for _, cell := range row.TableCells {
+					cell.Content = r.remove(cell.Content)
+				}
+			}
+		}
+		out = append(out, elem)
+	}
+	return out
+}
+
+// removeFromElement removes private content from a paragraph element,
+// returning zero, one, or more elements that remain
+func (r *privateContentRemover) removeFromElement(el *docs.ParagraphElement) []*docs.ParagraphElement {
+	if el.TextRun == nil {
+		if reason := r.rangeCovering(el.StartIndex, el.EndIndex); reason != "" {
+			r.exclude(reason, "(object)")
+			return nil
+		}
+		r.kept = true
+		return []*docs.ParagraphElement{el}
+	}
+
+	if r.highlight != nil && el.TextRun.TextStyle != nil && sameColor(el.TextRun.TextStyle.BackgroundColor, r.highlight) {
+		r.exclude("highlight "+r.rules.HighlightColor, el.TextRun.Content)
+		return nil
+	}
+
+	// cut out the parts of the text run that fall within private ranges
+	text := utf16.Encode([]rune(el.TextRun.Content))
+	reasons := make([]string, len(text))
+	for _, pr := range r.ranges {
+		for i := range text {
+			index := el.StartIndex + int64(i)
+			if index >= pr.start && index < pr.end && reasons[i] == "" {
+				reasons[i] = pr.reason
+			}
+		}
+	}
+
+	keep := make([]bool, len(text))
+	for i := range text {
+		if reasons[i] == "" {
+			keep[i] = true
+			r.kept = true
+		} else {
+			r.exclude(reasons[i], string(utf16.Decode(text[i:i+1])))
+		}
+	}
+
+	// split the run into pieces that are kept
+	var out []*docs.ParagraphElement
+	for i := 0; i < len(text); {
+		if !keep[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(text) && keep[j] {
+			j++
+		}
+		piece := *el
+		run := *el.TextRun
+		run.Content = string(utf16.Decode(text[i:j]))
+		piece.TextRun = &run
+		piece.StartIndex = el.StartIndex + int64(i)
+		piece.EndIndex = el.StartIndex + int64(j)
+		out = append(out, &piece)
+		i = j
+	}
+	return out
+}
+
+// rangeCovering gets the reason for a private range that covers the given range, or an empty string
+func (r *privateContentRemover) rangeCovering(start, end int64) string {
+	for _, pr := range r.ranges {
+		if pr.start <= start && pr.end >= end {
+			return pr.reason
+		}
+	}
+	return ""
+}
+
+// privateStyle determines whether a paragraph is in one of the private named styles
+func (r *privateContentRemover) privateStyle(style *docs.ParagraphStyle) bool {
+	if style == nil {
+		return false
+	}
+	for _, s := range r.rules.NamedStyles {
+		if strings.EqualFold(s, style.NamedStyleType) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseColor parses a color like "#ffff00"
+func parseColor(s string) (*docs.RgbColor, error) {
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("invalid color %q, expected a hex color like #ffff00", s)
+	}
+	return &docs.RgbColor{
+		Red:   float64((v>>16)&0xff) / 255,
+		Green: float64((v>>8)&0xff) / 255,
+		Blue:  float64(v&0xff) / 255,
+	}, nil
+}
+
+// sameColor determines whether an optional color is the given color, to within rounding error
+func sameColor(c *docs.OptionalColor, rgb *docs.RgbColor) bool {
+	if c == nil || c.Color == nil || c.Color.RgbColor == nil {
+		return false
+	}
+	const eps = 0.5 / 255
+	return math.Abs(c.Color.RgbColor.Red-rgb.Red) < eps &&
+		math.Abs(c.Color.RgbColor.Green-rgb.Green) < eps &&
+		math.Abs(c.Color.RgbColor.Blue-rgb.Blue) < eps
+}
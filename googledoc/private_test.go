@@ -0,0 +1,84 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
+)
+
+func TestRemovePrivateContent(t *testing.T) {
+	yellow := &docs.OptionalColor{Color: &docs.Color{RgbColor: &docs.RgbColor{Red: 1, Green: 1}}}
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Public secret text\n", TextStyle: &docs.TextStyle{}}),
+			doctest.Paragraph(20, "HEADING_6", nil, &docs.TextRun{Content: "Editor notes\n", TextStyle: &docs.TextStyle{}}),
+			doctest.Paragraph(33, "NORMAL_TEXT", nil,
+				&docs.TextRun{Content: "Keep ", TextStyle: &docs.TextStyle{}},
+				&docs.TextRun{Content: "TODO", TextStyle: &docs.TextStyle{BackgroundColor: yellow}},
+				&docs.TextRun{Content: "\n", TextStyle: &docs.TextStyle{}}),
+		}},
+		NamedRanges: map[string]docs.NamedRanges{
+			"private:draft": {NamedRanges: []*docs.NamedRange{{
+				Ranges: []*docs.Range{{StartIndex: 8, EndIndex: 15}},
+			}}},
+		},
+	}
+
+	exclusions, err := RemovePrivateContent(&doc, &PrivateContentRules{
+		NamedRangePrefix: "private:",
+		HighlightColor:   "#ffff00",
+		NamedStyles:      []string{"heading_6"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, doc.Body.Content, 2)
+	assert.Equal(t, "Public text\n", ParagraphText(doc.Body.Content[0].Paragraph))
+	assert.Equal(t, "Keep \n", ParagraphText(doc.Body.Content[1].Paragraph))
+
+	require.Len(t, exclusions, 3)
+	assert.Equal(t, `named range private:draft: "secret"`, exclusions[0].String())
+	assert.Equal(t, `named style HEADING_6: "Editor notes"`, exclusions[1].String())
+	assert.Equal(t, `highlight #ffff00: "TODO"`, exclusions[2].String())
+}
+
+func TestRemovePrivateImages(t *testing.T) {
+	image := func(start int64, id string) *docs.StructuralElement {
+		p := doctest.Paragraph(start+1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "\n", TextStyle: &docs.TextStyle{}})
+		p.StartIndex = start
+		p.Paragraph.Elements = append([]*docs.ParagraphElement{{
+			StartIndex:          start,
+			EndIndex:            start + 1,
+			InlineObjectElement: &docs.InlineObjectElement{InlineObjectId: id},
+		}}, p.Paragraph.Elements...)
+		return p
+	}
+	d := Archive{
+		Doc: &docs.Document{
+			Body: &docs.Body{Content: []*docs.StructuralElement{image(1, "public"), image(3, "secret")}},
+			NamedRanges: map[string]docs.NamedRanges{
+				"private:image": {NamedRanges: []*docs.NamedRange{{
+					Ranges: []*docs.Range{{StartIndex: 3, EndIndex: 4}},
+				}}},
+			},
+			Headers:       map[string]docs.Header{"header": {Content: []*docs.StructuralElement{image(0, "logo")}}},
+			InlineObjects: map[string]docs.InlineObject{"public": {}, "secret": {}, "logo": {}},
+		},
+		Images: []*Image{{Filename: "images/image1.png"}, {Filename: "images/image2.png"}},
+	}
+
+	_, err := RemovePrivateContent(d.Doc, &PrivateContentRules{NamedRangePrefix: "private:"})
+	require.NoError(t, err)
+	assert.Contains(t, d.Doc.InlineObjects, "public")
+	assert.Contains(t, d.Doc.InlineObjects, "logo")
+	assert.NotContains(t, d.Doc.InlineObjects, "secret")
+
+	images := ReferencedImages(&d, map[string]string{
+		"public": "images/image1.png",
+		"secret": "images/image2.png",
+	})
+	require.Len(t, images, 1)
+	assert.Equal(t, "images/image1.png", images[0].Filename)
+}
@@ -0,0 +1,214 @@
+package googledoc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Roles that style rules can assign to paragraphs
+const (
+	QuoteRole    = "blockquote" // a quotation set off from the text
+	CalloutRole  = "callout"    // a boxed note or warning
+	EpigraphRole = "epigraph"   // a quotation at the start of a document or chapter
+	CodeRole     = "code"       // a line of a code block, or inline code
+	SpoilerRole  = "spoiler"    // content that is hidden until the reader reveals it
+)
+
+// Roles that style rules can assign to text, besides CodeRole and SpoilerRole
+const (
+	HighlightRole = "highlight" // text marked as if with a highlighter pen
+)
+
+var blockRoles = map[string]bool{QuoteRole: true, CalloutRole: true, EpigraphRole: true, CodeRole: true, SpoilerRole: true}
+var inlineRoles = map[string]bool{HighlightRole: true, CodeRole: true, SpoilerRole: true}
+
+// StyleRule assigns a role to the paragraphs or text that match all of its
+// conditions. Exactly one of Block or Inline must be set. Block rules match
+// paragraphs, and their text conditions must hold for all of the text in
+// the paragraph. Inline rules match text and cannot have paragraph conditions.
+type StyleRule struct {
+	// paragraph conditions
+	NamedStyle string `yaml:"named_style"` // such as HEADING_6
+	Alignment  string `yaml:"alignment"`   // START, CENTER, END, or JUSTIFIED
+	Indented   *bool  `yaml:"indented"`
+
+	// text conditions
+	Font       string `yaml:"font"` // font family, such as Georgia
+	Bold       *bool  `yaml:"bold"`
+	Italic     *bool  `yaml:"italic"`
+	Color      string `yaml:"color"`      // text color, such as #ff0000
+	Background string `yaml:"background"` // highlight color, such as #fff2cc
+
+	Block  string `yaml:"block"`  // role for matching paragraphs
+	Inline string `yaml:"inline"` // role for matching text
+
+	color, background *docs.RgbColor
+}
+
+// StyleRules maps google doc styles to roles that every converter
+// understands. Earlier rules take precedence over later ones.
+type StyleRules struct {
+	Rules []*StyleRule `yaml:"rules"`
+
+	// Monospace lists fonts to treat as code in addition to the common
+	// monospace fonts
+	Monospace []string `yaml:"monospace"`
+}
+
+// LoadStyleRules loads style rules from a YAML or JSON file
+func LoadStyleRules(path string) (*StyleRules, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading style rules: %w", err)
+	}
+	rules, err := ParseStyleRules(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseStyleRules parses style rules in YAML or JSON, which is a subset of YAML
+func ParseStyleRules(buf []byte) (*StyleRules, error) {
+	var rules StyleRules
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	dec.KnownFields(true)
+	err := dec.Decode(&rules)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing style rules: %w", err)
+	}
+
+	for i, r := range rules.Rules {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return &rules, nil
+}
+
+// validate checks a rule and parses its colors
+func (r *StyleRule) validate() error {
+	switch {
+	case r.Block == "" && r.Inline == "":
+		return errors.New("must have either a block or an inline role")
+	case r.Block != "" && r.Inline != "":
+		return errors.New("cannot have both a block and an inline role")
+	case r.Block != "" && !blockRoles[r.Block]:
+		return fmt.Errorf("unknown block role %q", r.Block)
+	case r.Inline != "" && !inlineRoles[r.Inline]:
+		return fmt.Errorf("unknown inline role %q", r.Inline)
+	case r.Inline != "" && (r.NamedStyle != "" || r.Alignment != "" || r.Indented != nil):
+		return errors.New("inline rules cannot have paragraph conditions")
+	}
+
+	var err error
+	if r.Color != "" {
+		if r.color, err = parseColor(r.Color); err != nil {
+			return err
+		}
+	}
+	if r.Background != "" {
+		if r.background, err = parseColor(r.Background); err != nil {
+			return err
+		}
+	}
+
+	if r.NamedStyle == "" && r.Alignment == "" && r.Indented == nil && !r.hasTextConditions() {
+		return errors.New("must have at least one condition")
+	}
+	return nil
+}
+
+// hasTextConditions determines whether a rule has any conditions on text style
+func (r *StyleRule) hasTextConditions() bool {
+	return r.Font != "" || r.Bold != nil || r.Italic != nil || r.Color != "" || r.Background != ""
+}
+
+// BlockRole gets the role that the first matching rule assigns to a
+// paragraph, or an empty string if there is none. Rules may be nil.
+func (rules *StyleRules) BlockRole(p *docs.Paragraph) string {
+	if rules == nil {
+		return ""
+	}
+	for _, r := range rules.Rules {
+		if r.Block != "" && r.matchesParagraph(p) {
+			return r.Block
+		}
+	}
+	return ""
+}
+
+// InlineRole gets the role that the first matching rule assigns to a text
+// run, or an empty string if there is none. Rules may be nil.
+func (rules *StyleRules) InlineRole(t *docs.TextRun) string {
+	if rules == nil {
+		return ""
+	}
+	for _, r := range rules.Rules {
+		if r.Inline != "" && r.matchesText(t.TextStyle) {
+			return r.Inline
+		}
+	}
+	return ""
+}
+
+func (r *StyleRule) matchesParagraph(p *docs.Paragraph) bool {
+	style := p.ParagraphStyle
+	if style == nil {
+		style = &docs.ParagraphStyle{}
+	}
+	if r.NamedStyle != "" && !strings.EqualFold(style.NamedStyleType, r.NamedStyle) {
+		return false
+	}
+	if r.Alignment != "" && !strings.EqualFold(style.Alignment, r.Alignment) {
+		return false
+	}
+	if r.Indented != nil && (style.IndentStart != nil && style.IndentStart.Magnitude > 0) != *r.Indented {
+		return false
+	}
+	if !r.hasTextConditions() {
+		return true
+	}
+
+	// every run of text in the paragraph must match
+	var found bool
+	for _, el := range p.Elements {
+		if el.TextRun == nil || strings.TrimSpace(el.TextRun.Content) == "" {
+			continue
+		}
+		if !r.matchesText(el.TextRun.TextStyle) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func (r *StyleRule) matchesText(s *docs.TextStyle) bool {
+	if s == nil {
+		s = &docs.TextStyle{}
+	}
+	if r.Font != "" && (s.WeightedFontFamily == nil || !strings.EqualFold(s.WeightedFontFamily.FontFamily, r.Font)) {
+		return false
+	}
+	if r.Bold != nil && s.Bold != *r.Bold {
+		return false
+	}
+	if r.Italic != nil && s.Italic != *r.Italic {
+		return false
+	}
+	if r.color != nil && !sameColor(s.ForegroundColor, r.color) {
+		return false
+	}
+	if r.background != nil && !sameColor(s.BackgroundColor, r.background) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
+)
+
+func TestStyleRules(t *testing.T) {
+	rules, err := ParseStyleRules([]byte(`
+rules:
+  - named_style: HEADING_6
+    block: callout
+  - font: Georgia
+    italic: true
+    block: epigraph
+  - background: "#fff2cc"
+    inline: highlight
+`))
+	require.NoError(t, err)
+
+	callout := doctest.Paragraph(1, "HEADING_6", nil, &docs.TextRun{Content: "Note\n", TextStyle: &docs.TextStyle{}})
+	assert.Equal(t, CalloutRole, rules.BlockRole(callout.Paragraph))
+
+	georgia := &docs.TextStyle{Italic: true, WeightedFontFamily: &docs.WeightedFontFamily{FontFamily: "Georgia"}}
+	epigraph := doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Call me Ishmael.", TextStyle: georgia}, &docs.TextRun{Content: "\n"})
+	assert.Equal(t, EpigraphRole, rules.BlockRole(epigraph.Paragraph))
+
+	georgia.Italic = false
+	assert.Equal(t, "", rules.BlockRole(epigraph.Paragraph))
+
+	yellow := &docs.OptionalColor{Color: &docs.Color{RgbColor: &docs.RgbColor{Red: 1, Green: 242.0 / 255, Blue: 204.0 / 255}}}
+	assert.Equal(t, HighlightRole, rules.InlineRole(&docs.TextRun{TextStyle: &docs.TextStyle{BackgroundColor: yellow}}))
+	assert.Equal(t, "", rules.InlineRole(&docs.TextRun{TextStyle: &docs.TextStyle{}}))
+
+	// json works too, and nil rules match nothing
+	_, err = ParseStyleRules([]byte(`{"rules": [{"color": "#ff0000", "inline": "spoiler"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "", (*StyleRules)(nil).BlockRole(callout.Paragraph))
+}
+
+func TestStyleRulesErrors(t *testing.T) {
+	for _, src := range []string{
+		`rules: [{named_style: HEADING_6}]`,
+		`rules: [{named_style: HEADING_6, block: sidebar}]`,
+		`rules: [{named_style: HEADING_6, inline: highlight}]`,
+		`rules: [{color: red, inline: highlight}]`,
+		`rules: [{block: callout}]`,
+		`rules: [{colour: "#ff0000", inline: highlight}]`,
+	} {
+		_, err := ParseStyleRules([]byte(src))
+		assert.Error(t, err, src)
+	}
+}
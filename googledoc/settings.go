@@ -0,0 +1,135 @@
+package googledoc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// Settings are publishing settings given by authors in a two-column table
+// at the top of a document, with keys on the left and values on the right
+type Settings struct {
+	Title      string   `yaml:"title,omitempty"`
+	Subtitle   string   `yaml:"subtitle,omitempty"`
+	Tags       []string `yaml:"tags,omitempty"`
+	Draft      *bool    `yaml:"draft,omitempty"`
+	Canonical  string   `yaml:"canonical,omitempty"`
+	Coauthors  []string `yaml:"coauthors,omitempty"`
+	Sequence   string   `yaml:"sequence,omitempty"`
+	Slug       string   `yaml:"slug,omitempty"`
+	Frontpage  *bool    `yaml:"frontpage,omitempty"`  // whether to submit to the lesswrong frontpage
+	Moderation string   `yaml:"moderation,omitempty"` // lesswrong moderation style
+	Summary    string   `yaml:"summary,omitempty"`    // short description for listings
+}
+
+// setters parse the value for each key in a settings table
+var setters = map[string]func(s *Settings, v string) error{
+	"title":      func(s *Settings, v string) error { s.Title = v; return nil },
+	"subtitle":   func(s *Settings, v string) error { s.Subtitle = v; return nil },
+	"tags":       func(s *Settings, v string) error { s.Tags = splitList(v); return nil },
+	"draft":      func(s *Settings, v string) error { return parseBool(v, &s.Draft) },
+	"canonical":  func(s *Settings, v string) error { s.Canonical = v; return nil },
+	"coauthors":  func(s *Settings, v string) error { s.Coauthors = splitList(v); return nil },
+	"sequence":   func(s *Settings, v string) error { s.Sequence = v; return nil },
+	"slug":       func(s *Settings, v string) error { s.Slug = v; return nil },
+	"frontpage":  func(s *Settings, v string) error { return parseBool(v, &s.Frontpage) },
+	"moderation": func(s *Settings, v string) error { s.Moderation = v; return nil },
+	"summary":    func(s *Settings, v string) error { s.Summary = v; return nil },
+}
+
+// ExtractSettings looks for a settings table at the top of a document. If
+// one is found, it is parsed and removed from the document body, together
+// with the empty paragraph that google docs places after every table. If
+// there is no settings table then the result is nil.
+func ExtractSettings(doc *docs.Document) (*Settings, error) {
+	// the first element is always a section break
+	pos := 0
+	content := doc.Body.Content
+	for pos < len(content) && content[pos].SectionBreak != nil {
+		pos++
+	}
+	if pos >= len(content) || content[pos].Table == nil {
+		return nil, nil
+	}
+
+	// the table must have two columns and a recognized key in the first row
+	table := content[pos].Table
+	if table.Columns != 2 || len(table.TableRows) == 0 {
+		return nil, nil
+	}
+	if _, ok := setters[settingKey(table.TableRows[0])]; !ok {
+		return nil, nil
+	}
+
+	var s Settings
+	for _, row := range table.TableRows {
+		key := settingKey(row)
+		set, ok := setters[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q in settings table", key)
+		}
+		if err := set(&s, strings.TrimSpace(cellText(row.TableCells[1]))); err != nil {
+			return nil, fmt.Errorf("error parsing setting %q: %w", key, err)
+		}
+	}
+
+	// remove the table and the empty paragraph after it
+	end := pos + 1
+	if end < len(content) && content[end].Paragraph != nil && strings.TrimSpace(ParagraphText(content[end].Paragraph)) == "" {
+		end++
+	}
+	doc.Body.Content = append(content[:pos:pos], content[end:]...)
+	return &s, nil
+}
+
+// settingKey gets the normalized key for a row in a settings table
+func settingKey(row *docs.TableRow) string {
+	if len(row.TableCells) != 2 {
+		return ""
+	}
+	key := strings.ToLower(strings.TrimSpace(cellText(row.TableCells[0])))
+	return strings.TrimSuffix(key, ":")
+}
+
+// cellText gets the text in a table cell
+func cellText(cell *docs.TableCell) string {
+	var b strings.Builder
+	for _, elem := range cell.Content {
+		if elem.Paragraph != nil {
+			b.WriteString(ParagraphText(elem.Paragraph))
+		}
+	}
+	return b.String()
+}
+
+// splitList splits a comma-separated list
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseBool parses a boolean value such as "true" or "yes"
+func parseBool(s string, dest **bool) error {
+	var b bool
+	switch strings.ToLower(s) {
+	case "yes", "y", "on":
+		b = true
+	case "no", "n", "off":
+		b = false
+	default:
+		var err error
+		b, err = strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("expected true or false but got %q", s)
+		}
+	}
+	*dest = &b
+	return nil
+}
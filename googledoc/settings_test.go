@@ -0,0 +1,66 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
+)
+
+func settingsTable(rows ...[2]string) *docs.StructuralElement {
+	var t docs.Table
+	t.Columns = 2
+	for _, r := range rows {
+		var row docs.TableRow
+		for _, s := range r {
+			row.TableCells = append(row.TableCells, &docs.TableCell{
+				Content: []*docs.StructuralElement{doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: s + "\n"})},
+			})
+		}
+		t.TableRows = append(t.TableRows, &row)
+	}
+	return &docs.StructuralElement{Table: &t}
+}
+
+func TestExtractSettings(t *testing.T) {
+	doc := docs.Document{Body: &docs.Body{Content: []*docs.StructuralElement{
+		{SectionBreak: &docs.SectionBreak{}},
+		settingsTable(
+			[2]string{"Title", "My post"},
+			[2]string{"tags:", "ai, alignment"},
+			[2]string{"draft", "no"},
+		),
+		doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "\n"}),
+		doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Body\n"}),
+	}}}
+
+	s, err := ExtractSettings(&doc)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, "My post", s.Title)
+	assert.Equal(t, []string{"ai", "alignment"}, s.Tags)
+	require.NotNil(t, s.Draft)
+	assert.False(t, *s.Draft)
+
+	require.Len(t, doc.Body.Content, 2)
+	assert.Equal(t, "Body\n", ParagraphText(doc.Body.Content[1].Paragraph))
+}
+
+func TestExtractSettingsIgnoresOtherTables(t *testing.T) {
+	doc := docs.Document{Body: &docs.Body{Content: []*docs.StructuralElement{
+		settingsTable([2]string{"Name", "Value"}),
+	}}}
+
+	s, err := ExtractSettings(&doc)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	assert.Len(t, doc.Body.Content, 1)
+
+	doc.Body.Content = []*docs.StructuralElement{
+		settingsTable([2]string{"title", "x"}, [2]string{"colour", "red"}),
+	}
+	_, err = ExtractSettings(&doc)
+	assert.Error(t, err)
+}
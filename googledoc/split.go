@@ -0,0 +1,138 @@
+package googledoc
+
+import (
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// Section is a part of a document that is exported as a separate file
+type Section struct {
+	Heading string                    // text of the heading that begins the section, if any
+	Content []*docs.StructuralElement // the elements in the section, including the heading
+}
+
+// SplitAtPageBreaks splits a sequence of elements into sections that end at
+// paragraphs containing page breaks. Empty sections are dropped.
+func SplitAtPageBreaks(content []*docs.StructuralElement) []*Section {
+	var sections []*Section
+	cur := &Section{}
+	for _, elem := range content {
+		cur.Content = append(cur.Content, elem)
+		if hasPageBreak(elem) {
+			sections = appendSection(sections, cur)
+			cur = &Section{}
+		}
+	}
+	return appendSection(sections, cur)
+}
+
+// SplitAtHeadings splits a sequence of elements into sections that begin
+// at headings of the given level or higher. Content before the first such
+// heading forms a section with no heading, unless it is empty.
+func SplitAtHeadings(content []*docs.StructuralElement, level int) []*Section {
+	var sections []*Section
+	cur := &Section{}
+	for _, elem := range content {
+		if elem.Paragraph != nil && elem.Paragraph.ParagraphStyle != nil {
+			l := HeadingLevel(elem.Paragraph.ParagraphStyle.NamedStyleType)
+			if l > 0 && l <= level {
+				sections = appendSection(sections, cur)
+				cur = &Section{Heading: strings.TrimSpace(ParagraphText(elem.Paragraph))}
+			}
+		}
+		cur.Content = append(cur.Content, elem)
+	}
+	return appendSection(sections, cur)
+}
+
+// appendSection appends a section unless it has no heading and no text
+func appendSection(sections []*Section, s *Section) []*Section {
+	if s.Heading == "" && isBlank(s.Content) {
+		return sections
+	}
+	return append(sections, s)
+}
+
+// isBlank determines whether a sequence of elements contains no text, tables, or objects
+func isBlank(content []*docs.StructuralElement) bool {
+	for _, elem := range content {
+		if elem.Table != nil {
+			return false
+		}
+		if elem.Paragraph == nil {
+			continue
+		}
+		for _, el := range elem.Paragraph.Elements {
+			if el.TextRun == nil {
+				if el.PageBreak == nil {
+					return false
+				}
+				continue
+			}
+			if strings.TrimSpace(el.TextRun.Content) != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hasPageBreak determines whether an element is a paragraph containing a page break
+func hasPageBreak(elem *docs.StructuralElement) bool {
+	if elem.Paragraph == nil {
+		return false
+	}
+	for _, el := range elem.Paragraph.Elements {
+		if el.PageBreak != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// HeadingIDs gets the IDs of the headings in a sequence of elements
+func HeadingIDs(content []*docs.StructuralElement) []string {
+	var ids []string
+	for _, elem := range content {
+		if elem.Paragraph != nil && elem.Paragraph.ParagraphStyle != nil && elem.Paragraph.ParagraphStyle.HeadingId != "" {
+			ids = append(ids, elem.Paragraph.ParagraphStyle.HeadingId)
+		}
+	}
+	return ids
+}
+
+// FootnoteIDs gets the IDs of the footnotes referenced in a sequence of
+// elements, including those referenced from within the footnotes
+// themselves, in order of first reference
+func FootnoteIDs(doc *docs.Document, content []*docs.StructuralElement) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	var visit func(content []*docs.StructuralElement)
+	visit = func(content []*docs.StructuralElement) {
+		for _, elem := range content {
+			switch {
+			case elem.Paragraph != nil:
+				for _, el := range elem.Paragraph.Elements {
+					if el.FootnoteReference == nil || seen[el.FootnoteReference.FootnoteId] {
+						continue
+					}
+					id := el.FootnoteReference.FootnoteId
+					seen[id] = true
+					ids = append(ids, id)
+					if footnote, ok := doc.Footnotes[id]; ok {
+						visit(footnote.Content)
+					}
+				}
+			case elem.Table != nil:
+				for _, row := range elem.Table.TableRows {
+					for _, cell := range row.TableCells {
+						visit(cell.Content)
+					}
+				}
+			}
+		}
+	}
+	visit(content)
+	return ids
+}
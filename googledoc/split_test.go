@@ -0,0 +1,68 @@
+package googledoc
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
+)
+
+func TestSplitAtHeadings(t *testing.T) {
+	content := []*docs.StructuralElement{
+		{SectionBreak: &docs.SectionBreak{}},
+		doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Intro\n"}),
+		doctest.Paragraph(0, "HEADING_1", nil, &docs.TextRun{Content: "First\n"}),
+		doctest.Paragraph(0, "HEADING_2", nil, &docs.TextRun{Content: "Sub\n"}),
+		doctest.Paragraph(0, "HEADING_1", nil, &docs.TextRun{Content: "Second\n"}),
+		doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Last\n"}),
+	}
+
+	sections := SplitAtHeadings(content, 1)
+	require.Len(t, sections, 3)
+	assert.Equal(t, "", sections[0].Heading)
+	assert.Len(t, sections[0].Content, 2)
+	assert.Equal(t, "First", sections[1].Heading)
+	assert.Len(t, sections[1].Content, 2)
+	assert.Equal(t, "Second", sections[2].Heading)
+	assert.Len(t, sections[2].Content, 2)
+
+	assert.Len(t, SplitAtHeadings(content, 2), 4)
+}
+
+func TestSplitAtPageBreaks(t *testing.T) {
+	pageBreak := doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "\n"})
+	pageBreak.Paragraph.Elements = append([]*docs.ParagraphElement{{PageBreak: &docs.PageBreak{}}}, pageBreak.Paragraph.Elements...)
+
+	content := []*docs.StructuralElement{
+		doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "One\n"}),
+		pageBreak,
+		doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Two\n"}),
+	}
+
+	// the final element must not be dropped
+	sections := SplitAtPageBreaks(content)
+	require.Len(t, sections, 2)
+	assert.Len(t, sections[0].Content, 2)
+	require.Len(t, sections[1].Content, 1)
+	assert.Equal(t, "Two\n", ParagraphText(sections[1].Content[0].Paragraph))
+}
+
+func TestFootnoteIDs(t *testing.T) {
+	ref := func(id string) *docs.StructuralElement {
+		p := doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "x\n"})
+		p.Paragraph.Elements = append(p.Paragraph.Elements, &docs.ParagraphElement{FootnoteReference: &docs.FootnoteReference{FootnoteId: id}})
+		return p
+	}
+	doc := docs.Document{
+		Footnotes: map[string]docs.Footnote{
+			"a": {Content: []*docs.StructuralElement{ref("c")}},
+			"b": {},
+			"c": {},
+		},
+	}
+
+	content := []*docs.StructuralElement{ref("a"), ref("b"), ref("a")}
+	assert.Equal(t, []string{"a", "c", "b"}, FootnoteIDs(&doc, content))
+}
@@ -0,0 +1,113 @@
+package googledoc
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// Kinds of hidden block that authors can mark in a document
+const (
+	SpoilerBlock = "spoiler" // hidden until the reader reveals it
+	DetailsBlock = "details" // collapsed under a summary until the reader expands it
+)
+
+// BlockMarker is a paragraph that opens or closes a hidden block. Authors
+// write "[spoiler]" and "[/spoiler]" on lines of their own around
+// spoilers, and "[details: Summary]" and "[/details]" around collapsible
+// sections.
+type BlockMarker struct {
+	Kind    string // SpoilerBlock or DetailsBlock
+	Summary string // the summary shown for a collapsible section
+	Close   bool   // whether this marker closes a block rather than opening one
+}
+
+// a regular expression for paragraphs that open or close hidden blocks
+var blockMarkerRegexp = regexp.MustCompile(`(?i)^\[(/?)(spoiler|details)(?:\s*:\s*(.*?))?\]$`)
+
+// ParseBlockMarker determines whether a paragraph opens or closes a hidden
+// block, returning nil if it does not
+func ParseBlockMarker(p *docs.Paragraph) *BlockMarker {
+	m := blockMarkerRegexp.FindStringSubmatch(strings.TrimSpace(ParagraphText(p)))
+	if m == nil {
+		return nil
+	}
+	marker := BlockMarker{
+		Kind:    strings.ToLower(m[2]),
+		Summary: strings.TrimSpace(m[3]),
+		Close:   m[1] == "/",
+	}
+	if marker.Kind == DetailsBlock && marker.Summary == "" && !marker.Close {
+		marker.Summary = "Details"
+	}
+	return &marker
+}
+
+// Changes to hidden blocks that exporters render in response to markers
+const (
+	IgnoreMarker = iota // the marker does not match an open block
+	OpenSpoiler
+	CloseSpoiler
+	OpenDetails
+	CloseDetails
+)
+
+// HiddenBlocks keeps track of the spoilers and collapsible sections that
+// have been opened by markers, so that exporters need only render them
+type HiddenBlocks struct {
+	spoiler bool // whether the current paragraph is between [spoiler] and [/spoiler]
+	details int  // number of open collapsible sections
+}
+
+// InSpoiler determines whether the current paragraph is between [spoiler]
+// and [/spoiler]
+func (h *HiddenBlocks) InSpoiler() bool {
+	return h.spoiler
+}
+
+// Apply updates the open blocks for a marker and returns the change that
+// the exporter should render
+func (h *HiddenBlocks) Apply(m *BlockMarker) int {
+	switch {
+	case m.Kind == SpoilerBlock && !m.Close:
+		if h.spoiler {
+			log.Println("warning: ignoring [spoiler] inside another spoiler")
+		}
+		h.spoiler = true
+		return OpenSpoiler
+	case m.Kind == SpoilerBlock:
+		if !h.spoiler {
+			log.Println("warning: ignoring [/spoiler] with no matching [spoiler]")
+			return IgnoreMarker
+		}
+		h.spoiler = false
+		return CloseSpoiler
+	case !m.Close:
+		h.details++
+		return OpenDetails
+	default:
+		if h.details == 0 {
+			log.Println("warning: ignoring [/details] with no matching [details]")
+			return IgnoreMarker
+		}
+		h.details--
+		return CloseDetails
+	}
+}
+
+// Close forgets any blocks that were not closed explicitly and returns the
+// number of collapsible sections that the exporter should close. The
+// exporter should also end any spoiler.
+func (h *HiddenBlocks) Close() int {
+	if h.spoiler {
+		log.Println("warning: [spoiler] was not closed with [/spoiler]")
+	}
+	if h.details > 0 {
+		log.Println("warning: [details] was not closed with [/details]")
+	}
+	details := h.details
+	*h = HiddenBlocks{}
+	return details
+}
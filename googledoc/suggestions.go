@@ -0,0 +1,136 @@
+package googledoc
+
+import (
+	"fmt"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// Ways of dealing with suggested edits in a google doc
+const (
+	AcceptSuggestions = "accept" // show the document as if all suggestions were accepted
+	RejectSuggestions = "reject" // show the document as if all suggestions were rejected
+	MarkupSuggestions = "markup" // show suggestions inline, marked as insertions and deletions
+)
+
+// SuggestionsViewMode gets the view mode to request from the docs API for
+// one of the ways of dealing with suggestions. An empty string gives the
+// default view mode for the current user.
+func SuggestionsViewMode(suggestions string) (string, error) {
+	switch suggestions {
+	case "":
+		return "DEFAULT_FOR_CURRENT_ACCESS", nil
+	case AcceptSuggestions:
+		return "PREVIEW_SUGGESTIONS_ACCEPTED", nil
+	case RejectSuggestions:
+		return "PREVIEW_WITHOUT_SUGGESTIONS", nil
+	case MarkupSuggestions:
+		return "SUGGESTIONS_INLINE", nil
+	default:
+		return "", fmt.Errorf("invalid value for suggestions: %q (should be accept, reject, or markup)", suggestions)
+	}
+}
+
+// ApplySuggestions removes suggested insertions or deletions from a
+// document that was fetched with suggestions inline, so that it looks as if
+// all suggestions were accepted or rejected. Only text is affected;
+// suggested changes to styles are left as they are. Indices in the document
+// are not updated.
+func ApplySuggestions(doc *docs.Document, accept bool) {
+	doc.Body.Content = applySuggestions(doc.Body.Content, accept)
+	for id, footnote := range doc.Footnotes {
+		footnote.Content = applySuggestions(footnote.Content, accept)
+		doc.Footnotes[id] = footnote
+	}
+}
+
+func applySuggestions(content []*docs.StructuralElement, accept bool) []*docs.StructuralElement {
+	var out []*docs.StructuralElement
+	for _, elem := range content {
+		switch {
+		case elem.Paragraph != nil:
+			var elements []*docs.ParagraphElement
+			for _, el := range elem.Paragraph.Elements {
+				if !isRemoved(el, accept) {
+					elements = append(elements, el)
+				}
+			}
+			if len(elements) == 0 {
+				continue // the whole paragraph was suggested
+			}
+			elem.Paragraph.Elements = elements
+		case elem.Table != nil:
+			for _, row := range elem.Table.TableRows {
+				for _, cell := range row.TableCells {
+					cell.Content = applySuggestions(cell.Content, accept)
+				}
+			}
+		}
+		out = append(out, elem)
+	}
+	return out
+}
+
+// HasSuggestions determines whether a document that was fetched with
+// suggestions inline has any suggested insertions or deletions in its body
+// or footnotes
+func HasSuggestions(doc *docs.Document) bool {
+	if hasSuggestions(doc.Body.Content) {
+		return true
+	}
+	for _, footnote := range doc.Footnotes {
+		if hasSuggestions(footnote.Content) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSuggestions(content []*docs.StructuralElement) bool {
+	for _, elem := range content {
+		switch {
+		case elem.Paragraph != nil:
+			for _, el := range elem.Paragraph.Elements {
+				inserted, deleted := suggestionIDs(el)
+				if len(inserted) > 0 || len(deleted) > 0 {
+					return true
+				}
+			}
+		case elem.Table != nil:
+			for _, row := range elem.Table.TableRows {
+				for _, cell := range row.TableCells {
+					if hasSuggestions(cell.Content) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isRemoved determines whether a paragraph element disappears when suggestions are accepted or rejected
+func isRemoved(el *docs.ParagraphElement, accept bool) bool {
+	inserted, deleted := suggestionIDs(el)
+	if accept {
+		return len(deleted) > 0
+	}
+	return len(inserted) > 0
+}
+
+// suggestionIDs gets the IDs of the suggestions that insert or delete a paragraph element
+func suggestionIDs(el *docs.ParagraphElement) (inserted, deleted []string) {
+	switch {
+	case el.TextRun != nil:
+		return el.TextRun.SuggestedInsertionIds, el.TextRun.SuggestedDeletionIds
+	case el.InlineObjectElement != nil:
+		return el.InlineObjectElement.SuggestedInsertionIds, el.InlineObjectElement.SuggestedDeletionIds
+	case el.FootnoteReference != nil:
+		return el.FootnoteReference.SuggestedInsertionIds, el.FootnoteReference.SuggestedDeletionIds
+	case el.PageBreak != nil:
+		return el.PageBreak.SuggestedInsertionIds, el.PageBreak.SuggestedDeletionIds
+	case el.HorizontalRule != nil:
+		return el.HorizontalRule.SuggestedInsertionIds, el.HorizontalRule.SuggestedDeletionIds
+	}
+	return nil, nil
+}
@@ -0,0 +1,79 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// processChart writes a chart linked from a google sheet as a figure,
+// containing either a table of its data or an image that links to the
+// spreadsheet
+func (dc *htmlConverter) processChart(out *bytes.Buffer, id string, emb *docs.EmbeddedObject) {
+	source := googledoc.ChartURL(emb.LinkedContentReference)
+	if chart, ok := dc.opts.ChartTables[id]; ok && len(chart.Rows) > 0 {
+		fmt.Fprint(out, "<figure class=\"chart\">\n")
+		writeTable(out, chart.Rows)
+		fmt.Fprintf(out, "<figcaption><a href=\"%s\">%s</a></figcaption>\n</figure>\n",
+			html.EscapeString(chart.URL), html.EscapeString(googledoc.ChartTitle(chart.Title)))
+		return
+	}
+
+	img, ok := dc.imageTag(id, emb)
+	switch {
+	case !ok && source == "":
+		log.Println("warning: ignoring linked content with no image", id)
+	case !ok:
+		log.Println("warning: no image for linked chart, writing a link instead", id)
+		fmt.Fprintf(out, "<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(source), html.EscapeString(googledoc.ChartTitle(emb.Title)))
+	case source == "":
+		fmt.Fprintf(out, "<figure class=\"chart\">\n%s\n</figure>\n", img)
+	default:
+		fmt.Fprintf(out, "<figure class=\"chart\">\n<a href=\"%s\">%s</a>\n</figure>\n", html.EscapeString(source), img)
+	}
+}
+
+// writeTable writes rows of cell values as a table with the first row as
+// the header
+func writeTable(out *bytes.Buffer, rows [][]string) {
+	var columns int
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	fmt.Fprint(out, "<table>\n")
+	for i, row := range rows {
+		tag := "td"
+		switch {
+		case i == 0:
+			tag = "th"
+			fmt.Fprint(out, "<thead>\n")
+		case i == 1:
+			fmt.Fprint(out, "<tbody>\n")
+		}
+
+		fmt.Fprint(out, "<tr>")
+		for j := 0; j < columns; j++ {
+			var cell string
+			if j < len(row) {
+				cell = row[j]
+			}
+			fmt.Fprintf(out, "<%s>%s</%s>", tag, html.EscapeString(cell), tag)
+		}
+		fmt.Fprint(out, "</tr>\n")
+
+		if i == 0 {
+			fmt.Fprint(out, "</thead>\n")
+		}
+	}
+	if len(rows) > 1 {
+		fmt.Fprint(out, "</tbody>\n")
+	}
+	fmt.Fprint(out, "</table>\n")
+}
@@ -0,0 +1,877 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/markdown"
+	"google.golang.org/api/docs/v1"
+)
+
+// Options controls the conversion of google docs to HTML
+type Options struct {
+	// ImageURLByObjectID gives the src attribute for the image for each
+	// inline object, which may be a relative path or a data URI
+	ImageURLByObjectID map[string]string
+
+	// MarkupSuggestions renders suggested insertions and deletions as
+	// <ins> and <del>, as tracked changes
+	MarkupSuggestions bool
+
+	// FootnoteStyle determines where the content of footnotes goes
+	FootnoteStyle string
+
+	// ChartTables holds data to render as tables in place of linked
+	// charts, keyed by inline object ID. Other charts are rendered as
+	// images that link to their spreadsheet.
+	ChartTables map[string]*googledoc.Chart
+
+	// StyleRules assigns roles such as callouts and highlights to
+	// paragraphs and text according to their style. It may be nil.
+	StyleRules *googledoc.StyleRules
+
+	// HeadingURLs gives the URL for links to each heading, keyed by
+	// heading ID. If nil, links to headings point to anchors within the
+	// converted document.
+	HeadingURLs map[string]string
+
+	// MathStyle determines how latex math is written
+	MathStyle string
+
+	// DocURLs gives the URL for links to other documents, keyed by doc ID,
+	// for documents that are converted together, such as the chapters of
+	// a book. Links to headings in those documents are looked up in
+	// HeadingURLs.
+	DocURLs map[string]string
+
+	// HeadingIDPrefix is prepended to the id attributes of headings, so
+	// that several documents can be put on one page
+	HeadingIDPrefix string
+
+	// FootnoteOffset is the number of footnotes before this document, so
+	// that footnote numbers can continue across documents
+	FootnoteOffset int
+
+	// XHTML writes elements with no content as self-closing tags, so that
+	// the output is well-formed XML as EPUB requires
+	XHTML bool
+}
+
+// FromGoogleDoc converts a google doc to HTML
+func FromGoogleDoc(doc *docs.Document, opts *Options) (string, error) {
+	return Convert(doc, doc.Body.Content, opts)
+}
+
+// Convert converts a part of a google doc to HTML. The output is a
+// fragment that is intended to be inserted into the body of a page. Math is
+// written in spans that KaTeX can render, with the class "math inline" or
+// "math display", or as MathML if the math style is MathMLMath.
+func Convert(doc *docs.Document, elements []*docs.StructuralElement, opts *Options) (string, error) {
+	switch opts.FootnoteStyle {
+	case EndnoteFootnotes, AsideFootnotes, PopupFootnotes:
+	default:
+		return "", fmt.Errorf("invalid footnote style %q", opts.FootnoteStyle)
+	}
+	switch opts.MathStyle {
+	case KaTeXMath, MathMLMath:
+	default:
+		return "", fmt.Errorf("invalid math style %q", opts.MathStyle)
+	}
+
+	conv := htmlConverter{
+		doc:         doc,
+		opts:        opts,
+		anchors:     markdown.HeadingAnchors(doc.Body.Content),
+		headingURLs: opts.HeadingURLs,
+		replace:     make(map[string]string),
+	}
+	for id, anchor := range conv.anchors {
+		conv.anchors[id] = opts.HeadingIDPrefix + anchor
+	}
+	if conv.headingURLs == nil {
+		conv.headingURLs = make(map[string]string)
+		for id, anchor := range conv.anchors {
+			conv.headingURLs[id] = "#" + anchor
+		}
+	}
+
+	var body bytes.Buffer
+	err := conv.process(&body, elements)
+	if err != nil {
+		return "", fmt.Errorf("error converting document body to html: %w", err)
+	}
+
+	// process the footnotes, in the order they were first referenced
+	err = conv.writeFootnotes(&body)
+	if err != nil {
+		return "", err
+	}
+
+	// latex definitions go first so that KaTeX sees them before they are used
+	var out strings.Builder
+	if conv.mathDefs.Len() > 0 && opts.MathStyle == KaTeXMath {
+		fmt.Fprintf(&out, "<div class=\"math display\" hidden>\\[\n%s\\]</div>\n", html.EscapeString(conv.mathDefs.String()))
+	}
+
+	// apply string-to-string replacements (used to rewrite \T1 to \Tone due to latex rules)
+	s := googledoc.RenameLatexSymbols(body.String(), conv.replace)
+	if opts.MathStyle == MathMLMath {
+		s = convertMath(s, conv.mathDefs.String())
+	}
+	if opts.XHTML {
+		s = toXHTML(s)
+	}
+	out.WriteString(s)
+	return out.String(), nil
+}
+
+type htmlConverter struct {
+	doc         *docs.Document
+	opts        *Options
+	anchors     map[string]string // id attributes for headings, keyed by heading ID
+	headingURLs map[string]string
+	codeBlock   bytes.Buffer      // text identified as lines of code
+	footnotes   []string          // footnote IDs in order of first reference
+	mathDefs    bytes.Buffer      // latex \newcommand definitions
+	replace     map[string]string // string replacements to apply to whole doc
+	blockState
+}
+
+// blockState is the state of the converter within a sequence of elements,
+// which is set aside while converting footnotes and table cells
+type blockState struct {
+	lists      []string               // tags of the currently open lists, innermost last
+	wrapper    string                 // role of the open element around consecutive paragraphs, if any
+	hidden     googledoc.HiddenBlocks // spoilers and collapsible sections opened by markers
+	inSpoiler  bool                   // whether a spoiler block is open in the output
+	inFootnote bool
+	checked    bool     // whether the current paragraph is a completed checklist item
+	asides     []string // footnotes to write after the current block
+}
+
+func (dc *htmlConverter) process(out *bytes.Buffer, content []*docs.StructuralElement) error {
+	for _, elem := range content {
+		// for any element other than paragraph, close any open code block, list, or quote
+		if elem.Paragraph == nil {
+			dc.flushCodeBlock(out)
+			dc.closeLists(out, 0)
+			dc.setWrapper(out, "")
+			if !dc.hidden.InSpoiler() {
+				dc.setSpoiler(out, false)
+			}
+		}
+
+		switch {
+		case elem.Table != nil:
+			// tables with a single cell of monospace text are code blocks
+			if code, ok := dc.opts.StyleRules.TableCode(elem.Table); ok {
+				dc.codeBlock.WriteString(code)
+				dc.flushCodeBlock(out)
+				continue
+			}
+			err := dc.processTable(out, elem.Table)
+			if err != nil {
+				return err
+			}
+		case elem.TableOfContents != nil:
+			log.Println("warning: ignoring table of contents")
+		case elem.SectionBreak != nil:
+			// section breaks only affect page layout
+		case elem.Paragraph != nil:
+			err := dc.processParagraph(out, elem.Paragraph)
+			if err != nil {
+				return err
+			}
+		default:
+			log.Println("warning: encountered a body element of unknown type")
+		}
+	}
+
+	dc.flushCodeBlock(out)
+	dc.closeLists(out, 0)
+	dc.setWrapper(out, "")
+	dc.closeHiddenBlocks(out)
+	return nil
+}
+
+// processNested converts content that is nested inside another element,
+// such as a table cell, setting aside the state of the surrounding content
+func (dc *htmlConverter) processNested(out *bytes.Buffer, content []*docs.StructuralElement) error {
+	saved := dc.blockState
+	dc.blockState = blockState{inFootnote: saved.inFootnote}
+	defer func() { dc.blockState = saved }()
+	return dc.process(out, content)
+}
+
+// flushCodeBlock writes any lines stored in dc.codeBlock to a pre element,
+// or if there are no stored lines then it does nothing
+func (dc *htmlConverter) flushCodeBlock(out *bytes.Buffer) {
+	if dc.codeBlock.Len() == 0 {
+		return
+	}
+
+	lang, code := googledoc.CodeLanguage(strings.ReplaceAll(dc.codeBlock.String(), "\v", "\n"))
+	if lang != "" {
+		fmt.Fprintf(out, "<pre><code class=\"language-%s\">", html.EscapeString(lang))
+	} else {
+		fmt.Fprint(out, "<pre><code>")
+	}
+	fmt.Fprint(out, html.EscapeString(strings.TrimRight(code, "\n")))
+	fmt.Fprint(out, "</code></pre>\n")
+	dc.codeBlock.Reset()
+}
+
+// closeLists closes open lists until there are at most depth remaining
+func (dc *htmlConverter) closeLists(out *bytes.Buffer, depth int) {
+	for len(dc.lists) > depth {
+		tag := dc.lists[len(dc.lists)-1]
+		dc.lists = dc.lists[:len(dc.lists)-1]
+		fmt.Fprintf(out, "</li>\n</%s>\n", tag)
+	}
+}
+
+// openItem starts a list item, opening and closing lists until the nesting
+// level of the bullet is reached. Nested lists go inside the item above them.
+func (dc *htmlConverter) openItem(out *bytes.Buffer, b *docs.Bullet) {
+	depth := int(b.NestingLevel) + 1
+	dc.closeLists(out, depth)
+	if len(dc.lists) == depth {
+		fmt.Fprint(out, "</li>\n")
+	}
+	for len(dc.lists) < depth {
+		level := &docs.Bullet{ListId: b.ListId, NestingLevel: int64(len(dc.lists))}
+		switch googledoc.ListKind(dc.doc, level) {
+		case googledoc.NumberedList:
+			fmt.Fprint(out, "<ol>\n")
+			dc.lists = append(dc.lists, "ol")
+		case googledoc.Checklist:
+			fmt.Fprint(out, "<ul class=\"checklist\">\n")
+			dc.lists = append(dc.lists, "ul")
+		default:
+			fmt.Fprint(out, "<ul>\n")
+			dc.lists = append(dc.lists, "ul")
+		}
+	}
+	fmt.Fprint(out, "<li>")
+}
+
+// elements written around consecutive paragraphs with each role
+var wrapperTags = map[string][2]string{
+	googledoc.QuoteRole:    {"<blockquote>\n", "</blockquote>\n"},
+	googledoc.EpigraphRole: {"<blockquote class=\"epigraph\">\n", "</blockquote>\n"},
+	googledoc.CalloutRole:  {"<aside class=\"callout\">\n", "</aside>\n"},
+}
+
+// setWrapper closes the element around the previous paragraphs if it is
+// for a different role, and opens one for the given role
+func (dc *htmlConverter) setWrapper(out *bytes.Buffer, role string) {
+	if role == dc.wrapper {
+		return
+	}
+	if dc.wrapper != "" {
+		fmt.Fprint(out, wrapperTags[dc.wrapper][1])
+	}
+	if role != "" {
+		fmt.Fprint(out, wrapperTags[role][0])
+	}
+	dc.wrapper = role
+}
+
+// heading elements for each named style
+var headingTags = map[string]string{
+	"TITLE":     "h1",
+	"HEADING_1": "h1",
+	"HEADING_2": "h2",
+	"HEADING_3": "h3",
+	"HEADING_4": "h4",
+	"HEADING_5": "h5",
+	"HEADING_6": "h6",
+}
+
+func (dc *htmlConverter) processParagraph(out *bytes.Buffer, p *docs.Paragraph) error {
+	// markers for spoilers and collapsible sections are not written out
+	if marker := googledoc.ParseBlockMarker(p); marker != nil {
+		dc.processBlockMarker(out, marker)
+		return nil
+	}
+
+	// spoilers are either marked explicitly or assigned by style rules
+	role := dc.opts.StyleRules.BlockRole(p)
+	dc.setSpoiler(out, dc.hidden.InSpoiler() || role == googledoc.SpoilerRole)
+
+	// deal with code blocks
+	if role == googledoc.CodeRole || (role == "" && p.Bullet == nil && dc.opts.StyleRules.IsCode(p)) {
+		// code that is indented after a list item belongs to that item
+		if dc.codeBlock.Len() == 0 && !googledoc.IsIndented(p) {
+			dc.closeLists(out, 0)
+		}
+		dc.setWrapper(out, "")
+		for _, el := range p.Elements {
+			if el.TextRun != nil {
+				fmt.Fprint(&dc.codeBlock, el.TextRun.Content)
+			}
+		}
+		return nil
+	}
+
+	// if not a code block then flush any buffered code block
+	dc.flushCodeBlock(out)
+
+	// lines that begin \newcommand are moved to the top, with digits removed from the symbol
+	text := strings.TrimSuffix(googledoc.ParagraphText(p), "\n")
+	if m := newcommandRegexp.FindStringSubmatch(text); m != nil {
+		fixed := fixLatexSymbol(m[1])
+		if fixed != m[1] {
+			dc.replace[m[1]] = fixed
+		}
+		fmt.Fprintf(&dc.mathDefs, "\\newcommand{%s}{%s}\n", fixed, m[2])
+		return nil
+	}
+
+	// empty paragraphs are only there for spacing
+	if strings.TrimSpace(text) == "" && !googledoc.HasObjects(p) {
+		return nil
+	}
+
+	namedStyle := p.ParagraphStyle.NamedStyleType
+	wrapper := role
+	switch {
+	case role == googledoc.SpoilerRole:
+		wrapper = ""
+		namedStyle = "NORMAL_TEXT"
+	case role != "":
+		namedStyle = "NORMAL_TEXT"
+	case googledoc.IsIndented(p) && p.Bullet == nil:
+		wrapper = googledoc.QuoteRole
+	}
+	_, isHeading := headingTags[namedStyle]
+
+	// deal with list items, which stay open until the next item so that
+	// nested lists can go inside them
+	if p.Bullet != nil && isHeading {
+		log.Println("warning: found a heading that is part of a bulleted list, ignoring the bullet")
+	}
+	if p.Bullet != nil && !isHeading {
+		dc.setWrapper(out, "")
+		dc.openItem(out, p.Bullet)
+		if googledoc.ListKind(dc.doc, p.Bullet) == googledoc.Checklist {
+			// the checkbox marks the item as completed, so drop the strikethrough
+			dc.checked = googledoc.IsChecked(p)
+			if dc.checked {
+				fmt.Fprint(out, `<input type="checkbox" disabled checked> `)
+			} else {
+				fmt.Fprint(out, `<input type="checkbox" disabled> `)
+			}
+		}
+		err := dc.processElements(out, p)
+		dc.checked = false
+		if err != nil {
+			return err
+		}
+		dc.flushAsides(out)
+		return nil
+	}
+	dc.closeLists(out, 0)
+	dc.setWrapper(out, wrapper)
+
+	// paragraphs that contain only a horizontal rule or only an image
+	// stand on their own
+	if googledoc.IsRule(p) {
+		fmt.Fprint(out, "<hr>\n")
+		return nil
+	}
+	if id, ok := googledoc.FigureObject(p); ok && !isHeading {
+		dc.processFigure(out, id)
+		dc.flushAsides(out)
+		return nil
+	}
+
+	// choose the element for the paragraph
+	var open, close string
+	switch {
+	case isHeading:
+		tag := headingTags[namedStyle]
+		var attrs string
+		if anchor, ok := dc.anchors[p.ParagraphStyle.HeadingId]; ok && !dc.inFootnote {
+			attrs = fmt.Sprintf(` id="%s"`, html.EscapeString(anchor))
+		}
+		if namedStyle == "TITLE" {
+			attrs += ` class="title"`
+		}
+		open, close = "<"+tag+attrs+">", "</"+tag+">"
+	case namedStyle == "SUBTITLE":
+		open, close = `<p class="subtitle">`, "</p>"
+	case p.ParagraphStyle.Alignment == "CENTER":
+		open, close = `<p class="center">`, "</p>"
+	default:
+		open, close = "<p>", "</p>"
+	}
+
+	fmt.Fprint(out, open)
+	if err := dc.processElements(out, p); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, close)
+	dc.flushAsides(out)
+	return nil
+}
+
+// processElements writes the content of a paragraph
+func (dc *htmlConverter) processElements(out *bytes.Buffer, p *docs.Paragraph) error {
+	for _, el := range p.Elements {
+		switch {
+		case el.ColumnBreak != nil:
+			log.Println("warning: ignoring column break")
+		case el.Equation != nil:
+			log.Println("warning: ignoring equation")
+		case el.FootnoteReference != nil:
+			err := dc.writeFootnoteRef(out, el.FootnoteReference.FootnoteId)
+			if err != nil {
+				return err
+			}
+		case el.AutoText != nil:
+			log.Println("warning: ignoring auto text")
+		case el.HorizontalRule != nil:
+			log.Println("warning: ignoring horizontal rule within text")
+		case el.InlineObjectElement != nil:
+			dc.processInlineObject(out, el.InlineObjectElement)
+		case el.PageBreak != nil:
+			// web pages have no pages to break
+		case el.TextRun != nil:
+			dc.processTextRun(out, el.TextRun)
+		default:
+			log.Println("warning: encountered a paragraph element of unknown type")
+		}
+	}
+	return nil
+}
+
+// imageTag gets the img element for an inline object
+func (dc *htmlConverter) imageTag(id string, emb *docs.EmbeddedObject) (string, bool) {
+	src, ok := dc.opts.ImageURLByObjectID[id]
+	if !ok {
+		return "", false
+	}
+	alt := emb.Description
+	if alt == "" {
+		alt = emb.Title
+	}
+	return fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(src), html.EscapeString(alt)), true
+}
+
+func (dc *htmlConverter) embeddedObject(id string) (*docs.EmbeddedObject, bool) {
+	obj, ok := dc.doc.InlineObjects[id]
+	if !ok || obj.InlineObjectProperties == nil || obj.InlineObjectProperties.EmbeddedObject == nil {
+		log.Println("warning: could not find inline object for id", id)
+		return nil, false
+	}
+	return obj.InlineObjectProperties.EmbeddedObject, true
+}
+
+// processFigure writes an image or chart that stands on its own
+func (dc *htmlConverter) processFigure(out *bytes.Buffer, id string) {
+	emb, ok := dc.embeddedObject(id)
+	if !ok {
+		return
+	}
+	if emb.LinkedContentReference != nil {
+		dc.processChart(out, id, emb)
+		return
+	}
+	img, ok := dc.imageTag(id, emb)
+	if !ok {
+		log.Println("warning: no image for inline object", id)
+		return
+	}
+	fmt.Fprintf(out, "<figure>\n%s\n</figure>\n", img)
+}
+
+// processInlineObject writes an image that appears within text
+func (dc *htmlConverter) processInlineObject(out *bytes.Buffer, objRef *docs.InlineObjectElement) {
+	id := objRef.InlineObjectId
+	emb, ok := dc.embeddedObject(id)
+	if !ok {
+		return
+	}
+
+	img, ok := dc.imageTag(id, emb)
+	source := googledoc.ChartURL(emb.LinkedContentReference)
+	switch {
+	case !ok && source == "":
+		log.Println("warning: no image for inline object", id)
+	case !ok:
+		fmt.Fprintf(out, `<a href="%s">%s</a>`, html.EscapeString(source), html.EscapeString(googledoc.ChartTitle(emb.Title)))
+	case source == "":
+		fmt.Fprint(out, img)
+	default:
+		fmt.Fprintf(out, `<a href="%s">%s</a>`, html.EscapeString(source), img)
+	}
+}
+
+func (dc *htmlConverter) processTextRun(out *bytes.Buffer, t *docs.TextRun) {
+	// the newline at the end of each paragraph is dealt with by the caller
+	content := strings.TrimSuffix(t.Content, "\n")
+	if content == "" {
+		return
+	}
+
+	// build the elements that wrap the text, outermost first
+	var tags []string
+	style := t.TextStyle
+	if dc.opts.MarkupSuggestions {
+		switch {
+		case len(t.SuggestedDeletionIds) > 0:
+			tags = append(tags, "del")
+		case len(t.SuggestedInsertionIds) > 0:
+			tags = append(tags, "ins")
+		}
+	}
+	if style.Link != nil {
+		tags = append(tags, `a href="`+html.EscapeString(dc.linkURL(style.Link))+`"`)
+	}
+	if style.Bold {
+		tags = append(tags, "strong")
+	}
+	if style.Italic {
+		tags = append(tags, "em")
+	}
+	if style.Strikethrough && !dc.checked {
+		tags = append(tags, "s")
+	}
+	if style.Underline && style.Link == nil {
+		tags = append(tags, "u")
+	}
+	if style.SmallCaps {
+		tags = append(tags, `span class="smallcaps"`)
+	}
+	switch style.BaselineOffset {
+	case "SUBSCRIPT":
+		tags = append(tags, "sub")
+	case "SUPERSCRIPT":
+		tags = append(tags, "sup")
+	}
+
+	code := dc.opts.StyleRules.IsMonospace(style.WeightedFontFamily)
+	switch dc.opts.StyleRules.InlineRole(t) {
+	case googledoc.HighlightRole:
+		tags = append(tags, "mark")
+	case googledoc.SpoilerRole:
+		tags = append(tags, `span class="spoiler"`)
+	case googledoc.CodeRole:
+		code = true
+	}
+	if code {
+		tags = append(tags, "code")
+	}
+
+	// keep whitespace outside of the elements
+	left, middle, right := splitSpace(content)
+	out.WriteString(escape(left))
+	for _, tag := range tags {
+		fmt.Fprintf(out, "<%s>", tag)
+	}
+	if code {
+		out.WriteString(escapeCode(middle))
+	} else {
+		out.WriteString(escape(middle))
+	}
+	for i := len(tags) - 1; i >= 0; i-- {
+		fmt.Fprintf(out, "</%s>", strings.Fields(tags[i])[0])
+	}
+	out.WriteString(escape(right))
+}
+
+// processTable writes a table, with the first row as the header row if
+// there is more than one row. Merged cells become cells that span several
+// rows or columns.
+func (dc *htmlConverter) processTable(out *bytes.Buffer, t *docs.Table) error {
+	header := len(t.TableRows) > 1
+	covered := make(map[[2]int]bool) // cells hidden by merged cells above or to the left
+	fmt.Fprint(out, "<table>\n")
+	for i, row := range t.TableRows {
+		switch {
+		case i == 0 && header:
+			fmt.Fprint(out, "<thead>\n")
+		case i == 1:
+			fmt.Fprint(out, "<tbody>\n")
+		}
+
+		fmt.Fprint(out, "<tr>")
+		for j, cell := range row.TableCells {
+			if covered[[2]int{i, j}] {
+				continue
+			}
+
+			tag := "td"
+			if i == 0 && header {
+				tag = "th"
+			}
+			var attrs string
+			rowspan, colspan := int64(1), int64(1)
+			if s := cell.TableCellStyle; s != nil {
+				if s.RowSpan > 1 {
+					rowspan = s.RowSpan
+					attrs += fmt.Sprintf(` rowspan="%d"`, rowspan)
+				}
+				if s.ColumnSpan > 1 {
+					colspan = s.ColumnSpan
+					attrs += fmt.Sprintf(` colspan="%d"`, colspan)
+				}
+			}
+			for r := 0; r < int(rowspan); r++ {
+				for c := 0; c < int(colspan); c++ {
+					covered[[2]int{i + r, j + c}] = true
+				}
+			}
+
+			fmt.Fprintf(out, "<%s%s>", tag, attrs)
+			if err := dc.processCell(out, cell.Content); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "</%s>", tag)
+		}
+		fmt.Fprint(out, "</tr>\n")
+
+		if i == 0 && header {
+			fmt.Fprint(out, "</thead>\n")
+		}
+	}
+	if header {
+		fmt.Fprint(out, "</tbody>\n")
+	}
+	fmt.Fprint(out, "</table>\n")
+	return nil
+}
+
+// processCell writes the content of a table cell. A cell with a single
+// paragraph of ordinary text is written without a paragraph element.
+func (dc *htmlConverter) processCell(out *bytes.Buffer, content []*docs.StructuralElement) error {
+	// drop the empty paragraph that follows nested tables
+	if n := len(content); n > 1 && content[n-1].Paragraph != nil && strings.TrimSpace(googledoc.ParagraphText(content[n-1].Paragraph)) == "" {
+		content = content[:n-1]
+	}
+
+	if len(content) == 1 && content[0].Paragraph != nil {
+		p := content[0].Paragraph
+		role := dc.opts.StyleRules.BlockRole(p)
+		plain := p.ParagraphStyle.NamedStyleType == "NORMAL_TEXT" && p.Bullet == nil && !googledoc.IsIndented(p)
+		if plain && role == "" && !dc.opts.StyleRules.IsCode(p) && googledoc.ParseBlockMarker(p) == nil {
+			saved := dc.asides
+			dc.asides = nil
+			err := dc.processElements(out, p)
+			dc.flushAsides(out)
+			dc.asides = saved
+			return err
+		}
+	}
+	return dc.processNested(out, content)
+}
+
+// linkURL determines the URL for a link, resolving links to headings
+func (dc *htmlConverter) linkURL(link *docs.Link) string {
+	headingID := link.HeadingId
+	if headingID == "" {
+		headingID = dc.linkedHeading(link.Url)
+	}
+	if headingID == "" {
+		if link.Url == "" && link.BookmarkId != "" {
+			log.Printf("warning: links to bookmarks are not supported (bookmark %s)", link.BookmarkId)
+		}
+		if u, ok := dc.opts.DocURLs[googledoc.DocIDFromURL(link.Url)]; ok && link.Url != "" {
+			return u
+		}
+		return link.Url
+	}
+
+	if u, ok := dc.headingURLs[headingID]; ok {
+		return u
+	}
+	log.Printf("warning: no heading found for link to %s", headingID)
+	return link.Url
+}
+
+// linkedHeading gets the heading ID from a URL that links to a heading in
+// the document being converted, or in one of the documents in DocURLs, or
+// returns an empty string
+func (dc *htmlConverter) linkedHeading(s string) string {
+	if s == "" {
+		return ""
+	}
+	id := googledoc.DocIDFromURL(s)
+	if _, ok := dc.opts.DocURLs[id]; !ok && (dc.doc.DocumentId == "" || id != dc.doc.DocumentId) {
+		return ""
+	}
+	if i := strings.Index(s, "#heading="); i >= 0 {
+		return s[i+len("#heading="):]
+	}
+	return ""
+}
+
+// escape escapes HTML special characters in text. As in the markdown
+// exporter, a backslash followed by letters is treated as a latex symbol,
+// and is wrapped in a span for KaTeX to render. The span also takes in any
+// arguments in braces and any subscripts and superscripts that follow.
+func escape(s string) string {
+	var out strings.Builder
+	for len(s) > 0 {
+		r, sz := utf8.DecodeRuneInString(s)
+		if r == '\\' {
+			if n := mathLength(s); n > 0 {
+				fmt.Fprintf(&out, `<span class="math inline">\(%s\)</span>`, html.EscapeString(s[:n]))
+				s = s[n:]
+				continue
+			}
+		}
+		s = s[sz:]
+
+		switch r {
+		case '\v':
+			// google docs uses vertical tabs for line breaks within a paragraph
+			out.WriteString("<br>\n")
+		case '\n':
+			out.WriteString(" ")
+		default:
+			out.WriteString(html.EscapeString(string(r)))
+		}
+	}
+	return out.String()
+}
+
+// mathLength gets the length of the latex at the start of a string, which
+// is a backslash and a symbol name followed by any number of groups in
+// braces and scripts. It returns zero if the string does not start with a
+// latex symbol.
+func mathLength(s string) int {
+	n := symbolLength(s)
+	if n == 0 {
+		return 0
+	}
+	for n < len(s) {
+		switch s[n] {
+		case '{':
+			m := groupLength(s[n:])
+			if m == 0 {
+				return n
+			}
+			n += m
+		case '_', '^':
+			rest := s[n+1:]
+			m := groupLength(rest)
+			if m == 0 {
+				m = symbolLength(rest)
+			}
+			if m == 0 {
+				r, sz := utf8.DecodeRuneInString(rest)
+				if unicode.IsLetter(r) || unicode.IsNumber(r) {
+					m = sz
+				}
+			}
+			if m == 0 {
+				return n
+			}
+			n += 1 + m
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// symbolLength gets the length of a backslash followed by letters and
+// numbers at the start of a string, or zero if there is none
+func symbolLength(s string) int {
+	if !strings.HasPrefix(s, "\\") {
+		return 0
+	}
+	next, _ := utf8.DecodeRuneInString(s[1:])
+	if !unicode.IsLetter(next) {
+		return 0
+	}
+	end := strings.IndexFunc(s[1:], func(r rune) bool {
+		return !unicode.IsNumber(r) && !unicode.IsLetter(r)
+	})
+	if end < 0 {
+		return len(s)
+	}
+	return end + 1
+}
+
+// groupLength gets the length of a group in balanced braces at the start
+// of a string, or zero if there is none
+func groupLength(s string) int {
+	if !strings.HasPrefix(s, "{") {
+		return 0
+	}
+	var depth int
+	for i, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '\n', '\v':
+			return 0
+		}
+	}
+	return 0
+}
+
+// a regular expression for elements that have no closing tag in HTML
+var voidElementRegexp = regexp.MustCompile(`<(br|hr|img|input)((?: [^>]*)?)>`)
+
+// toXHTML closes the elements that have no closing tag, and gives values
+// to boolean attributes. Text never contains a literal "<", since it is
+// always escaped, so every match is a tag that we wrote.
+func toXHTML(s string) string {
+	return voidElementRegexp.ReplaceAllStringFunc(s, func(tag string) string {
+		m := voidElementRegexp.FindStringSubmatch(tag)
+		attrs := m[2]
+		if m[1] == "input" {
+			attrs = strings.ReplaceAll(attrs, " disabled", ` disabled="disabled"`)
+			attrs = strings.ReplaceAll(attrs, " checked", ` checked="checked"`)
+		}
+		return "<" + m[1] + attrs + "/>"
+	})
+}
+
+// escapeCode escapes text that is to appear within a code element
+func escapeCode(s string) string {
+	return strings.ReplaceAll(html.EscapeString(s), "\v", "<br>\n")
+}
+
+// splitSpace splits a string into leading whitespace, trailing
+// whitespace, and everything inbetween
+func splitSpace(s string) (left, middle, right string) {
+	trimmed := strings.TrimLeftFunc(s, unicode.IsSpace)
+	left = s[:len(s)-len(trimmed)]
+	middle = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	right = trimmed[len(middle):]
+	return
+}
+
+// a regular expression for latex \newcommand lines
+var newcommandRegexp = regexp.MustCompile(`^\\newcommand\{(.+?)\}\{(.*)\}$`)
+
+// fixLatexSymbol changes \T1 to \Tone and so forth, because latex does not permit numbers in symbols
+var fixLatexSymbol = strings.NewReplacer(
+	"0", "zero",
+	"1", "one",
+	"2", "two",
+	"3", "three",
+	"4", "four",
+	"5", "five",
+	"6", "six",
+	"7", "seven",
+	"8", "eight",
+	"9", "nine",
+).Replace
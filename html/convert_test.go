@@ -0,0 +1,170 @@
+package html
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
+)
+
+func TestFromGoogleDoc(t *testing.T) {
+	heading := doctest.Paragraph(1, "HEADING_1", nil, &docs.TextRun{Content: "Intro & more\n"})
+	heading.Paragraph.ParagraphStyle.HeadingId = "h.1"
+	image := doctest.Paragraph(45, "NORMAL_TEXT", nil, &docs.TextRun{Content: "\n"})
+	image.Paragraph.Elements = append([]*docs.ParagraphElement{{InlineObjectElement: &docs.InlineObjectElement{InlineObjectId: "img"}}}, image.Paragraph.Elements...)
+
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			heading,
+			doctest.Paragraph(14, "NORMAL_TEXT", nil,
+				&docs.TextRun{Content: "See "},
+				&docs.TextRun{Content: "above", TextStyle: &docs.TextStyle{Bold: true, Link: &docs.Link{HeadingId: "h.1"}}},
+				&docs.TextRun{Content: " for \\alpha < 1\n"}),
+			doctest.Paragraph(37, "NORMAL_TEXT", &docs.Bullet{ListId: "l"}, &docs.TextRun{Content: "one\n"}),
+			doctest.Paragraph(41, "NORMAL_TEXT", &docs.Bullet{ListId: "l", NestingLevel: 1}, &docs.TextRun{Content: "two\n"}),
+			image,
+			doctest.Paragraph(47, "NORMAL_TEXT", nil, &docs.TextRun{Content: "x := 1\n", TextStyle: &docs.TextStyle{
+				WeightedFontFamily: &docs.WeightedFontFamily{FontFamily: "Courier New"},
+			}}),
+		}},
+		Lists: map[string]docs.List{
+			"l": {ListProperties: &docs.ListProperties{NestingLevels: []*docs.NestingLevel{
+				{GlyphSymbol: "●"},
+				{GlyphType: "DECIMAL"},
+			}}},
+		},
+		InlineObjects: map[string]docs.InlineObject{
+			"img": {InlineObjectProperties: &docs.InlineObjectProperties{EmbeddedObject: &docs.EmbeddedObject{
+				Description:     "A cat",
+				ImageProperties: &docs.ImageProperties{},
+			}}},
+		},
+	}
+
+	out, err := FromGoogleDoc(&doc, &Options{ImageURLByObjectID: map[string]string{"img": "data:image/png;base64,AAAA"}})
+	require.NoError(t, err)
+	assert.Equal(t, `<h1 id="intro--more">Intro &amp; more</h1>
+<p>See <a href="#intro--more"><strong>above</strong></a> for <span class="math inline">\(\alpha\)</span> &lt; 1</p>
+<ul>
+<li>one<ol>
+<li>two</li>
+</ol>
+</li>
+</ul>
+<figure>
+<img src="data:image/png;base64,AAAA" alt="A cat">
+</figure>
+<pre><code class="language-go">x := 1</code></pre>
+`, out)
+}
+
+func TestFootnotes(t *testing.T) {
+	ref := func(id string) *docs.ParagraphElement {
+		return &docs.ParagraphElement{FootnoteReference: &docs.FootnoteReference{FootnoteId: id}}
+	}
+	para := doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Text"}, &docs.TextRun{Content: "\n"})
+	para.Paragraph.Elements = []*docs.ParagraphElement{para.Paragraph.Elements[0], ref("f"), para.Paragraph.Elements[1]}
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{para}},
+		Footnotes: map[string]docs.Footnote{
+			"f": {Content: []*docs.StructuralElement{doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: "A note\n"})}},
+		},
+	}
+
+	out, err := FromGoogleDoc(&doc, &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, `<p>Text<sup class="footnote-ref"><a href="#fn-1" id="fnref-1">1</a></sup></p>
+<section class="footnotes" role="doc-endnotes">
+<ol>
+<li id="fn-1">
+<p>A note <a href="#fnref-1" class="footnote-back" role="doc-backlink">↩</a></p>
+</li>
+</ol>
+</section>
+`, out)
+
+	out, err = FromGoogleDoc(&doc, &Options{FootnoteStyle: AsideFootnotes})
+	require.NoError(t, err)
+	assert.Equal(t, `<p>Text<sup class="footnote-ref"><a href="#fn-1" id="fnref-1">1</a></sup></p>
+<aside class="footnote" id="fn-1">
+<sup>1</sup>
+<p>A note</p>
+</aside>
+`, out)
+
+	out, err = FromGoogleDoc(&doc, &Options{FootnoteStyle: PopupFootnotes})
+	require.NoError(t, err)
+	assert.Equal(t, `<p>Text<sup class="footnote-ref"><a epub:type="noteref" href="#fn-1">1</a></sup></p>
+<aside epub:type="footnote" class="footnote" id="fn-1">
+<p>A note</p>
+</aside>
+`, out)
+}
+
+func TestXHTML(t *testing.T) {
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "one\vtwo\n"}),
+		}},
+	}
+
+	out, err := FromGoogleDoc(&doc, &Options{XHTML: true})
+	require.NoError(t, err)
+	assert.Equal(t, "<p>one<br/>\ntwo</p>\n", out)
+}
+
+func TestTableSpans(t *testing.T) {
+	cell := func(text string, rows, cols int64) *docs.TableCell {
+		return &docs.TableCell{
+			Content:        []*docs.StructuralElement{doctest.Paragraph(0, "NORMAL_TEXT", nil, &docs.TextRun{Content: text + "\n"})},
+			TableCellStyle: &docs.TableCellStyle{RowSpan: rows, ColumnSpan: cols},
+		}
+	}
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{{Table: &docs.Table{
+			Columns: 2,
+			TableRows: []*docs.TableRow{
+				{TableCells: []*docs.TableCell{cell("Both", 1, 2), cell("", 1, 1)}},
+				{TableCells: []*docs.TableCell{cell("Tall", 2, 1), cell("a", 1, 1)}},
+				{TableCells: []*docs.TableCell{cell("", 1, 1), cell("b", 1, 1)}},
+			},
+		}}}},
+	}
+
+	out, err := FromGoogleDoc(&doc, &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, `<table>
+<thead>
+<tr><th colspan="2">Both</th></tr>
+</thead>
+<tbody>
+<tr><td rowspan="2">Tall</td><td>a</td></tr>
+<tr><td>b</td></tr>
+</tbody>
+</table>
+`, out)
+}
+
+func TestMathML(t *testing.T) {
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "\\newcommand{\\half}{\\frac{1}{2}}\n"}),
+			doctest.Paragraph(33, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Take \\half of \\sum_{i}x and \\unknown.\n"}),
+		}},
+	}
+
+	out, err := FromGoogleDoc(&doc, &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, `<div class="math display" hidden>\[
+\newcommand{\half}{\frac{1}{2}}
+\]</div>
+<p>Take <span class="math inline">\(\half\)</span> of <span class="math inline">\(\sum_{i}\)</span>x and <span class="math inline">\(\unknown\)</span>.</p>
+`, out)
+
+	out, err = FromGoogleDoc(&doc, &Options{MathStyle: MathMLMath})
+	require.NoError(t, err)
+	assert.Equal(t, `<p>Take <math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><mfrac><mn>1</mn><mn>2</mn></mfrac><annotation encoding="application/x-tex">\half</annotation></semantics></math> of <math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><munder><mo largeop="true" movablelimits="true">∑</mo><mi>i</mi></munder><annotation encoding="application/x-tex">\sum_{i}</annotation></semantics></math>x and <span class="math inline">\(\unknown\)</span>.</p>
+`, out)
+}
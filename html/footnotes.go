@@ -0,0 +1,142 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Footnote styles for rendering google doc footnotes in HTML
+const (
+	EndnoteFootnotes = ""      // render footnotes as a numbered list of notes at the end
+	AsideFootnotes   = "aside" // render footnotes as <aside> elements after the paragraph that references them
+	PopupFootnotes   = "popup" // render footnotes as EPUB notes at the end, which e-readers show as popups
+)
+
+// addFootnoteID adds a footnote ID if it is not already in the list, so
+// that we know the order in which footnotes appeared in the text, and
+// returns the number of the footnote and whether this is its first reference
+func (dc *htmlConverter) addFootnoteID(id string) (int, bool) {
+	for i, f := range dc.footnotes {
+		if f == id {
+			return dc.opts.FootnoteOffset + i + 1, false
+		}
+	}
+	dc.footnotes = append(dc.footnotes, id)
+	return dc.opts.FootnoteOffset + len(dc.footnotes), true
+}
+
+// writeFootnoteRef writes a reference to a footnote, which is numbered in
+// order of first reference rather than labelled with its google doc ID
+func (dc *htmlConverter) writeFootnoteRef(out *bytes.Buffer, id string) error {
+	n, first := dc.addFootnoteID(id)
+	if dc.opts.FootnoteStyle == PopupFootnotes {
+		fmt.Fprintf(out, `<sup class="footnote-ref"><a epub:type="noteref" href="#fn-%d">%d</a></sup>`, n, n)
+		return nil
+	}
+	if first {
+		fmt.Fprintf(out, `<sup class="footnote-ref"><a href="#fn-%d" id="fnref-%d">%d</a></sup>`, n, n, n)
+	} else {
+		fmt.Fprintf(out, `<sup class="footnote-ref"><a href="#fn-%d">%d</a></sup>`, n, n)
+	}
+
+	// asides cannot go inside paragraphs, so they are written after the
+	// paragraph that first references them
+	if dc.opts.FootnoteStyle == AsideFootnotes && first {
+		text, ok, err := dc.convertFootnote(id)
+		if err != nil {
+			return err
+		}
+		if ok {
+			dc.asides = append(dc.asides, fmt.Sprintf("<aside class=\"footnote\" id=\"fn-%d\">\n<sup>%d</sup>\n%s\n</aside>\n", n, n, text))
+		}
+	}
+	return nil
+}
+
+// flushAsides writes the footnotes referenced in the block just written
+func (dc *htmlConverter) flushAsides(out *bytes.Buffer) {
+	for _, aside := range dc.asides {
+		fmt.Fprint(out, aside)
+	}
+	dc.asides = nil
+}
+
+// convertFootnote converts the content of a footnote, setting aside the
+// state of the surrounding text so that footnotes can be converted inline
+func (dc *htmlConverter) convertFootnote(id string) (string, bool, error) {
+	footnote, ok := dc.doc.Footnotes[id]
+	if !ok {
+		log.Printf("warning: no content found for footnote %q referenced in document", id)
+		return "", false, nil
+	}
+
+	saved := dc.blockState
+	dc.blockState = blockState{inFootnote: true}
+	defer func() { dc.blockState = saved }()
+
+	var buf bytes.Buffer
+	err := dc.process(&buf, footnote.Content)
+	if err != nil {
+		return "", false, fmt.Errorf("error converting footnote %s content to html: %w", id, err)
+	}
+	return strings.TrimSpace(buf.String()), true, nil
+}
+
+// writeFootnotes writes the content of the footnotes at the end of the
+// document. Footnotes referenced from other footnotes are included, since
+// the list grows as footnotes are converted.
+func (dc *htmlConverter) writeFootnotes(out *bytes.Buffer) error {
+	if dc.opts.FootnoteStyle == AsideFootnotes || len(dc.footnotes) == 0 {
+		return nil
+	}
+
+	if dc.opts.FootnoteStyle == PopupFootnotes {
+		return dc.writePopupFootnotes(out)
+	}
+
+	fmt.Fprint(out, "<section class=\"footnotes\" role=\"doc-endnotes\">\n")
+	if dc.opts.FootnoteOffset > 0 {
+		fmt.Fprintf(out, "<ol start=\"%d\">\n", dc.opts.FootnoteOffset+1)
+	} else {
+		fmt.Fprint(out, "<ol>\n")
+	}
+	for i := 0; i < len(dc.footnotes); i++ {
+		text, ok, err := dc.convertFootnote(dc.footnotes[i])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		// the link back to the reference goes at the end of the last paragraph
+		n := dc.opts.FootnoteOffset + i + 1
+		backlink := fmt.Sprintf(`<a href="#fnref-%d" class="footnote-back" role="doc-backlink">↩</a>`, n)
+		if strings.HasSuffix(text, "</p>") {
+			text = strings.TrimSuffix(text, "</p>") + " " + backlink + "</p>"
+		} else {
+			text += "\n" + backlink
+		}
+		fmt.Fprintf(out, "<li id=\"fn-%d\">\n%s\n</li>\n", n, text)
+	}
+	fmt.Fprint(out, "</ol>\n</section>\n")
+	return nil
+}
+
+// writePopupFootnotes writes the content of the footnotes at the end of
+// the document as EPUB footnotes, which e-readers show when the reference
+// is tapped and otherwise hide
+func (dc *htmlConverter) writePopupFootnotes(out *bytes.Buffer) error {
+	for i := 0; i < len(dc.footnotes); i++ {
+		text, ok, err := dc.convertFootnote(dc.footnotes[i])
+		if err != nil {
+			return err
+		}
+		if ok {
+			fmt.Fprintf(out, "<aside epub:type=\"footnote\" class=\"footnote\" id=\"fn-%d\">\n%s\n</aside>\n", dc.opts.FootnoteOffset+i+1, text)
+		}
+	}
+	return nil
+}
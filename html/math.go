@@ -0,0 +1,39 @@
+package html
+
+import (
+	"html"
+	"log"
+	"regexp"
+
+	"github.com/alexflint/doc-publisher/mathml"
+)
+
+// Math styles
+const (
+	KaTeXMath  = ""       // math is written as latex in spans for KaTeX to render
+	MathMLMath = "mathml" // math is converted to MathML, which needs no script
+)
+
+// a regular expression for the spans that escape writes around latex
+var mathSpanRegexp = regexp.MustCompile(`<span class="math inline">\\\((.*?)\\\)</span>`)
+
+// convertMath replaces the latex in math spans with MathML, expanding the
+// given \newcommand definitions. Latex that cannot be converted is left for
+// KaTeX to render.
+func convertMath(s string, defs string) string {
+	conv := mathml.NewConverter()
+	err := conv.DefineMacros(defs)
+	if err != nil {
+		log.Printf("warning: error in latex definitions: %v", err)
+	}
+
+	return mathSpanRegexp.ReplaceAllStringFunc(s, func(span string) string {
+		tex := html.UnescapeString(mathSpanRegexp.FindStringSubmatch(span)[1])
+		out, err := conv.Convert(tex, false)
+		if err != nil {
+			log.Printf("warning: leaving %q as latex: %v", tex, err)
+			return span
+		}
+		return out
+	})
+}
@@ -0,0 +1,55 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+)
+
+// processBlockMarker opens or closes a spoiler or collapsible section
+func (dc *htmlConverter) processBlockMarker(out *bytes.Buffer, m *googledoc.BlockMarker) {
+	dc.flushCodeBlock(out)
+	switch dc.hidden.Apply(m) {
+	case googledoc.OpenSpoiler:
+		dc.setSpoiler(out, true)
+	case googledoc.CloseSpoiler:
+		dc.setSpoiler(out, false)
+	case googledoc.OpenDetails:
+		dc.closeLists(out, 0)
+		dc.setWrapper(out, "")
+		fmt.Fprintf(out, "<details>\n<summary>%s</summary>\n", html.EscapeString(m.Summary))
+	case googledoc.CloseDetails:
+		dc.closeLists(out, 0)
+		dc.setWrapper(out, "")
+		fmt.Fprint(out, "</details>\n")
+	}
+}
+
+// setSpoiler starts or ends a spoiler block, which is a collapsed
+// <details> element so that it works without any script or style sheet
+func (dc *htmlConverter) setSpoiler(out *bytes.Buffer, on bool) {
+	if on == dc.inSpoiler {
+		return
+	}
+	dc.flushCodeBlock(out)
+	dc.closeLists(out, 0)
+	dc.setWrapper(out, "")
+	dc.inSpoiler = on
+	if on {
+		fmt.Fprint(out, "<details class=\"spoiler\">\n<summary>Spoiler</summary>\n")
+	} else {
+		fmt.Fprint(out, "</details>\n")
+	}
+}
+
+// closeHiddenBlocks closes any spoilers and collapsible sections that were
+// not closed explicitly
+func (dc *htmlConverter) closeHiddenBlocks(out *bytes.Buffer) {
+	details := dc.hidden.Close()
+	dc.setSpoiler(out, false)
+	for ; details > 0; details-- {
+		fmt.Fprint(out, "</details>\n")
+	}
+}
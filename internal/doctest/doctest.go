@@ -0,0 +1,24 @@
+// Package doctest builds google docs for use in tests
+package doctest
+
+import "google.golang.org/api/docs/v1"
+
+// Paragraph creates a paragraph starting at an index with the given text
+// runs, filling in the indices of each run. Runs with no text style get an
+// empty one.
+func Paragraph(index int64, style string, bullet *docs.Bullet, runs ...*docs.TextRun) *docs.StructuralElement {
+	p := docs.Paragraph{
+		ParagraphStyle: &docs.ParagraphStyle{NamedStyleType: style},
+		Bullet:         bullet,
+	}
+	start := index
+	for _, r := range runs {
+		n := int64(len([]rune(r.Content)))
+		if r.TextStyle == nil {
+			r.TextStyle = &docs.TextStyle{}
+		}
+		p.Elements = append(p.Elements, &docs.ParagraphElement{StartIndex: index, EndIndex: index + n, TextRun: r})
+		index += n
+	}
+	return &docs.StructuralElement{StartIndex: start, EndIndex: index, Paragraph: &p}
+}
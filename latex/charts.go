@@ -0,0 +1,59 @@
+package latex
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// processChart writes a chart linked from a google sheet, either as a table
+// of its data or as an image that links to the spreadsheet
+func (dc *latexConverter) processChart(out *bytes.Buffer, id string, emb *docs.EmbeddedObject) {
+	source := googledoc.ChartURL(emb.LinkedContentReference)
+	if chart, ok := dc.opts.ChartTables[id]; ok && len(chart.Rows) > 0 {
+		writeTable(out, chart.Rows)
+		fmt.Fprintf(out, "\n\\href{%s}{%s}", escapeURL(chart.URL), escape(googledoc.ChartTitle(chart.Title)))
+		return
+	}
+
+	filename, ok := dc.opts.ImageFilenameByObjectID[id]
+	switch {
+	case !ok && source == "":
+		log.Println("warning: ignoring linked content with no image", id)
+	case !ok:
+		log.Println("warning: no image for linked chart, writing a link instead", id)
+		fmt.Fprintf(out, "\\url{%s}", escapeURL(source))
+	case source == "":
+		fmt.Fprintf(out, "\\includegraphics[width=0.8\\linewidth]{%s}", filename)
+	default:
+		fmt.Fprintf(out, "\\href{%s}{\\includegraphics[width=0.8\\linewidth]{%s}}", escapeURL(source), filename)
+	}
+}
+
+// writeTable writes rows of cell values as a tabular with the first row
+// separated from the rest as a header
+func writeTable(out *bytes.Buffer, rows [][]string) {
+	var columns int
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	fmt.Fprintf(out, "\\begin{tabular}{|%s}\n\\hline\n", strings.Repeat("l|", columns))
+	for i, row := range rows {
+		cells := make([]string, columns)
+		for j := range row {
+			cells[j] = escape(row[j])
+		}
+		fmt.Fprintf(out, "%s \\\\\n\\hline\n", strings.Join(cells, " & "))
+		if i == 0 && len(rows) > 1 {
+			fmt.Fprint(out, "\\hline\n")
+		}
+	}
+	fmt.Fprint(out, "\\end{tabular}\n")
+}
@@ -0,0 +1,666 @@
+package latex
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// Spoiler styles for rendering spoiler blocks in latex
+const (
+	FootnoteSpoilers = ""         // move spoilers into footnotes
+	AppendixSpoilers = "appendix" // move spoilers to a section at the end
+)
+
+// Options controls the conversion of google docs to latex
+type Options struct {
+	// ImageFilenameByObjectID gives the path, relative to the latex
+	// output, of the image for each inline object
+	ImageFilenameByObjectID map[string]string
+
+	// Comments are rendered as \todo margin notes, which requires the
+	// todonotes package. Pass nil to omit comments.
+	Comments []*googledoc.Comment
+
+	// MarkupSuggestions renders suggested insertions in blue and suggested
+	// deletions in red strikethrough, as tracked changes
+	MarkupSuggestions bool
+
+	// ChartTables holds data to render as tables in place of linked
+	// charts, keyed by inline object ID. Other charts are rendered as
+	// images that link to their spreadsheet.
+	ChartTables map[string]*googledoc.Chart
+
+	// StyleRules assigns roles such as callouts and highlights to
+	// paragraphs and text according to their style. It may be nil.
+	StyleRules *googledoc.StyleRules
+
+	// SpoilerStyle determines where spoilers are moved to so that readers
+	// do not see them by accident
+	SpoilerStyle string
+
+	// HeadingLabels gives a \label for each heading, keyed by heading ID.
+	// Labelled headings can be the target of links, which become
+	// \hyperref references.
+	HeadingLabels map[string]string
+
+	// DocLabels gives the \label for links to whole documents, keyed by doc
+	// ID, for documents that are converted together, such as the chapters
+	// of a book
+	DocLabels map[string]string
+
+	// RedefineMacros defines macros with \providecommand and \renewcommand
+	// rather than \newcommand, so that documents that are converted
+	// together may define the same macro
+	RedefineMacros bool
+}
+
+// FromGoogleDoc converts a google doc to latex
+func FromGoogleDoc(doc *docs.Document, opts *Options) (string, error) {
+	return Convert(doc, doc.Body.Content, opts)
+}
+
+// Convert converts a part of a google doc to latex. The output does not
+// include a preamble, and is intended to be inserted into a template.
+func Convert(doc *docs.Document, elements []*docs.StructuralElement, opts *Options) (string, error) {
+	switch opts.SpoilerStyle {
+	case FootnoteSpoilers, AppendixSpoilers:
+	default:
+		return "", fmt.Errorf("invalid spoiler style %q", opts.SpoilerStyle)
+	}
+
+	conv := latexConverter{
+		doc:     doc,
+		opts:    opts,
+		replace: make(map[string]string),
+	}
+
+	var tex bytes.Buffer
+	for _, c := range googledoc.UnanchoredComments(opts.Comments) {
+		fmt.Fprintf(&tex, "\\todo[inline]{%s}\n\n", todoText(c))
+	}
+
+	err := conv.process(&tex, elements)
+	if err != nil {
+		return "", fmt.Errorf("error converting document body to latex: %w", err)
+	}
+	conv.writeSpoilerAppendix(&tex)
+
+	// drop sequences of two or more empty lines
+	var out strings.Builder
+	out.Grow(tex.Len())
+	var emptylines int
+	for _, line := range strings.Split(tex.String(), "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if len(line) == 0 {
+			emptylines++
+			if emptylines < 2 {
+				out.WriteRune('\n')
+			}
+			continue
+		}
+		emptylines = 0
+
+		// apply string-to-string replacements (used to rewrite \T1 to \Tone due to latex rules)
+		line = googledoc.RenameLatexSymbols(line, conv.replace)
+
+		out.WriteString(line + "\n")
+	}
+
+	return out.String(), nil
+}
+
+type latexConverter struct {
+	doc        *docs.Document
+	opts       *Options
+	codeBlock  bytes.Buffer      // text identified as lines of code
+	lists      []string          // environments for the currently open lists, innermost last
+	replace    map[string]string // string replacements to apply to whole doc
+	inFootnote bool
+	columns    int  // number of columns in the current section
+	checked    bool // whether the current paragraph is a completed checklist item
+
+	hidden   googledoc.HiddenBlocks // spoilers and collapsible sections opened by markers
+	spoiler  *bytes.Buffer          // the content of the spoiler being collected, if any
+	spoilers []string               // spoilers collected for the appendix
+
+	inTable        bool     // whether a table is being converted
+	tableFootnotes []string // text of the footnotes marked in the current table
+}
+
+func (dc *latexConverter) process(body *bytes.Buffer, content []*docs.StructuralElement) error {
+	for i, elem := range content {
+		// markers for spoilers and collapsible sections are not written out
+		if elem.Paragraph != nil {
+			if marker := googledoc.ParseBlockMarker(elem.Paragraph); marker != nil {
+				dc.processBlockMarker(body, marker)
+				continue
+			}
+		}
+
+		// spoilers are either marked explicitly or assigned by style rules
+		spoiler := dc.hidden.InSpoiler()
+		if elem.Paragraph != nil && dc.opts.StyleRules.BlockRole(elem.Paragraph) == googledoc.SpoilerRole {
+			spoiler = true
+		}
+		dc.setSpoiler(body, spoiler)
+		out := dc.writer(body)
+
+		// for any element other than paragraph, close any open code block or list
+		if elem.Paragraph == nil {
+			dc.flushCodeBlock(out)
+			dc.closeLists(out, 0)
+		}
+
+		switch {
+		case elem.Table != nil:
+			// tables with a single cell of monospace text are code blocks
+			if code, ok := dc.opts.StyleRules.TableCode(elem.Table); ok {
+				dc.codeBlock.WriteString(code)
+				dc.flushCodeBlock(out)
+				continue
+			}
+			err := dc.processTable(out, elem.Table)
+			if err != nil {
+				return err
+			}
+		case elem.TableOfContents != nil:
+			fmt.Fprint(out, "\\tableofcontents\n\n")
+		case elem.SectionBreak != nil:
+			dc.processSectionBreak(out, elem.SectionBreak, i == 0)
+		case elem.Paragraph != nil:
+			err := dc.processParagraph(out, elem.Paragraph)
+			if err != nil {
+				return err
+			}
+		default:
+			log.Println("warning: encountered a body element of unknown type")
+		}
+	}
+
+	dc.closeHiddenBlocks(body)
+	dc.flushCodeBlock(body)
+	dc.closeLists(body, 0)
+	dc.closeColumns(body)
+	return nil
+}
+
+// processSectionBreak starts a new page and sets up columns for a new section
+func (dc *latexConverter) processSectionBreak(out *bytes.Buffer, b *docs.SectionBreak, first bool) {
+	dc.closeColumns(out)
+	if b.SectionStyle == nil {
+		return
+	}
+
+	// the first element of every document is a section break, which does not start a new page
+	if b.SectionStyle.SectionType == "NEXT_PAGE" && !first {
+		fmt.Fprint(out, "\\newpage\n\n")
+	}
+
+	if n := len(b.SectionStyle.ColumnProperties); n > 1 {
+		fmt.Fprint(out, "{")
+		if b.SectionStyle.ColumnSeparatorStyle == "BETWEEN_EACH_COLUMN" {
+			fmt.Fprint(out, "\\setlength{\\columnseprule}{0.4pt}")
+		}
+		fmt.Fprintf(out, "\\begin{multicols}{%d}\n\n", n)
+		dc.columns = n
+	}
+}
+
+// closeColumns ends the multi-column layout for the current section, if any
+func (dc *latexConverter) closeColumns(out *bytes.Buffer) {
+	if dc.columns > 1 {
+		fmt.Fprint(out, "\\end{multicols}}\n\n")
+	}
+	dc.columns = 0
+}
+
+// names of languages known to the listings package, keyed by the names
+// used by googledoc.CodeLanguage
+var listingsLanguages = map[string]string{
+	"bash":    "bash",
+	"sh":      "sh",
+	"c":       "C",
+	"cpp":     "C++",
+	"c++":     "C++",
+	"html":    "HTML",
+	"java":    "Java",
+	"perl":    "Perl",
+	"php":     "PHP",
+	"python":  "Python",
+	"r":       "R",
+	"ruby":    "Ruby",
+	"sql":     "SQL",
+	"haskell": "Haskell",
+}
+
+// flushCodeBlock writes any lines stored in dc.codeBlock to a listing, or
+// a verbatim environment if the language is not known to the listings
+// package. If there are no stored lines then it does nothing.
+func (dc *latexConverter) flushCodeBlock(out *bytes.Buffer) {
+	if dc.codeBlock.Len() == 0 {
+		return
+	}
+
+	lang, code := googledoc.CodeLanguage(strings.ReplaceAll(dc.codeBlock.String(), "\v", "\n"))
+	if l, ok := listingsLanguages[lang]; ok {
+		fmt.Fprintf(out, "\\begin{lstlisting}[language=%s]\n", l)
+		fmt.Fprint(out, strings.TrimRight(code, "\n")+"\n")
+		fmt.Fprintln(out, "\\end{lstlisting}")
+	} else {
+		fmt.Fprintln(out, "\\begin{verbatim}")
+		fmt.Fprint(out, strings.TrimRight(code, "\n")+"\n")
+		fmt.Fprintln(out, "\\end{verbatim}")
+	}
+	fmt.Fprintln(out)
+	dc.codeBlock.Reset()
+}
+
+// closeLists closes open list environments until there are at most depth remaining
+func (dc *latexConverter) closeLists(out *bytes.Buffer, depth int) {
+	closed := len(dc.lists) > depth
+	for len(dc.lists) > depth {
+		env := dc.lists[len(dc.lists)-1]
+		dc.lists = dc.lists[:len(dc.lists)-1]
+		fmt.Fprintf(out, "\\end{%s}\n", env)
+	}
+	if closed && depth == 0 {
+		fmt.Fprintln(out)
+	}
+}
+
+// openLists opens list environments until the nesting level of a bullet is reached
+func (dc *latexConverter) openLists(out *bytes.Buffer, b *docs.Bullet) {
+	depth := int(b.NestingLevel) + 1
+	if len(dc.lists) > depth {
+		dc.closeLists(out, depth)
+	}
+	for len(dc.lists) < depth {
+		env := "itemize"
+		level := &docs.Bullet{ListId: b.ListId, NestingLevel: int64(len(dc.lists))}
+		if googledoc.ListKind(dc.doc, level) == googledoc.NumberedList {
+			env = "enumerate"
+		}
+		fmt.Fprintf(out, "\\begin{%s}\n", env)
+		dc.lists = append(dc.lists, env)
+	}
+}
+
+// sectioning commands for each named style
+var headingCommands = map[string]string{
+	"HEADING_1": "section",
+	"HEADING_2": "subsection",
+	"HEADING_3": "subsubsection",
+	"HEADING_4": "paragraph",
+	"HEADING_5": "subparagraph",
+	"HEADING_6": "subparagraph",
+}
+
+func (dc *latexConverter) processParagraph(out *bytes.Buffer, p *docs.Paragraph) error {
+	role := dc.opts.StyleRules.BlockRole(p)
+
+	// deal with code blocks
+	if role == googledoc.CodeRole || (role == "" && p.Bullet == nil && dc.opts.StyleRules.IsCode(p)) {
+		// code that is indented after a list item belongs to that item
+		noIndent := p.ParagraphStyle.IndentStart == nil || p.ParagraphStyle.IndentStart.Magnitude == 0
+		if dc.codeBlock.Len() == 0 && noIndent {
+			dc.closeLists(out, 0)
+		}
+		for _, el := range p.Elements {
+			if el.TextRun != nil {
+				fmt.Fprint(&dc.codeBlock, el.TextRun.Content)
+			}
+		}
+		return nil
+	}
+
+	// if not a code block then flush any buffered code block
+	dc.flushCodeBlock(out)
+
+	// lines that begin \newcommand are passed through, with digits removed from the symbol
+	if text := strings.TrimSuffix(googledoc.ParagraphText(p), "\n"); newcommandRegexp.MatchString(text) {
+		m := newcommandRegexp.FindStringSubmatch(text)
+		fixed := fixLatexSymbol(m[1])
+		if fixed != m[1] {
+			dc.replace[m[1]] = fixed
+		}
+		if dc.opts.RedefineMacros {
+			fmt.Fprintf(out, "\\providecommand{%s}{}\\renewcommand{%s}{%s}\n\n", fixed, fixed, m[2])
+		} else {
+			fmt.Fprintf(out, "\\newcommand{%s}{%s}\n\n", fixed, m[2])
+		}
+		return nil
+	}
+
+	// deal with bullets
+	if p.Bullet != nil {
+		dc.openLists(out, p.Bullet)
+		switch {
+		case googledoc.ListKind(dc.doc, p.Bullet) != googledoc.Checklist:
+			fmt.Fprint(out, "\\item ")
+		case googledoc.IsChecked(p):
+			// the box marks the item as completed, so drop the strikethrough
+			fmt.Fprint(out, "\\item[$\\boxtimes$] ")
+			dc.checked = true
+		default:
+			fmt.Fprint(out, "\\item[$\\square$] ")
+		}
+		var todos bytes.Buffer
+		err := dc.processElements(out, &todos, p)
+		dc.checked = false
+		if err != nil {
+			return err
+		}
+		todos.WriteTo(out)
+		fmt.Fprint(out, "\n")
+		return nil
+	}
+	if len(dc.lists) > 0 {
+		dc.closeLists(out, 0)
+	}
+
+	// deal with headings, which cannot contain margin notes
+	namedStyle := p.ParagraphStyle.NamedStyleType
+	if role != "" {
+		namedStyle = "NORMAL_TEXT"
+	}
+	switch namedStyle {
+	case "TITLE", "SUBTITLE":
+		log.Printf("warning: ignoring %s paragraph, which is set by the template", p.ParagraphStyle.NamedStyleType)
+		return nil
+	}
+	if cmd, ok := headingCommands[namedStyle]; ok {
+		var todos bytes.Buffer
+		fmt.Fprintf(out, "\\%s{", cmd)
+		if err := dc.processElements(out, &todos, p); err != nil {
+			return err
+		}
+		fmt.Fprint(out, "}")
+		if label, ok := dc.opts.HeadingLabels[p.ParagraphStyle.HeadingId]; ok && !dc.inFootnote {
+			fmt.Fprintf(out, "\\label{%s}", label)
+		}
+		fmt.Fprint(out, "\n")
+		todos.WriteTo(out)
+		fmt.Fprint(out, "\n\n")
+		return nil
+	}
+
+	// deal with the paragraph's role, block quotes, and centered text
+	var begin, end string
+	switch {
+	case role == googledoc.CalloutRole:
+		begin, end = "\\begin{center}\n\\fbox{\\parbox{0.9\\linewidth}{", "}}\n\\end{center}"
+	case role == googledoc.EpigraphRole:
+		begin, end = "\\epigraph{", "}{}"
+	case role == googledoc.QuoteRole:
+		begin, end = "\\begin{quote}\n", "\n\\end{quote}"
+	case role != "":
+	case googledoc.IsIndented(p):
+		begin, end = "\\begin{quote}\n", "\n\\end{quote}"
+	case p.ParagraphStyle.Alignment == "CENTER":
+		begin, end = "\\begin{center}\n", "\n\\end{center}"
+	}
+
+	fmt.Fprint(out, begin)
+	if err := dc.processElements(out, out, p); err != nil {
+		return err
+	}
+	fmt.Fprint(out, end)
+
+	// write two newlines at the end of each paragraph
+	fmt.Fprint(out, "\n\n")
+	return nil
+}
+
+// processElements writes the content of a paragraph, and writes margin notes for comments to todos
+func (dc *latexConverter) processElements(out, todos *bytes.Buffer, p *docs.Paragraph) error {
+	for _, el := range p.Elements {
+		switch {
+		case el.ColumnBreak != nil:
+			log.Println("warning: ignoring column break")
+		case el.Equation != nil:
+			log.Println("warning: ignoring equation")
+		case el.FootnoteReference != nil:
+			err := dc.processFootnote(out, el.FootnoteReference.FootnoteId)
+			if err != nil {
+				return err
+			}
+		case el.AutoText != nil:
+			switch el.AutoText.Type {
+			case "PAGE_NUMBER":
+				fmt.Fprint(out, "\\thepage{}")
+			case "PAGE_COUNT":
+				fmt.Fprint(out, "\\thelastpage{}")
+			default:
+				log.Println("warning: ignoring auto text")
+			}
+		case el.HorizontalRule != nil:
+			fmt.Fprint(out, "\n\n\\noindent\\rule{\\linewidth}{0.4pt}\n\n")
+		case el.InlineObjectElement != nil:
+			dc.processInlineObject(out, el.InlineObjectElement)
+		case el.PageBreak != nil:
+			fmt.Fprint(out, "\n\n\\newpage\n\n")
+		case el.TextRun != nil:
+			dc.processTextRun(out, el.TextRun)
+		default:
+			log.Println("warning: encountered a paragraph element of unknown type")
+		}
+
+		if !dc.inFootnote {
+			for _, c := range googledoc.CommentsEndingIn(dc.opts.Comments, el.StartIndex, el.EndIndex) {
+				fmt.Fprintf(todos, "\\todo{%s}", todoText(c))
+			}
+		}
+	}
+	return nil
+}
+
+// processFootnote writes a footnote inline
+func (dc *latexConverter) processFootnote(out *bytes.Buffer, id string) error {
+	footnote, ok := dc.doc.Footnotes[id]
+	if !ok {
+		log.Printf("warning: no content found for footnote %q referenced in document", id)
+		return nil
+	}
+
+	// indices inside footnotes do not correspond to comment anchors, and
+	// lists inside the footnote must not interfere with those outside
+	inner := latexConverter{
+		doc:        dc.doc,
+		opts:       dc.opts,
+		replace:    dc.replace,
+		inFootnote: true,
+	}
+
+	var buf bytes.Buffer
+	err := inner.process(&buf, footnote.Content)
+	if err != nil {
+		return fmt.Errorf("error converting footnote %s content to latex: %w", id, err)
+	}
+
+	// footnotes cannot appear inside tables, so only the mark goes there
+	text := strings.TrimSpace(buf.String())
+	if dc.inTable {
+		fmt.Fprint(out, "\\footnotemark{}")
+		dc.tableFootnotes = append(dc.tableFootnotes, text)
+		return nil
+	}
+	fmt.Fprintf(out, "\\footnote{%s}", text)
+	return nil
+}
+
+func (dc *latexConverter) processInlineObject(out *bytes.Buffer, objRef *docs.InlineObjectElement) {
+	id := objRef.InlineObjectId
+	obj, ok := dc.doc.InlineObjects[id]
+	if !ok {
+		log.Println("warning: could not find inline object for id", id)
+		return
+	}
+
+	emb := obj.InlineObjectProperties.EmbeddedObject
+	switch {
+	case emb.LinkedContentReference != nil:
+		dc.processChart(out, id, emb)
+	case emb.ImageProperties != nil || emb.EmbeddedDrawingProperties != nil:
+		filename, ok := dc.opts.ImageFilenameByObjectID[id]
+		if !ok {
+			log.Println("warning: no image file for inline object", id)
+			return
+		}
+		fmt.Fprintf(out, "\\includegraphics[width=0.8\\linewidth]{%s}", filename)
+	}
+}
+
+// linkLabel gets the label that a link points to, if it points to a
+// labelled heading or document, or returns an empty string
+func (dc *latexConverter) linkLabel(link *docs.Link) string {
+	if link == nil {
+		return ""
+	}
+	headingID := link.HeadingId
+	docID := googledoc.DocIDFromURL(link.Url)
+	if _, ok := dc.opts.DocLabels[docID]; ok || (docID != "" && docID == dc.doc.DocumentId) {
+		if i := strings.Index(link.Url, "#heading="); i >= 0 {
+			headingID = link.Url[i+len("#heading="):]
+		}
+	}
+	if headingID != "" {
+		return dc.opts.HeadingLabels[headingID]
+	}
+	if link.Url != "" {
+		return dc.opts.DocLabels[docID]
+	}
+	return ""
+}
+
+func (dc *latexConverter) processTextRun(out *bytes.Buffer, t *docs.TextRun) {
+	// the newline at the end of each paragraph is dealt with by the caller
+	content := strings.TrimSuffix(t.Content, "\n")
+	if content == "" {
+		return
+	}
+
+	// build the commands that wrap the text
+	var wrappers []string
+	style := t.TextStyle
+	if dc.opts.MarkupSuggestions {
+		switch {
+		case len(t.SuggestedDeletionIds) > 0:
+			wrappers = append(wrappers, "\\textcolor{red}{\\sout{")
+		case len(t.SuggestedInsertionIds) > 0:
+			wrappers = append(wrappers, "\\textcolor{blue}{\\uline{")
+		}
+	}
+	if style.Bold {
+		wrappers = append(wrappers, "\\textbf{")
+	}
+	if style.Italic {
+		wrappers = append(wrappers, "\\emph{")
+	}
+	if style.Strikethrough && !dc.checked {
+		wrappers = append(wrappers, "\\sout{")
+	}
+	if style.Underline && style.Link == nil {
+		wrappers = append(wrappers, "\\underline{")
+	}
+	if style.SmallCaps {
+		wrappers = append(wrappers, "\\textsc{")
+	}
+	switch dc.opts.StyleRules.InlineRole(t) {
+	case googledoc.HighlightRole:
+		wrappers = append(wrappers, "\\colorbox{yellow}{")
+	case googledoc.CodeRole:
+		wrappers = append(wrappers, "\\texttt{")
+	case googledoc.SpoilerRole:
+		// leave only a footnote mark in the text, unless already in a footnote
+		if !dc.inFootnote {
+			wrappers = append([]string{"\\footnote{"}, wrappers...)
+		}
+	default:
+		if dc.opts.StyleRules.IsMonospace(style.WeightedFontFamily) {
+			wrappers = append(wrappers, "\\texttt{")
+		}
+	}
+	switch style.BaselineOffset {
+	case "SUBSCRIPT":
+		wrappers = append(wrappers, "\\textsubscript{")
+	case "SUPERSCRIPT":
+		wrappers = append(wrappers, "\\textsuperscript{")
+	}
+	if label := dc.linkLabel(style.Link); label != "" {
+		wrappers = append(wrappers, "\\hyperref["+label+"]{")
+	} else if style.Link != nil && style.Link.Url != "" {
+		wrappers = append(wrappers, "\\href{"+escapeURL(style.Link.Url)+"}{")
+	}
+
+	// latex commands cannot be separated from the content by whitespace
+	// any more than markdown emphasis can, so keep whitespace outside
+	left, middle, right := splitSpace(content)
+	out.WriteString(escape(left))
+	for _, w := range wrappers {
+		out.WriteString(w)
+	}
+	out.WriteString(escape(middle))
+	for _, w := range wrappers {
+		out.WriteString(strings.Repeat("}", strings.Count(w, "{")))
+	}
+	out.WriteString(escape(right))
+}
+
+// processTable writes a table as a tabular environment
+func (dc *latexConverter) processTable(out *bytes.Buffer, t *docs.Table) error {
+	dc.inTable = true
+	fmt.Fprintf(out, "\\begin{tabular}{|%s}\n\\hline\n", strings.Repeat("l|", int(t.Columns)))
+	for _, row := range t.TableRows {
+		for j, cell := range row.TableCells {
+			if j > 0 {
+				fmt.Fprint(out, " & ")
+			}
+			for k, e := range cell.Content {
+				if e.Paragraph == nil {
+					log.Println("warning: table cell contained a non-paragraph structural element, ignoring")
+					continue
+				}
+				if k > 0 {
+					fmt.Fprint(out, " ")
+				}
+				if err := dc.processElements(out, out, e.Paragraph); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Fprint(out, " \\\\\n\\hline\n")
+	}
+	fmt.Fprint(out, "\\end{tabular}\n\n")
+	dc.inTable = false
+
+	// write the text of the footnotes marked in the table, stepping the
+	// footnote counter back so that the numbers match the marks
+	if len(dc.tableFootnotes) > 0 {
+		fmt.Fprintf(out, "\\addtocounter{footnote}{-%d}\n", len(dc.tableFootnotes))
+		for _, text := range dc.tableFootnotes {
+			fmt.Fprintf(out, "\\stepcounter{footnote}\\footnotetext{%s}\n", text)
+		}
+		fmt.Fprintln(out)
+		dc.tableFootnotes = nil
+	}
+	return nil
+}
+
+// todoText formats a comment and its replies for a \todo margin note
+func todoText(c *googledoc.Comment) string {
+	parts := []string{escape(c.Author) + ": " + escape(c.Content)}
+	for _, r := range c.Replies {
+		parts = append(parts, escape(r.Author)+": "+escape(r.Content))
+	}
+	return strings.Join(parts, " --- ")
+}
+
+// a regular expression for latex \newcommand lines
+var newcommandRegexp = regexp.MustCompile(`^\\newcommand\{(.+?)\}\{(.*)\}$`)
@@ -0,0 +1,186 @@
+package latex
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
+)
+
+func TestFromGoogleDoc(t *testing.T) {
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			doctest.Paragraph(1, "HEADING_1", nil, &docs.TextRun{Content: "Intro\n"}),
+			doctest.Paragraph(7, "NORMAL_TEXT", nil,
+				&docs.TextRun{Content: "Costs 5% of "},
+				&docs.TextRun{Content: "all", TextStyle: &docs.TextStyle{Bold: true}},
+				&docs.TextRun{Content: " \\alpha\n"}),
+			doctest.Paragraph(29, "NORMAL_TEXT", &docs.Bullet{ListId: "l"}, &docs.TextRun{Content: "one\n"}),
+			doctest.Paragraph(33, "NORMAL_TEXT", &docs.Bullet{ListId: "l", NestingLevel: 1}, &docs.TextRun{Content: "two\n"}),
+		}},
+		Lists: map[string]docs.List{
+			"l": {ListProperties: &docs.ListProperties{NestingLevels: []*docs.NestingLevel{
+				{GlyphSymbol: "●"},
+				{GlyphType: "DECIMAL"},
+			}}},
+		},
+	}
+
+	comments := []*googledoc.Comment{{Author: "Alice", Content: "100% sure?", Quote: "all"}}
+	googledoc.AnchorComments(&doc, comments)
+
+	tex, err := FromGoogleDoc(&doc, &Options{Comments: comments})
+	require.NoError(t, err)
+	assert.Equal(t, `\section{Intro}
+
+Costs 5\% of \textbf{all}\todo{Alice: 100\% sure?} $\alpha$
+
+\begin{itemize}
+\item one
+\begin{enumerate}
+\item two
+\end{enumerate}
+\end{itemize}
+
+`, tex)
+}
+
+func TestPageLayout(t *testing.T) {
+	pageBreak := doctest.Paragraph(10, "NORMAL_TEXT", nil, &docs.TextRun{Content: "\n"})
+	pageBreak.Paragraph.Elements = append([]*docs.ParagraphElement{{PageBreak: &docs.PageBreak{}}}, pageBreak.Paragraph.Elements...)
+
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			{SectionBreak: &docs.SectionBreak{SectionStyle: &docs.SectionStyle{SectionType: "CONTINUOUS"}}},
+			doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "One\n"}),
+			pageBreak,
+			{SectionBreak: &docs.SectionBreak{SectionStyle: &docs.SectionStyle{
+				SectionType:      "NEXT_PAGE",
+				ColumnProperties: []*docs.SectionColumnProperties{{}, {}},
+			}}},
+			doctest.Paragraph(12, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Two\n"}),
+		}},
+		DocumentStyle: &docs.DocumentStyle{DefaultFooterId: "f"},
+		Footers: map[string]docs.Footer{
+			"f": {Content: []*docs.StructuralElement{{
+				Paragraph: &docs.Paragraph{
+					ParagraphStyle: &docs.ParagraphStyle{Alignment: "CENTER"},
+					Elements: []*docs.ParagraphElement{
+						{TextRun: &docs.TextRun{Content: "Page ", TextStyle: &docs.TextStyle{}}},
+						{AutoText: &docs.AutoText{Type: "PAGE_NUMBER"}},
+					},
+				},
+			}}},
+		},
+	}
+
+	tex, err := FromGoogleDoc(&doc, &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "One\n\n\\newpage\n\n\\newpage\n\n{\\begin{multicols}{2}\n\nTwo\n\n\\end{multicols}}\n\n", tex)
+
+	style, err := PageStyle(&doc)
+	require.NoError(t, err)
+	assert.Contains(t, style, "\\makeoddfoot{googledoc}{}{Page \\thepage{}}{}\n")
+	assert.Contains(t, style, "\\makeoddhead{googledoc}{}{}{}\n")
+}
+
+func TestChecklists(t *testing.T) {
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			doctest.Paragraph(1, "NORMAL_TEXT", &docs.Bullet{ListId: "l"}, &docs.TextRun{Content: "todo\n"}),
+			doctest.Paragraph(6, "NORMAL_TEXT", &docs.Bullet{ListId: "l"},
+				&docs.TextRun{Content: "done\n", TextStyle: &docs.TextStyle{Strikethrough: true}}),
+		}},
+		Lists: map[string]docs.List{
+			"l": {ListProperties: &docs.ListProperties{NestingLevels: []*docs.NestingLevel{{}}}},
+		},
+	}
+
+	tex, err := FromGoogleDoc(&doc, &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "\\begin{itemize}\n\\item[$\\square$] todo\n\\item[$\\boxtimes$] done\n\\end{itemize}\n\n", tex)
+}
+
+func TestMacros(t *testing.T) {
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "\\newcommand{\\R}{\\mathbb{R}}\n"}),
+		}},
+	}
+
+	tex, err := FromGoogleDoc(&doc, &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "\\newcommand{\\R}{\\mathbb{R}}\n\n", tex)
+
+	tex, err = FromGoogleDoc(&doc, &Options{RedefineMacros: true})
+	require.NoError(t, err)
+	assert.Equal(t, "\\providecommand{\\R}{}\\renewcommand{\\R}{\\mathbb{R}}\n\n", tex)
+}
+
+func TestSpoilers(t *testing.T) {
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Puzzle\n"}),
+			doctest.Paragraph(8, "NORMAL_TEXT", nil, &docs.TextRun{Content: "[spoiler]\n"}),
+			doctest.Paragraph(18, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Answer\n"}),
+			doctest.Paragraph(25, "NORMAL_TEXT", nil, &docs.TextRun{Content: "[/spoiler]\n"}),
+		}},
+	}
+
+	tex, err := FromGoogleDoc(&doc, &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "Puzzle\n\n\\emph{Spoiler}\\footnote{Answer}\n\n", tex)
+
+	tex, err = FromGoogleDoc(&doc, &Options{SpoilerStyle: AppendixSpoilers})
+	require.NoError(t, err)
+	assert.Equal(t, "Puzzle\n\n\\emph{See \\hyperref[spoiler-1]{spoiler 1} at the end.}\n\n"+
+		"\\section*{Spoilers}\n\n\\subsection*{Spoiler 1}\\phantomsection\\label{spoiler-1}\n\nAnswer\n\n", tex)
+}
+
+func TestTableFootnotes(t *testing.T) {
+	cell := func(id string) *docs.TableCell {
+		p := doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "x\n"})
+		p.Paragraph.Elements = append(p.Paragraph.Elements, &docs.ParagraphElement{FootnoteReference: &docs.FootnoteReference{FootnoteId: id}})
+		return &docs.TableCell{Content: []*docs.StructuralElement{p}}
+	}
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			{Table: &docs.Table{Columns: 2, TableRows: []*docs.TableRow{{TableCells: []*docs.TableCell{cell("a"), cell("b")}}}}},
+		}},
+		Footnotes: map[string]docs.Footnote{
+			"a": {Content: []*docs.StructuralElement{doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Alpha\n"})}},
+			"b": {Content: []*docs.StructuralElement{doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Beta\n"})}},
+		},
+	}
+
+	tex, err := FromGoogleDoc(&doc, &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "\\begin{tabular}{|l|l|}\n\\hline\nx\\footnotemark{} & x\\footnotemark{} \\\\\n\\hline\n\\end{tabular}\n\n"+
+		"\\addtocounter{footnote}{-2}\n\\stepcounter{footnote}\\footnotetext{Alpha}\n\\stepcounter{footnote}\\footnotetext{Beta}\n\n", tex)
+}
+
+func TestLabels(t *testing.T) {
+	heading := doctest.Paragraph(1, "HEADING_1", nil, &docs.TextRun{Content: "Intro\n"})
+	heading.Paragraph.ParagraphStyle.HeadingId = "h.1"
+	doc := docs.Document{
+		DocumentId: "one",
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			heading,
+			doctest.Paragraph(7, "NORMAL_TEXT", nil,
+				&docs.TextRun{Content: "See", TextStyle: &docs.TextStyle{Link: &docs.Link{Url: "https://docs.google.com/document/d/two/edit#heading=h.2"}}},
+				&docs.TextRun{Content: " and "},
+				&docs.TextRun{Content: "two", TextStyle: &docs.TextStyle{Link: &docs.Link{Url: "https://docs.google.com/document/d/two/edit"}}},
+				&docs.TextRun{Content: "\n"}),
+		}},
+	}
+
+	tex, err := FromGoogleDoc(&doc, &Options{
+		HeadingLabels: map[string]string{"h.1": "ch1:intro", "h.2": "ch2:intro"},
+		DocLabels:     map[string]string{"one": "ch1", "two": "ch2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "\\section{Intro}\\label{ch1:intro}\n\n\\hyperref[ch2:intro]{See} and \\hyperref[ch2]{two}\n\n", tex)
+}
@@ -0,0 +1,96 @@
+package latex
+
+// This file contains utilities for escaping text in latex
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// escape escapes latex special characters in text. As in the markdown
+// exporter, a backslash followed by letters is treated as a latex symbol
+// and wrapped in dollar signs.
+func escape(s string) string {
+	var out strings.Builder
+	var inLatex bool
+	for len(s) > 0 {
+		r, sz := utf8.DecodeRuneInString(s)
+		s = s[sz:]
+
+		word := unicode.IsNumber(r) || unicode.IsLetter(r)
+		if inLatex && !word {
+			out.WriteString("$")
+			inLatex = false
+		}
+		if inLatex {
+			out.WriteRune(r)
+			continue
+		}
+
+		switch r {
+		case '\\':
+			next, _ := utf8.DecodeRuneInString(s)
+			if unicode.IsLetter(next) {
+				out.WriteString("$\\")
+				inLatex = true
+			} else {
+				out.WriteString("\\textbackslash{}")
+			}
+		case '#', '$', '%', '&', '_', '{', '}':
+			out.WriteRune('\\')
+			out.WriteRune(r)
+		case '~':
+			out.WriteString("\\textasciitilde{}")
+		case '^':
+			out.WriteString("\\textasciicircum{}")
+		case '\v':
+			// google docs uses vertical tabs for line breaks within a paragraph
+			out.WriteString("\\\\\n")
+		case '\n':
+			out.WriteString(" ")
+		default:
+			out.WriteRune(r)
+		}
+	}
+
+	if inLatex {
+		out.WriteString("$")
+	}
+	return out.String()
+}
+
+// Escape escapes latex special characters in text that is inserted into a
+// template, such as a title, in the same way as text in documents
+func Escape(s string) string {
+	return escape(s)
+}
+
+// escapeURL escapes the characters in a URL that are special inside \href
+func escapeURL(s string) string {
+	return strings.NewReplacer(`#`, `\#`, `%`, `\%`).Replace(s)
+}
+
+// splitSpace splits a string into leading whitespace, trailing
+// whitespace, and everything inbetween
+func splitSpace(s string) (left, middle, right string) {
+	trimmed := strings.TrimLeftFunc(s, unicode.IsSpace)
+	left = s[:len(s)-len(trimmed)]
+	middle = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	right = trimmed[len(middle):]
+	return
+}
+
+// fixLatexSymbol changes \T1 to \Tone and so forth, because latex does not permit numbers in symbols
+var fixLatexSymbol = strings.NewReplacer(
+	"0", "zero",
+	"1", "one",
+	"2", "two",
+	"3", "three",
+	"4", "four",
+	"5", "five",
+	"6", "six",
+	"7", "seven",
+	"8", "eight",
+	"9", "nine",
+).Replace
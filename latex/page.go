@@ -0,0 +1,101 @@
+package latex
+
+// This file converts headers and footers to memoir page styles
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// PageStyle generates preamble commands that define and select a memoir
+// page style named "googledoc" from the headers and footers of a document.
+// The result is empty if the document has no headers or footers.
+func PageStyle(doc *docs.Document) (string, error) {
+	style := doc.DocumentStyle
+	if style == nil || (style.DefaultHeaderId == "" && style.DefaultFooterId == "") {
+		return "", nil
+	}
+	if style.UseFirstPageHeaderFooter {
+		log.Println("warning: ignoring the separate header and footer for the first page")
+	}
+
+	// the even page header and footer are only used if requested
+	evenHeader, evenFooter := style.DefaultHeaderId, style.DefaultFooterId
+	if style.UseEvenPageHeaderFooter {
+		evenHeader, evenFooter = style.EvenPageHeaderId, style.EvenPageFooterId
+	}
+
+	// comment anchors refer to the body, so treat headers like footnotes
+	conv := latexConverter{doc: doc, opts: &Options{}, replace: make(map[string]string), inFootnote: true}
+
+	var out strings.Builder
+	out.WriteString("\\makepagestyle{googledoc}\n")
+	for _, slot := range []struct {
+		command string
+		content []*docs.StructuralElement
+	}{
+		{"makeoddhead", headerContent(doc, style.DefaultHeaderId)},
+		{"makeevenhead", headerContent(doc, evenHeader)},
+		{"makeoddfoot", footerContent(doc, style.DefaultFooterId)},
+		{"makeevenfoot", footerContent(doc, evenFooter)},
+	} {
+		left, center, right, err := conv.headerParts(slot.content)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&out, "\\%s{googledoc}{%s}{%s}{%s}\n", slot.command, left, center, right)
+	}
+	out.WriteString("\\pagestyle{googledoc}\n")
+	return out.String(), nil
+}
+
+func headerContent(doc *docs.Document, id string) []*docs.StructuralElement {
+	if id == "" {
+		return nil
+	}
+	return doc.Headers[id].Content
+}
+
+func footerContent(doc *docs.Document, id string) []*docs.StructuralElement {
+	if id == "" {
+		return nil
+	}
+	return doc.Footers[id].Content
+}
+
+// headerParts converts the paragraphs in a header or footer to the left,
+// center, and right parts of a memoir header, according to their alignment
+func (dc *latexConverter) headerParts(content []*docs.StructuralElement) (left, center, right string, err error) {
+	var parts [3][]string
+	for _, elem := range content {
+		if elem.Paragraph == nil {
+			if elem.SectionBreak == nil {
+				log.Println("warning: ignoring non-paragraph content in header or footer")
+			}
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := dc.processElements(&buf, &buf, elem.Paragraph); err != nil {
+			return "", "", "", err
+		}
+		text := strings.TrimSpace(buf.String())
+		if text == "" {
+			continue
+		}
+
+		var pos int
+		switch elem.Paragraph.ParagraphStyle.Alignment {
+		case "CENTER":
+			pos = 1
+		case "END":
+			pos = 2
+		}
+		parts[pos] = append(parts[pos], text)
+	}
+	return strings.Join(parts[0], " "), strings.Join(parts[1], " "), strings.Join(parts[2], " "), nil
+}
@@ -0,0 +1,89 @@
+package latex
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+)
+
+// processBlockMarker opens or closes a spoiler or collapsible section.
+// Print cannot hide anything, so collapsible sections are written out in
+// full under their summary.
+func (dc *latexConverter) processBlockMarker(out *bytes.Buffer, m *googledoc.BlockMarker) {
+	switch dc.hidden.Apply(m) {
+	case googledoc.OpenSpoiler:
+		dc.setSpoiler(out, true)
+	case googledoc.CloseSpoiler:
+		dc.setSpoiler(out, false)
+	case googledoc.OpenDetails:
+		w := dc.writer(out)
+		dc.flushCodeBlock(w)
+		dc.closeLists(w, 0)
+		fmt.Fprintf(w, "\\textbf{%s}\n\n", escape(m.Summary))
+	}
+}
+
+// writer gets the buffer that content should currently be written to,
+// which is the spoiler being collected if there is one
+func (dc *latexConverter) writer(out *bytes.Buffer) *bytes.Buffer {
+	if dc.spoiler != nil {
+		return dc.spoiler
+	}
+	return out
+}
+
+// setSpoiler starts or ends a spoiler. The content of a spoiler is
+// collected and then written as a footnote or in an appendix, except
+// inside footnotes, which are already out of the way of the text.
+func (dc *latexConverter) setSpoiler(out *bytes.Buffer, on bool) {
+	if on == (dc.spoiler != nil) || dc.inFootnote {
+		return
+	}
+	if on {
+		dc.flushCodeBlock(out)
+		dc.closeLists(out, 0)
+		dc.spoiler = new(bytes.Buffer)
+		return
+	}
+
+	dc.flushCodeBlock(dc.spoiler)
+	dc.closeLists(dc.spoiler, 0)
+	content := strings.TrimSpace(dc.spoiler.String())
+	dc.spoiler = nil
+	if content == "" {
+		return
+	}
+
+	switch dc.opts.SpoilerStyle {
+	case AppendixSpoilers:
+		dc.spoilers = append(dc.spoilers, content)
+		n := len(dc.spoilers)
+		fmt.Fprintf(out, "\\emph{See \\hyperref[spoiler-%d]{spoiler %d} at the end.}\n\n", n, n)
+	default:
+		if strings.Contains(content, "\\begin{verbatim}") || strings.Contains(content, "\\begin{lstlisting}") {
+			log.Println("warning: code blocks cannot appear in footnotes, use --spoilers=appendix instead")
+		}
+		fmt.Fprintf(out, "\\emph{Spoiler}\\footnote{%s}\n\n", content)
+	}
+}
+
+// closeHiddenBlocks closes any spoilers and collapsible sections that were
+// not closed explicitly
+func (dc *latexConverter) closeHiddenBlocks(out *bytes.Buffer) {
+	dc.hidden.Close()
+	dc.setSpoiler(out, false)
+}
+
+// writeSpoilerAppendix writes the spoilers that were collected for the appendix
+func (dc *latexConverter) writeSpoilerAppendix(out *bytes.Buffer) {
+	if len(dc.spoilers) == 0 {
+		return
+	}
+	fmt.Fprint(out, "\\section*{Spoilers}\n\n")
+	for i, content := range dc.spoilers {
+		fmt.Fprintf(out, "\\subsection*{Spoiler %d}\\phantomsection\\label{spoiler-%d}\n\n%s\n\n", i+1, i+1, content)
+	}
+}
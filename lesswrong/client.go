@@ -48,6 +48,7 @@ func (c *Client) createRequest(query string) *graphql.Request {
 
 // User represents information about a lesswrong user
 type User struct {
+	ID       string `json:"_id"`
 	Username string
 	Email    string
 	Bio      string
@@ -66,6 +67,7 @@ func (c *Client) User(ctx context.Context, username string) (*User, error) {
 	query($username: String!) {
 		user(input: {selector: {slug: $username}}) {
 			result {
+				_id
 				username
 				email
 				bio
@@ -81,9 +83,52 @@ func (c *Client) User(ctx context.Context, username string) (*User, error) {
 		return nil, fmt.Errorf("error performing graphql query: %w", err)
 	}
 
+	if res.User.Result == nil {
+		return nil, fmt.Errorf("no lesswrong user named %q", username)
+	}
 	return res.User.Result, nil
 }
 
+// Tag represents a lesswrong tag
+type Tag struct {
+	ID   string `json:"_id"`
+	Name string
+	Slug string
+}
+
+type tagContainer struct {
+	Tag struct {
+		Result *Tag
+	}
+}
+
+// Tag fetches information about a tag
+func (c *Client) Tag(ctx context.Context, slug string) (*Tag, error) {
+	// make a request
+	req := c.createRequest(`
+	query($slug: String!) {
+		tag(input: {selector: {slug: $slug}}) {
+			result {
+				_id
+				name
+				slug
+			}
+		}
+	}`)
+
+	req.Var("slug", slug)
+
+	// run it and capture the response
+	var res tagContainer
+	if err := c.graphql.Run(ctx, req, &res); err != nil {
+		return nil, fmt.Errorf("error performing graphql query: %w", err)
+	}
+	if res.Tag.Result == nil {
+		return nil, fmt.Errorf("no lesswrong tag with slug %q", slug)
+	}
+	return res.Tag.Result, nil
+}
+
 type loginRequest struct {
 	Username string
 	Password string
@@ -120,6 +165,16 @@ func (c *Client) login(ctx context.Context, r loginRequest) (*loginResponse, err
 type CreatePostRequest struct {
 	Title   string // title of the post
 	Content string // markdown-formatted post contents
+
+	Draft             *bool  // whether to create the post as a draft (default true)
+	SubmitToFrontpage *bool  // whether to submit the post to the frontpage (default true)
+	ModerationStyle   string // moderation style for comments (default "easy-going")
+	CanonicalSource   string // URL of the original, for crossposts
+
+	Tags      []string // slugs of tags to apply to the post
+	Coauthors []string // usernames of coauthors
+	Sequence  string   // ID of the sequence that the post belongs to
+	Slug      string   // the URL slug for the post
 }
 
 type CreatePostResponse struct {
@@ -142,18 +197,29 @@ type postOriginalContents struct {
 	Data string `json:"data"` // the content of the post, in markdown
 }
 
+type coauthorStatus struct {
+	UserID    string `json:"userId"`
+	Confirmed bool   `json:"confirmed"`
+	Requested bool   `json:"requested"`
+}
+
 // CreatePost creates a new post
 func (c *Client) CreatePost(ctx context.Context, r CreatePostRequest) (*CreatePostResponse, error) {
 	req := c.createRequest(`
-	mutation($title:String!, $contents:JSON!) {
+	mutation($title:String!, $contents:JSON!, $draft:Boolean!, $submitToFrontpage:Boolean!, $moderationStyle:String!, $canonicalSource:String, $tagRelevance:JSON, $coauthorStatuses:[JSON], $canonicalSequenceId:String, $slug:String) {
 		createPost(data: {
 			title: $title,
-			submitToFrontpage: true,
-			draft: true,
+			submitToFrontpage: $submitToFrontpage,
+			draft: $draft,
 			meta: false,
 			isEvent: false,
 			types: [],
-			moderationStyle: "easy-going",
+			moderationStyle: $moderationStyle,
+			canonicalSource: $canonicalSource,
+			tagRelevance: $tagRelevance,
+			coauthorStatuses: $coauthorStatuses,
+			canonicalSequenceId: $canonicalSequenceId,
+			slug: $slug,
 			contents: $contents,
 		}) {
 			data {
@@ -173,6 +239,48 @@ func (c *Client) CreatePost(ctx context.Context, r CreatePostRequest) (*CreatePo
 
 	req.Var("contents", contents)
 	req.Var("title", r.Title)
+	req.Var("draft", r.Draft == nil || *r.Draft)
+	req.Var("submitToFrontpage", r.SubmitToFrontpage == nil || *r.SubmitToFrontpage)
+
+	moderationStyle := r.ModerationStyle
+	if moderationStyle == "" {
+		moderationStyle = "easy-going"
+	}
+	req.Var("moderationStyle", moderationStyle)
+
+	if r.CanonicalSource != "" {
+		req.Var("canonicalSource", r.CanonicalSource)
+	}
+	if r.Sequence != "" {
+		req.Var("canonicalSequenceId", r.Sequence)
+	}
+	if r.Slug != "" {
+		req.Var("slug", r.Slug)
+	}
+
+	// lesswrong refers to tags and coauthors by ID, so look them up first
+	if len(r.Tags) > 0 {
+		relevance := make(map[string]int)
+		for _, slug := range r.Tags {
+			tag, err := c.Tag(ctx, slug)
+			if err != nil {
+				return nil, err
+			}
+			relevance[tag.ID] = 1
+		}
+		req.Var("tagRelevance", relevance)
+	}
+	if len(r.Coauthors) > 0 {
+		var statuses []coauthorStatus
+		for _, username := range r.Coauthors {
+			user, err := c.User(ctx, username)
+			if err != nil {
+				return nil, err
+			}
+			statuses = append(statuses, coauthorStatus{UserID: user.ID, Confirmed: true})
+		}
+		req.Var("coauthorStatuses", statuses)
+	}
 
 	// run it and capture the response
 	var res createPostContainer
@@ -0,0 +1,352 @@
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// Statuses of a checked link
+const (
+	OK         = "ok"
+	Broken     = "broken"     // the server returned 4xx or 5xx, or the heading does not exist
+	Redirect   = "redirect"   // the server redirected elsewhere
+	Timeout    = "timeout"    // the server did not respond in time
+	Failed     = "error"      // the request could not be made
+	Unverified = "unverified" // the link could not be checked
+	Skipped    = "skipped"    // the link was not checked because the checker is offline
+)
+
+// Link is a link in a document
+type Link struct {
+	Text       string // the text of the link
+	Segment    string // "body", "footnote", "header", or "footer"
+	URL        string // the URL of an external link
+	HeadingID  string // the heading that an internal link points to
+	BookmarkID string // the bookmark that an internal link points to
+}
+
+// Internal determines whether a link points within the document
+func (l *Link) Internal() bool {
+	return l.HeadingID != "" || l.BookmarkID != ""
+}
+
+// Result is the outcome of checking a link
+type Result struct {
+	Link       *Link
+	Status     string // one of the statuses above
+	StatusCode int    // the HTTP status code, if the server responded
+	Location   string // where the server redirected to
+	Err        error  // the error for failed requests
+}
+
+// Problem determines whether the link is broken or could not be reached
+func (r *Result) Problem() bool {
+	return r.Status == Broken || r.Status == Timeout || r.Status == Failed
+}
+
+func (r *Result) String() string {
+	target := r.Link.URL
+	if r.Link.HeadingID != "" {
+		target = "#heading=" + r.Link.HeadingID
+	} else if r.Link.BookmarkID != "" {
+		target = "#bookmark=" + r.Link.BookmarkID
+	}
+
+	detail := r.Status
+	switch {
+	case r.Status == Redirect:
+		detail = fmt.Sprintf("redirect (%d) to %s", r.StatusCode, r.Location)
+	case r.Err != nil:
+		detail = fmt.Sprintf("%s: %v", r.Status, r.Err)
+	case r.StatusCode != 0:
+		detail = fmt.Sprintf("%s (%d %s)", r.Status, r.StatusCode, http.StatusText(r.StatusCode))
+	}
+	return fmt.Sprintf("%s: %q in %s links to %s", detail, r.Link.Text, r.Link.Segment, target)
+}
+
+// Links gets every link in a document, including those in footnotes,
+// headers, footers, and tables. Links to headings and bookmarks in the same
+// document are resolved to their IDs. Consecutive text runs with the same
+// link are merged.
+func Links(doc *docs.Document) []*Link {
+	var links []*Link
+	var prev *Link
+	googledoc.ForEachTextRun(doc, func(segment string, t *docs.TextRun) {
+		if t.TextStyle == nil || t.TextStyle.Link == nil {
+			prev = nil
+			return
+		}
+		dl := t.TextStyle.Link
+		if prev != nil && prev.Segment == segment && prev.URL == dl.Url &&
+			prev.HeadingID == dl.HeadingId && prev.BookmarkID == dl.BookmarkId {
+			prev.Text += t.Content
+			return
+		}
+
+		link := Link{
+			Text:       t.Content,
+			Segment:    segment,
+			URL:        dl.Url,
+			HeadingID:  dl.HeadingId,
+			BookmarkID: dl.BookmarkId,
+		}
+		if kind, id := sameDocAnchor(doc, dl.Url); kind == "heading" {
+			link.HeadingID = id
+		} else if kind == "bookmark" {
+			link.BookmarkID = id
+		}
+		links = append(links, &link)
+		prev = &link
+	})
+
+	for _, link := range links {
+		link.Text = strings.TrimSpace(link.Text)
+	}
+	return links
+}
+
+// sameDocAnchor gets the kind and ID of the heading or bookmark that a URL
+// points to if it links within the given document
+func sameDocAnchor(doc *docs.Document, s string) (kind, id string) {
+	if s == "" || doc.DocumentId == "" || googledoc.DocIDFromURL(s) != doc.DocumentId {
+		return "", ""
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", ""
+	}
+	parts := strings.SplitN(u.Fragment, "=", 2)
+	if len(parts) != 2 || (parts[0] != "heading" && parts[0] != "bookmark") {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// Checker checks the links in a document
+type Checker struct {
+	// Client makes HTTP requests. Redirects are reported rather than
+	// followed, whatever the client's redirect policy. If nil, a client
+	// with default settings is used.
+	Client *http.Client
+
+	Concurrency int           // maximum number of requests at once, or 4 if zero
+	HostDelay   time.Duration // minimum time between requests to the same host
+	Timeout     time.Duration // time limit for each request, or 10 seconds if zero
+	Offline     bool          // only check links within the document
+}
+
+// Check checks every link in a document, returning one result per link in
+// the order that Links finds them. Each external URL is requested once.
+func (c *Checker) Check(ctx context.Context, doc *docs.Document) []*Result {
+	links := Links(doc)
+
+	headings := make(map[string]bool)
+	if doc.Body != nil {
+		for _, id := range googledoc.HeadingIDs(doc.Body.Content) {
+			headings[id] = true
+		}
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, link := range links {
+		if !link.Internal() && isHTTP(link.URL) && !seen[link.URL] {
+			seen[link.URL] = true
+			urls = append(urls, link.URL)
+		}
+	}
+
+	var external map[string]*Result
+	if !c.Offline {
+		external = c.checkURLs(ctx, urls)
+	}
+
+	var results []*Result
+	for _, link := range links {
+		r := Result{Link: link}
+		switch {
+		case link.HeadingID != "":
+			r.Status = OK
+			if !headings[link.HeadingID] {
+				r.Status = Broken
+				r.Err = errors.New("no such heading")
+			}
+		case link.BookmarkID != "":
+			// the docs API does not say where bookmarks are, but some
+			// documents use heading IDs as bookmarks
+			r.Status = OK
+			if !headings[link.BookmarkID] {
+				r.Status = Unverified
+				r.Err = errors.New("bookmarks cannot be checked")
+			}
+		case !isHTTP(link.URL):
+			r.Status = Unverified
+			r.Err = errors.New("not an http link")
+		case c.Offline:
+			r.Status = Skipped
+		default:
+			ext := external[link.URL]
+			r.Status, r.StatusCode, r.Location, r.Err = ext.Status, ext.StatusCode, ext.Location, ext.Err
+		}
+		results = append(results, &r)
+	}
+	return results
+}
+
+// isHTTP determines whether a URL can be checked with an HTTP request
+func isHTTP(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// checkURLs requests each URL concurrently, returning results keyed by URL
+func (c *Checker) checkURLs(ctx context.Context, urls []string) map[string]*Result {
+	client := http.Client{}
+	if c.Client != nil {
+		client = *c.Client
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	var limiter hostLimiter
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]*Result)
+	queue := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queue {
+				r := c.checkURL(ctx, &client, &limiter, u)
+				mu.Lock()
+				results[u] = r
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range urls {
+		queue <- u
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+// checkURL requests a URL with HEAD, falling back to GET for servers that
+// reject HEAD requests
+func (c *Checker) checkURL(ctx context.Context, client *http.Client, limiter *hostLimiter, u string) *Result {
+	resp, err := c.request(ctx, client, limiter, http.MethodHead, u)
+	if err == nil && resp.StatusCode >= 400 {
+		resp, err = c.request(ctx, client, limiter, http.MethodGet, u)
+	}
+
+	var r Result
+	switch {
+	case isTimeout(err):
+		r.Status, r.Err = Timeout, err
+	case err != nil:
+		r.Status, r.Err = Failed, err
+	case resp.StatusCode >= 400:
+		r.Status, r.StatusCode = Broken, resp.StatusCode
+	case resp.StatusCode >= 300:
+		r.Status, r.StatusCode = Redirect, resp.StatusCode
+		r.Location = resp.Header.Get("Location")
+		if loc, err := resp.Location(); err == nil {
+			r.Location = loc.String()
+		}
+	default:
+		r.Status, r.StatusCode = OK, resp.StatusCode
+	}
+	return &r
+}
+
+// request makes a single request once the host is free, discarding the body
+func (c *Checker) request(ctx context.Context, client *http.Client, limiter *hostLimiter, method, u string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "doc-publisher link checker")
+
+	err = limiter.wait(ctx, req.URL.Host, c.HostDelay)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// isTimeout determines whether a request failed because it took too long
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// hostLimiter spaces out requests to each host
+type hostLimiter struct {
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// wait blocks until a request to a host is allowed, and reserves the
+// following slot for the next request to the same host
+func (l *hostLimiter) wait(ctx context.Context, host string, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.next == nil {
+		l.next = make(map[string]time.Time)
+	}
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(delay)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,122 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
+)
+
+func link(text string, l *docs.Link) *docs.TextRun {
+	return &docs.TextRun{Content: text, TextStyle: &docs.TextStyle{Link: l}}
+}
+
+func TestCheck(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/ok":
+		case "/missing":
+			http.NotFound(w, r)
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	defer server.Close()
+
+	intro := doctest.Paragraph(1, "HEADING_1", nil, &docs.TextRun{Content: "Intro\n"})
+	intro.Paragraph.ParagraphStyle.HeadingId = "h.intro"
+
+	doc := docs.Document{
+		DocumentId: "self",
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			intro,
+			doctest.Paragraph(7, "NORMAL_TEXT", nil,
+				link("fine", &docs.Link{Url: server.URL + "/ok"}),
+				&docs.TextRun{Content: " and "},
+				link("gone", &docs.Link{Url: server.URL + "/missing"}),
+				link("moved", &docs.Link{Url: server.URL + "/moved"}),
+				link("intro", &docs.Link{HeadingId: "h.intro"}),
+				link("nowhere", &docs.Link{HeadingId: "h.nowhere"}),
+				link("also intro", &docs.Link{Url: "https://docs.google.com/document/d/self/edit#heading=h.intro"}),
+				&docs.TextRun{Content: "\n"}),
+			{Table: &docs.Table{TableRows: []*docs.TableRow{{TableCells: []*docs.TableCell{{
+				Content: []*docs.StructuralElement{
+					doctest.Paragraph(50, "NORMAL_TEXT", nil, link("no head", &docs.Link{Url: server.URL + "/nohead"})),
+				},
+			}}}}}},
+		}},
+		Footnotes: map[string]docs.Footnote{
+			"f1": {Content: []*docs.StructuralElement{
+				doctest.Paragraph(0, "NORMAL_TEXT", nil,
+					link("slow", &docs.Link{Url: server.URL + "/slow"}),
+					link("fine again", &docs.Link{Url: server.URL + "/ok"})),
+			}},
+		},
+	}
+
+	checker := Checker{Client: server.Client(), Timeout: 100 * time.Millisecond, HostDelay: time.Millisecond}
+	results := checker.Check(context.Background(), &doc)
+	require.Len(t, results, 9)
+
+	statuses := make(map[string]string)
+	for _, r := range results {
+		statuses[r.Link.Text] = r.Status
+	}
+	assert.Equal(t, map[string]string{
+		"fine":       OK,
+		"gone":       Broken,
+		"moved":      Redirect,
+		"intro":      OK,
+		"nowhere":    Broken,
+		"also intro": OK,
+		"no head":    OK,
+		"slow":       Timeout,
+		"fine again": OK,
+	}, statuses)
+
+	assert.Equal(t, "footnote", results[7].Link.Segment)
+	assert.Equal(t, http.StatusNotFound, results[1].StatusCode)
+	assert.Equal(t, server.URL+"/ok", results[2].Location)
+	assert.Equal(t, 1, requests["HEAD /ok"])
+	assert.Equal(t, 1, requests["GET /nohead"])
+}
+
+func TestCheckOffline(t *testing.T) {
+	doc := docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			doctest.Paragraph(1, "NORMAL_TEXT", nil,
+				link("example", &docs.Link{Url: "https://example.com/"}),
+				link("mail", &docs.Link{Url: "mailto:someone@example.com"}),
+				link("mark", &docs.Link{BookmarkId: "id.123"})),
+		}},
+	}
+
+	checker := Checker{Offline: true}
+	results := checker.Check(context.Background(), &doc)
+	require.Len(t, results, 3)
+	assert.Equal(t, Skipped, results[0].Status)
+	assert.Equal(t, Unverified, results[1].Status)
+	assert.Equal(t, Unverified, results[2].Status)
+}
@@ -0,0 +1,204 @@
+package lint
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// checkImageAlt reports images with neither a title nor a description,
+// which become the alt text in exports
+func checkImageAlt(l *linter) {
+	l.forEachParagraph(func(segment string, p *docs.Paragraph) {
+		for _, el := range p.Elements {
+			if el.InlineObjectElement == nil {
+				continue
+			}
+			id := el.InlineObjectElement.InlineObjectId
+			obj, ok := l.doc.InlineObjects[id]
+			if !ok || obj.InlineObjectProperties == nil || obj.InlineObjectProperties.EmbeddedObject == nil {
+				continue
+			}
+			embedded := obj.InlineObjectProperties.EmbeddedObject
+			if strings.TrimSpace(embedded.Title) == "" && strings.TrimSpace(embedded.Description) == "" {
+				l.report(segment, p, el.StartIndex, "image %s has no alt text", id)
+			}
+		}
+	})
+}
+
+// headingLevel gets the level of a heading style, or zero for other styles
+func headingLevel(style *docs.ParagraphStyle) int {
+	if style == nil || !strings.HasPrefix(style.NamedStyleType, "HEADING_") {
+		return 0
+	}
+	level, _ := strconv.Atoi(strings.TrimPrefix(style.NamedStyleType, "HEADING_"))
+	return level
+}
+
+// checkHeadingLevels reports headings that are more than one level below
+// the previous heading. The first heading may be at any level.
+func checkHeadingLevels(l *linter) {
+	if l.doc.Body == nil {
+		return
+	}
+	var prev int
+	forEachParagraph(l.doc.Body.Content, func(p *docs.Paragraph) {
+		level := headingLevel(p.ParagraphStyle)
+		if level == 0 {
+			return
+		}
+		if prev > 0 && level > prev+1 {
+			l.report("body", p, paragraphStart(p), "heading level %d follows heading level %d", level, prev)
+		}
+		prev = level
+	})
+}
+
+// checkEmptyHeadings reports headings with no text
+func checkEmptyHeadings(l *linter) {
+	l.forEachParagraph(func(segment string, p *docs.Paragraph) {
+		if p.ParagraphStyle == nil {
+			return
+		}
+		switch p.ParagraphStyle.NamedStyleType {
+		case "TITLE", "SUBTITLE":
+		default:
+			if headingLevel(p.ParagraphStyle) == 0 {
+				return
+			}
+		}
+		if strings.TrimSpace(googledoc.ParagraphText(p)) == "" && !googledoc.HasObjects(p) {
+			l.report(segment, p, paragraphStart(p), "empty %s", p.ParagraphStyle.NamedStyleType)
+		}
+	})
+}
+
+// checkMissingFootnotes reports footnote references with no footnote, or
+// with a footnote that is empty
+func checkMissingFootnotes(l *linter) {
+	l.forEachParagraph(func(segment string, p *docs.Paragraph) {
+		for _, el := range p.Elements {
+			if el.FootnoteReference == nil {
+				continue
+			}
+			id := el.FootnoteReference.FootnoteId
+			footnote, ok := l.doc.Footnotes[id]
+			if !ok {
+				l.report(segment, p, el.StartIndex, "footnote %s is referenced but missing", id)
+				continue
+			}
+			var text strings.Builder
+			forEachParagraph(footnote.Content, func(fp *docs.Paragraph) {
+				text.WriteString(googledoc.ParagraphText(fp))
+			})
+			if strings.TrimSpace(text.String()) == "" {
+				l.report(segment, p, el.StartIndex, "footnote %s is empty", id)
+			}
+		}
+	})
+}
+
+// checkUnsupported reports content that no exporter can convert
+func checkUnsupported(l *linter) {
+	l.forEachParagraph(func(segment string, p *docs.Paragraph) {
+		for _, id := range p.PositionedObjectIds {
+			l.report(segment, p, paragraphStart(p), "positioned object %s will be dropped (make it inline instead)", id)
+		}
+		for _, el := range p.Elements {
+			switch {
+			case el.Equation != nil:
+				l.report(segment, p, el.StartIndex, "equation will be dropped (write it in LaTeX instead)")
+			case el.ColumnBreak != nil:
+				l.report(segment, p, el.StartIndex, "column break will be dropped")
+			}
+		}
+	})
+}
+
+// a regular expression for markers of unfinished text
+var todoRegexp = regexp.MustCompile(`\b(?:TODO|TK)\b`)
+
+// checkTodos reports leftover TODO and TK markers
+func checkTodos(l *linter) {
+	l.forEachParagraph(func(segment string, p *docs.Paragraph) {
+		for _, el := range p.Elements {
+			if el.TextRun == nil {
+				continue
+			}
+			for _, m := range todoRegexp.FindAllString(el.TextRun.Content, -1) {
+				l.report(segment, p, el.StartIndex, "leftover %s", m)
+			}
+		}
+	})
+}
+
+// checkSuggestions reports each suggested edit once, at the first place it
+// appears. Suggestions only appear in documents that were fetched with
+// suggestions marked up inline.
+func checkSuggestions(l *linter) {
+	seen := make(map[string]bool)
+	l.forEachParagraph(func(segment string, p *docs.Paragraph) {
+		for _, el := range p.Elements {
+			for _, id := range suggestionIDs(el) {
+				if !seen[id] {
+					seen[id] = true
+					l.report(segment, p, el.StartIndex, "suggestion %s has not been accepted or rejected", id)
+				}
+			}
+		}
+	})
+}
+
+// suggestionIDs gets the IDs of the suggested edits to a paragraph element
+func suggestionIDs(el *docs.ParagraphElement) []string {
+	var ids []string
+	switch {
+	case el.TextRun != nil:
+		ids = append(ids, el.TextRun.SuggestedInsertionIds...)
+		ids = append(ids, el.TextRun.SuggestedDeletionIds...)
+		var changes []string
+		for id := range el.TextRun.SuggestedTextStyleChanges {
+			changes = append(changes, id)
+		}
+		sort.Strings(changes)
+		ids = append(ids, changes...)
+	case el.InlineObjectElement != nil:
+		ids = append(ids, el.InlineObjectElement.SuggestedInsertionIds...)
+		ids = append(ids, el.InlineObjectElement.SuggestedDeletionIds...)
+	case el.FootnoteReference != nil:
+		ids = append(ids, el.FootnoteReference.SuggestedInsertionIds...)
+		ids = append(ids, el.FootnoteReference.SuggestedDeletionIds...)
+	case el.PageBreak != nil:
+		ids = append(ids, el.PageBreak.SuggestedInsertionIds...)
+		ids = append(ids, el.PageBreak.SuggestedDeletionIds...)
+	case el.HorizontalRule != nil:
+		ids = append(ids, el.HorizontalRule.SuggestedInsertionIds...)
+		ids = append(ids, el.HorizontalRule.SuggestedDeletionIds...)
+	}
+	return ids
+}
+
+// checkComments reports comments that have not been resolved. Comments
+// are only present in archives that were fetched with comments.
+func checkComments(l *linter) {
+	for _, c := range l.archive.Comments {
+		if c.Resolved {
+			continue
+		}
+		issue := Issue{
+			Check:    l.check.ID,
+			Severity: l.check.Severity,
+			Message:  "unresolved comment by " + c.Author + ": " + excerpt(c.Content),
+			Context:  excerpt(c.Quote),
+		}
+		if c.Anchored() {
+			issue.Segment, issue.Index = "body", c.StartIndex
+		}
+		l.issues = append(l.issues, &issue)
+	}
+}
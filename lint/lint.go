@@ -0,0 +1,226 @@
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Severities of issues
+const (
+	Error   = "error"   // the document should not be published until this is fixed
+	Warning = "warning" // the document can be published but may not look as intended
+)
+
+// Issue is a problem found by a check
+type Issue struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Segment  string `json:"segment,omitempty"` // "body", "footnote", "header", or "footer"
+	Index    int64  `json:"index,omitempty"`   // UTF-16 index in the segment
+	Context  string `json:"context,omitempty"` // the start of the paragraph the issue is in
+}
+
+func (i *Issue) String() string {
+	s := fmt.Sprintf("%s: [%s] %s", i.Severity, i.Check, i.Message)
+	if i.Segment != "" {
+		s += fmt.Sprintf(" (%s, index %d)", i.Segment, i.Index)
+	}
+	if i.Context != "" {
+		s += fmt.Sprintf(": %q", i.Context)
+	}
+	return s
+}
+
+// Check is a test that a document is ready to publish
+type Check struct {
+	ID          string
+	Description string
+	Severity    string
+	run         func(l *linter)
+}
+
+// Checks is every check, in the order that they run
+var Checks = []*Check{
+	{"image-alt", "images without alt text", Warning, checkImageAlt},
+	{"heading-levels", "headings that skip a level", Warning, checkHeadingLevels},
+	{"empty-heading", "headings with no text", Warning, checkEmptyHeadings},
+	{"missing-footnote", "footnotes that are referenced but have no content", Error, checkMissingFootnotes},
+	{"unsupported", "features that will be dropped when exporting", Warning, checkUnsupported},
+	{"todo", "leftover TODO and TK markers", Error, checkTodos},
+	{"suggestion", "suggested edits that have not been accepted or rejected", Error, checkSuggestions},
+	{"comment", "comments that have not been resolved", Warning, checkComments},
+}
+
+// Config selects which checks to run
+type Config struct {
+	Only    []string `yaml:"only"`    // run only these checks
+	Disable []string `yaml:"disable"` // do not run these checks
+}
+
+// LoadConfig loads lint configuration from a YAML or JSON file
+func LoadConfig(path string) (*Config, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading lint configuration: %w", err)
+	}
+
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	dec.KnownFields(true)
+	err = dec.Decode(&cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing lint configuration in %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// enabled gets the set of checks to run
+func (cfg *Config) enabled() (map[string]bool, error) {
+	known := make(map[string]bool)
+	for _, c := range Checks {
+		known[c.ID] = true
+	}
+	for _, id := range append(append([]string(nil), cfg.Only...), cfg.Disable...) {
+		if !known[id] {
+			return nil, fmt.Errorf("unknown check %q", id)
+		}
+	}
+
+	enabled := make(map[string]bool)
+	for _, c := range Checks {
+		enabled[c.ID] = len(cfg.Only) == 0
+	}
+	for _, id := range cfg.Only {
+		enabled[id] = true
+	}
+	for _, id := range cfg.Disable {
+		enabled[id] = false
+	}
+	return enabled, nil
+}
+
+// Run runs the enabled checks on a document. Configuration may be nil to
+// run every check.
+func Run(d *googledoc.Archive, cfg *Config) ([]*Issue, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	enabled, err := cfg.enabled()
+	if err != nil {
+		return nil, err
+	}
+
+	l := linter{archive: d, doc: d.Doc}
+	for _, c := range Checks {
+		if enabled[c.ID] {
+			l.check = c
+			c.run(&l)
+		}
+	}
+	return l.issues, nil
+}
+
+// linter holds the state of a lint run
+type linter struct {
+	archive *googledoc.Archive
+	doc     *docs.Document
+	check   *Check // the check that is running
+	issues  []*Issue
+}
+
+// report adds an issue for the running check
+func (l *linter) report(segment string, p *docs.Paragraph, index int64, format string, args ...interface{}) {
+	issue := Issue{
+		Check:    l.check.ID,
+		Severity: l.check.Severity,
+		Message:  fmt.Sprintf(format, args...),
+		Segment:  segment,
+		Index:    index,
+	}
+	if p != nil {
+		issue.Context = excerpt(googledoc.ParagraphText(p))
+	}
+	l.issues = append(l.issues, &issue)
+}
+
+// forEachParagraph calls a function for each paragraph in the body,
+// footnotes, headers, and footers, including those in tables. Footnotes,
+// headers, and footers are visited in order of their IDs.
+func (l *linter) forEachParagraph(f func(segment string, p *docs.Paragraph)) {
+	visit := func(segment string, content []*docs.StructuralElement) {
+		forEachParagraph(content, func(p *docs.Paragraph) {
+			f(segment, p)
+		})
+	}
+
+	if l.doc.Body != nil {
+		visit("body", l.doc.Body.Content)
+	}
+
+	var ids []string
+	for id := range l.doc.Footnotes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		visit("footnote", l.doc.Footnotes[id].Content)
+	}
+	ids = ids[:0]
+	for id := range l.doc.Headers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		visit("header", l.doc.Headers[id].Content)
+	}
+	ids = ids[:0]
+	for id := range l.doc.Footers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		visit("footer", l.doc.Footers[id].Content)
+	}
+}
+
+func forEachParagraph(content []*docs.StructuralElement, f func(*docs.Paragraph)) {
+	for _, elem := range content {
+		switch {
+		case elem.Paragraph != nil:
+			f(elem.Paragraph)
+		case elem.Table != nil:
+			for _, row := range elem.Table.TableRows {
+				for _, cell := range row.TableCells {
+					forEachParagraph(cell.Content, f)
+				}
+			}
+		}
+	}
+}
+
+// paragraphStart gets the index of the first element in a paragraph
+func paragraphStart(p *docs.Paragraph) int64 {
+	if len(p.Elements) == 0 {
+		return 0
+	}
+	return p.Elements[0].StartIndex
+}
+
+// excerpt shortens text to show where an issue is
+func excerpt(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > 60 {
+		s = string(r[:57]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
+)
+
+func testArchive() *googledoc.Archive {
+	// a paragraph with a footnote reference and an image between its runs
+	body := doctest.Paragraph(7, "NORMAL_TEXT", nil, &docs.TextRun{Content: "Some TODO text"}, &docs.TextRun{Content: "\n"})
+	text, end := body.Paragraph.Elements[0], body.Paragraph.Elements[1]
+	end.StartIndex, end.EndIndex, body.EndIndex = 23, 24, 24
+	body.Paragraph.Elements = []*docs.ParagraphElement{
+		text,
+		{StartIndex: 21, EndIndex: 22, FootnoteReference: &docs.FootnoteReference{FootnoteId: "f1"}},
+		{StartIndex: 22, EndIndex: 23, InlineObjectElement: &docs.InlineObjectElement{InlineObjectId: "img1"}},
+		end,
+	}
+
+	return &googledoc.Archive{
+		Doc: &docs.Document{
+			Body: &docs.Body{Content: []*docs.StructuralElement{
+				doctest.Paragraph(1, "HEADING_1", nil, &docs.TextRun{Content: "Intro\n"}),
+				body,
+				doctest.Paragraph(24, "HEADING_3", nil, &docs.TextRun{Content: "Details\n"}),
+				doctest.Paragraph(32, "HEADING_2", nil, &docs.TextRun{Content: " \n"}),
+			}},
+			InlineObjects: map[string]docs.InlineObject{
+				"img1": {InlineObjectProperties: &docs.InlineObjectProperties{EmbeddedObject: &docs.EmbeddedObject{}}},
+			},
+		},
+		Comments: []*googledoc.Comment{
+			{Author: "Ann", Content: "Is this right?", Quote: "Intro", StartIndex: 1, EndIndex: 6},
+			{Author: "Bob", Content: "Fixed", Resolved: true},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	issues, err := Run(testArchive(), nil)
+	require.NoError(t, err)
+
+	var checks []string
+	for _, issue := range issues {
+		checks = append(checks, issue.Check)
+	}
+	assert.Equal(t, []string{"image-alt", "heading-levels", "empty-heading", "missing-footnote", "todo", "comment"}, checks)
+
+	assert.Equal(t, &Issue{
+		Check:    "heading-levels",
+		Severity: Warning,
+		Message:  "heading level 3 follows heading level 1",
+		Segment:  "body",
+		Index:    24,
+		Context:  "Details",
+	}, issues[1])
+	assert.Equal(t, Error, issues[3].Severity)
+	assert.Equal(t, `error: [todo] leftover TODO (body, index 7): "Some TODO text"`, issues[4].String())
+}
+
+func TestConfig(t *testing.T) {
+	issues, err := Run(testArchive(), &Config{Only: []string{"todo", "comment"}, Disable: []string{"comment"}})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "todo", issues[0].Check)
+
+	_, err = Run(testArchive(), &Config{Disable: []string{"nonsense"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// processChart writes a chart linked from a google sheet, either as a table
+// of its data or as an image that links to the spreadsheet
+func (dc *markdownConverter) processChart(out *bytes.Buffer, id string, emb *docs.EmbeddedObject) {
+	source := googledoc.ChartURL(emb.LinkedContentReference)
+	if chart, ok := dc.chartTables[id]; ok && len(chart.Rows) > 0 {
+		// tables must be separated from the surrounding text by blank lines
+		fmt.Fprint(out, "\n\n")
+		writeTable(out, chart.Rows)
+		fmt.Fprintf(out, "\n[%s](%s)\n\n", googledoc.ChartTitle(chart.Title), chart.URL)
+		return
+	}
+
+	image, ok := dc.imageURLByObjectID[id]
+	switch {
+	case !ok && source == "":
+		log.Println("warning: ignoring linked content with no image", id)
+	case !ok:
+		log.Println("warning: no image for linked chart, writing a link instead", id)
+		fmt.Fprintf(out, "[%s](%s)", googledoc.ChartTitle(emb.Title), source)
+	case source == "":
+		fmt.Fprintf(out, "![%s](%s)", emb.Title, image)
+	default:
+		fmt.Fprintf(out, "[![%s](%s)](%s)", emb.Title, image, source)
+	}
+}
+
+// writeTable writes rows of cell values as a markdown table with the first
+// row as the header
+func writeTable(out *bytes.Buffer, rows [][]string) {
+	var columns int
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	for i, row := range rows {
+		fmt.Fprint(out, "|")
+		for j := 0; j < columns; j++ {
+			var cell string
+			if j < len(row) {
+				cell = strings.ReplaceAll(row[j], "|", "\\|")
+			}
+			fmt.Fprintf(out, " %s |", cell)
+		}
+		fmt.Fprint(out, "\n")
+		if i == 0 {
+			fmt.Fprintf(out, "|%s\n", strings.Repeat(" --- |", columns))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// writeComments writes the comments whose anchors end within a paragraph element
+func (dc *markdownConverter) writeComments(out *bytes.Buffer, el *docs.ParagraphElement) {
+	if dc.inFootnote || len(dc.comments) == 0 {
+		return
+	}
+
+	comments := googledoc.CommentsEndingIn(dc.comments, el.StartIndex, el.EndIndex)
+	if len(comments) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, c := range comments {
+		dc.writeCommentRef(&buf, c)
+	}
+
+	// the comments belong before the newline that ends the paragraph
+	if n := out.Len(); n > 0 && out.Bytes()[n-1] == '\n' {
+		out.Truncate(n - 1)
+		buf.WriteByte('\n')
+	}
+	buf.WriteTo(out)
+}
+
+// writeUnanchoredComments writes comments that apply to the document as a whole
+func (dc *markdownConverter) writeUnanchoredComments(out *bytes.Buffer) {
+	comments := googledoc.UnanchoredComments(dc.comments)
+	for _, c := range comments {
+		dc.writeCommentRef(out, c)
+		fmt.Fprint(out, "\n\n")
+	}
+}
+
+// writeCommentRef writes a comment in criticmarkup, or a reference to a comment in footnote style
+func (dc *markdownConverter) writeCommentRef(out *bytes.Buffer, c *googledoc.Comment) {
+	switch dc.commentStyle {
+	case CriticMarkupComments:
+		// criticmarkup comments cannot span multiple lines
+		var parts []string
+		for _, m := range commentMessages(c) {
+			parts = append(parts, m.author+": "+strings.Join(strings.Fields(m.content), " "))
+		}
+		fmt.Fprintf(out, "{>>%s<<}", strings.Join(parts, " — "))
+
+	case FootnoteComments:
+		dc.commentRefs = append(dc.commentRefs, c)
+		n := len(dc.commentRefs)
+		fmt.Fprintf(out, `<sup id="comment-ref-%d"><a href="#comment-%d">[c%d]</a></sup>`, n, n, n)
+	}
+}
+
+// writeCommentFootnotes writes the list of comments referenced in footnote style
+func (dc *markdownConverter) writeCommentFootnotes(out *bytes.Buffer) {
+	if len(dc.commentRefs) == 0 {
+		return
+	}
+
+	fmt.Fprintln(out, `<ol class="comments">`)
+	for i, c := range dc.commentRefs {
+		fmt.Fprintf(out, `<li id="comment-%d">`, i+1)
+		for j, m := range commentMessages(c) {
+			if j > 0 {
+				fmt.Fprint(out, "<br>")
+			}
+			content := strings.ReplaceAll(html.EscapeString(m.content), "\n", "<br>")
+			fmt.Fprintf(out, "<strong>%s:</strong> %s", html.EscapeString(m.author), content)
+		}
+		fmt.Fprintf(out, ` <a href="#comment-ref-%d">↩</a></li>`+"\n", i+1)
+	}
+	fmt.Fprintln(out, `</ol>`)
+}
+
+// commentMessage is a comment or a reply
+type commentMessage struct {
+	author  string
+	content string
+}
+
+// commentMessages gets a comment followed by its replies
+func commentMessages(c *googledoc.Comment) []commentMessage {
+	msgs := []commentMessage{{author: c.Author, content: c.Content}}
+	for _, r := range c.Replies {
+		msgs = append(msgs, commentMessage{author: r.Author, content: r.Content})
+	}
+	return msgs
+}
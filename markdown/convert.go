@@ -12,6 +12,61 @@ import (
 	"google.golang.org/api/docs/v1"
 )
 
+// Comment styles for rendering google doc comments in markdown
+const (
+	StripComments        = ""             // drop comments entirely
+	CriticMarkupComments = "criticmarkup" // render comments as {>> ... <<}
+	FootnoteComments     = "footnotes"    // render comments as HTML footnotes
+)
+
+// Suggestion styles for marking up suggested edits in markdown
+const (
+	NoSuggestionMarkup      = ""             // render suggestions as ordinary text
+	CriticMarkupSuggestions = "criticmarkup" // render suggestions as {++ ++} and {-- --}
+	HTMLSuggestions         = "html"         // render suggestions as <ins> and <del>
+)
+
+// Checklist styles for rendering google doc checklists in markdown
+const (
+	TaskListChecklists = ""     // render checklists as github-flavored task lists
+	HTMLChecklists     = "html" // render checklists as disabled HTML checkboxes
+)
+
+// Spoiler styles for rendering spoiler blocks in markdown
+const (
+	LessWrongSpoilers = ""     // render spoilers as paragraphs beginning >!
+	HTMLSpoilers      = "html" // render spoilers as <details> elements
+)
+
+// Options controls the conversion of google docs to markdown
+type Options struct {
+	ImageURLByObjectID map[string]string    // URLs for images, keyed by inline object ID
+	Comments           []*googledoc.Comment // comments on the document
+	CommentStyle       string               // how to render comments
+	SuggestionStyle    string               // how to mark up suggested insertions and deletions
+	ChecklistStyle     string               // how to render checklist items
+	SpoilerStyle       string               // how to render spoiler blocks
+	FootnoteStyle      string               // how to render footnotes
+
+	// ChartTables holds data to render as tables in place of linked
+	// charts, keyed by inline object ID. Other charts are rendered as
+	// images that link to their spreadsheet.
+	ChartTables map[string]*googledoc.Chart
+
+	// StyleRules assigns roles such as callouts and highlights to
+	// paragraphs and text according to their style. It may be nil.
+	StyleRules *googledoc.StyleRules
+
+	// HeadingURLs gives the URL for links to each heading, keyed by
+	// heading ID. If nil, links to headings point to anchors within the
+	// converted document.
+	HeadingURLs map[string]string
+
+	// PageBreak is written in place of each page break, separated from the
+	// surrounding text by blank lines. Page breaks are dropped if it is empty.
+	PageBreak string
+}
+
 // FromGoogleDoc converts a google doc to markdown
 func FromGoogleDoc(doc *docs.Document, imageURLByObjectID map[string]string) (string, error) {
 	return FromGoogleDocSegment(doc, doc.Body.Content, imageURLByObjectID)
@@ -19,46 +74,81 @@ func FromGoogleDoc(doc *docs.Document, imageURLByObjectID map[string]string) (st
 
 // FromGoogleDocSegment converts a part of a google doc to markdown
 func FromGoogleDocSegment(doc *docs.Document, elements []*docs.StructuralElement, imageURLByObjectID map[string]string) (string, error) {
+	return Convert(doc, elements, &Options{ImageURLByObjectID: imageURLByObjectID})
+}
+
+// Convert converts a part of a google doc to markdown
+func Convert(doc *docs.Document, elements []*docs.StructuralElement, opts *Options) (string, error) {
+	switch opts.CommentStyle {
+	case StripComments, CriticMarkupComments, FootnoteComments:
+	default:
+		return "", fmt.Errorf("invalid comment style %q", opts.CommentStyle)
+	}
+	switch opts.SuggestionStyle {
+	case NoSuggestionMarkup, CriticMarkupSuggestions, HTMLSuggestions:
+	default:
+		return "", fmt.Errorf("invalid suggestion style %q", opts.SuggestionStyle)
+	}
+	switch opts.ChecklistStyle {
+	case TaskListChecklists, HTMLChecklists:
+	default:
+		return "", fmt.Errorf("invalid checklist style %q", opts.ChecklistStyle)
+	}
+	switch opts.FootnoteStyle {
+	case MarkdownFootnotes, SidenoteFootnotes, EndnoteFootnotes:
+	default:
+		return "", fmt.Errorf("invalid footnote style %q", opts.FootnoteStyle)
+	}
+	switch opts.SpoilerStyle {
+	case LessWrongSpoilers, HTMLSpoilers:
+	default:
+		return "", fmt.Errorf("invalid spoiler style %q", opts.SpoilerStyle)
+	}
+
 	// convert the document to markdown
 	conv := markdownConverter{
 		replace:            make(map[string]string),
 		doc:                doc,
-		imageURLByObjectID: imageURLByObjectID,
+		imageURLByObjectID: opts.ImageURLByObjectID,
+		commentStyle:       opts.CommentStyle,
+		suggestionStyle:    opts.SuggestionStyle,
+		checklistStyle:     opts.ChecklistStyle,
+		spoilerStyle:       opts.SpoilerStyle,
+		footnoteStyle:      opts.FootnoteStyle,
+		chartTables:        opts.ChartTables,
+		rules:              opts.StyleRules,
+		headingURLs:        opts.HeadingURLs,
+		pageBreak:          opts.PageBreak,
+	}
+	if conv.headingURLs == nil {
+		conv.headingURLs = make(map[string]string)
+		for id, anchor := range HeadingAnchors(doc.Body.Content) {
+			conv.headingURLs[id] = "#" + anchor
+		}
+	}
+	if opts.CommentStyle != StripComments {
+		conv.comments = opts.Comments
 	}
 
-	// process the main body content
+	// comments that are not attached to any text go at the top
 	var markdown bytes.Buffer
+	conv.writeUnanchoredComments(&markdown)
+
+	// process the main body content
 	err := conv.process(&markdown, elements)
 	if err != nil {
 		return "", fmt.Errorf("error converting document body to markdown: %w", err)
 	}
 
-	// process the footnotes
-	for _, footnoteID := range conv.footnotes {
-		footnote, ok := doc.Footnotes[footnoteID]
-		if !ok {
-			fmt.Printf("warning: no content found for footnote %q referenced in document", footnoteID)
-			continue
-		}
-
-		var footnoteMarkdown bytes.Buffer
-		err = conv.process(&footnoteMarkdown, footnote.Content)
-		if err != nil {
-			return "", fmt.Errorf("error converting footnote %s content to markdown: %w", footnote.FootnoteId, err)
-		}
-
-		fmt.Fprintf(&markdown, "[^%s]: ", footnote.FootnoteId)
-		for i, line := range strings.Split(footnoteMarkdown.String(), "\n") {
-			if i > 0 {
-				fmt.Fprint(&markdown, "    ") // multi-line footnotes in markdown must be indented
-			}
-
-			fmt.Fprintln(&markdown, line)
-		}
-
-		fmt.Fprint(&markdown, "\n") // make sure there is an empty line between each footnote
+	// process the footnotes, in the order they were first referenced
+	err = conv.writeFootnotes(&markdown)
+	if err != nil {
+		return "", err
 	}
 
+	// write the list of comments referenced from the text
+	conv.writeCommentFootnotes(&markdown)
+
 	var out strings.Builder
 	out.Grow(markdown.Len())
 
@@ -87,9 +177,7 @@ func FromGoogleDocSegment(doc *docs.Document, elements []*docs.StructuralElement
 		emptylines = 0
 
 		// apply string-to-string replacements (used to rewrite \T1 to \Tone due to latex rules)
-		for from, to := range conv.replace {
-			line = strings.ReplaceAll(line, from, to)
-		}
+		line = googledoc.RenameLatexSymbols(line, conv.replace)
 
 		out.WriteString(line + "\n")
 	}
@@ -101,9 +189,32 @@ type markdownConverter struct {
 	doc                *docs.Document
 	imageURLByObjectID map[string]string
 	codeBlock          bytes.Buffer // text identified as lines of code
-	footnotes          []string     // footnote IDs processed by this converter
+	footnotes          []string     // footnote IDs processed by this converter, in order of first reference
+	footnoteStyle      string
 	latexDefs          bytes.Buffer
 	replace            map[string]string // string replacements to apply to whole doc
+	comments           []*googledoc.Comment
+	commentStyle       string
+	suggestionStyle    string
+	checklistStyle     string
+	spoilerStyle       string
+	chartTables        map[string]*googledoc.Chart
+	rules              *googledoc.StyleRules
+	headingURLs        map[string]string
+	pageBreak          string
+	commentRefs        []*googledoc.Comment // comments referenced so far, for footnote-style comments
+	blockState
+}
+
+// blockState is the state of the converter within a sequence of elements,
+// which is set aside while converting footnotes inline
+type blockState struct {
+	codeIndent string                 // indentation for the current code block, when it is part of a list item
+	listIndent string                 // indentation for content within the current list item
+	hidden     googledoc.HiddenBlocks // spoilers and collapsible sections opened by markers
+	inSpoiler  bool                   // whether a spoiler block is open in the output
+	inFootnote bool
+	checked    bool // whether the current paragraph is a completed checklist item
 }
 
 func (dc *markdownConverter) process(out *bytes.Buffer, content []*docs.StructuralElement) error {
@@ -112,10 +223,20 @@ func (dc *markdownConverter) process(out *bytes.Buffer, content []*docs.Structur
 		// for any element other than paragraph, write the closing markdown for any open code block
 		if elem.Paragraph == nil {
 			dc.flushCodeBlock(out)
+			dc.listIndent = ""
+			if !dc.hidden.InSpoiler() {
+				dc.setSpoiler(out, false)
+			}
 		}
 
 		switch {
 		case elem.Table != nil:
+			// tables with a single cell of monospace text are code blocks
+			if code, ok := dc.rules.TableCode(elem.Table); ok {
+				dc.codeBlock.WriteString(code)
+				dc.flushCodeBlock(out)
+				continue
+			}
 			err := dc.processTable(out, elem.Table)
 			if err != nil {
 				return err
@@ -134,8 +255,9 @@ func (dc *markdownConverter) process(out *bytes.Buffer, content []*docs.Structur
 		}
 	}
 
-	// flush any remaining code block
+	// flush any remaining code block and close any hidden blocks
 	dc.flushCodeBlock(out)
+	dc.closeHiddenBlocks(out)
 
 	return nil
 }
@@ -147,47 +269,87 @@ func (dc *markdownConverter) flushCodeBlock(out *bytes.Buffer) {
 		return
 	}
 
-	fmt.Fprintln(out, "```")
-	dc.codeBlock.WriteTo(out)
-	fmt.Fprintln(out, "```")
+	lang, code := googledoc.CodeLanguage(strings.ReplaceAll(dc.codeBlock.String(), "\v", "\n"))
+	fmt.Fprintf(out, "%s```%s\n", dc.codeIndent, lang)
+	for _, line := range strings.Split(strings.TrimRight(code, "\n"), "\n") {
+		if line != "" {
+			fmt.Fprint(out, dc.codeIndent)
+		}
+		fmt.Fprintln(out, line)
+	}
+	fmt.Fprintf(out, "%s```\n", dc.codeIndent)
 	fmt.Fprintln(out)
 	dc.codeBlock.Reset()
+	dc.codeIndent = ""
+}
+
+// writeCheckbox writes the marker for a checklist item
+func (dc *markdownConverter) writeCheckbox(out *bytes.Buffer, checked bool) {
+	switch {
+	case dc.checklistStyle == HTMLChecklists && checked:
+		fmt.Fprintf(out, `* <input type="checkbox" disabled checked> `)
+	case dc.checklistStyle == HTMLChecklists:
+		fmt.Fprintf(out, `* <input type="checkbox" disabled> `)
+	case checked:
+		fmt.Fprintf(out, "- [x] ")
+	default:
+		fmt.Fprintf(out, "- [ ] ")
+	}
+}
+
+// markdown written before paragraphs with each role
+var blockPrefixes = map[string]string{
+	googledoc.QuoteRole:    "> ",
+	googledoc.EpigraphRole: "> ",
+	googledoc.CalloutRole:  "> [!NOTE]\n> ",
 }
 
 func (dc *markdownConverter) processParagraph(out *bytes.Buffer, p *docs.Paragraph) error {
-	// deal with code blocks
-	isCode := p.ParagraphStyle.NamedStyleType == "NORMAL_TEXT" && p.Bullet == nil
-	if isCode {
-		for _, el := range p.Elements {
-			if el.TextRun == nil {
-				isCode = false
-				break
-			}
-			if !googledoc.IsMonospace(el.TextRun.TextStyle.WeightedFontFamily) {
-				isCode = false
-				break
-			}
-		}
+	// markers for spoilers and collapsible sections are not written out
+	if marker := googledoc.ParseBlockMarker(p); marker != nil {
+		dc.processBlockMarker(out, marker)
+		return nil
 	}
 
-	if isCode {
+	// spoilers are either marked explicitly or assigned by style rules
+	role := dc.rules.BlockRole(p)
+	dc.setSpoiler(out, dc.hidden.InSpoiler() || role == googledoc.SpoilerRole)
+
+	// deal with code blocks
+	if role == googledoc.CodeRole || (role == "" && p.Bullet == nil && dc.rules.IsCode(p)) {
+		// code that is indented after a list item belongs to that item
+		if dc.codeBlock.Len() == 0 && googledoc.IsIndented(p) {
+			dc.codeIndent = dc.listIndent
+		}
 		for _, el := range p.Elements {
-			fmt.Fprint(&dc.codeBlock, el.TextRun.Content)
+			if el.TextRun != nil {
+				fmt.Fprint(&dc.codeBlock, el.TextRun.Content)
+			}
 		}
 		return nil
 	}
 
 	// if not a code block then flush any buffered code block
 	dc.flushCodeBlock(out)
+	if p.Bullet == nil {
+		dc.listIndent = ""
+	}
 
-	// print the blockquote prefix
-	if p.ParagraphStyle.IndentStart != nil && p.ParagraphStyle.IndentStart.Magnitude > 0 && p.Bullet == nil {
+	// print the blockquote prefix, or the prefix for the paragraph's role
+	namedStyle := p.ParagraphStyle.NamedStyleType
+	if dc.inSpoiler && dc.spoilerStyle == LessWrongSpoilers {
+		fmt.Fprint(out, ">! ")
+	}
+	if role != "" {
+		fmt.Fprint(out, blockPrefixes[role])
+		namedStyle = "NORMAL_TEXT"
+	} else if googledoc.IsIndented(p) && p.Bullet == nil {
 		fmt.Fprintf(out, "> ")
 	}
 
 	// print the heading prefix
 	var isHeading bool
-	switch p.ParagraphStyle.NamedStyleType {
+	switch namedStyle {
 	case "TITLE":
 		isHeading = true
 		fmt.Fprintf(out, "# ")
@@ -216,18 +378,22 @@ func (dc *markdownConverter) processParagraph(out *bytes.Buffer, p *docs.Paragra
 		if isHeading {
 			fmt.Println("found a heading that is part of a bulletted list, ignoring the bullet")
 		} else {
-			list := dc.doc.Lists[p.Bullet.ListId]
-			level := list.ListProperties.NestingLevels[p.Bullet.NestingLevel]
+			indent := strings.Repeat("  ", int(p.Bullet.NestingLevel))
+			fmt.Fprint(out, indent)
 
-			var i int64
-			for i = 0; i < p.Bullet.NestingLevel; i++ {
-				fmt.Fprintf(out, "  ")
-			}
+			// content within a list item lines up with the text after the marker
+			dc.listIndent = indent + "  "
 
-			// if there is no fixed glyph symbol then this is an ordered list
-			if level.GlyphSymbol == "" {
+			switch googledoc.ListKind(dc.doc, p.Bullet) {
+			case googledoc.NumberedList:
+				dc.listIndent = indent + "   "
 				fmt.Fprintf(out, "1. ")
-			} else {
+			case googledoc.Checklist:
+				// the checkbox marks the item as completed, so drop the strikethrough
+				dc.checked = googledoc.IsChecked(p)
+				defer func() { dc.checked = false }()
+				dc.writeCheckbox(out, dc.checked)
+			default:
 				fmt.Fprintf(out, "* ")
 			}
 		}
@@ -242,9 +408,10 @@ func (dc *markdownConverter) processParagraph(out *bytes.Buffer, p *docs.Paragra
 			// TODO: implement
 			log.Println("warning: ignoring equation")
 		case el.FootnoteReference != nil:
-			fmt.Fprintf(out, "[^%s]", el.FootnoteReference.FootnoteId)
-			// add this footnote ID if it is not already in the list
-			dc.addFootnoteID(el.FootnoteReference.FootnoteId)
+			err := dc.writeFootnoteRef(out, el.FootnoteReference.FootnoteId)
+			if err != nil {
+				return err
+			}
 		case el.AutoText != nil:
 			log.Println("warning: ignoring auto text")
 		case el.HorizontalRule != nil:
@@ -255,7 +422,9 @@ func (dc *markdownConverter) processParagraph(out *bytes.Buffer, p *docs.Paragra
 				return err
 			}
 		case el.PageBreak != nil:
-			log.Println("warning: ignoring page break")
+			if dc.pageBreak != "" {
+				fmt.Fprintf(out, "\n\n%s\n\n", dc.pageBreak)
+			}
 		case el.TextRun != nil:
 			err := dc.processTextRun(out, el.TextRun)
 			if err != nil {
@@ -264,6 +433,8 @@ func (dc *markdownConverter) processParagraph(out *bytes.Buffer, p *docs.Paragra
 		default:
 			log.Println("warning: encountered a paragraph element of unknown type")
 		}
+
+		dc.writeComments(out, el)
 	}
 
 	// write two newlines at the end of each paragraph
@@ -271,18 +442,17 @@ func (dc *markdownConverter) processParagraph(out *bytes.Buffer, p *docs.Paragra
 	return nil
 }
 
-// add a footnote ID if it is not already in the list (so that we know the order in which footnotes appeared in the text)
-func (dc *markdownConverter) addFootnoteID(id string) {
-	var found bool
-	for _, f := range dc.footnotes {
+// addFootnoteID adds a footnote ID if it is not already in the list, so
+// that we know the order in which footnotes appeared in the text, and
+// returns the number of the footnote
+func (dc *markdownConverter) addFootnoteID(id string) int {
+	for i, f := range dc.footnotes {
 		if f == id {
-			found = true
-			break
+			return i + 1
 		}
 	}
-	if !found {
-		dc.footnotes = append(dc.footnotes, id)
-	}
+	dc.footnotes = append(dc.footnotes, id)
+	return len(dc.footnotes)
 }
 
 func (dc *markdownConverter) processInlineObject(out *bytes.Buffer, objRef *docs.InlineObjectElement) error {
@@ -295,10 +465,10 @@ func (dc *markdownConverter) processInlineObject(out *bytes.Buffer, objRef *docs
 
 	emb := obj.InlineObjectProperties.EmbeddedObject
 	switch {
+	case emb.LinkedContentReference != nil:
+		dc.processChart(out, id, emb)
 	case emb.ImageProperties != nil || emb.EmbeddedDrawingProperties != nil:
 		fmt.Fprintf(out, "![%s](%s)", emb.Title, dc.imageURLByObjectID[id])
-	case emb.LinkedContentReference != nil:
-		log.Println("warning: ignoring linked spreadsheet / chart")
 	}
 
 	return nil
@@ -314,21 +484,33 @@ func (dc *markdownConverter) processTextRun(out *bytes.Buffer, t *docs.TextRun)
 	if t.TextStyle.Bold {
 		surround = "**"
 	}
-	if t.TextStyle.Strikethrough {
+	if t.TextStyle.Strikethrough && !dc.checked {
 		surround = "-"
 	}
-	if googledoc.IsMonospace(t.TextStyle.WeightedFontFamily) {
+	if dc.rules.IsMonospace(t.TextStyle.WeightedFontFamily) {
 		surround = "`"
 	}
+	before, after := surround, surround
+
+	// style rules take precedence over the formatting above
+	role := dc.rules.InlineRole(t)
+	switch role {
+	case googledoc.HighlightRole:
+		before, after = "==", "=="
+	case googledoc.SpoilerRole:
+		before, after = ">!", "!<"
+	case googledoc.CodeRole:
+		before, after = "`", "`"
+	}
 
 	// the following features are not supported at all in markdown
 	if t.TextStyle.SmallCaps {
 		log.Printf("warning: ignoring smallcaps on %q", t.Content)
 	}
-	if t.TextStyle.BackgroundColor != nil {
+	if t.TextStyle.BackgroundColor != nil && role == "" {
 		log.Printf("warning: ignoring background color on %q", t.Content)
 	}
-	if t.TextStyle.ForegroundColor != nil && t.TextStyle.Link == nil {
+	if t.TextStyle.ForegroundColor != nil && t.TextStyle.Link == nil && role == "" {
 		log.Printf("warning: ignoring foreground color on %q", t.Content)
 	}
 	if t.TextStyle.Underline && t.TextStyle.Link == nil {
@@ -368,6 +550,13 @@ outer:
 			continue outer
 		}
 
+		// mark up suggested insertions and deletions
+		open, close := dc.suggestionMarkers(t)
+		if strings.TrimSpace(line) == "" {
+			open, close = "", ""
+		}
+		fmt.Fprint(out, open)
+
 		// write the beginning of a link in form [...TEXT...](...URL...)
 		if link != nil {
 			fmt.Fprint(out, "[")
@@ -379,18 +568,20 @@ outer:
 
 		fmt.Fprint(out, leadingSpace)
 		if len(middle) > 0 {
-			fmt.Fprint(out, surround)
+			fmt.Fprint(out, before)
 			if err := dc.processLine(out, middle); err != nil {
 				return err
 			}
-			fmt.Fprint(out, surround)
+			fmt.Fprint(out, after)
 		}
 		fmt.Fprint(out, trailingSpace)
 
 		if link != nil {
-			fmt.Fprintf(out, "](%s)", link.Url)
+			fmt.Fprintf(out, "](%s)", dc.linkURL(link))
 		}
 
+		fmt.Fprint(out, close)
+
 		if i+1 < len(lines) {
 			fmt.Fprintf(out, "\n")
 		}
@@ -399,6 +590,22 @@ outer:
 	return nil
 }
 
+// suggestionMarkers gets the markup that goes before and after a suggested insertion or deletion
+func (dc *markdownConverter) suggestionMarkers(t *docs.TextRun) (open, close string) {
+	deleted, inserted := len(t.SuggestedDeletionIds) > 0, len(t.SuggestedInsertionIds) > 0
+	switch {
+	case dc.suggestionStyle == CriticMarkupSuggestions && deleted:
+		return "{--", "--}"
+	case dc.suggestionStyle == CriticMarkupSuggestions && inserted:
+		return "{++", "++}"
+	case dc.suggestionStyle == HTMLSuggestions && deleted:
+		return "<del>", "</del>"
+	case dc.suggestionStyle == HTMLSuggestions && inserted:
+		return "<ins>", "</ins>"
+	}
+	return "", ""
+}
+
 // processLine adds dollar signs around latex identifiers
 func (dc *markdownConverter) processLine(out *bytes.Buffer, line string) error {
 	var inLatex bool
@@ -482,8 +689,10 @@ func (dc *markdownConverter) processParagraphInTableCell(out *bytes.Buffer, p *d
 		case el.Equation != nil:
 			log.Println("warning: ignoring equation")
 		case el.FootnoteReference != nil:
-			fmt.Fprintf(out, "[^%s]", el.FootnoteReference.FootnoteId)
-			dc.addFootnoteID(el.FootnoteReference.FootnoteId)
+			err := dc.writeFootnoteRef(out, el.FootnoteReference.FootnoteId)
+			if err != nil {
+				return err
+			}
 		case el.AutoText != nil:
 			log.Println("warning: ignoring auto text")
 		case el.HorizontalRule != nil:
@@ -500,6 +709,8 @@ func (dc *markdownConverter) processParagraphInTableCell(out *bytes.Buffer, p *d
 		default:
 			log.Println("warning: encountered a paragraph element of unknown type")
 		}
+
+		dc.writeComments(out, el)
 	}
 
 	return nil
@@ -518,7 +729,7 @@ func (dc *markdownConverter) processTextRunInTableCell(out *bytes.Buffer, t *doc
 	if t.TextStyle.Strikethrough {
 		log.Println("warning: ignoring strikethrough in table cell")
 	}
-	if googledoc.IsMonospace(t.TextStyle.WeightedFontFamily) {
+	if dc.rules.IsMonospace(t.TextStyle.WeightedFontFamily) {
 		log.Println("warning: ignoring monospace in table cell")
 	}
 
@@ -554,11 +765,17 @@ func (dc *markdownConverter) processTextRunInTableCell(out *bytes.Buffer, t *doc
 		content = strings.ReplaceAll(content, "\n", " ")
 	}
 
+	// mark up suggested insertions and deletions
+	open, close := dc.suggestionMarkers(t)
+	if strings.TrimSpace(content) == "" {
+		open, close = "", ""
+	}
+
 	// write the beginning of a link in form [...TEXT...](...URL...)
 	if t.TextStyle.Link == nil {
-		fmt.Fprint(out, content)
+		fmt.Fprint(out, open+content+close)
 	} else {
-		fmt.Fprintf(out, "[%s](%s)", content, t.TextStyle.Link.Url)
+		fmt.Fprintf(out, "%s[%s](%s)%s", open, content, dc.linkURL(t.TextStyle.Link), close)
 	}
 
 	return nil
@@ -0,0 +1,120 @@
+package markdown
+
+// This file contains a diff algorithm for sequences of tokens
+
+// editOp is the type of an operation in an edit script
+type editOp int
+
+const (
+	opEqual editOp = iota
+	opDelete
+	opInsert
+)
+
+// edit is one step in an edit script that transforms sequence a into sequence b
+type edit struct {
+	Op editOp
+	A  int // index into a, for opEqual and opDelete
+	B  int // index into b, for opEqual and opInsert
+}
+
+// diffTokens computes a shortest edit script from a to b using Myers' algorithm
+func diffTokens(a, b []int) []edit {
+	// trim the common prefix and suffix, which is usually most of a document
+	var prefix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	var suffix int
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []edit
+	for i := 0; i < prefix; i++ {
+		out = append(out, edit{Op: opEqual, A: i, B: i})
+	}
+	for _, e := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		e.A += prefix
+		e.B += prefix
+		out = append(out, e)
+	}
+	for i := suffix; i > 0; i-- {
+		out = append(out, edit{Op: opEqual, A: len(a) - i, B: len(b) - i})
+	}
+	return out
+}
+
+// myers implements the greedy O(ND) diff algorithm
+func myers(a, b []int) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[k+max] holds the furthest x reached on diagonal k, and trace holds a copy of v for each d
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	var d int
+outer:
+	for d = 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+max] < v[k+1+max]) {
+				x = v[k+1+max] // move down, which is an insertion
+			} else {
+				x = v[k-1+max] + 1 // move right, which is a deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+max] = x
+			if x >= n && y >= m {
+				break outer
+			}
+		}
+	}
+
+	// walk backwards through the trace to recover the edit script
+	var rev []edit
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+max] < v[k+1+max]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+max]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, edit{Op: opEqual, A: x, B: y})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, edit{Op: opInsert, B: y})
+		} else {
+			x--
+			rev = append(rev, edit{Op: opDelete, A: x})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, edit{Op: opEqual, A: x, B: y})
+	}
+
+	out := make([]edit, len(rev))
+	for i := range rev {
+		out[i] = rev[len(rev)-1-i]
+	}
+	return out
+}
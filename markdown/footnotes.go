@@ -0,0 +1,116 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Footnote styles for rendering google doc footnotes in markdown
+const (
+	MarkdownFootnotes = ""          // render footnotes as [^1] with definitions at the end
+	SidenoteFootnotes = "sidenotes" // render footnotes as HTML sidenotes next to the text
+	EndnoteFootnotes  = "endnotes"  // render footnotes as HTML links to a section of notes at the end
+)
+
+// writeFootnoteRef writes a reference to a footnote, which is numbered in
+// order of first reference rather than labelled with its google doc ID
+func (dc *markdownConverter) writeFootnoteRef(out *bytes.Buffer, id string) error {
+	n := dc.addFootnoteID(id)
+	switch dc.footnoteStyle {
+	case SidenoteFootnotes:
+		text, err := dc.inlineFootnote(id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, `<label for="sn-%d" class="margin-toggle sidenote-number"></label>`, n)
+		fmt.Fprintf(out, `<input type="checkbox" id="sn-%d" class="margin-toggle"/>`, n)
+		fmt.Fprintf(out, `<span class="sidenote">%s</span>`, text)
+	case EndnoteFootnotes:
+		fmt.Fprintf(out, `<sup id="fnref-%d"><a href="#fn-%d">%d</a></sup>`, n, n, n)
+	default:
+		fmt.Fprintf(out, "[^%d]", n)
+	}
+	return nil
+}
+
+// convertFootnote converts the content of a footnote, setting aside the
+// state of the surrounding text so that footnotes can be converted inline
+func (dc *markdownConverter) convertFootnote(id string) (string, bool, error) {
+	footnote, ok := dc.doc.Footnotes[id]
+	if !ok {
+		log.Printf("warning: no content found for footnote %q referenced in document", id)
+		return "", false, nil
+	}
+
+	// indices inside footnotes do not correspond to comment anchors
+	saved := dc.blockState
+	dc.blockState = blockState{inFootnote: true}
+	defer func() { dc.blockState = saved }()
+
+	var buf bytes.Buffer
+	err := dc.process(&buf, footnote.Content)
+	if err != nil {
+		return "", false, fmt.Errorf("error converting footnote %s content to markdown: %w", id, err)
+	}
+	return strings.TrimSpace(buf.String()), true, nil
+}
+
+// inlineFootnote converts a footnote to text that can appear within a
+// paragraph, with its paragraphs separated by line breaks
+func (dc *markdownConverter) inlineFootnote(id string) (string, error) {
+	text, _, err := dc.convertFootnote(id)
+	if err != nil {
+		return "", err
+	}
+	var paragraphs []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, strings.ReplaceAll(p, "\n", " "))
+		}
+	}
+	return strings.Join(paragraphs, "<br>"), nil
+}
+
+// writeFootnotes writes the content of the footnotes at the end of the
+// document. Footnotes referenced from other footnotes are included, since
+// the list grows as footnotes are converted.
+func (dc *markdownConverter) writeFootnotes(out *bytes.Buffer) error {
+	if dc.footnoteStyle == SidenoteFootnotes || len(dc.footnotes) == 0 {
+		return nil
+	}
+
+	if dc.footnoteStyle == EndnoteFootnotes {
+		fmt.Fprint(out, "<section class=\"endnotes\">\n<ol>\n")
+	}
+	for i := 0; i < len(dc.footnotes); i++ {
+		text, ok, err := dc.convertFootnote(dc.footnotes[i])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		n := i + 1
+		if dc.footnoteStyle == EndnoteFootnotes {
+			// blank lines around the content let it be parsed as markdown
+			fmt.Fprintf(out, "<li id=\"fn-%d\">\n\n%s <a href=\"#fnref-%d\">↩</a>\n\n</li>\n", n, text, n)
+			continue
+		}
+
+		fmt.Fprintf(out, "[^%d]: ", n)
+		for j, line := range strings.Split(text, "\n") {
+			if j > 0 && line != "" {
+				fmt.Fprint(out, "    ") // multi-line footnotes in markdown must be indented
+			}
+			fmt.Fprintln(out, line)
+		}
+		fmt.Fprint(out, "\n") // make sure there is an empty line between each footnote
+	}
+	if dc.footnoteStyle == EndnoteFootnotes {
+		fmt.Fprint(out, "</ol>\n</section>\n\n")
+	}
+	return nil
+}
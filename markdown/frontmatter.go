@@ -0,0 +1,103 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter formats publishing settings as a YAML front matter block for
+// static site generators, or returns an empty string if there are none
+func FrontMatter(s *googledoc.Settings) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+
+	buf, err := yaml.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling front matter: %w", err)
+	}
+	return "---\n" + string(buf) + "---\n\n", nil
+}
+
+// Front matter formats for pages in static sites
+const (
+	YAMLFrontMatter = "yaml" // between --- lines, as used by Jekyll, Hugo, and Eleventy
+	TOMLFrontMatter = "toml" // between +++ lines, as used by Hugo
+)
+
+// Page is the front matter for a page in a static site
+type Page struct {
+	Title   string    `yaml:"title"`
+	Date    time.Time `yaml:"date,omitempty"`
+	Slug    string    `yaml:"slug,omitempty"`
+	Tags    []string  `yaml:"tags,omitempty"`
+	Summary string    `yaml:"summary,omitempty"`
+	Draft   bool      `yaml:"draft,omitempty"`
+}
+
+// PageFrontMatter formats the front matter for a page in a static site as
+// YAML or TOML
+func PageFrontMatter(p *Page, format string) (string, error) {
+	switch format {
+	case YAMLFrontMatter:
+		buf, err := yaml.Marshal(p)
+		if err != nil {
+			return "", fmt.Errorf("error marshalling front matter: %w", err)
+		}
+		return "---\n" + string(buf) + "---\n\n", nil
+
+	case TOMLFrontMatter:
+		var b strings.Builder
+		b.WriteString("+++\n")
+		fmt.Fprintf(&b, "title = %s\n", tomlString(p.Title))
+		if !p.Date.IsZero() {
+			fmt.Fprintf(&b, "date = %s\n", p.Date.Format(time.RFC3339))
+		}
+		if p.Slug != "" {
+			fmt.Fprintf(&b, "slug = %s\n", tomlString(p.Slug))
+		}
+		if len(p.Tags) > 0 {
+			var tags []string
+			for _, tag := range p.Tags {
+				tags = append(tags, tomlString(tag))
+			}
+			fmt.Fprintf(&b, "tags = [%s]\n", strings.Join(tags, ", "))
+		}
+		if p.Summary != "" {
+			fmt.Fprintf(&b, "summary = %s\n", tomlString(p.Summary))
+		}
+		if p.Draft {
+			b.WriteString("draft = true\n")
+		}
+		b.WriteString("+++\n\n")
+		return b.String(), nil
+	}
+	return "", fmt.Errorf("invalid front matter format %q", format)
+}
+
+// tomlString quotes a string as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
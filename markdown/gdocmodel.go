@@ -0,0 +1,422 @@
+package markdown
+
+// This file parses markdown into a simple model of a google doc, which is
+// then used to generate google docs API requests
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"google.golang.org/api/docs/v1"
+)
+
+// the font used for code blocks and inline code, which must be one that
+// googledoc.IsMonospace recognizes so that documents survive a round trip
+const codeFont = "Courier New"
+
+// the indent used for block quotes, in points
+const blockquoteIndent = 36
+
+// gdocBlock is either a paragraph or a table
+type gdocBlock struct {
+	Paragraph *gdocParagraph
+	Table     *gdocTable
+}
+
+// gdocParagraph is a paragraph of text, not including the final newline
+type gdocParagraph struct {
+	NamedStyle string      // HEADING_1, NORMAL_TEXT, etc
+	Indent     float64     // indent in points, used for block quotes
+	Centered   bool        // whether the paragraph is centered
+	Rule       bool        // whether the paragraph stands in for a horizontal rule
+	List       *gdocBullet // nil if the paragraph is not part of a list
+	Pieces     []*gdocPiece
+}
+
+// gdocBullet identifies a paragraph as part of a list
+type gdocBullet struct {
+	List  int // paragraphs in the same list have the same value here
+	Depth int // nesting level, starting at zero
+}
+
+// gdocList holds information about a list as a whole
+type gdocList struct {
+	Ordered  bool
+	Checkbox bool
+}
+
+// gdocPiece is a styled run of text, an image, or a footnote reference
+type gdocPiece struct {
+	Text     string
+	Style    gdocStyle
+	Image    string       // URL of an image
+	Footnote []*gdocBlock // content of a footnote
+	checkbox *bool        // task list checkbox, removed once the list item is complete
+}
+
+// gdocStyle represents the text styles that can be expressed in markdown
+type gdocStyle struct {
+	Bold          bool
+	Italic        bool
+	Strikethrough bool
+	Code          bool
+	Link          string
+}
+
+// gdocTable is a table in which each cell contains a single paragraph
+type gdocTable struct {
+	Rows [][]*gdocParagraph
+}
+
+// gdocModel is the result of parsing a markdown document
+type gdocModel struct {
+	Blocks []*gdocBlock
+	Lists  []*gdocList
+}
+
+// length gets the length of a paragraph in UTF-16 code units, not including
+// the final newline, which is how google docs measures indices
+func (p *gdocParagraph) length() int64 {
+	var n int64
+	for _, piece := range p.Pieces {
+		n += piece.length()
+	}
+	return n
+}
+
+// length gets the length of a piece in UTF-16 code units
+func (p *gdocPiece) length() int64 {
+	if p.Image != "" || p.Footnote != nil {
+		return 1
+	}
+	return utf16Len(p.Text)
+}
+
+// utf16Len gets the length of a string in UTF-16 code units
+func utf16Len(s string) int64 {
+	return int64(len(utf16.Encode([]rune(s))))
+}
+
+// parseMarkdown parses markdown into the google doc model
+func parseMarkdown(source []byte, resolveImage func(string) (string, error)) (*gdocModel, error) {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM, extension.Footnote))
+	root := md.Parser().Parse(text.NewReader(source))
+
+	b := gdocBuilder{
+		source:       source,
+		resolveImage: resolveImage,
+		footnotes:    make(map[int]*east.Footnote),
+	}
+
+	// find the footnote definitions first so that references can be resolved
+	for n := root.FirstChild(); n != nil; n = n.NextSibling() {
+		if list, ok := n.(*east.FootnoteList); ok {
+			for f := list.FirstChild(); f != nil; f = f.NextSibling() {
+				if footnote, ok := f.(*east.Footnote); ok {
+					b.footnotes[footnote.Index] = footnote
+				}
+			}
+		}
+	}
+
+	blocks, err := b.blocks(root, blockContext{})
+	if err != nil {
+		return nil, err
+	}
+	return &gdocModel{Blocks: blocks, Lists: b.lists}, nil
+}
+
+// blockContext holds the state that applies to nested blocks
+type blockContext struct {
+	indent float64     // indent for block quotes
+	list   *gdocBullet // list that nested paragraphs belong to
+}
+
+type gdocBuilder struct {
+	source       []byte
+	resolveImage func(string) (string, error)
+	footnotes    map[int]*east.Footnote
+	lists        []*gdocList
+}
+
+// blocks converts the children of a markdown node to blocks
+func (b *gdocBuilder) blocks(parent ast.Node, ctx blockContext) ([]*gdocBlock, error) {
+	var out []*gdocBlock
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		blocks, err := b.block(n, ctx)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, blocks...)
+	}
+	return out, nil
+}
+
+// block converts a single markdown block to zero or more google doc blocks
+func (b *gdocBuilder) block(n ast.Node, ctx blockContext) ([]*gdocBlock, error) {
+	newParagraph := func(style string) *gdocParagraph {
+		return &gdocParagraph{NamedStyle: style, Indent: ctx.indent, List: ctx.list}
+	}
+
+	switch n := n.(type) {
+	case *ast.Heading:
+		p := newParagraph(fmt.Sprintf("HEADING_%d", n.Level))
+		if err := b.inlines(p, n, gdocStyle{}); err != nil {
+			return nil, err
+		}
+		return []*gdocBlock{{Paragraph: p}}, nil
+
+	case *ast.Paragraph, *ast.TextBlock:
+		p := newParagraph("NORMAL_TEXT")
+		if err := b.inlines(p, n, gdocStyle{}); err != nil {
+			return nil, err
+		}
+		return []*gdocBlock{{Paragraph: p}}, nil
+
+	case *ast.ThematicBreak:
+		// the google docs API has no way to insert a horizontal rule
+		log.Println("warning: rendering horizontal rule as a centered asterism")
+		p := newParagraph("NORMAL_TEXT")
+		p.Centered = true
+		p.Rule = true
+		p.Pieces = []*gdocPiece{{Text: "* * *"}}
+		return []*gdocBlock{{Paragraph: p}}, nil
+
+	case *ast.CodeBlock, *ast.FencedCodeBlock:
+		// each line of code becomes a paragraph in a monospace font
+		var out []*gdocBlock
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			seg := lines.At(i)
+			line := strings.TrimRight(string(seg.Value(b.source)), "\r\n")
+			p := newParagraph("NORMAL_TEXT")
+			p.Pieces = []*gdocPiece{{Text: line, Style: gdocStyle{Code: true}}}
+			out = append(out, &gdocBlock{Paragraph: p})
+		}
+		return out, nil
+
+	case *ast.Blockquote:
+		inner := ctx
+		inner.indent += blockquoteIndent
+		return b.blocks(n, inner)
+
+	case *ast.List:
+		// in google docs, nested lists are part of the same list as their parent
+		listIndex, depth := len(b.lists), 0
+		if ctx.list != nil {
+			listIndex, depth = ctx.list.List, ctx.list.Depth+1
+		} else {
+			b.lists = append(b.lists, &gdocList{Ordered: n.IsOrdered()})
+		}
+		list := b.lists[listIndex]
+
+		var out []*gdocBlock
+		for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+			blocks, err := b.listItem(item, ctx, list, listIndex, depth)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, blocks...)
+		}
+		return out, nil
+
+	case *east.Table:
+		var t gdocTable
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			_, isHeader := row.(*east.TableHeader)
+			var cells []*gdocParagraph
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				p := &gdocParagraph{NamedStyle: "NORMAL_TEXT"}
+				if err := b.inlines(p, cell, gdocStyle{Bold: isHeader}); err != nil {
+					return nil, err
+				}
+				cells = append(cells, p)
+			}
+			t.Rows = append(t.Rows, cells)
+		}
+		return []*gdocBlock{{Table: &t}}, nil
+
+	case *east.FootnoteList:
+		// footnote definitions are handled where they are referenced
+		return nil, nil
+
+	case *ast.HTMLBlock:
+		log.Println("warning: ignoring html block")
+		return nil, nil
+
+	default:
+		log.Printf("warning: ignoring markdown block of type %s", n.Kind())
+		return nil, nil
+	}
+}
+
+// listItem converts a list item. Nested lists are added at the next
+// nesting level, and any further paragraphs are joined to the first
+// paragraph with line breaks since a list item is a single paragraph in
+// google docs.
+func (b *gdocBuilder) listItem(item ast.Node, ctx blockContext, list *gdocList, listIndex, depth int) ([]*gdocBlock, error) {
+	inner := ctx
+	inner.list = &gdocBullet{List: listIndex, Depth: depth}
+
+	var first *gdocParagraph
+	var out []*gdocBlock
+	for n := item.FirstChild(); n != nil; n = n.NextSibling() {
+		blocks, err := b.block(n, inner)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range blocks {
+			p := block.Paragraph
+			switch {
+			case p == nil || p.List != inner.list:
+				// tables and nested lists
+				out = append(out, block)
+			case first == nil:
+				first = p
+				out = append(out, block)
+			case out[len(out)-1].Paragraph == first:
+				first.Pieces = append(first.Pieces, &gdocPiece{Text: "\v"})
+				first.Pieces = append(first.Pieces, p.Pieces...)
+			default:
+				out = append(out, block)
+			}
+		}
+	}
+
+	// checked task list items are rendered with strikethrough, as in google docs
+	if first != nil && len(first.Pieces) > 0 && first.Pieces[0].checkbox != nil {
+		list.Checkbox = true
+		if *first.Pieces[0].checkbox {
+			for _, piece := range first.Pieces {
+				piece.Style.Strikethrough = true
+			}
+		}
+		first.Pieces = first.Pieces[1:]
+	}
+	return out, nil
+}
+
+// inlines converts the inline children of a markdown node and appends them to a paragraph
+func (b *gdocBuilder) inlines(p *gdocParagraph, parent ast.Node, style gdocStyle) error {
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Text:
+			p.appendText(string(n.Segment.Value(b.source)), style)
+			if n.HardLineBreak() {
+				p.appendText("\v", style)
+			} else if n.SoftLineBreak() {
+				p.appendText(" ", style)
+			}
+		case *ast.String:
+			p.appendText(string(n.Value), style)
+		case *ast.Emphasis:
+			inner := style
+			if n.Level >= 2 {
+				inner.Bold = true
+			} else {
+				inner.Italic = true
+			}
+			if err := b.inlines(p, n, inner); err != nil {
+				return err
+			}
+		case *east.Strikethrough:
+			inner := style
+			inner.Strikethrough = true
+			if err := b.inlines(p, n, inner); err != nil {
+				return err
+			}
+		case *ast.CodeSpan:
+			inner := style
+			inner.Code = true
+			var code bytes.Buffer
+			for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+				if t, ok := c.(*ast.Text); ok {
+					code.Write(t.Segment.Value(b.source))
+				}
+			}
+			p.appendText(code.String(), inner)
+		case *ast.Link:
+			inner := style
+			inner.Link = string(n.Destination)
+			if err := b.inlines(p, n, inner); err != nil {
+				return err
+			}
+		case *ast.AutoLink:
+			inner := style
+			inner.Link = string(n.URL(b.source))
+			p.appendText(string(n.Label(b.source)), inner)
+		case *ast.Image:
+			url := string(n.Destination)
+			if b.resolveImage != nil {
+				resolved, err := b.resolveImage(url)
+				if err != nil {
+					return fmt.Errorf("error resolving image %s: %w", url, err)
+				}
+				url = resolved
+			}
+			p.Pieces = append(p.Pieces, &gdocPiece{Image: url})
+		case *east.FootnoteLink:
+			footnote, ok := b.footnotes[n.Index]
+			if !ok {
+				log.Printf("warning: no definition found for footnote %d", n.Index)
+				continue
+			}
+			content, err := b.blocks(footnote, blockContext{})
+			if err != nil {
+				return err
+			}
+			p.Pieces = append(p.Pieces, &gdocPiece{Footnote: content})
+		case *east.FootnoteBacklink:
+			// these only exist for rendering html
+		case *east.TaskCheckBox:
+			checked := n.IsChecked
+			p.Pieces = append(p.Pieces, &gdocPiece{checkbox: &checked})
+		case *ast.RawHTML:
+			log.Println("warning: ignoring inline html")
+		default:
+			log.Printf("warning: ignoring markdown inline of type %s", n.Kind())
+		}
+	}
+	return nil
+}
+
+// appendText adds text to a paragraph, merging it with the previous piece if the styles match
+func (p *gdocParagraph) appendText(s string, style gdocStyle) {
+	if s == "" {
+		return
+	}
+	if n := len(p.Pieces); n > 0 {
+		last := p.Pieces[n-1]
+		if last.Image == "" && last.Footnote == nil && last.checkbox == nil && last.Style == style {
+			last.Text += s
+			return
+		}
+	}
+	p.Pieces = append(p.Pieces, &gdocPiece{Text: s, Style: style})
+}
+
+// textStyle converts a style to a google docs text style together with the
+// list of fields to update. All fields are always included so that inserted
+// text does not inherit styles from neighboring text.
+func (s gdocStyle) textStyle() (*docs.TextStyle, string) {
+	ts := docs.TextStyle{
+		Bold:          s.Bold,
+		Italic:        s.Italic,
+		Strikethrough: s.Strikethrough,
+	}
+	if s.Code {
+		ts.WeightedFontFamily = &docs.WeightedFontFamily{FontFamily: codeFont, Weight: 400}
+	}
+	if s.Link != "" {
+		ts.Link = &docs.Link{Url: s.Link}
+	}
+	return &ts, "bold,italic,strikethrough,weightedFontFamily,link"
+}
@@ -0,0 +1,103 @@
+package markdown
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// Slug converts text to a form suitable for anchors and filenames, in the
+// same way that github generates anchors for headings
+func Slug(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// Escape backslash-escapes the characters that markdown would otherwise
+// treat as formatting, so that s appears literally in inline text
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `\<`, `>`, `\>`, `#`, `\#`, `!`, `\!`, `&`, `\&`,
+)
+
+// HeadingAnchors computes the anchor for each heading in a sequence of
+// elements, keyed by heading ID. Duplicate anchors get a numeric suffix.
+func HeadingAnchors(content []*docs.StructuralElement) map[string]string {
+	anchors := make(map[string]string)
+	seen := make(map[string]int)
+	for _, elem := range content {
+		p := elem.Paragraph
+		if p == nil || p.ParagraphStyle == nil || p.ParagraphStyle.HeadingId == "" {
+			continue
+		}
+
+		var text strings.Builder
+		for _, el := range p.Elements {
+			if el.TextRun != nil {
+				text.WriteString(el.TextRun.Content)
+			}
+		}
+
+		anchor := Slug(text.String())
+		if n := seen[anchor]; n > 0 {
+			seen[anchor]++
+			anchor = fmt.Sprintf("%s-%d", anchor, n)
+		} else {
+			seen[anchor] = 1
+		}
+		anchors[p.ParagraphStyle.HeadingId] = anchor
+	}
+	return anchors
+}
+
+// linkURL determines the URL for a link, resolving links to headings
+func (dc *markdownConverter) linkURL(link *docs.Link) string {
+	headingID := link.HeadingId
+	if headingID == "" {
+		headingID = dc.sameDocHeading(link.Url)
+	}
+	if headingID == "" {
+		if link.Url == "" && link.BookmarkId != "" {
+			log.Printf("warning: links to bookmarks are not supported (bookmark %s)", link.BookmarkId)
+		}
+		return link.Url
+	}
+
+	if u, ok := dc.headingURLs[headingID]; ok {
+		return u
+	}
+	log.Printf("warning: no heading found for link to %s", headingID)
+	return link.Url
+}
+
+// sameDocHeading gets the heading ID from a URL that links to a heading in
+// the document being converted, or returns an empty string
+func (dc *markdownConverter) sameDocHeading(s string) string {
+	if s == "" || dc.doc.DocumentId == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host != "docs.google.com" || !strings.Contains(u.Path, "/d/"+dc.doc.DocumentId) {
+		return ""
+	}
+	if !strings.HasPrefix(u.Fragment, "heading=") {
+		return ""
+	}
+	return strings.TrimPrefix(u.Fragment, "heading=")
+}
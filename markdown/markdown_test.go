@@ -2,8 +2,13 @@ package markdown
 
 import (
 	"testing"
+	"time"
 
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/internal/doctest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
 )
 
 func TestNewCommandPattern(t *testing.T) {
@@ -14,3 +19,363 @@ func TestNewCommandPattern(t *testing.T) {
 		assert.Equal(t, `bar`, cmd.Value)
 	}
 }
+
+func TestToGoogleDoc(t *testing.T) {
+	src := "# Title\n\nHello **world**[^1]\n\n* one\n  * two\n\n[^1]: A note.\n"
+	r, err := ToGoogleDoc([]byte(src), 1, nil)
+	require.NoError(t, err)
+
+	// the list is inserted first, then the paragraphs before it
+	require.NotNil(t, r.Body[0].InsertText)
+	assert.Equal(t, "one\n\ttwo\n", r.Body[0].InsertText.Text)
+	assert.EqualValues(t, 1, r.Body[0].InsertText.Location.Index)
+
+	var texts []string
+	var bold []*docs.Range
+	var footnotes int
+	for _, req := range r.Body {
+		switch {
+		case req.InsertText != nil:
+			texts = append(texts, req.InsertText.Text)
+		case req.UpdateTextStyle != nil && req.UpdateTextStyle.TextStyle.Bold:
+			bold = append(bold, req.UpdateTextStyle.Range)
+		case req.CreateFootnote != nil:
+			footnotes++
+			assert.EqualValues(t, 18, req.CreateFootnote.Location.Index)
+		}
+	}
+	assert.Equal(t, []string{"one\n\ttwo\n", "Title\nHello world\n"}, texts)
+	require.Len(t, bold, 1)
+	assert.EqualValues(t, 13, bold[0].StartIndex)
+	assert.EqualValues(t, 18, bold[0].EndIndex)
+
+	// footnote content is populated separately
+	assert.Equal(t, 1, footnotes)
+	require.Len(t, r.Footnotes, 1)
+	assert.NotNil(t, r.Body[r.Footnotes[0].CreateIndex].CreateFootnote)
+	assert.Equal(t, "A note.\n", r.Footnotes[0].Requests[0].InsertText.Text)
+}
+
+func TestToGoogleDocTable(t *testing.T) {
+	src := "| a | b |\n| --- | --- |\n| c | d |\n"
+	r, err := ToGoogleDoc([]byte(src), 1, nil)
+	require.NoError(t, err)
+
+	require.NotNil(t, r.Body[0].InsertTable)
+	var cells []int64
+	for _, req := range r.Body[1:] {
+		if req.InsertText != nil {
+			cells = append(cells, req.InsertText.Location.Index)
+		}
+	}
+	// cells are filled in reverse order
+	assert.Equal(t, []int64{12, 10, 7, 5}, cells)
+}
+
+// makeDoc creates a google doc with one paragraph for each string
+func makeDoc(paragraphs ...string) *docs.Document {
+	doc := docs.Document{Body: &docs.Body{}}
+	index := int64(1)
+	for _, s := range paragraphs {
+		p := doctest.Paragraph(index, "NORMAL_TEXT", nil, &docs.TextRun{Content: s})
+		doc.Body.Content = append(doc.Body.Content, p)
+		index = p.EndIndex
+	}
+	return &doc
+}
+
+// applyTextRequests applies insertions and deletions to the text of a body
+func applyTextRequests(text string, reqs []*docs.Request) string {
+	runes := []rune(" " + text) // google docs indices start at 1
+	for _, req := range reqs {
+		switch {
+		case req.InsertText != nil:
+			i := req.InsertText.Location.Index
+			runes = append(runes[:i], append([]rune(req.InsertText.Text), runes[i:]...)...)
+		case req.DeleteContentRange != nil:
+			r := req.DeleteContentRange.Range
+			runes = append(runes[:r.StartIndex], runes[r.EndIndex:]...)
+		}
+	}
+	return string(runes[1:])
+}
+
+func TestSyncGoogleDoc(t *testing.T) {
+	doc := makeDoc("Hello “big” world\n", "Second para\n", "Third paragraph\n")
+	src := "Hello \"big\" wide world\n\nSecond **para**\n"
+
+	reqs, err := SyncGoogleDoc(doc, []byte(src), nil)
+	require.NoError(t, err)
+
+	// curly quotes are not treated as changes
+	assert.Equal(t, "Hello “big” wide world\nSecond para\n", applyTextRequests("Hello “big” world\nSecond para\nThird paragraph\n", reqs))
+
+	var styled []*docs.Range
+	for _, req := range reqs {
+		if req.UpdateTextStyle != nil && req.UpdateTextStyle.TextStyle.Bold {
+			styled = append(styled, req.UpdateTextStyle.Range)
+		}
+	}
+	require.Len(t, styled, 1)
+	assert.EqualValues(t, 26, styled[0].StartIndex)
+	assert.EqualValues(t, 30, styled[0].EndIndex)
+
+	// documents with pending suggestions are not synced
+	doc = makeDoc("Hello world\n")
+	doc.Body.Content[0].Paragraph.Elements[0].TextRun.SuggestedInsertionIds = []string{"s1"}
+	_, err = SyncGoogleDoc(doc, []byte("Hello world\n"), nil)
+	assert.Error(t, err)
+}
+
+func TestComments(t *testing.T) {
+	doc := makeDoc("Hello world\n", "Second para\n")
+	comments := []*googledoc.Comment{
+		{Author: "Alice", Content: "which world?", Quote: "world", Replies: []*googledoc.Reply{{Author: "Bob", Content: "this one"}}},
+		{Author: "Carol", Content: "resolved", Quote: "Second", Resolved: true},
+		{Author: "Dave", Content: "general\nremark"},
+	}
+	googledoc.AnchorComments(doc, comments)
+	assert.EqualValues(t, 7, comments[0].StartIndex)
+	assert.EqualValues(t, 12, comments[0].EndIndex)
+	assert.False(t, comments[2].Anchored())
+
+	md, err := Convert(doc, doc.Body.Content, &Options{Comments: comments, CommentStyle: CriticMarkupComments})
+	require.NoError(t, err)
+	assert.Equal(t, "{>>Dave: general remark<<}\n\nHello world{>>Alice: which world? — Bob: this one<<}\n\nSecond para\n\n", md)
+
+	md, err = Convert(doc, doc.Body.Content, &Options{Comments: comments, CommentStyle: FootnoteComments})
+	require.NoError(t, err)
+	assert.Contains(t, md, `Hello world<sup id="comment-ref-2"><a href="#comment-2">[c2]</a></sup>`)
+	assert.Contains(t, md, `<li id="comment-2"><strong>Alice:</strong> which world?<br><strong>Bob:</strong> this one <a href="#comment-ref-2">↩</a></li>`)
+
+	md, err = Convert(doc, doc.Body.Content, &Options{Comments: comments})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world\n\nSecond para\n\n", md)
+}
+
+func TestSuggestions(t *testing.T) {
+	makeSuggestedDoc := func() *docs.Document {
+		doc := makeDoc("Hello old new world\n")
+		p := doc.Body.Content[0].Paragraph
+		p.Elements = []*docs.ParagraphElement{
+			{TextRun: &docs.TextRun{Content: "Hello ", TextStyle: &docs.TextStyle{}}},
+			{TextRun: &docs.TextRun{Content: "old", TextStyle: &docs.TextStyle{}, SuggestedDeletionIds: []string{"s1"}}},
+			{TextRun: &docs.TextRun{Content: "new", TextStyle: &docs.TextStyle{}, SuggestedInsertionIds: []string{"s1"}}},
+			{TextRun: &docs.TextRun{Content: " world\n", TextStyle: &docs.TextStyle{}}},
+		}
+		return doc
+	}
+
+	doc := makeSuggestedDoc()
+	md, err := Convert(doc, doc.Body.Content, &Options{SuggestionStyle: CriticMarkupSuggestions})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello {--old--}{++new++} world\n\n", md)
+
+	md, err = Convert(doc, doc.Body.Content, &Options{SuggestionStyle: HTMLSuggestions})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello <del>old</del><ins>new</ins> world\n\n", md)
+
+	googledoc.ApplySuggestions(doc, true)
+	md, err = FromGoogleDoc(doc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello new world\n\n", md)
+
+	doc = makeSuggestedDoc()
+	googledoc.ApplySuggestions(doc, false)
+	md, err = FromGoogleDoc(doc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello old world\n\n", md)
+}
+
+func TestFrontMatter(t *testing.T) {
+	draft := true
+	fm, err := FrontMatter(&googledoc.Settings{Title: "My post", Tags: []string{"a", "b"}, Draft: &draft})
+	require.NoError(t, err)
+	assert.Equal(t, "---\ntitle: My post\ntags:\n    - a\n    - b\ndraft: true\n---\n\n", fm)
+
+	fm, err = FrontMatter(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", fm)
+}
+
+func TestPageFrontMatter(t *testing.T) {
+	page := Page{
+		Title:   `Say "hi"`,
+		Date:    time.Date(2024, 3, 5, 9, 20, 30, 0, time.UTC),
+		Slug:    "say-hi",
+		Tags:    []string{"a", "b"},
+		Summary: "One\ttwo",
+	}
+	fm, err := PageFrontMatter(&page, YAMLFrontMatter)
+	require.NoError(t, err)
+	assert.Equal(t, "---\ntitle: Say \"hi\"\ndate: 2024-03-05T09:20:30Z\nslug: say-hi\ntags:\n    - a\n    - b\nsummary: \"One\\ttwo\"\n---\n\n", fm)
+
+	fm, err = PageFrontMatter(&page, TOMLFrontMatter)
+	require.NoError(t, err)
+	assert.Equal(t, "+++\ntitle = \"Say \\\"hi\\\"\"\ndate = 2024-03-05T09:20:30Z\nslug = \"say-hi\"\ntags = [\"a\", \"b\"]\nsummary = \"One\\ttwo\"\n+++\n\n", fm)
+
+	fm, err = PageFrontMatter(&Page{Title: "Untitled"}, TOMLFrontMatter)
+	require.NoError(t, err)
+	assert.Equal(t, "+++\ntitle = \"Untitled\"\n+++\n\n", fm)
+}
+
+func TestHeadingLinks(t *testing.T) {
+	doc := makeDoc("Intro\n", "See below\n", "Intro\n")
+	doc.DocumentId = "doc1"
+	doc.Body.Content[0].Paragraph.ParagraphStyle = &docs.ParagraphStyle{NamedStyleType: "HEADING_1", HeadingId: "h.a"}
+	doc.Body.Content[2].Paragraph.ParagraphStyle = &docs.ParagraphStyle{NamedStyleType: "HEADING_1", HeadingId: "h.b"}
+	assert.Equal(t, map[string]string{"h.a": "intro", "h.b": "intro-1"}, HeadingAnchors(doc.Body.Content))
+
+	run := doc.Body.Content[1].Paragraph.Elements[0].TextRun
+	run.Content = "below"
+	run.TextStyle.Link = &docs.Link{HeadingId: "h.b"}
+
+	md, err := FromGoogleDoc(doc, nil)
+	require.NoError(t, err)
+	assert.Contains(t, md, "[below](#intro-1)")
+
+	run.TextStyle.Link = &docs.Link{Url: "https://docs.google.com/document/d/doc1/edit#heading=h.b"}
+	md, err = Convert(doc, doc.Body.Content, &Options{HeadingURLs: map[string]string{"h.b": "second.md#intro"}})
+	require.NoError(t, err)
+	assert.Contains(t, md, "[below](second.md#intro)")
+}
+
+func TestPageBreak(t *testing.T) {
+	doc := makeDoc("One\n", "Two\n")
+	p := doc.Body.Content[0].Paragraph
+	p.Elements = append(p.Elements, &docs.ParagraphElement{PageBreak: &docs.PageBreak{}})
+
+	md, err := FromGoogleDoc(doc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "One\n\nTwo\n\n", md)
+
+	md, err = Convert(doc, doc.Body.Content, &Options{PageBreak: "* * *"})
+	require.NoError(t, err)
+	assert.Equal(t, "One\n\n* * *\n\nTwo\n\n", md)
+}
+
+func TestChecklists(t *testing.T) {
+	doc := makeDoc("Todo\n", "Done\n")
+	doc.Lists = map[string]docs.List{
+		"l": {ListProperties: &docs.ListProperties{NestingLevels: []*docs.NestingLevel{{GlyphType: "GLYPH_TYPE_UNSPECIFIED"}}}},
+	}
+	for _, elem := range doc.Body.Content {
+		elem.Paragraph.Bullet = &docs.Bullet{ListId: "l"}
+	}
+	doc.Body.Content[1].Paragraph.Elements[0].TextRun.TextStyle.Strikethrough = true
+
+	md, err := FromGoogleDoc(doc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "- [ ] Todo\n\n- [x] Done\n\n", md)
+
+	md, err = Convert(doc, doc.Body.Content, &Options{ChecklistStyle: HTMLChecklists})
+	require.NoError(t, err)
+	assert.Contains(t, md, "* <input type=\"checkbox\" disabled checked> Done\n")
+}
+
+func TestLinkedCharts(t *testing.T) {
+	doc := makeDoc("x\n")
+	p := doc.Body.Content[0].Paragraph
+	p.Elements = append([]*docs.ParagraphElement{{InlineObjectElement: &docs.InlineObjectElement{InlineObjectId: "c"}}}, p.Elements...)
+	doc.InlineObjects = map[string]docs.InlineObject{
+		"c": {InlineObjectProperties: &docs.InlineObjectProperties{EmbeddedObject: &docs.EmbeddedObject{
+			Title: "Sales",
+			LinkedContentReference: &docs.LinkedContentReference{
+				SheetsChartReference: &docs.SheetsChartReference{SpreadsheetId: "s1", ChartId: 7},
+			},
+		}}},
+	}
+
+	md, err := FromGoogleDoc(doc, map[string]string{"c": "chart.png"})
+	require.NoError(t, err)
+	assert.Equal(t, "[![Sales](chart.png)](https://docs.google.com/spreadsheets/d/s1/edit)x\n\n", md)
+
+	md, err = Convert(doc, doc.Body.Content, &Options{ChartTables: map[string]*googledoc.Chart{
+		"c": {Title: "Sales", URL: "https://example.com/s1", Rows: [][]string{{"Year", "Total"}, {"2020", "1|2"}}},
+	}})
+	require.NoError(t, err)
+	assert.Contains(t, md, "| Year | Total |\n| --- | --- |\n| 2020 | 1\\|2 |\n\n[Sales](https://example.com/s1)\n")
+}
+
+func TestCodeBlocks(t *testing.T) {
+	doc := makeDoc("Steps\n", "lang: go\n", "x := 1\n", "After\n")
+	doc.Lists = map[string]docs.List{
+		"l": {ListProperties: &docs.ListProperties{NestingLevels: []*docs.NestingLevel{{GlyphType: "DECIMAL"}}}},
+	}
+	doc.Body.Content[0].Paragraph.Bullet = &docs.Bullet{ListId: "l"}
+	for _, elem := range doc.Body.Content[1:3] {
+		elem.Paragraph.ParagraphStyle.IndentStart = &docs.Dimension{Magnitude: 36, Unit: "PT"}
+		elem.Paragraph.Elements[0].TextRun.TextStyle.WeightedFontFamily = &docs.WeightedFontFamily{FontFamily: "JetBrains Mono"}
+	}
+
+	md, err := FromGoogleDoc(doc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "1. Steps\n\n   ```go\n   x := 1\n   ```\n\nAfter\n\n", md)
+}
+
+func TestStyleRules(t *testing.T) {
+	rules, err := googledoc.ParseStyleRules([]byte(`
+rules:
+  - named_style: HEADING_6
+    block: callout
+  - background: "#ffff00"
+    inline: highlight
+`))
+	require.NoError(t, err)
+
+	doc := makeDoc("Careful\n", "Very important\n")
+	doc.Body.Content[0].Paragraph.ParagraphStyle.NamedStyleType = "HEADING_6"
+	doc.Body.Content[1].Paragraph.Elements[0].TextRun.TextStyle.BackgroundColor = &docs.OptionalColor{
+		Color: &docs.Color{RgbColor: &docs.RgbColor{Red: 1, Green: 1}},
+	}
+
+	md, err := Convert(doc, doc.Body.Content, &Options{StyleRules: rules})
+	require.NoError(t, err)
+	assert.Equal(t, "> [!NOTE]\n> Careful\n\n==Very important==\n\n", md)
+}
+
+func TestSpoilers(t *testing.T) {
+	doc := makeDoc("Puzzle\n", "[spoiler]\n", "The answer\n", "is 42\n", "[/spoiler]\n", "[details: Proof]\n", "Trivial\n", "[/details]\n")
+
+	md, err := FromGoogleDoc(doc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Puzzle\n\n>! The answer\n\n>! is 42\n\n<details>\n<summary>Proof</summary>\n\nTrivial\n\n</details>\n\n", md)
+
+	md, err = Convert(doc, doc.Body.Content, &Options{SpoilerStyle: HTMLSpoilers})
+	require.NoError(t, err)
+	assert.Contains(t, md, "<details>\n<summary>Spoiler</summary>\n\nThe answer\n\nis 42\n\n</details>\n")
+}
+
+func TestFootnotes(t *testing.T) {
+	doc := makeDoc("One\n", "Two\n")
+	ref := func(id string) *docs.ParagraphElement {
+		return &docs.ParagraphElement{FootnoteReference: &docs.FootnoteReference{FootnoteId: id}}
+	}
+	p := doc.Body.Content[0].Paragraph
+	p.Elements = []*docs.ParagraphElement{p.Elements[0], ref("kix.b"), ref("kix.a")}
+	doc.Body.Content[1].Paragraph.Elements = append([]*docs.ParagraphElement{ref("kix.b")}, doc.Body.Content[1].Paragraph.Elements...)
+	doc.Footnotes = map[string]docs.Footnote{
+		"kix.a": {FootnoteId: "kix.a", Content: makeDoc("Alpha\n").Body.Content},
+		"kix.b": {FootnoteId: "kix.b", Content: makeDoc("Beta\n").Body.Content},
+	}
+	p.Elements[0].TextRun.Content = "One"
+
+	md, err := FromGoogleDoc(doc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "One[^1][^2]\n\n[^1]Two\n\n[^1]: Beta\n\n[^2]: Alpha\n\n", md)
+
+	md, err = Convert(doc, doc.Body.Content, &Options{FootnoteStyle: SidenoteFootnotes})
+	require.NoError(t, err)
+	assert.Contains(t, md, `<input type="checkbox" id="sn-2" class="margin-toggle"/><span class="sidenote">Alpha</span>`)
+	assert.NotContains(t, md, "[^")
+
+	md, err = Convert(doc, doc.Body.Content, &Options{FootnoteStyle: EndnoteFootnotes})
+	require.NoError(t, err)
+	assert.Contains(t, md, `One<sup id="fnref-1"><a href="#fn-1">1</a></sup>`)
+	assert.Contains(t, md, "<li id=\"fn-2\">\n\nAlpha <a href=\"#fnref-2\">↩</a>\n\n</li>\n")
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, `Why \*not\* use \[brackets\]\_now\\\!`, Escape(`Why *not* use [brackets]_now\!`))
+	assert.Equal(t, "plain text", Escape("plain text"))
+}
@@ -0,0 +1,52 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+)
+
+// processBlockMarker opens or closes a spoiler or collapsible section
+func (dc *markdownConverter) processBlockMarker(out *bytes.Buffer, m *googledoc.BlockMarker) {
+	dc.flushCodeBlock(out)
+	switch dc.hidden.Apply(m) {
+	case googledoc.OpenSpoiler:
+		dc.setSpoiler(out, true)
+	case googledoc.CloseSpoiler:
+		dc.setSpoiler(out, false)
+	case googledoc.OpenDetails:
+		fmt.Fprintf(out, "<details>\n<summary>%s</summary>\n\n", html.EscapeString(m.Summary))
+	case googledoc.CloseDetails:
+		fmt.Fprint(out, "</details>\n\n")
+	}
+}
+
+// setSpoiler starts or ends a spoiler block. In the default style each
+// paragraph of a spoiler is marked separately so this writes nothing.
+func (dc *markdownConverter) setSpoiler(out *bytes.Buffer, on bool) {
+	if on == dc.inSpoiler {
+		return
+	}
+	dc.flushCodeBlock(out)
+	dc.inSpoiler = on
+	if dc.spoilerStyle != HTMLSpoilers {
+		return
+	}
+	if on {
+		fmt.Fprint(out, "<details>\n<summary>Spoiler</summary>\n\n")
+	} else {
+		fmt.Fprint(out, "</details>\n\n")
+	}
+}
+
+// closeHiddenBlocks closes any spoilers and collapsible sections that were
+// not closed explicitly
+func (dc *markdownConverter) closeHiddenBlocks(out *bytes.Buffer) {
+	details := dc.hidden.Close()
+	dc.setSpoiler(out, false)
+	for ; details > 0; details-- {
+		fmt.Fprint(out, "</details>\n\n")
+	}
+}
@@ -0,0 +1,540 @@
+package markdown
+
+// This file computes a minimal set of google docs requests that bring an
+// existing google doc into line with a markdown document
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"google.golang.org/api/docs/v1"
+)
+
+// SyncGoogleDoc computes the smallest set of requests that update the text
+// and text styles of a google doc to match a markdown document, leaving
+// everything else in the document, including comments, untouched. The
+// document must have been fetched with suggestions inline, which is the
+// default, so that indices match those used by BatchUpdate.
+//
+// Text is compared word by word. Tables and bullets are not synced, and new
+// footnotes are not created. Documents with pending suggestions are refused,
+// since their text cannot be matched up with the markdown. The footnotes that are
+// present in both documents are synced in the same way as the body.
+func SyncGoogleDoc(doc *docs.Document, source []byte, resolveImage func(string) (string, error)) ([]*docs.Request, error) {
+	if googledoc.HasSuggestions(doc) {
+		return nil, errors.New("document has suggested edits: accept or reject them before syncing")
+	}
+
+	// remove the latex header that FromGoogleDoc adds, since in the google
+	// doc those definitions are ordinary paragraphs
+	source = latexHeaderRegexp.ReplaceAll(source, nil)
+
+	model, err := parseMarkdown(source, resolveImage)
+	if err != nil {
+		return nil, err
+	}
+
+	s := syncer{doc: doc, tokens: make(map[string]int)}
+	s.segment("", doc.Body.Content, model.Blocks, false)
+	return s.reqs, nil
+}
+
+// regular expression for the latex header at the beginning of converted markdown
+var latexHeaderRegexp = regexp.MustCompile(`^\$\$\n(\\newcommand.*\n)*\$\$\n+`)
+
+// regular expression for the dollar signs that processLine puts around latex identifiers
+var inlineLatexRegexp = regexp.MustCompile(`\$(\\[\pL\pN]+)\$`)
+
+// objectPlaceholder stands in for images and footnote references
+const objectPlaceholder = '\uFFFC'
+
+// syncUnit is a single character or object in a document
+type syncUnit struct {
+	r         rune      // the character, or objectPlaceholder for images and footnotes
+	kind      byte      // for objects: 'F' for footnotes and 'I' for images
+	style     gdocStyle // the text style as far as markdown can express it
+	index     int64     // for existing units: the index in the google doc
+	paragraph int       // index of the paragraph that contains this unit
+	footnote  string    // for existing footnote references: the footnote ID
+	piece     *gdocPiece
+}
+
+// width gets the number of indices that a unit occupies in a google doc
+func (u *syncUnit) width() int64 {
+	if u.r > 0xffff {
+		return 2
+	}
+	return 1
+}
+
+type syncer struct {
+	doc    *docs.Document
+	tokens map[string]int // for interning tokens
+	reqs   []*docs.Request
+}
+
+// pendingOp is a request that is applied at a given index in the original document
+type pendingOp struct {
+	pos      int64
+	priority int // ops at the same position with higher priority go first
+	reqs     []*docs.Request
+}
+
+// segment computes the requests for the body or for one footnote
+func (s *syncer) segment(segmentID string, content []*docs.StructuralElement, target []*gdocBlock, isFootnote bool) {
+	existing, paragraphs := s.existingUnits(content, isFootnote)
+	wanted, wantedParagraphs := s.targetUnits(target)
+	if len(existing) == 0 {
+		return
+	}
+
+	edits := diffTokens(s.tokenize(existing), s.tokenize(wanted))
+
+	// expand the token-level edit script into a unit-level edit script
+	var units []edit
+	aTokens, bTokens := tokenRanges(existing), tokenRanges(wanted)
+	for _, e := range edits {
+		switch e.Op {
+		case opEqual:
+			ar, br := aTokens[e.A], bTokens[e.B]
+			for i := 0; i < ar[1]-ar[0]; i++ {
+				units = append(units, edit{Op: opEqual, A: ar[0] + i, B: br[0] + i})
+			}
+		case opDelete:
+			ar := aTokens[e.A]
+			for i := ar[0]; i < ar[1]; i++ {
+				units = append(units, edit{Op: opDelete, A: i})
+			}
+		case opInsert:
+			br := bTokens[e.B]
+			for i := br[0]; i < br[1]; i++ {
+				units = append(units, edit{Op: opInsert, B: i})
+			}
+		}
+	}
+
+	var ops []pendingOp
+
+	// nextIndex finds the index of the first existing unit at or after position i in the edit script
+	nextIndex := func(i int) int64 {
+		for ; i < len(units); i++ {
+			if units[i].Op != opInsert {
+				return existing[units[i].A].index
+			}
+		}
+		return existing[len(existing)-1].index
+	}
+
+	// final positions of wanted units after all edits, for paragraph styles
+	final := make([]int64, len(wanted))
+	var shift int64
+
+	for i := 0; i < len(units); {
+		e := units[i]
+		if e.Op == opEqual {
+			a, b := existing[e.A], wanted[e.B]
+			final[e.B] = a.index + shift
+
+			// sync footnotes that are present in both documents
+			if a.kind == 'F' && b.kind == 'F' {
+				if f, ok := s.doc.Footnotes[a.footnote]; ok {
+					s.segment(a.footnote, f.Content, b.piece.Footnote, true)
+				}
+			}
+
+			// group consecutive characters that need the same change of style
+			if a.kind == 0 && a.r != '\n' && a.style != b.style.projected() {
+				j := i + 1
+				for ; j < len(units) && units[j].Op == opEqual; j++ {
+					prev, next, w := existing[units[j-1].A], existing[units[j].A], wanted[units[j].B]
+					if next.kind != 0 || next.r == '\n' || next.style == w.style.projected() ||
+						w.style != b.style || next.index != prev.index+prev.width() {
+						break
+					}
+					final[units[j].B] = next.index + shift
+				}
+
+				last := existing[units[j-1].A]
+				ts, fields := b.style.textStyle()
+				ops = append(ops, pendingOp{
+					pos:      a.index,
+					priority: 1,
+					reqs: []*docs.Request{{
+						UpdateTextStyle: &docs.UpdateTextStyleRequest{
+							Range:     &docs.Range{StartIndex: a.index, EndIndex: last.index + last.width(), SegmentId: segmentID},
+							TextStyle: ts,
+							Fields:    fields,
+						},
+					}},
+				})
+				i = j
+				continue
+			}
+			i++
+			continue
+		}
+
+		// collect a hunk of consecutive deletions and insertions
+		var deleted, inserted []*syncUnit
+		var insertedIdx []int
+		j := i
+		for j < len(units) && units[j].Op != opEqual {
+			if units[j].Op == opDelete {
+				deleted = append(deleted, existing[units[j].A])
+			} else {
+				inserted = append(inserted, wanted[units[j].B])
+				insertedIdx = append(insertedIdx, units[j].B)
+			}
+			j++
+		}
+		pos := nextIndex(j)
+
+		var reqs []*docs.Request
+		reqs = append(reqs, s.insertions(pos, inserted, segmentID)...)
+		reqs = append(reqs, deletions(deleted, segmentID)...)
+		ops = append(ops, pendingOp{pos: pos, reqs: reqs})
+
+		for _, u := range deleted {
+			shift -= u.width()
+		}
+		var offset int64
+		for k, u := range inserted {
+			final[insertedIdx[k]] = pos + shift + offset
+			// new footnotes are not created so they take up no space
+			if u.kind != 'F' {
+				offset += u.width()
+			}
+		}
+		shift += offset
+		i = j
+	}
+
+	// apply the ops from the end of the document backwards so that indices remain valid
+	sort.SliceStable(ops, func(i, j int) bool {
+		if ops[i].pos != ops[j].pos {
+			return ops[i].pos > ops[j].pos
+		}
+		return ops[i].priority > ops[j].priority
+	})
+	for _, op := range ops {
+		s.reqs = append(s.reqs, op.reqs...)
+	}
+
+	// finally update the style of new paragraphs and paragraphs whose style changed
+	insertedNewline := make(map[int]bool)
+	matchedParagraph := make(map[int]int)
+	for _, e := range units {
+		if e.Op == opInsert && wanted[e.B].r == '\n' {
+			insertedNewline[e.B] = true
+		}
+		if e.Op == opEqual && wanted[e.B].r == '\n' {
+			matchedParagraph[e.B] = existing[e.A].paragraph
+		}
+	}
+
+	var start int
+	for i, u := range wanted {
+		if u.r != '\n' {
+			continue
+		}
+		p := wantedParagraphs[u.paragraph]
+		r := &docs.Range{StartIndex: final[start], EndIndex: final[i] + 1, SegmentId: segmentID}
+		start = i + 1
+
+		if insertedNewline[i] {
+			s.reqs = append(s.reqs, paragraphStyleRequest(p, r))
+			if p.List == nil {
+				s.reqs = append(s.reqs, &docs.Request{
+					DeleteParagraphBullets: &docs.DeleteParagraphBulletsRequest{Range: r},
+				})
+			}
+		} else if k, ok := matchedParagraph[i]; ok && paragraphs[k].ParagraphStyle.NamedStyleType != p.NamedStyle {
+			s.reqs = append(s.reqs, paragraphStyleRequest(p, r))
+		}
+	}
+}
+
+// paragraphStyleRequest creates a request that sets the named style of a paragraph
+func paragraphStyleRequest(p *gdocParagraph, r *docs.Range) *docs.Request {
+	return &docs.Request{
+		UpdateParagraphStyle: &docs.UpdateParagraphStyleRequest{
+			Range:          r,
+			ParagraphStyle: &docs.ParagraphStyle{NamedStyleType: p.NamedStyle},
+			Fields:         "namedStyleType",
+		},
+	}
+}
+
+// insertions generates requests that insert units at a position. The
+// units are inserted in reverse order, each at the same position.
+func (s *syncer) insertions(pos int64, units []*syncUnit, segmentID string) []*docs.Request {
+	var reqs []*docs.Request
+	for j := len(units); j > 0; {
+		u := units[j-1]
+		if u.kind != 0 {
+			if u.kind == 'I' {
+				reqs = append(reqs, &docs.Request{
+					InsertInlineImage: &docs.InsertInlineImageRequest{
+						Uri:      u.piece.Image,
+						Location: &docs.Location{Index: pos, SegmentId: segmentID},
+					},
+				})
+			} else {
+				log.Println("warning: not creating new footnote when syncing")
+			}
+			j--
+			continue
+		}
+
+		// find the run of text with the same style
+		i := j - 1
+		for i > 0 && units[i-1].kind == 0 && units[i-1].style == u.style {
+			i--
+		}
+		var text []rune
+		for _, v := range units[i:j] {
+			text = append(text, v.r)
+		}
+		ts, fields := u.style.textStyle()
+		n := int64(len(utf16.Encode(text)))
+		reqs = append(reqs,
+			&docs.Request{
+				InsertText: &docs.InsertTextRequest{
+					Text:     string(text),
+					Location: &docs.Location{Index: pos, SegmentId: segmentID},
+				},
+			},
+			&docs.Request{
+				UpdateTextStyle: &docs.UpdateTextStyleRequest{
+					Range:     &docs.Range{StartIndex: pos, EndIndex: pos + n, SegmentId: segmentID},
+					TextStyle: ts,
+					Fields:    fields,
+				},
+			})
+		j = i
+	}
+	return reqs
+}
+
+// deletions generates requests that delete units, grouping units that are
+// contiguous in the document and working backwards so that indices remain valid
+func deletions(units []*syncUnit, segmentID string) []*docs.Request {
+	var reqs []*docs.Request
+	for j := len(units); j > 0; {
+		i := j - 1
+		for i > 0 && units[i-1].index+units[i-1].width() == units[i].index {
+			i--
+		}
+		last := units[j-1]
+		reqs = append(reqs, &docs.Request{
+			DeleteContentRange: &docs.DeleteContentRangeRequest{
+				Range: &docs.Range{StartIndex: units[i].index, EndIndex: last.index + last.width(), SegmentId: segmentID},
+			},
+		})
+		j = i
+	}
+	return reqs
+}
+
+// existingUnits flattens the paragraphs in a google doc into units. Tables,
+// latex definitions, and elements other than text, images and footnotes are
+// left out, so they are never modified.
+func (s *syncer) existingUnits(content []*docs.StructuralElement, isFootnote bool) ([]*syncUnit, []*docs.Paragraph) {
+	var units []*syncUnit
+	var paragraphs []*docs.Paragraph
+	for _, elem := range content {
+		p := elem.Paragraph
+		if p == nil {
+			continue
+		}
+		if newcommandPattern.Find(&newcommand{}, googledoc.ParagraphText(p)) {
+			continue
+		}
+
+		paragraphs = append(paragraphs, p)
+		for _, el := range p.Elements {
+			u := syncUnit{index: el.StartIndex, paragraph: len(paragraphs) - 1}
+			switch {
+			case el.TextRun != nil:
+				t := el.TextRun
+				u.style = styleFromDoc(t.TextStyle)
+				for _, r := range t.Content {
+					v := u
+					v.r = r
+					units = append(units, &v)
+					u.index += v.width()
+				}
+				continue
+			case el.FootnoteReference != nil:
+				u.kind = 'F'
+				u.footnote = el.FootnoteReference.FootnoteId
+			case el.InlineObjectElement != nil:
+				u.kind = 'I'
+			default:
+				continue
+			}
+			u.r = objectPlaceholder
+			units = append(units, &u)
+		}
+	}
+
+	// google puts a space at the beginning of each footnote
+	if isFootnote && len(units) > 0 && units[0].r == ' ' {
+		units = units[1:]
+	}
+	return units, paragraphs
+}
+
+// targetUnits flattens the paragraphs in a markdown document into units
+func (s *syncer) targetUnits(blocks []*gdocBlock) ([]*syncUnit, []*gdocParagraph) {
+	var units []*syncUnit
+	var paragraphs []*gdocParagraph
+	for _, b := range blocks {
+		if b.Table != nil {
+			log.Println("warning: tables are not synced")
+			continue
+		}
+
+		p := b.Paragraph
+		if p.Rule {
+			continue
+		}
+		paragraphs = append(paragraphs, p)
+		for _, piece := range p.Pieces {
+			u := syncUnit{style: piece.Style, paragraph: len(paragraphs) - 1, piece: piece}
+			switch {
+			case piece.Image != "":
+				u.kind = 'I'
+				u.r = objectPlaceholder
+				units = append(units, &u)
+			case piece.Footnote != nil:
+				u.kind = 'F'
+				u.r = objectPlaceholder
+				units = append(units, &u)
+			default:
+				for _, r := range inlineLatexRegexp.ReplaceAllString(piece.Text, "$1") {
+					v := u
+					v.r = r
+					units = append(units, &v)
+				}
+			}
+		}
+		units = append(units, &syncUnit{r: '\n', paragraph: len(paragraphs) - 1})
+	}
+	return units, paragraphs
+}
+
+// styleFromDoc projects a google docs text style onto the styles that the
+// markdown converter can express, so that styles lost in conversion are
+// not treated as changes
+func styleFromDoc(ts *docs.TextStyle) gdocStyle {
+	var s gdocStyle
+	if ts == nil {
+		return s
+	}
+	if ts.Link != nil {
+		s.Link = ts.Link.Url
+	}
+	switch {
+	case googledoc.IsMonospace(ts.WeightedFontFamily):
+		s.Code = true
+	case ts.Strikethrough:
+		s.Strikethrough = true
+	case ts.Bold:
+		s.Bold = true
+	case ts.Italic:
+		s.Italic = true
+	}
+	return s
+}
+
+// projected applies the same projection as styleFromDoc to a markdown style
+func (s gdocStyle) projected() gdocStyle {
+	out := gdocStyle{Link: s.Link}
+	switch {
+	case s.Code:
+		out.Code = true
+	case s.Strikethrough:
+		out.Strikethrough = true
+	case s.Bold:
+		out.Bold = true
+	case s.Italic:
+		out.Italic = true
+	}
+	return out
+}
+
+// tokenRanges splits units into tokens: words, runs of whitespace, and
+// individual punctuation characters, newlines, and objects. It returns the
+// start and end of each token.
+func tokenRanges(units []*syncUnit) [][2]int {
+	var out [][2]int
+	for i := 0; i < len(units); {
+		j := i + 1
+		if class := tokenClass(units[i]); class != 0 {
+			for j < len(units) && tokenClass(units[j]) == class {
+				j++
+			}
+		}
+		out = append(out, [2]int{i, j})
+		i = j
+	}
+	return out
+}
+
+// tokenClass returns 'w' for word characters, 's' for spaces, and 0 for
+// characters that are tokens on their own
+func tokenClass(u *syncUnit) byte {
+	switch {
+	case u.kind != 0 || u.r == '\n':
+		return 0
+	case unicode.IsLetter(u.r) || unicode.IsDigit(u.r):
+		return 'w'
+	case unicode.IsSpace(u.r):
+		return 's'
+	}
+	return 0
+}
+
+// tokenize converts units into interned tokens for diffing
+func (s *syncer) tokenize(units []*syncUnit) []int {
+	var out []int
+	for _, r := range tokenRanges(units) {
+		var b strings.Builder
+		for _, u := range units[r[0]:r[1]] {
+			if u.kind != 0 {
+				b.WriteByte(0)
+				b.WriteByte(u.kind)
+			} else {
+				b.WriteRune(normalizeRune(u.r))
+			}
+		}
+		key := b.String()
+		id, ok := s.tokens[key]
+		if !ok {
+			id = len(s.tokens)
+			s.tokens[key] = id
+		}
+		out = append(out, id)
+	}
+	return out
+}
+
+// normalizeRune treats characters that the markdown converter rewrites as equal
+func normalizeRune(r rune) rune {
+	switch r {
+	case '“', '”':
+		return '"'
+	case '‘', '’':
+		return '\''
+	case '\u00a0':
+		return ' '
+	}
+	return r
+}
@@ -0,0 +1,389 @@
+package markdown
+
+// This file converts markdown to requests for the google docs API
+
+import (
+	"log"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+)
+
+// GoogleDocRequests holds the google docs API requests that insert a
+// converted markdown document into a google doc
+type GoogleDocRequests struct {
+	// Body contains requests to be applied to the body in a single batch update
+	Body []*docs.Request
+
+	// Footnotes contains the requests that populate each footnote. Since
+	// footnote IDs are not known until the body has been updated, these
+	// requests have empty segment IDs, which must be filled in using
+	// SetSegmentID.
+	Footnotes []*FootnoteRequests
+}
+
+// FootnoteRequests holds the requests that populate a single footnote
+type FootnoteRequests struct {
+	CreateIndex int             // index in Body of the CreateFootnote request for this footnote
+	Requests    []*docs.Request // requests to populate the footnote
+}
+
+// ToGoogleDoc converts markdown to google docs API requests that insert the
+// content at the given index in the body of a document. The resolveImage
+// function maps image destinations in the markdown to URLs that google can
+// fetch, and may be nil.
+func ToGoogleDoc(source []byte, index int64, resolveImage func(string) (string, error)) (*GoogleDocRequests, error) {
+	model, err := parseMarkdown(source, resolveImage)
+	if err != nil {
+		return nil, err
+	}
+
+	var out GoogleDocRequests
+	w := requestWriter{index: index, lists: model.Lists, out: &out}
+	w.blocks(model.Blocks)
+	out.Body = w.reqs
+	return &out, nil
+}
+
+// SetSegmentID sets the segment ID for every location and range in a list of requests
+func SetSegmentID(reqs []*docs.Request, segmentID string) {
+	for _, req := range reqs {
+		switch {
+		case req.InsertText != nil:
+			req.InsertText.Location.SegmentId = segmentID
+		case req.InsertInlineImage != nil:
+			req.InsertInlineImage.Location.SegmentId = segmentID
+		case req.UpdateTextStyle != nil:
+			req.UpdateTextStyle.Range.SegmentId = segmentID
+		case req.UpdateParagraphStyle != nil:
+			req.UpdateParagraphStyle.Range.SegmentId = segmentID
+		case req.CreateParagraphBullets != nil:
+			req.CreateParagraphBullets.Range.SegmentId = segmentID
+		case req.DeleteParagraphBullets != nil:
+			req.DeleteParagraphBullets.Range.SegmentId = segmentID
+		case req.DeleteContentRange != nil:
+			req.DeleteContentRange.Range.SegmentId = segmentID
+		}
+	}
+}
+
+// requestWriter generates requests that insert blocks at a fixed index.
+// Blocks are inserted in reverse order, so that each insertion pushes the
+// previously inserted content forward and we never need to know the size
+// of anything other than the block currently being inserted.
+type requestWriter struct {
+	index      int64
+	lists      []*gdocList
+	reqs       []*docs.Request
+	out        *GoogleDocRequests
+	inFootnote bool
+}
+
+func (w *requestWriter) add(req *docs.Request) {
+	w.reqs = append(w.reqs, req)
+}
+
+// blocks generates requests to insert a sequence of blocks
+func (w *requestWriter) blocks(blocks []*gdocBlock) {
+	// group consecutive paragraphs into chunks that are inserted with a
+	// single request, keeping lists separate from other paragraphs
+	// because creating bullets changes indices
+	var groups [][]*gdocBlock
+	for _, b := range blocks {
+		n := len(groups)
+		if n > 0 && b.Paragraph != nil {
+			prev := groups[n-1][len(groups[n-1])-1]
+			if prev.Paragraph != nil && sameList(prev.Paragraph.List, b.Paragraph.List) {
+				groups[n-1] = append(groups[n-1], b)
+				continue
+			}
+		}
+		groups = append(groups, []*gdocBlock{b})
+	}
+
+	for i := len(groups) - 1; i >= 0; i-- {
+		if t := groups[i][0].Table; t != nil {
+			w.table(t)
+			continue
+		}
+
+		var chunk []*gdocParagraph
+		for _, b := range groups[i] {
+			chunk = append(chunk, b.Paragraph)
+		}
+		w.paragraphs(chunk)
+	}
+}
+
+// sameList determines whether two paragraphs belong in the same chunk
+func sameList(a, b *gdocBullet) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.List == b.List
+}
+
+// objectPosition is an image or footnote together with the position at
+// which it is to be inserted
+type objectPosition struct {
+	pos   int64
+	piece *gdocPiece
+}
+
+// paragraphs generates requests to insert a sequence of paragraphs
+func (w *requestWriter) paragraphs(chunk []*gdocParagraph) {
+	start := w.index
+
+	// list paragraphs begin with one tab per nesting level, which are
+	// removed when bullets are created
+	var text strings.Builder
+	var objects []objectPosition
+	var pos int64
+	for _, p := range chunk {
+		if p.List != nil {
+			tabs := strings.Repeat("\t", p.List.Depth)
+			text.WriteString(tabs)
+			pos += int64(len(tabs))
+		}
+		for _, piece := range p.Pieces {
+			if piece.Image != "" || piece.Footnote != nil {
+				objects = append(objects, objectPosition{pos: pos, piece: piece})
+				continue
+			}
+			text.WriteString(piece.Text)
+			pos += utf16Len(piece.Text)
+		}
+		text.WriteString("\n")
+		pos++
+	}
+
+	w.add(&docs.Request{
+		InsertText: &docs.InsertTextRequest{
+			Text:     text.String(),
+			Location: &docs.Location{Index: start},
+		},
+	})
+
+	// insert images and footnotes in reverse order so that earlier positions remain valid
+	for i := len(objects) - 1; i >= 0; i-- {
+		loc := &docs.Location{Index: start + objects[i].pos}
+		piece := objects[i].piece
+		if piece.Image != "" {
+			w.add(&docs.Request{
+				InsertInlineImage: &docs.InsertInlineImageRequest{
+					Uri:      piece.Image,
+					Location: loc,
+				},
+			})
+		} else {
+			w.footnote(piece.Footnote, loc)
+		}
+	}
+
+	// create or remove bullets
+	end := start + pos + int64(len(objects))
+	if chunk[0].List != nil {
+		w.add(&docs.Request{
+			CreateParagraphBullets: &docs.CreateParagraphBulletsRequest{
+				Range:        &docs.Range{StartIndex: start, EndIndex: end},
+				BulletPreset: w.bulletPreset(chunk[0].List),
+			},
+		})
+	} else {
+		w.add(&docs.Request{
+			DeleteParagraphBullets: &docs.DeleteParagraphBulletsRequest{
+				Range: &docs.Range{StartIndex: start, EndIndex: end},
+			},
+		})
+	}
+
+	// now that tabs have been removed, apply the paragraph and text styles
+	pos = start
+	for _, p := range chunk {
+		pstart := pos
+		for _, piece := range p.Pieces {
+			n := piece.length()
+			if piece.Image == "" && piece.Footnote == nil {
+				ts, fields := piece.Style.textStyle()
+				w.add(&docs.Request{
+					UpdateTextStyle: &docs.UpdateTextStyleRequest{
+						Range:     &docs.Range{StartIndex: pos, EndIndex: pos + n},
+						TextStyle: ts,
+						Fields:    fields,
+					},
+				})
+			}
+			pos += n
+		}
+		pos++ // for the newline
+		w.paragraphStyle(p, pstart, pos)
+	}
+}
+
+// paragraphStyle generates a request to set the style for a paragraph
+func (w *requestWriter) paragraphStyle(p *gdocParagraph, start, end int64) {
+	ps := docs.ParagraphStyle{
+		NamedStyleType: p.NamedStyle,
+		Alignment:      "START",
+	}
+	if p.Centered {
+		ps.Alignment = "CENTER"
+	}
+
+	// the indentation of list items is controlled by the list
+	fields := "namedStyleType,alignment"
+	if p.List == nil {
+		ps.IndentStart = &docs.Dimension{Magnitude: p.Indent, Unit: "PT"}
+		ps.IndentFirstLine = &docs.Dimension{Magnitude: p.Indent, Unit: "PT"}
+		fields += ",indentStart,indentFirstLine"
+	}
+
+	w.add(&docs.Request{
+		UpdateParagraphStyle: &docs.UpdateParagraphStyleRequest{
+			Range:          &docs.Range{StartIndex: start, EndIndex: end},
+			ParagraphStyle: &ps,
+			Fields:         fields,
+		},
+	})
+}
+
+// bulletPreset picks the google docs bullet preset for a list
+func (w *requestWriter) bulletPreset(b *gdocBullet) string {
+	list := w.lists[b.List]
+	switch {
+	case list.Checkbox:
+		return "BULLET_CHECKBOX"
+	case list.Ordered:
+		return "NUMBERED_DECIMAL_ALPHA_ROMAN"
+	default:
+		return "BULLET_DISC_CIRCLE_SQUARE"
+	}
+}
+
+// footnote generates a request to create a footnote, and generates the
+// requests to populate it separately
+func (w *requestWriter) footnote(content []*gdocBlock, loc *docs.Location) {
+	if w.inFootnote {
+		log.Println("warning: ignoring footnote inside footnote")
+		return
+	}
+
+	createIndex := len(w.reqs)
+	w.add(&docs.Request{
+		CreateFootnote: &docs.CreateFootnoteRequest{Location: loc},
+	})
+
+	// a new footnote contains a space followed by a newline, and the
+	// content is inserted after the space
+	inner := requestWriter{index: 1, lists: w.lists, out: w.out, inFootnote: true}
+	var length int64
+	var paragraphs []*gdocBlock
+	for _, b := range content {
+		if b.Table != nil {
+			log.Println("warning: ignoring table inside footnote")
+			continue
+		}
+		paragraphs = append(paragraphs, b)
+		length += b.Paragraph.length() + 1
+	}
+	inner.blocks(paragraphs)
+
+	// remove the extra newline after the inserted content
+	if length > 0 {
+		inner.add(&docs.Request{
+			DeleteContentRange: &docs.DeleteContentRangeRequest{
+				Range: &docs.Range{StartIndex: inner.index + length - 1, EndIndex: inner.index + length},
+			},
+		})
+	}
+
+	w.out.Footnotes = append(w.out.Footnotes, &FootnoteRequests{
+		CreateIndex: createIndex,
+		Requests:    inner.reqs,
+	})
+}
+
+// table generates requests to insert a table and populate its cells
+func (w *requestWriter) table(t *gdocTable) {
+	var columns int
+	for _, row := range t.Rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	if columns == 0 {
+		return
+	}
+
+	w.add(&docs.Request{
+		InsertTable: &docs.InsertTableRequest{
+			Rows:     int64(len(t.Rows)),
+			Columns:  int64(columns),
+			Location: &docs.Location{Index: w.index},
+		},
+	})
+
+	// A newline is inserted before the table, then the table itself, each
+	// row, and each cell occupy one index, and each cell initially contains
+	// an empty paragraph. We fill the cells in reverse order so that the
+	// indices of earlier cells remain valid.
+	tableStart := w.index + 1
+	for r := len(t.Rows) - 1; r >= 0; r-- {
+		rowStart := tableStart + 1 + int64(r*(1+2*columns))
+		for c := len(t.Rows[r]) - 1; c >= 0; c-- {
+			cell := t.Rows[r][c]
+			if len(cell.Pieces) == 0 {
+				continue
+			}
+
+			inner := requestWriter{
+				index:      rowStart + 1 + int64(2*c) + 1,
+				lists:      w.lists,
+				out:        w.out,
+				inFootnote: w.inFootnote,
+			}
+			inner.cell(cell)
+			w.reqs = append(w.reqs, inner.reqs...)
+		}
+	}
+}
+
+// cell generates requests to populate a table cell, which already contains
+// a paragraph, so no newline is inserted
+func (w *requestWriter) cell(p *gdocParagraph) {
+	var text strings.Builder
+	for _, piece := range p.Pieces {
+		if piece.Image != "" || piece.Footnote != nil {
+			log.Println("warning: ignoring image or footnote inside table cell")
+			continue
+		}
+		text.WriteString(piece.Text)
+	}
+	if text.Len() == 0 {
+		return
+	}
+
+	w.add(&docs.Request{
+		InsertText: &docs.InsertTextRequest{
+			Text:     text.String(),
+			Location: &docs.Location{Index: w.index},
+		},
+	})
+
+	pos := w.index
+	for _, piece := range p.Pieces {
+		if piece.Image != "" || piece.Footnote != nil {
+			continue
+		}
+		n := utf16Len(piece.Text)
+		ts, fields := piece.Style.textStyle()
+		w.add(&docs.Request{
+			UpdateTextStyle: &docs.UpdateTextStyleRequest{
+				Range:     &docs.Range{StartIndex: pos, EndIndex: pos + n},
+				TextStyle: ts,
+				Fields:    fields,
+			},
+		})
+		pos += n
+	}
+}
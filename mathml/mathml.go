@@ -0,0 +1,827 @@
+// Package mathml converts the subset of latex math that appears in our
+// documents to MathML, which browsers render without any script
+package mathml
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxExpansions limits the number of macro expansions in one formula, so
+// that recursive macros produce an error rather than running forever
+const maxExpansions = 1000
+
+// Converter converts latex math to MathML, expanding user macros
+type Converter struct {
+	macros map[string]*macro
+}
+
+// macro is a command defined with \newcommand
+type macro struct {
+	args int
+	body []token
+}
+
+// NewConverter creates a converter with no user macros
+func NewConverter() *Converter {
+	return &Converter{macros: make(map[string]*macro)}
+}
+
+// Define defines a macro as if by \newcommand{\name}[args]{body}. The name
+// may be given with or without its backslash.
+func (c *Converter) Define(name string, args int, body string) error {
+	name = strings.TrimPrefix(name, `\`)
+	if name == "" {
+		return errors.New("macro has no name")
+	}
+	if args < 0 || args > 9 {
+		return fmt.Errorf("macro \\%s has %d arguments but at most 9 are allowed", name, args)
+	}
+	c.macros[name] = &macro{args: args, body: tokenize(body)}
+	return nil
+}
+
+// DefineMacros defines each \newcommand or \renewcommand in some latex.
+// Anything other than macro definitions is ignored.
+func (c *Converter) DefineMacros(tex string) error {
+	toks := tokenize(tex)
+	for i := 0; i < len(toks); i++ {
+		if toks[i].kind != commandToken || (toks[i].text != "newcommand" && toks[i].text != "renewcommand") {
+			continue
+		}
+		p := parser{toks: toks, pos: i + 1}
+
+		// the name may be in braces or not
+		name, err := p.arg()
+		if err != nil || len(name) != 1 || name[0].kind != commandToken {
+			return fmt.Errorf("expected a command name after \\%s", toks[i].text)
+		}
+
+		// the number of arguments is optional
+		var args int
+		if p.peekChar('[') {
+			p.pos++
+			var digits string
+			for p.pos < len(p.toks) && !p.peekChar(']') {
+				digits += p.toks[p.pos].text
+				p.pos++
+			}
+			p.pos++
+			args, err = strconv.Atoi(strings.TrimSpace(digits))
+			if err != nil {
+				return fmt.Errorf("invalid number of arguments for \\%s: %q", name[0].text, digits)
+			}
+		}
+
+		body, err := p.arg()
+		if err != nil {
+			return fmt.Errorf("expected a body for \\%s: %w", name[0].text, err)
+		}
+		if args < 0 || args > 9 {
+			return fmt.Errorf("macro \\%s has %d arguments but at most 9 are allowed", name[0].text, args)
+		}
+		c.macros[name[0].text] = &macro{args: args, body: body}
+		i = p.pos - 1
+	}
+	return nil
+}
+
+// Convert converts a latex formula, without its surrounding dollar signs,
+// to a math element. Display formulas are set on a line of their own. The
+// original latex is kept as an annotation.
+func (c *Converter) Convert(tex string, display bool) (string, error) {
+	p := parser{toks: tokenize(tex), macros: c.macros}
+	content, err := p.expr()
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.toks) {
+		return "", fmt.Errorf("unexpected %s", p.toks[p.pos])
+	}
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString(`><semantics>`)
+	b.WriteString(mrow(content))
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(tex))
+	b.WriteString(`</annotation></semantics></math>`)
+	return b.String(), nil
+}
+
+// Expand expands user macros in a latex formula, giving latex that renders
+// the same way without the macro definitions
+func (c *Converter) Expand(tex string) (string, error) {
+	p := parser{toks: tokenize(tex), macros: c.macros}
+	var out []token
+	for p.pos < len(p.toks) {
+		t := p.toks[p.pos]
+		if t.kind == commandToken && p.macros[t.text] != nil {
+			if err := p.expand(); err != nil {
+				return "", err
+			}
+			continue
+		}
+		out = append(out, t)
+		p.pos++
+	}
+	return untokenize(out), nil
+}
+
+// Kinds of token in latex source
+const (
+	charToken    = iota // a single character
+	commandToken        // a command such as \alpha, without its backslash
+	beginToken          // an opening brace
+	endToken            // a closing brace
+	paramToken          // a macro parameter such as #1, without its hash
+)
+
+type token struct {
+	kind       int
+	text       string
+	spaceAfter bool // whether whitespace follows, which matters only in text
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case commandToken:
+		return `\` + t.text
+	case beginToken:
+		return "{"
+	case endToken:
+		return "}"
+	case paramToken:
+		return "#" + t.text
+	}
+	return strconv.Quote(t.text)
+}
+
+// tokenize splits latex into tokens, dropping comments and whitespace,
+// which has no effect in math. Whitespace is noted on the token before it
+// for the sake of commands such as \text.
+func tokenize(s string) []token {
+	var toks []token
+	for len(s) > 0 {
+		r, sz := utf8.DecodeRuneInString(s)
+		s = s[sz:]
+		switch {
+		case r == '\\':
+			// a command is a backslash followed by letters, or by one other character
+			n := strings.IndexFunc(s, func(r rune) bool { return !isASCIILetter(r) })
+			if n < 0 {
+				n = len(s)
+			}
+			if n == 0 && len(s) > 0 {
+				_, n = utf8.DecodeRuneInString(s)
+			}
+			toks = append(toks, token{kind: commandToken, text: s[:n]})
+			s = s[n:]
+		case r == '{':
+			toks = append(toks, token{kind: beginToken})
+		case r == '}':
+			toks = append(toks, token{kind: endToken})
+		case r == '#' && len(s) > 0 && s[0] >= '1' && s[0] <= '9':
+			toks = append(toks, token{kind: paramToken, text: s[:1]})
+			s = s[1:]
+		case r == '%':
+			if i := strings.IndexByte(s, '\n'); i >= 0 {
+				s = s[i+1:]
+			} else {
+				s = ""
+			}
+		case unicode.IsSpace(r):
+			if len(toks) > 0 {
+				toks[len(toks)-1].spaceAfter = true
+			}
+		default:
+			toks = append(toks, token{kind: charToken, text: string(r)})
+		}
+	}
+	return toks
+}
+
+// untokenize writes tokens back out as latex, with a space wherever there
+// was whitespace and wherever a command would otherwise run into a letter
+func untokenize(toks []token) string {
+	var b strings.Builder
+	for i, t := range toks {
+		if t.kind == charToken {
+			b.WriteString(t.text)
+		} else {
+			b.WriteString(t.String())
+		}
+		letters := t.kind == commandToken && isASCIILetter(rune(t.text[0]))
+		if t.spaceAfter || (letters && i+1 < len(toks) && toks[i+1].kind == charToken && isASCIILetter(rune(toks[i+1].text[0]))) {
+			b.WriteString(" ")
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// node is a piece of converted MathML
+type node struct {
+	xml    string
+	limits bool // whether scripts go above and below rather than to the side
+}
+
+// parser converts a sequence of tokens to MathML
+type parser struct {
+	toks       []token
+	pos        int
+	macros     map[string]*macro
+	expansions int
+	variant    string // mathvariant for identifiers, set by commands such as \mathbf
+}
+
+// peekChar determines whether the next token is a particular character
+func (p *parser) peekChar(r rune) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == charToken && p.toks[p.pos].text == string(r)
+}
+
+// peekCommand determines whether the next token is one of some commands
+func (p *parser) peekCommand(names ...string) bool {
+	if p.pos >= len(p.toks) || p.toks[p.pos].kind != commandToken {
+		return false
+	}
+	for _, name := range names {
+		if p.toks[p.pos].text == name {
+			return true
+		}
+	}
+	return false
+}
+
+// arg reads a macro argument, which is a single token or a group in braces,
+// and returns its tokens without the braces
+func (p *parser) arg() ([]token, error) {
+	if p.pos >= len(p.toks) {
+		return nil, errors.New("missing argument")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	switch t.kind {
+	case endToken:
+		return nil, errors.New("missing argument")
+	case beginToken:
+		start := p.pos
+		depth := 1
+		for ; p.pos < len(p.toks); p.pos++ {
+			switch p.toks[p.pos].kind {
+			case beginToken:
+				depth++
+			case endToken:
+				depth--
+			}
+			if depth == 0 {
+				p.pos++
+				return p.toks[start : p.pos-1], nil
+			}
+		}
+		return nil, errors.New("unbalanced braces")
+	}
+	return []token{t}, nil
+}
+
+// optionalArg reads an argument in square brackets, if there is one
+func (p *parser) optionalArg() ([]token, bool) {
+	if !p.peekChar('[') {
+		return nil, false
+	}
+	start := p.pos + 1
+	for i := start; i < len(p.toks); i++ {
+		if p.toks[i].kind == charToken && p.toks[i].text == "]" {
+			p.pos = i + 1
+			return p.toks[start:i], true
+		}
+	}
+	return nil, false
+}
+
+// sub converts a sequence of tokens on its own, sharing macros with this parser
+func (p *parser) sub(toks []token, variant string) (string, error) {
+	inner := parser{toks: toks, macros: p.macros, expansions: p.expansions, variant: variant}
+	nodes, err := inner.expr()
+	p.expansions = inner.expansions
+	if err != nil {
+		return "", err
+	}
+	if inner.pos < len(inner.toks) {
+		return "", fmt.Errorf("unexpected %s", inner.toks[inner.pos])
+	}
+	return mrow(nodes), nil
+}
+
+// argXML reads an argument and converts it to MathML
+func (p *parser) argXML() (string, error) {
+	toks, err := p.arg()
+	if err != nil {
+		return "", err
+	}
+	return p.sub(toks, p.variant)
+}
+
+// expr converts tokens until the end of the input or the end of a group
+func (p *parser) expr() ([]node, error) {
+	var nodes []node
+	for p.pos < len(p.toks) {
+		t := p.toks[p.pos]
+		if t.kind == endToken || (t.kind == commandToken && (t.text == "right" || t.text == "mright")) {
+			break
+		}
+
+		n, ok, err := p.atom()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		n, err = p.scripts(n)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// scripts reads any subscript and superscript after a node
+func (p *parser) scripts(base node) (node, error) {
+	var sub, sup string
+	for p.peekChar('_') || p.peekChar('^') || p.peekChar('\'') {
+		c := p.toks[p.pos].text
+		p.pos++
+		if c == "'" {
+			sup += "<mo>′</mo>"
+			continue
+		}
+		x, err := p.scriptArg()
+		if err != nil {
+			return node{}, fmt.Errorf("after %s: %w", c, err)
+		}
+		if c == "_" {
+			if sub != "" {
+				return node{}, errors.New("double subscript")
+			}
+			sub = x
+		} else {
+			if sup != "" && !strings.HasSuffix(sup, "</mo>") {
+				return node{}, errors.New("double superscript")
+			}
+			sup += x
+		}
+	}
+	if strings.Count(sup, "<") > 2 && !strings.HasPrefix(sup, "<mrow>") {
+		sup = "<mrow>" + sup + "</mrow>"
+	}
+
+	under, over, both := "msub", "msup", "msubsup"
+	if base.limits {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != "" && sup != "":
+		return node{xml: fmt.Sprintf("<%s>%s%s%s</%s>", both, base.xml, sub, sup, both)}, nil
+	case sub != "":
+		return node{xml: fmt.Sprintf("<%s>%s%s</%s>", under, base.xml, sub, under)}, nil
+	case sup != "":
+		return node{xml: fmt.Sprintf("<%s>%s%s</%s>", over, base.xml, sup, over)}, nil
+	}
+	return base, nil
+}
+
+// scriptArg reads the argument of a subscript or superscript, which is a
+// single symbol or a group
+func (p *parser) scriptArg() (string, error) {
+	for p.pos < len(p.toks) && p.toks[p.pos].kind == commandToken && p.macros[p.toks[p.pos].text] != nil {
+		if err := p.expand(); err != nil {
+			return "", err
+		}
+	}
+	if p.pos >= len(p.toks) {
+		return "", errors.New("missing script")
+	}
+	if p.toks[p.pos].kind == beginToken {
+		return p.argXML()
+	}
+	n, ok, err := p.atom()
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errors.New("missing script")
+	}
+	return n.xml, nil
+}
+
+// expand replaces the macro at the current position with its body
+func (p *parser) expand() error {
+	name := p.toks[p.pos].text
+	m := p.macros[name]
+	p.expansions++
+	if p.expansions > maxExpansions {
+		return fmt.Errorf("too many macro expansions while expanding \\%s", name)
+	}
+	p.pos++
+
+	var args [][]token
+	for i := 0; i < m.args; i++ {
+		a, err := p.arg()
+		if err != nil {
+			return fmt.Errorf("argument %d of \\%s: %w", i+1, name, err)
+		}
+		args = append(args, a)
+	}
+
+	var expanded []token
+	for _, t := range m.body {
+		if t.kind == paramToken {
+			n, _ := strconv.Atoi(t.text)
+			if n > len(args) {
+				return fmt.Errorf("\\%s refers to #%d but has only %d arguments", name, n, len(args))
+			}
+			expanded = append(expanded, args[n-1]...)
+			continue
+		}
+		expanded = append(expanded, t)
+	}
+	p.toks = append(expanded, p.toks[p.pos:]...)
+	p.pos = 0
+	return nil
+}
+
+// atom converts a single symbol, group, or command with its arguments. The
+// result is not ok for commands that produce nothing, such as \rm.
+func (p *parser) atom() (node, bool, error) {
+	if p.pos >= len(p.toks) {
+		return node{}, false, errors.New("unexpected end of formula")
+	}
+	t := p.toks[p.pos]
+	switch t.kind {
+	case beginToken:
+		saved := p.variant
+		p.pos++
+		nodes, err := p.expr()
+		p.variant = saved
+		if err != nil {
+			return node{}, false, err
+		}
+		if p.pos >= len(p.toks) || p.toks[p.pos].kind != endToken {
+			return node{}, false, errors.New("unbalanced braces")
+		}
+		p.pos++
+		return node{xml: mrow(nodes)}, true, nil
+	case endToken:
+		return node{}, false, errors.New("unbalanced braces")
+	case paramToken:
+		return node{}, false, fmt.Errorf("unexpected #%s outside of a macro", t.text)
+	case charToken:
+		return p.char()
+	}
+
+	if p.macros[t.text] != nil {
+		return node{}, false, p.expand()
+	}
+	p.pos++
+	return p.command(t.text)
+}
+
+// char converts a character, or a run of digits
+func (p *parser) char() (node, bool, error) {
+	t := p.toks[p.pos]
+	p.pos++
+	r, _ := utf8.DecodeRuneInString(t.text)
+	switch {
+	case unicode.IsDigit(r):
+		num := t.text
+		for p.pos < len(p.toks) && p.toks[p.pos].kind == charToken {
+			next, _ := utf8.DecodeRuneInString(p.toks[p.pos].text)
+			isPoint := next == '.' && p.pos+1 < len(p.toks) && isDigitToken(p.toks[p.pos+1])
+			if !unicode.IsDigit(next) && !isPoint {
+				break
+			}
+			num += p.toks[p.pos].text
+			p.pos++
+		}
+		return node{xml: "<mn>" + num + "</mn>"}, true, nil
+	case unicode.IsLetter(r):
+		return node{xml: p.identifier(t.text, false)}, true, nil
+	case r == '~':
+		return node{xml: `<mspace width="0.25em"/>`}, true, nil
+	case r == '&':
+		return node{}, false, errors.New("alignment with & is not supported")
+	case r == '^' || r == '_':
+		return node{}, false, fmt.Errorf("%s with nothing before it", t.text)
+	}
+	if op, ok := operatorChars[r]; ok {
+		return node{xml: operator(op)}, true, nil
+	}
+	return node{xml: "<mo>" + html.EscapeString(t.text) + "</mo>"}, true, nil
+}
+
+func isDigitToken(t token) bool {
+	r, _ := utf8.DecodeRuneInString(t.text)
+	return t.kind == charToken && unicode.IsDigit(r)
+}
+
+// identifier writes an mi element in the current variant. Single letters
+// are italic unless upright is set.
+func (p *parser) identifier(s string, upright bool) string {
+	variant := p.variant
+	if variant == "" && upright && utf8.RuneCountInString(s) == 1 {
+		variant = "normal"
+	}
+	if variant == "" || (variant == "normal" && utf8.RuneCountInString(s) > 1) {
+		return "<mi>" + html.EscapeString(s) + "</mi>"
+	}
+	return fmt.Sprintf(`<mi mathvariant="%s">%s</mi>`, variant, html.EscapeString(s))
+}
+
+// operator writes an mo element
+func operator(s string) string {
+	return "<mo>" + html.EscapeString(s) + "</mo>"
+}
+
+// command converts a command and its arguments
+func (p *parser) command(name string) (node, bool, error) {
+	if s, ok := identifiers[name]; ok {
+		return node{xml: p.identifier(s, false)}, true, nil
+	}
+	if s, ok := uprightIdentifiers[name]; ok {
+		return node{xml: p.identifier(s, true)}, true, nil
+	}
+	if s, ok := operators[name]; ok {
+		return node{xml: operator(s)}, true, nil
+	}
+	if s, ok := escapedChars[name]; ok {
+		return node{xml: p.identifier(s, true)}, true, nil
+	}
+	if s, ok := largeOperators[name]; ok {
+		return node{xml: `<mo largeop="true" movablelimits="true">` + s + `</mo>`, limits: !strings.Contains(name, "int")}, true, nil
+	}
+	if limits, ok := functions[name]; ok {
+		return node{xml: "<mi>" + name + "</mi>", limits: limits}, true, nil
+	}
+	if width, ok := spaces[name]; ok {
+		return node{xml: `<mspace width="` + width + `"/>`}, true, nil
+	}
+	if variant, ok := variantSwitches[name]; ok {
+		p.variant = variant
+		return node{}, false, nil
+	}
+
+	if variant, ok := variantCommands[name]; ok {
+		toks, err := p.arg()
+		if err != nil {
+			return node{}, false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		xml, err := p.sub(toks, variant)
+		return node{xml: xml}, true, err
+	}
+	if variant, ok := textCommands[name]; ok {
+		toks, err := p.arg()
+		if err != nil {
+			return node{}, false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		attrs := ""
+		if variant != "" {
+			attrs = ` mathvariant="` + variant + `"`
+		}
+		return node{xml: "<mtext" + attrs + ">" + html.EscapeString(plainText(toks)) + "</mtext>"}, true, nil
+	}
+	if a, ok := accents[name]; ok {
+		x, err := p.argXML()
+		if err != nil {
+			return node{}, false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		if a.under {
+			return node{xml: `<munder accentunder="true">` + x + operator(a.mark) + `</munder>`}, true, nil
+		}
+		return node{xml: `<mover accent="true">` + x + operator(a.mark) + `</mover>`}, true, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, err := p.argXML()
+		if err != nil {
+			return node{}, false, fmt.Errorf("numerator of \\%s: %w", name, err)
+		}
+		den, err := p.argXML()
+		if err != nil {
+			return node{}, false, fmt.Errorf("denominator of \\%s: %w", name, err)
+		}
+		return node{xml: "<mfrac>" + num + den + "</mfrac>"}, true, nil
+
+	case "binom":
+		n, err := p.argXML()
+		if err != nil {
+			return node{}, false, fmt.Errorf("\\binom: %w", err)
+		}
+		k, err := p.argXML()
+		if err != nil {
+			return node{}, false, fmt.Errorf("\\binom: %w", err)
+		}
+		return node{xml: `<mrow><mo>(</mo><mfrac linethickness="0">` + n + k + `</mfrac><mo>)</mo></mrow>`}, true, nil
+
+	case "sqrt":
+		index, hasIndex := p.optionalArg()
+		x, err := p.argXML()
+		if err != nil {
+			return node{}, false, fmt.Errorf("\\sqrt: %w", err)
+		}
+		if hasIndex {
+			i, err := p.sub(index, p.variant)
+			if err != nil {
+				return node{}, false, err
+			}
+			return node{xml: "<mroot>" + x + i + "</mroot>"}, true, nil
+		}
+		return node{xml: "<msqrt>" + x + "</msqrt>"}, true, nil
+
+	case "left", "mleft":
+		return p.fenced(name)
+
+	case "big", "Big", "bigg", "Bigg", "bigl", "bigr", "Bigl", "Bigr", "biggl", "biggr", "Biggl", "Biggr":
+		d, err := p.delimiter()
+		if err != nil {
+			return node{}, false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		return node{xml: `<mo stretchy="true">` + html.EscapeString(d) + `</mo>`}, true, nil
+
+	case "operatorname", "operatornamewithlimits", "mathop":
+		toks, err := p.arg()
+		if err != nil {
+			return node{}, false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		return node{xml: "<mi>" + html.EscapeString(plainText(toks)) + "</mi>", limits: name != "operatorname"}, true, nil
+
+	case "kern", "mkern", "hspace", "mspace", "hskip", "mskip":
+		width, err := p.dimension()
+		if err != nil {
+			return node{}, false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		return node{xml: `<mspace width="` + width + `"/>`}, true, nil
+
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return node{}, false, nil
+
+	case "\\":
+		return node{}, false, errors.New("line breaks are not supported")
+	case "begin":
+		env, _ := p.arg()
+		return node{}, false, fmt.Errorf("the %s environment is not supported", plainText(env))
+	}
+	return node{}, false, fmt.Errorf("unsupported command \\%s", name)
+}
+
+// fenced converts \left( ... \right) to a row between stretchy delimiters
+func (p *parser) fenced(name string) (node, bool, error) {
+	open, err := p.delimiter()
+	if err != nil {
+		return node{}, false, fmt.Errorf("\\%s: %w", name, err)
+	}
+	nodes, err := p.expr()
+	if err != nil {
+		return node{}, false, err
+	}
+	if !p.peekCommand("right", "mright") {
+		return node{}, false, fmt.Errorf("\\%s without a matching \\right", name)
+	}
+	p.pos++
+	close, err := p.delimiter()
+	if err != nil {
+		return node{}, false, fmt.Errorf("\\right: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	if open != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(open) + `</mo>`)
+	}
+	for _, n := range nodes {
+		b.WriteString(n.xml)
+	}
+	if close != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(close) + `</mo>`)
+	}
+	b.WriteString("</mrow>")
+	return node{xml: b.String()}, true, nil
+}
+
+// delimiter reads the delimiter after \left, \right, or \big. The empty
+// delimiter "." gives an empty string.
+func (p *parser) delimiter() (string, error) {
+	if p.pos >= len(p.toks) {
+		return "", errors.New("missing delimiter")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	switch {
+	case t.kind == charToken && t.text == ".":
+		return "", nil
+	case t.kind == charToken:
+		return t.text, nil
+	case t.kind == commandToken:
+		if s, ok := operators[t.text]; ok {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("invalid delimiter %s", t)
+}
+
+// dimension reads a length such as -.3em or {2mu} and converts it to ems
+func (p *parser) dimension() (string, error) {
+	var s string
+	if p.pos < len(p.toks) && p.toks[p.pos].kind == beginToken {
+		toks, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		s = plainText(toks)
+	} else {
+		// read a number followed by a two-letter unit
+		for p.pos < len(p.toks) && p.toks[p.pos].kind == charToken && strings.ContainsAny(p.toks[p.pos].text, "+-.0123456789") {
+			s += p.toks[p.pos].text
+			p.pos++
+		}
+		for i := 0; i < 2 && p.pos < len(p.toks) && p.toks[p.pos].kind == charToken; i++ {
+			s += p.toks[p.pos].text
+			p.pos++
+		}
+	}
+
+	s = strings.TrimSpace(s)
+	if len(s) < 3 {
+		return "", fmt.Errorf("invalid dimension %q", s)
+	}
+	value, unit := s[:len(s)-2], s[len(s)-2:]
+	if strings.HasPrefix(value, ".") || strings.HasPrefix(value, "-.") {
+		value = strings.Replace(value, ".", "0.", 1)
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid dimension %q", s)
+	}
+	switch unit {
+	case "em", "ex", "pt", "px":
+	case "mu":
+		n, unit = n/18, "em"
+	default:
+		return "", fmt.Errorf("unsupported unit in %q", s)
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64) + unit, nil
+}
+
+// plainText gets the text of some tokens, for commands such as \text
+func plainText(toks []token) string {
+	var b strings.Builder
+	for _, t := range toks {
+		switch t.kind {
+		case charToken:
+			b.WriteString(t.text)
+		case commandToken:
+			if _, ok := spaces[t.text]; ok {
+				b.WriteString(" ")
+			} else if s, ok := escapedChars[t.text]; ok {
+				b.WriteString(s)
+			}
+		}
+		if t.spaceAfter && (t.kind != commandToken || !isASCIILetter(rune(t.text[0]))) {
+			b.WriteString(" ")
+		}
+	}
+	// leading and trailing whitespace in token elements is ignored, so use
+	// non-breaking spaces there
+	s := b.String()
+	trimmed := strings.TrimLeft(s, " ")
+	s = strings.Repeat("\u00a0", len(s)-len(trimmed)) + trimmed
+	trimmed = strings.TrimRight(s, " ")
+	return trimmed + strings.Repeat("\u00a0", len(s)-len(trimmed))
+}
+
+// mrow joins nodes into a single element
+func mrow(nodes []node) string {
+	if len(nodes) == 1 {
+		return nodes[0].xml
+	}
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	for _, n := range nodes {
+		b.WriteString(n.xml)
+	}
+	b.WriteString("</mrow>")
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package mathml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	conv := NewConverter()
+	err := conv.DefineMacros(`\newcommand{\R}{\mathbb{R}} \newcommand{\norm}[1]{\left\| #1 \right\|}`)
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		`\frac{a}{b}`:            `<mfrac><mi>a</mi><mi>b</mi></mfrac>`,
+		`x_i^2`:                  `<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>`,
+		`\alpha + \Gamma`:        `<mrow><mi>α</mi><mo>+</mo><mi mathvariant="normal">Γ</mi></mrow>`,
+		`\sum_{i=1}^n`:           `<munderover><mo largeop="true" movablelimits="true">∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover>`,
+		`\norm{x} \in \R`:        `<mrow><mrow><mo fence="true" stretchy="true">‖</mo><mi>x</mi><mo fence="true" stretchy="true">‖</mo></mrow><mo>∈</mo><mi mathvariant="double-struck">R</mi></mrow>`,
+		`\sqrt[3]{2.5}`:          `<mroot><mn>2.5</mn><mn>3</mn></mroot>`,
+		`f'(x)`:                  `<mrow><msup><mi>f</mi><mo>′</mo></msup><mo>(</mo><mi>x</mi><mo>)</mo></mrow>`,
+		`\text{if } x`:           "<mrow><mtext>if\u00a0</mtext><mi>x</mi></mrow>",
+		`\left. \frac12 \right)`: `<mrow><mfrac><mn>1</mn><mn>2</mn></mfrac><mo fence="true" stretchy="true">)</mo></mrow>`,
+	}
+	for tex, expected := range cases {
+		out, err := conv.Convert(tex, false)
+		require.NoError(t, err, tex)
+		assert.Contains(t, out, "<semantics>"+expected+"<annotation", tex)
+	}
+
+	out, err := conv.Convert(`x`, true)
+	require.NoError(t, err)
+	assert.Equal(t, `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><mi>x</mi><annotation encoding="application/x-tex">x</annotation></semantics></math>`, out)
+}
+
+func TestConvertErrors(t *testing.T) {
+	conv := NewConverter()
+	require.NoError(t, conv.Define(`\loop`, 0, `\loop`))
+
+	for _, tex := range []string{`\frac{a}`, `{x`, `\left( x`, `\unknown`, `x_1_2`, `\loop`, `x^`, `a_`, `\sqrt{x^}`, `\frac{a_}{2}`} {
+		_, err := conv.Convert(tex, false)
+		assert.Error(t, err, tex)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	conv := NewConverter()
+	require.NoError(t, conv.DefineMacros(`\newcommand{\R}{\mathbb{R}} \newcommand{\norm}[1]{\left\| #1 \right\|}`))
+
+	out, err := conv.Expand(`\norm{x} \in \R`)
+	require.NoError(t, err)
+	assert.Equal(t, `\left\| x\right\|\in \mathbb{R}`, out)
+
+	out, err = conv.Expand(`\alpha\R x`)
+	require.NoError(t, err)
+	assert.Equal(t, `\alpha\mathbb{R}x`, out)
+
+	require.NoError(t, conv.Define(`\loop`, 0, `\loop`))
+	_, err = conv.Expand(`\loop`)
+	assert.Error(t, err)
+}
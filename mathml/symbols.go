@@ -0,0 +1,316 @@
+package mathml
+
+// This file contains the tables of latex commands that stand for a single symbol
+
+// letters that are identifiers, keyed by command name. Upper case greek
+// letters are upright, as in latex.
+var identifiers = map[string]string{
+	"alpha":      "α",
+	"beta":       "β",
+	"gamma":      "γ",
+	"delta":      "δ",
+	"epsilon":    "ϵ",
+	"varepsilon": "ε",
+	"zeta":       "ζ",
+	"eta":        "η",
+	"theta":      "θ",
+	"vartheta":   "ϑ",
+	"iota":       "ι",
+	"kappa":      "κ",
+	"lambda":     "λ",
+	"mu":         "μ",
+	"nu":         "ν",
+	"xi":         "ξ",
+	"pi":         "π",
+	"varpi":      "ϖ",
+	"rho":        "ρ",
+	"varrho":     "ϱ",
+	"sigma":      "σ",
+	"varsigma":   "ς",
+	"tau":        "τ",
+	"upsilon":    "υ",
+	"phi":        "ϕ",
+	"varphi":     "φ",
+	"chi":        "χ",
+	"psi":        "ψ",
+	"omega":      "ω",
+	"ell":        "ℓ",
+	"hbar":       "ℏ",
+	"imath":      "ı",
+	"jmath":      "ȷ",
+	"wp":         "℘",
+}
+
+// symbols that are upright identifiers
+var uprightIdentifiers = map[string]string{
+	"Gamma":    "Γ",
+	"Delta":    "Δ",
+	"Theta":    "Θ",
+	"Lambda":   "Λ",
+	"Xi":       "Ξ",
+	"Pi":       "Π",
+	"Sigma":    "Σ",
+	"Upsilon":  "Υ",
+	"Phi":      "Φ",
+	"Psi":      "Ψ",
+	"Omega":    "Ω",
+	"infty":    "∞",
+	"partial":  "∂",
+	"nabla":    "∇",
+	"emptyset": "∅",
+	"aleph":    "ℵ",
+	"Re":       "ℜ",
+	"Im":       "ℑ",
+	"top":      "⊤",
+	"bot":      "⊥",
+	"prime":    "′",
+}
+
+// symbols that are operators, relations, punctuation, or delimiters
+var operators = map[string]string{
+	"pm":              "±",
+	"mp":              "∓",
+	"times":           "×",
+	"div":             "÷",
+	"cdot":            "⋅",
+	"ast":             "∗",
+	"star":            "⋆",
+	"circ":            "∘",
+	"bullet":          "∙",
+	"oplus":           "⊕",
+	"otimes":          "⊗",
+	"wedge":           "∧",
+	"land":            "∧",
+	"vee":             "∨",
+	"lor":             "∨",
+	"neg":             "¬",
+	"lnot":            "¬",
+	"cup":             "∪",
+	"cap":             "∩",
+	"setminus":        "∖",
+	"leq":             "≤",
+	"le":              "≤",
+	"geq":             "≥",
+	"ge":              "≥",
+	"neq":             "≠",
+	"ne":              "≠",
+	"ll":              "≪",
+	"gg":              "≫",
+	"approx":          "≈",
+	"sim":             "∼",
+	"simeq":           "≃",
+	"cong":            "≅",
+	"equiv":           "≡",
+	"propto":          "∝",
+	"in":              "∈",
+	"notin":           "∉",
+	"ni":              "∋",
+	"subset":          "⊂",
+	"subseteq":        "⊆",
+	"supset":          "⊃",
+	"supseteq":        "⊇",
+	"mid":             "∣",
+	"parallel":        "∥",
+	"perp":            "⊥",
+	"forall":          "∀",
+	"exists":          "∃",
+	"to":              "→",
+	"rightarrow":      "→",
+	"leftarrow":       "←",
+	"gets":            "←",
+	"leftrightarrow":  "↔",
+	"Rightarrow":      "⇒",
+	"Leftarrow":       "⇐",
+	"Leftrightarrow":  "⇔",
+	"implies":         "⟹",
+	"iff":             "⟺",
+	"mapsto":          "↦",
+	"uparrow":         "↑",
+	"downarrow":       "↓",
+	"ldots":           "…",
+	"dots":            "…",
+	"cdots":           "⋯",
+	"vdots":           "⋮",
+	"ddots":           "⋱",
+	"langle":          "⟨",
+	"rangle":          "⟩",
+	"lfloor":          "⌊",
+	"rfloor":          "⌋",
+	"lceil":           "⌈",
+	"rceil":           "⌉",
+	"lvert":           "|",
+	"rvert":           "|",
+	"vert":            "|",
+	"lVert":           "‖",
+	"rVert":           "‖",
+	"Vert":            "‖",
+	"colon":           ":",
+	"{":               "{",
+	"}":               "}",
+	"|":               "‖",
+	"lbrace":          "{",
+	"rbrace":          "}",
+	"backslash":       "∖",
+	"triangleq":       "≜",
+	"coloneqq":        "≔",
+	"therefore":       "∴",
+	"because":         "∵",
+	"angle":           "∠",
+	"dagger":          "†",
+	"leftrightarrows": "⇆",
+}
+
+// escaped characters that are ordinary identifiers
+var escapedChars = map[string]string{
+	"%": "%",
+	"$": "$",
+	"&": "&",
+	"#": "#",
+	"_": "_",
+}
+
+// large operators, whose scripts go above and below in display math
+var largeOperators = map[string]string{
+	"sum":       "∑",
+	"prod":      "∏",
+	"coprod":    "∐",
+	"int":       "∫",
+	"iint":      "∬",
+	"iiint":     "∭",
+	"oint":      "∮",
+	"bigcup":    "⋃",
+	"bigcap":    "⋂",
+	"bigoplus":  "⨁",
+	"bigotimes": "⨂",
+	"bigvee":    "⋁",
+	"bigwedge":  "⋀",
+}
+
+// named functions, and whether their scripts go above and below in
+// display math
+var functions = map[string]bool{
+	"sin":    false,
+	"cos":    false,
+	"tan":    false,
+	"cot":    false,
+	"sec":    false,
+	"csc":    false,
+	"arcsin": false,
+	"arccos": false,
+	"arctan": false,
+	"sinh":   false,
+	"cosh":   false,
+	"tanh":   false,
+	"log":    false,
+	"ln":     false,
+	"lg":     false,
+	"exp":    false,
+	"arg":    false,
+	"deg":    false,
+	"dim":    false,
+	"hom":    false,
+	"ker":    false,
+	"det":    true,
+	"gcd":    true,
+	"lim":    true,
+	"liminf": true,
+	"limsup": true,
+	"max":    true,
+	"min":    true,
+	"sup":    true,
+	"inf":    true,
+	"Pr":     true,
+}
+
+// accents written over or under their argument
+var accents = map[string]struct {
+	mark  string
+	under bool
+}{
+	"hat":            {"^", false},
+	"widehat":        {"^", false},
+	"bar":            {"¯", false},
+	"overline":       {"¯", false},
+	"tilde":          {"~", false},
+	"widetilde":      {"~", false},
+	"vec":            {"→", false},
+	"overrightarrow": {"→", false},
+	"dot":            {"˙", false},
+	"ddot":           {"¨", false},
+	"underline":      {"_", true},
+}
+
+// commands that set the style of their argument, and the mathvariant for each
+var variantCommands = map[string]string{
+	"mathrm":     "normal",
+	"mathbf":     "bold",
+	"boldsymbol": "bold-italic",
+	"bm":         "bold-italic",
+	"mathit":     "italic",
+	"mathbb":     "double-struck",
+	"mathcal":    "script",
+	"mathscr":    "script",
+	"mathfrak":   "fraktur",
+	"mathsf":     "sans-serif",
+	"mathtt":     "monospace",
+}
+
+// old-style commands that set the style of the rest of the group
+var variantSwitches = map[string]string{
+	"rm":  "normal",
+	"bf":  "bold",
+	"it":  "italic",
+	"cal": "script",
+	"sf":  "sans-serif",
+	"tt":  "monospace",
+}
+
+// commands that write text, which is not parsed as math
+var textCommands = map[string]string{
+	"text":   "",
+	"textrm": "",
+	"mbox":   "",
+	"textit": "italic",
+	"textbf": "bold",
+	"textsf": "sans-serif",
+	"texttt": "monospace",
+}
+
+// spacing commands and their widths
+var spaces = map[string]string{
+	",":            "0.1667em",
+	"thinspace":    "0.1667em",
+	":":            "0.2222em",
+	">":            "0.2222em",
+	"medspace":     "0.2222em",
+	";":            "0.2778em",
+	"thickspace":   "0.2778em",
+	"!":            "-0.1667em",
+	"negthinspace": "-0.1667em",
+	" ":            "0.25em",
+	"quad":         "1em",
+	"qquad":        "2em",
+}
+
+// characters that are operators when they appear in math
+var operatorChars = map[rune]string{
+	'+':  "+",
+	'-':  "−",
+	'*':  "∗",
+	'=':  "=",
+	'<':  "<",
+	'>':  ">",
+	'(':  "(",
+	')':  ")",
+	'[':  "[",
+	']':  "]",
+	'|':  "|",
+	'/':  "/",
+	',':  ",",
+	';':  ";",
+	':':  ":",
+	'!':  "!",
+	'?':  "?",
+	'.':  ".",
+	'\'': "′",
+}
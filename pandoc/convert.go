@@ -0,0 +1,1018 @@
+package pandoc
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/markdown"
+	"github.com/alexflint/doc-publisher/mathml"
+	"google.golang.org/api/docs/v1"
+)
+
+// Options controls the conversion of google docs to pandoc documents
+type Options struct {
+	// ImageURLByObjectID gives the URL for the image for each inline
+	// object, which may be a relative path
+	ImageURLByObjectID map[string]string
+
+	// ChartTables holds data to render as tables in place of linked
+	// charts, keyed by inline object ID. Other charts are rendered as
+	// images that link to their spreadsheet.
+	ChartTables map[string]*googledoc.Chart
+
+	// StyleRules assigns roles such as callouts and highlights to
+	// paragraphs and text according to their style. It may be nil.
+	StyleRules *googledoc.StyleRules
+}
+
+// FromGoogleDoc converts a google doc to a pandoc document with no metadata
+func FromGoogleDoc(doc *docs.Document, opts *Options) (*Document, error) {
+	return Convert(doc, doc.Body.Content, opts)
+}
+
+// Convert converts a part of a google doc to a pandoc document with no
+// metadata. Code blocks, images, and footnotes are recognized in the same
+// way as in the other converters. Latex symbols in the text become math,
+// with the macros defined by \newcommand lines expanded, since pandoc
+// writers other than latex do not understand macro definitions.
+func Convert(doc *docs.Document, elements []*docs.StructuralElement, opts *Options) (*Document, error) {
+	conv := converter{
+		doc:     doc,
+		opts:    opts,
+		anchors: markdown.HeadingAnchors(doc.Body.Content),
+		macros:  mathml.NewConverter(),
+		replace: make(map[string]string),
+	}
+
+	// macros may be used before they are defined, so find them all first
+	err := conv.defineMacros(elements)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := conv.processNested(elements)
+	if err != nil {
+		return nil, fmt.Errorf("error converting document body to pandoc: %w", err)
+	}
+	return &Document{
+		APIVersion: APIVersion,
+		Meta:       make(map[string]*Element),
+		Blocks:     nonNil(blocks),
+	}, nil
+}
+
+type converter struct {
+	doc     *docs.Document
+	opts    *Options
+	anchors map[string]string // identifiers for headings, keyed by heading ID
+	macros  *mathml.Converter
+	replace map[string]string // replacements for latex symbols containing digits
+	blockState
+}
+
+// blockState is the state of the converter within a sequence of elements,
+// which is set aside while converting footnotes and table cells
+type blockState struct {
+	frames     []*frame // open elements, outermost first, starting with the top level
+	codeBlock  bytes.Buffer
+	hidden     googledoc.HiddenBlocks // spoilers and collapsible sections opened by markers
+	inFootnote bool
+	checked    bool // whether the current paragraph is a completed checklist item
+}
+
+// Kinds of open element
+const (
+	topFrame     = iota // the sequence of elements being converted
+	wrapperFrame        // a quote, epigraph, or callout around consecutive paragraphs
+	spoilerFrame        // a spoiler
+	detailsFrame        // a collapsible section
+	listFrame           // a list, at one nesting level
+)
+
+// frame is an element that is open in the output, which is built from the
+// blocks inside it when it is closed
+type frame struct {
+	kind    int
+	role    string       // the role of a wrapper
+	summary string       // the summary of a collapsible section
+	list    string       // the kind of a list
+	items   [][]*Element // the items of a list before the current one
+	blocks  []*Element   // the blocks in the frame, or in the current item of a list
+}
+
+// defineMacros finds the \newcommand lines in a sequence of elements
+func (dc *converter) defineMacros(content []*docs.StructuralElement) error {
+	var defs strings.Builder
+	for _, elem := range content {
+		if elem.Paragraph == nil {
+			continue
+		}
+		m := newcommandRegexp.FindStringSubmatch(strings.TrimSuffix(googledoc.ParagraphText(elem.Paragraph), "\n"))
+		if m == nil {
+			continue
+		}
+		fixed := fixLatexSymbol(m[1])
+		if fixed != m[1] {
+			dc.replace[m[1]] = fixed
+		}
+		fmt.Fprintf(&defs, "\\newcommand{%s}{%s}\n", fixed, m[2])
+	}
+	err := dc.macros.DefineMacros(dc.fixSymbols(defs.String()))
+	if err != nil {
+		return fmt.Errorf("error in latex macro definitions: %w", err)
+	}
+	return nil
+}
+
+// fixSymbols renames latex symbols that contain digits
+func (dc *converter) fixSymbols(s string) string {
+	return googledoc.RenameLatexSymbols(s, dc.replace)
+}
+
+func (dc *converter) top() *frame {
+	return dc.frames[len(dc.frames)-1]
+}
+
+// emit adds blocks to the innermost open element
+func (dc *converter) emit(blocks ...*Element) {
+	f := dc.top()
+	f.blocks = append(f.blocks, blocks...)
+}
+
+// pop closes the innermost open element and adds it to the one around it
+func (dc *converter) pop() {
+	f := dc.top()
+	dc.frames = dc.frames[:len(dc.frames)-1]
+	switch f.kind {
+	case wrapperFrame:
+		if f.role == googledoc.QuoteRole {
+			dc.emit(wrap("BlockQuote", f.blocks))
+		} else {
+			dc.emit(newElement("Div", Attr{Classes: []string{f.role}}, nonNil(f.blocks)))
+		}
+	case spoilerFrame:
+		dc.emit(newElement("Div", Attr{Classes: []string{"spoiler"}}, nonNil(f.blocks)))
+	case detailsFrame:
+		attr := Attr{Classes: []string{"details"}, Attributes: [][2]string{{"summary", f.summary}}}
+		dc.emit(newElement("Div", attr, nonNil(f.blocks)))
+	case listFrame:
+		items := append(f.items, nonNil(f.blocks))
+		if f.list == googledoc.NumberedList {
+			dc.emit(newElement("OrderedList", []interface{}{1, tag("Decimal"), tag("Period")}, items))
+		} else {
+			dc.emit(newElement("BulletList", items))
+		}
+	}
+}
+
+// process converts a sequence of elements, adding them to the innermost
+// open element
+func (dc *converter) process(content []*docs.StructuralElement) error {
+	for _, elem := range content {
+		// for any element other than paragraph, close any open code block, list, or quote
+		if elem.Paragraph == nil {
+			dc.flushCodeBlock()
+			dc.closeLists(0)
+			dc.setWrapper("")
+			if !dc.hidden.InSpoiler() {
+				dc.setSpoiler(false)
+			}
+		}
+
+		switch {
+		case elem.Table != nil:
+			// tables with a single cell of monospace text are code blocks
+			if code, ok := dc.opts.StyleRules.TableCode(elem.Table); ok {
+				dc.codeBlock.WriteString(code)
+				dc.flushCodeBlock()
+				continue
+			}
+			err := dc.processTable(elem.Table)
+			if err != nil {
+				return err
+			}
+		case elem.TableOfContents != nil:
+			log.Println("warning: ignoring table of contents")
+		case elem.SectionBreak != nil:
+			// section breaks only affect page layout
+		case elem.Paragraph != nil:
+			err := dc.processParagraph(elem.Paragraph)
+			if err != nil {
+				return err
+			}
+		default:
+			log.Println("warning: encountered a body element of unknown type")
+		}
+	}
+
+	dc.flushCodeBlock()
+	dc.closeLists(0)
+	dc.setWrapper("")
+	dc.closeHiddenBlocks()
+	return nil
+}
+
+// processNested converts content that is nested inside another element,
+// such as a table cell, setting aside the state of the surrounding content
+func (dc *converter) processNested(content []*docs.StructuralElement) ([]*Element, error) {
+	saved := dc.blockState
+	dc.blockState = blockState{inFootnote: saved.inFootnote}
+	defer func() { dc.blockState = saved }()
+
+	dc.frames = []*frame{{kind: topFrame}}
+	err := dc.process(content)
+	if err != nil {
+		return nil, err
+	}
+	return dc.frames[0].blocks, nil
+}
+
+// flushCodeBlock writes any lines stored in dc.codeBlock as a code block,
+// or if there are no stored lines then it does nothing
+func (dc *converter) flushCodeBlock() {
+	if dc.codeBlock.Len() == 0 {
+		return
+	}
+
+	lang, code := googledoc.CodeLanguage(strings.ReplaceAll(dc.codeBlock.String(), "\v", "\n"))
+	var attr Attr
+	if lang != "" {
+		attr.Classes = []string{lang}
+	}
+	dc.emit(newElement("CodeBlock", attr, strings.TrimRight(code, "\n")))
+	dc.codeBlock.Reset()
+}
+
+// listDepth counts the lists that are open inside any other open element
+func (dc *converter) listDepth() int {
+	var n int
+	for i := len(dc.frames) - 1; i >= 0 && dc.frames[i].kind == listFrame; i-- {
+		n++
+	}
+	return n
+}
+
+// closeLists closes open lists until there are at most depth remaining
+func (dc *converter) closeLists(depth int) {
+	for dc.listDepth() > depth {
+		dc.pop()
+	}
+}
+
+// openItem starts a list item, opening and closing lists until the nesting
+// level of the bullet is reached. Nested lists go inside the item above them.
+func (dc *converter) openItem(b *docs.Bullet) {
+	depth := int(b.NestingLevel) + 1
+	dc.closeLists(depth)
+	if dc.listDepth() == depth {
+		f := dc.top()
+		f.items = append(f.items, nonNil(f.blocks))
+		f.blocks = nil
+	}
+	for dc.listDepth() < depth {
+		level := &docs.Bullet{ListId: b.ListId, NestingLevel: int64(dc.listDepth())}
+		dc.frames = append(dc.frames, &frame{kind: listFrame, list: googledoc.ListKind(dc.doc, level)})
+	}
+}
+
+// setWrapper closes the element around the previous paragraphs if it is
+// for a different role, and opens one for the given role
+func (dc *converter) setWrapper(role string) {
+	var current string
+	if dc.top().kind == wrapperFrame {
+		current = dc.top().role
+	}
+	if role == current {
+		return
+	}
+	if current != "" {
+		dc.pop()
+	}
+	if role != "" {
+		dc.frames = append(dc.frames, &frame{kind: wrapperFrame, role: role})
+	}
+}
+
+func (dc *converter) processParagraph(p *docs.Paragraph) error {
+	// markers for spoilers and collapsible sections are not written out
+	if marker := googledoc.ParseBlockMarker(p); marker != nil {
+		dc.processBlockMarker(marker)
+		return nil
+	}
+
+	// spoilers are either marked explicitly or assigned by style rules
+	role := dc.opts.StyleRules.BlockRole(p)
+	dc.setSpoiler(dc.hidden.InSpoiler() || role == googledoc.SpoilerRole)
+
+	// deal with code blocks
+	if role == googledoc.CodeRole || (role == "" && p.Bullet == nil && dc.opts.StyleRules.IsCode(p)) {
+		// code that is indented after a list item belongs to that item
+		if dc.codeBlock.Len() == 0 && !googledoc.IsIndented(p) {
+			dc.closeLists(0)
+		}
+		dc.setWrapper("")
+		for _, el := range p.Elements {
+			if el.TextRun != nil {
+				dc.codeBlock.WriteString(el.TextRun.Content)
+			}
+		}
+		return nil
+	}
+
+	// if not a code block then flush any buffered code block
+	dc.flushCodeBlock()
+
+	// macro definitions have already been dealt with
+	text := strings.TrimSuffix(googledoc.ParagraphText(p), "\n")
+	if newcommandRegexp.MatchString(text) {
+		return nil
+	}
+
+	// empty paragraphs are only there for spacing
+	if strings.TrimSpace(text) == "" && !googledoc.HasObjects(p) {
+		return nil
+	}
+
+	namedStyle := p.ParagraphStyle.NamedStyleType
+	wrapper := role
+	switch {
+	case role == googledoc.SpoilerRole:
+		wrapper = ""
+		namedStyle = "NORMAL_TEXT"
+	case role != "":
+		namedStyle = "NORMAL_TEXT"
+	case googledoc.IsIndented(p) && p.Bullet == nil:
+		wrapper = googledoc.QuoteRole
+	}
+	level := googledoc.HeadingLevel(namedStyle)
+	if namedStyle == "TITLE" {
+		level = 1
+	}
+
+	// deal with list items, which stay open until the next item so that
+	// nested lists can go inside them
+	if p.Bullet != nil && level > 0 {
+		log.Println("warning: found a heading that is part of a bulleted list, ignoring the bullet")
+	}
+	if p.Bullet != nil && level == 0 {
+		dc.setWrapper("")
+		dc.openItem(p.Bullet)
+
+		// pandoc represents checkboxes as characters at the start of the item
+		var inlines []*Element
+		if googledoc.ListKind(dc.doc, p.Bullet) == googledoc.Checklist {
+			dc.checked = googledoc.IsChecked(p)
+			if dc.checked {
+				inlines = append(inlines, str("☒"), space())
+			} else {
+				inlines = append(inlines, str("☐"), space())
+			}
+		}
+		content, err := dc.processElements(p)
+		dc.checked = false
+		if err != nil {
+			return err
+		}
+		dc.emit(plain(append(inlines, content...)))
+		return nil
+	}
+	dc.closeLists(0)
+	dc.setWrapper(wrapper)
+
+	// paragraphs that contain only a horizontal rule or only an image
+	// stand on their own
+	if googledoc.IsRule(p) {
+		dc.emit(tag("HorizontalRule"))
+		return nil
+	}
+	if id, ok := googledoc.FigureObject(p); ok && level == 0 {
+		return dc.processFigure(id)
+	}
+
+	inlines, err := dc.processElements(p)
+	if err != nil {
+		return err
+	}
+	switch {
+	case level > 0:
+		var attr Attr
+		if anchor, ok := dc.anchors[p.ParagraphStyle.HeadingId]; ok && !dc.inFootnote {
+			attr.ID = anchor
+		}
+		if namedStyle == "TITLE" {
+			attr.Classes = []string{"title"}
+		}
+		dc.emit(newElement("Header", level, attr, nonNil(inlines)))
+	case namedStyle == "SUBTITLE":
+		dc.emit(newElement("Div", Attr{Classes: []string{"subtitle"}}, []*Element{para(inlines)}))
+	case p.ParagraphStyle.Alignment == "CENTER":
+		dc.emit(newElement("Div", Attr{Classes: []string{"center"}}, []*Element{para(inlines)}))
+	default:
+		dc.emit(para(inlines))
+	}
+	return nil
+}
+
+// processBlockMarker opens or closes a spoiler or collapsible section
+func (dc *converter) processBlockMarker(m *googledoc.BlockMarker) {
+	dc.flushCodeBlock()
+	switch dc.hidden.Apply(m) {
+	case googledoc.OpenSpoiler:
+		dc.setSpoiler(true)
+	case googledoc.CloseSpoiler:
+		dc.setSpoiler(false)
+	case googledoc.OpenDetails:
+		dc.closeLists(0)
+		dc.setWrapper("")
+		dc.frames = append(dc.frames, &frame{kind: detailsFrame, summary: m.Summary})
+	case googledoc.CloseDetails:
+		// the section may already have been closed along with a spoiler around it
+		if i := dc.innermost(detailsFrame); i >= 0 {
+			for len(dc.frames) > i {
+				dc.pop()
+			}
+		}
+	}
+}
+
+// innermost gets the position of the innermost open element of a kind, or -1
+func (dc *converter) innermost(kind int) int {
+	for i := len(dc.frames) - 1; i >= 0; i-- {
+		if dc.frames[i].kind == kind {
+			return i
+		}
+	}
+	return -1
+}
+
+// setSpoiler starts or ends a spoiler block
+func (dc *converter) setSpoiler(on bool) {
+	i := dc.innermost(spoilerFrame)
+	if on == (i >= 0) {
+		return
+	}
+	dc.flushCodeBlock()
+	dc.closeLists(0)
+	dc.setWrapper("")
+	if on {
+		dc.frames = append(dc.frames, &frame{kind: spoilerFrame})
+		return
+	}
+	for len(dc.frames) > i {
+		dc.pop()
+	}
+}
+
+// closeHiddenBlocks closes any spoilers and collapsible sections that were
+// not closed explicitly
+func (dc *converter) closeHiddenBlocks() {
+	dc.hidden.Close()
+	dc.setSpoiler(false)
+	for len(dc.frames) > 1 {
+		dc.pop()
+	}
+}
+
+// processElements converts the content of a paragraph to inlines
+func (dc *converter) processElements(p *docs.Paragraph) ([]*Element, error) {
+	var out []*Element
+	for _, el := range p.Elements {
+		switch {
+		case el.ColumnBreak != nil:
+			log.Println("warning: ignoring column break")
+		case el.Equation != nil:
+			log.Println("warning: ignoring equation")
+		case el.FootnoteReference != nil:
+			note, err := dc.processFootnote(el.FootnoteReference.FootnoteId)
+			if err != nil {
+				return nil, err
+			}
+			if note != nil {
+				out = append(out, note)
+			}
+		case el.AutoText != nil:
+			log.Println("warning: ignoring auto text")
+		case el.HorizontalRule != nil:
+			log.Println("warning: ignoring horizontal rule within text")
+		case el.InlineObjectElement != nil:
+			out = append(out, dc.processInlineObject(el.InlineObjectElement)...)
+		case el.PageBreak != nil:
+			// page breaks are left to the output format
+		case el.TextRun != nil:
+			out = append(out, dc.processTextRun(el.TextRun)...)
+		default:
+			log.Println("warning: encountered a paragraph element of unknown type")
+		}
+	}
+	return out, nil
+}
+
+// processFootnote converts a footnote to a note, which pandoc keeps at the
+// point where it is referenced
+func (dc *converter) processFootnote(id string) (*Element, error) {
+	footnote, ok := dc.doc.Footnotes[id]
+	if !ok {
+		log.Println("warning: could not find footnote", id)
+		return nil, nil
+	}
+
+	saved := dc.inFootnote
+	dc.inFootnote = true
+	defer func() { dc.inFootnote = saved }()
+	blocks, err := dc.processNested(footnote.Content)
+	if err != nil {
+		return nil, fmt.Errorf("error converting footnote %s: %w", id, err)
+	}
+	return newElement("Note", nonNil(blocks)), nil
+}
+
+func (dc *converter) embeddedObject(id string) (*docs.EmbeddedObject, bool) {
+	obj, ok := dc.doc.InlineObjects[id]
+	if !ok || obj.InlineObjectProperties == nil || obj.InlineObjectProperties.EmbeddedObject == nil {
+		log.Println("warning: could not find inline object for id", id)
+		return nil, false
+	}
+	return obj.InlineObjectProperties.EmbeddedObject, true
+}
+
+// image gets the image element for an inline object
+func (dc *converter) image(id string, emb *docs.EmbeddedObject) (*Element, bool) {
+	src, ok := dc.opts.ImageURLByObjectID[id]
+	if !ok {
+		return nil, false
+	}
+	alt := emb.Description
+	if alt == "" {
+		alt = emb.Title
+	}
+	return newElement("Image", Attr{}, nonNil(words(alt)), []string{src, emb.Title}), true
+}
+
+// link wraps inlines in a link
+func link(url string, inlines []*Element) *Element {
+	return newElement("Link", Attr{}, nonNil(inlines), []string{url, ""})
+}
+
+// processFigure converts an image or chart that stands on its own
+func (dc *converter) processFigure(id string) error {
+	emb, ok := dc.embeddedObject(id)
+	if !ok {
+		return nil
+	}
+	if emb.LinkedContentReference != nil {
+		return dc.processChart(id, emb)
+	}
+	img, ok := dc.image(id, emb)
+	if !ok {
+		log.Println("warning: no image for inline object", id)
+		return nil
+	}
+	dc.emit(para([]*Element{img}))
+	return nil
+}
+
+// processInlineObject converts an image that appears within text
+func (dc *converter) processInlineObject(objRef *docs.InlineObjectElement) []*Element {
+	id := objRef.InlineObjectId
+	emb, ok := dc.embeddedObject(id)
+	if !ok {
+		return nil
+	}
+
+	img, ok := dc.image(id, emb)
+	source := googledoc.ChartURL(emb.LinkedContentReference)
+	switch {
+	case !ok && source == "":
+		log.Println("warning: no image for inline object", id)
+		return nil
+	case !ok:
+		return []*Element{link(source, words(googledoc.ChartTitle(emb.Title)))}
+	case source == "":
+		return []*Element{img}
+	default:
+		return []*Element{link(source, []*Element{img})}
+	}
+}
+
+// processChart converts a chart linked from a google sheet to a table of
+// its data, or to an image that links to the spreadsheet
+func (dc *converter) processChart(id string, emb *docs.EmbeddedObject) error {
+	source := googledoc.ChartURL(emb.LinkedContentReference)
+	if chart, ok := dc.opts.ChartTables[id]; ok && len(chart.Rows) > 0 {
+		var columns int
+		for _, row := range chart.Rows {
+			if len(row) > columns {
+				columns = len(row)
+			}
+		}
+		var rows [][]interface{}
+		for _, row := range chart.Rows {
+			var cells []interface{}
+			for j := 0; j < columns; j++ {
+				var text string
+				if j < len(row) {
+					text = row[j]
+				}
+				cells = append(cells, cell(1, 1, []*Element{plain(words(text))}))
+			}
+			rows = append(rows, cells)
+		}
+		caption := para([]*Element{link(chart.URL, words(googledoc.ChartTitle(chart.Title)))})
+		dc.emit(newElement("Div", Attr{Classes: []string{"chart"}}, []*Element{table(rows, columns), caption}))
+		return nil
+	}
+
+	img, ok := dc.image(id, emb)
+	switch {
+	case !ok && source == "":
+		log.Println("warning: ignoring linked content with no image", id)
+	case !ok:
+		log.Println("warning: no image for linked chart, writing a link instead", id)
+		dc.emit(para([]*Element{link(source, words(googledoc.ChartTitle(emb.Title)))}))
+	case source == "":
+		dc.emit(para([]*Element{img}))
+	default:
+		dc.emit(para([]*Element{link(source, []*Element{img})}))
+	}
+	return nil
+}
+
+func (dc *converter) processTextRun(t *docs.TextRun) []*Element {
+	// the newline at the end of each paragraph is dealt with by the caller
+	content := strings.TrimSuffix(t.Content, "\n")
+	if content == "" {
+		return nil
+	}
+
+	// the elements that wrap the text, outermost first
+	var wrappers []func([]*Element) *Element
+	style := t.TextStyle
+	if style.Link != nil {
+		url := dc.linkURL(style.Link)
+		wrappers = append(wrappers, func(inlines []*Element) *Element { return link(url, inlines) })
+	}
+	simple := func(t string) func([]*Element) *Element {
+		return func(inlines []*Element) *Element { return wrap(t, inlines) }
+	}
+	span := func(class string) func([]*Element) *Element {
+		return func(inlines []*Element) *Element {
+			return newElement("Span", Attr{Classes: []string{class}}, nonNil(inlines))
+		}
+	}
+	if style.Bold {
+		wrappers = append(wrappers, simple("Strong"))
+	}
+	if style.Italic {
+		wrappers = append(wrappers, simple("Emph"))
+	}
+	if style.Strikethrough && !dc.checked {
+		wrappers = append(wrappers, simple("Strikeout"))
+	}
+	if style.Underline && style.Link == nil {
+		wrappers = append(wrappers, simple("Underline"))
+	}
+	if style.SmallCaps {
+		wrappers = append(wrappers, simple("SmallCaps"))
+	}
+	switch style.BaselineOffset {
+	case "SUBSCRIPT":
+		wrappers = append(wrappers, simple("Subscript"))
+	case "SUPERSCRIPT":
+		wrappers = append(wrappers, simple("Superscript"))
+	}
+
+	code := dc.opts.StyleRules.IsMonospace(style.WeightedFontFamily)
+	switch dc.opts.StyleRules.InlineRole(t) {
+	case googledoc.HighlightRole:
+		wrappers = append(wrappers, span("mark"))
+	case googledoc.SpoilerRole:
+		wrappers = append(wrappers, span("spoiler"))
+	case googledoc.CodeRole:
+		code = true
+	}
+
+	// keep whitespace outside of the elements
+	left, middle, right := splitSpace(content)
+	var inlines []*Element
+	if code {
+		inlines = []*Element{newElement("Code", Attr{}, strings.ReplaceAll(middle, "\v", "\n"))}
+	} else {
+		inlines = dc.text(middle)
+	}
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		inlines = []*Element{wrappers[i](inlines)}
+	}
+
+	var out []*Element
+	out = append(out, dc.text(left)...)
+	out = append(out, inlines...)
+	return append(out, dc.text(right)...)
+}
+
+// linkURL determines the URL for a link, pointing links to headings in
+// this document at their identifiers
+func (dc *converter) linkURL(link *docs.Link) string {
+	headingID := link.HeadingId
+	if headingID == "" && dc.doc.DocumentId != "" && googledoc.DocIDFromURL(link.Url) == dc.doc.DocumentId {
+		if i := strings.Index(link.Url, "#heading="); i >= 0 {
+			headingID = link.Url[i+len("#heading="):]
+		}
+	}
+	if headingID == "" {
+		if link.Url == "" && link.BookmarkId != "" {
+			log.Printf("warning: links to bookmarks are not supported (bookmark %s)", link.BookmarkId)
+		}
+		return link.Url
+	}
+	if anchor, ok := dc.anchors[headingID]; ok {
+		return "#" + anchor
+	}
+	log.Printf("warning: no heading found for link to %s", headingID)
+	return link.Url
+}
+
+// text converts text to words and spaces. As in the other converters, a
+// backslash followed by letters is treated as a latex symbol, and becomes
+// math together with any arguments in braces and any subscripts and
+// superscripts that follow.
+func (dc *converter) text(s string) []*Element {
+	var out []*Element
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			out = append(out, str(word.String()))
+			word.Reset()
+		}
+	}
+	for len(s) > 0 {
+		r, sz := utf8.DecodeRuneInString(s)
+		if r == '\\' {
+			if n := mathLength(s); n > 0 {
+				flush()
+				out = append(out, dc.math(s[:n]))
+				s = s[n:]
+				continue
+			}
+		}
+		s = s[sz:]
+
+		switch {
+		case r == '\v':
+			// google docs uses vertical tabs for line breaks within a paragraph
+			flush()
+			out = append(out, lineBreak())
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+			if n := len(out); n > 0 && (out[n-1].Type == "Space" || out[n-1].Type == "LineBreak") {
+				continue
+			}
+			out = append(out, space())
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return out
+}
+
+// words converts plain text, in which there is no math, to words and spaces
+func words(s string) []*Element {
+	var out []*Element
+	for i, w := range strings.Fields(s) {
+		if i > 0 {
+			out = append(out, space())
+		}
+		out = append(out, str(w))
+	}
+	return out
+}
+
+// math converts a latex formula to inline math, expanding macros
+func (dc *converter) math(tex string) *Element {
+	tex = dc.fixSymbols(tex)
+	expanded, err := dc.macros.Expand(tex)
+	if err != nil {
+		log.Printf("warning: could not expand macros in %q: %v", tex, err)
+		expanded = tex
+	}
+	return newElement("Math", tag("InlineMath"), expanded)
+}
+
+// processTable converts a table, with the first row as the header row if
+// there is more than one row. Merged cells become cells that span several
+// rows or columns.
+func (dc *converter) processTable(t *docs.Table) error {
+	covered := make(map[[2]int]bool) // cells hidden by merged cells above or to the left
+	var rows [][]interface{}
+	for i, row := range t.TableRows {
+		var cells []interface{}
+		for j, c := range row.TableCells {
+			if covered[[2]int{i, j}] {
+				continue
+			}
+
+			rowspan, colspan := int64(1), int64(1)
+			if s := c.TableCellStyle; s != nil {
+				if s.RowSpan > 1 {
+					rowspan = s.RowSpan
+				}
+				if s.ColumnSpan > 1 {
+					colspan = s.ColumnSpan
+				}
+			}
+			for r := 0; r < int(rowspan); r++ {
+				for c := 0; c < int(colspan); c++ {
+					covered[[2]int{i + r, j + c}] = true
+				}
+			}
+
+			blocks, err := dc.processCell(c.Content)
+			if err != nil {
+				return err
+			}
+			cells = append(cells, cell(rowspan, colspan, blocks))
+		}
+		rows = append(rows, cells)
+	}
+	columns := int(t.Columns)
+	if columns == 0 && len(t.TableRows) > 0 {
+		columns = len(t.TableRows[0].TableCells)
+	}
+	dc.emit(table(rows, columns))
+	return nil
+}
+
+// processCell converts the content of a table cell. A cell with a single
+// paragraph of ordinary text becomes plain text.
+func (dc *converter) processCell(content []*docs.StructuralElement) ([]*Element, error) {
+	// drop the empty paragraph that follows nested tables
+	if n := len(content); n > 1 && content[n-1].Paragraph != nil && strings.TrimSpace(googledoc.ParagraphText(content[n-1].Paragraph)) == "" {
+		content = content[:n-1]
+	}
+
+	if len(content) == 1 && content[0].Paragraph != nil {
+		p := content[0].Paragraph
+		role := dc.opts.StyleRules.BlockRole(p)
+		plainText := p.ParagraphStyle.NamedStyleType == "NORMAL_TEXT" && p.Bullet == nil && !googledoc.IsIndented(p)
+		if plainText && role == "" && !dc.opts.StyleRules.IsCode(p) && googledoc.ParseBlockMarker(p) == nil {
+			inlines, err := dc.processElements(p)
+			if err != nil {
+				return nil, err
+			}
+			return []*Element{plain(inlines)}, nil
+		}
+	}
+	return dc.processNested(content)
+}
+
+// cell creates a table cell
+func cell(rowspan, colspan int64, blocks []*Element) []interface{} {
+	return []interface{}{Attr{}, tag("AlignDefault"), rowspan, colspan, nonNil(blocks)}
+}
+
+// table creates a table from rows of cells, with the first row as the
+// header if there is more than one row
+func table(rows [][]interface{}, columns int) *Element {
+	colspecs := []interface{}{}
+	for i := 0; i < columns; i++ {
+		colspecs = append(colspecs, []interface{}{tag("AlignDefault"), tag("ColWidthDefault")})
+	}
+
+	head := []interface{}{}
+	body := []interface{}{}
+	for i, cells := range rows {
+		if cells == nil {
+			cells = []interface{}{}
+		}
+		row := []interface{}{Attr{}, cells}
+		if i == 0 && len(rows) > 1 {
+			head = append(head, row)
+		} else {
+			body = append(body, row)
+		}
+	}
+
+	caption := []interface{}{nil, []*Element{}}
+	return newElement("Table",
+		Attr{},
+		caption,
+		colspecs,
+		[]interface{}{Attr{}, head},
+		[]interface{}{[]interface{}{Attr{}, 0, []interface{}{}, body}},
+		[]interface{}{Attr{}, []interface{}{}})
+}
+
+// mathLength gets the length of the latex at the start of a string, which
+// is a backslash and a symbol name followed by any number of groups in
+// braces and scripts. It returns zero if the string does not start with a
+// latex symbol.
+func mathLength(s string) int {
+	n := symbolLength(s)
+	if n == 0 {
+		return 0
+	}
+	for n < len(s) {
+		switch s[n] {
+		case '{':
+			m := groupLength(s[n:])
+			if m == 0 {
+				return n
+			}
+			n += m
+		case '_', '^':
+			rest := s[n+1:]
+			m := groupLength(rest)
+			if m == 0 {
+				m = symbolLength(rest)
+			}
+			if m == 0 {
+				r, sz := utf8.DecodeRuneInString(rest)
+				if unicode.IsLetter(r) || unicode.IsNumber(r) {
+					m = sz
+				}
+			}
+			if m == 0 {
+				return n
+			}
+			n += 1 + m
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// symbolLength gets the length of a backslash followed by letters and
+// numbers at the start of a string, or zero if there is none
+func symbolLength(s string) int {
+	if !strings.HasPrefix(s, "\\") {
+		return 0
+	}
+	next, _ := utf8.DecodeRuneInString(s[1:])
+	if !unicode.IsLetter(next) {
+		return 0
+	}
+	end := strings.IndexFunc(s[1:], func(r rune) bool {
+		return !unicode.IsNumber(r) && !unicode.IsLetter(r)
+	})
+	if end < 0 {
+		return len(s)
+	}
+	return end + 1
+}
+
+// groupLength gets the length of a group in balanced braces at the start
+// of a string, or zero if there is none
+func groupLength(s string) int {
+	if !strings.HasPrefix(s, "{") {
+		return 0
+	}
+	var depth int
+	for i, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '\n', '\v':
+			return 0
+		}
+	}
+	return 0
+}
+
+// splitSpace splits a string into leading whitespace, trailing
+// whitespace, and everything inbetween
+func splitSpace(s string) (left, middle, right string) {
+	trimmed := strings.TrimLeftFunc(s, unicode.IsSpace)
+	left = s[:len(s)-len(trimmed)]
+	middle = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	right = trimmed[len(middle):]
+	return
+}
+
+// a regular expression for latex \newcommand lines
+var newcommandRegexp = regexp.MustCompile(`^\\newcommand\{(.+?)\}\{(.*)\}$`)
+
+// fixLatexSymbol changes \T1 to \Tone and so forth, because latex does not permit numbers in symbols
+var fixLatexSymbol = strings.NewReplacer(
+	"0", "zero",
+	"1", "one",
+	"2", "two",
+	"3", "three",
+	"4", "four",
+	"5", "five",
+	"6", "six",
+	"7", "seven",
+	"8", "eight",
+	"9", "nine",
+).Replace
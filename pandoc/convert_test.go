@@ -0,0 +1,163 @@
+package pandoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/html"
+	"github.com/alexflint/doc-publisher/internal/doctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/docs/v1"
+)
+
+var courier = &docs.TextStyle{WeightedFontFamily: &docs.WeightedFontFamily{FontFamily: "Courier New"}}
+
+// testDocument creates a document with a heading, a link, a list, an image,
+// and a code block
+func testDocument() *docs.Document {
+	heading := doctest.Paragraph(1, "HEADING_1", nil, &docs.TextRun{Content: "Intro\n"})
+	heading.Paragraph.ParagraphStyle.HeadingId = "h.1"
+	image := doctest.Paragraph(40, "NORMAL_TEXT", nil, &docs.TextRun{Content: "\n"})
+	image.Paragraph.Elements = append([]*docs.ParagraphElement{{InlineObjectElement: &docs.InlineObjectElement{InlineObjectId: "img"}}}, image.Paragraph.Elements...)
+
+	return &docs.Document{
+		Body: &docs.Body{Content: []*docs.StructuralElement{
+			heading,
+			doctest.Paragraph(7, "NORMAL_TEXT", nil,
+				&docs.TextRun{Content: "See "},
+				&docs.TextRun{Content: "above", TextStyle: &docs.TextStyle{Bold: true, Link: &docs.Link{HeadingId: "h.1"}}},
+				&docs.TextRun{Content: " for more\n"}),
+			doctest.Paragraph(26, "NORMAL_TEXT", &docs.Bullet{ListId: "l"}, &docs.TextRun{Content: "one\n"}),
+			doctest.Paragraph(30, "NORMAL_TEXT", &docs.Bullet{ListId: "l", NestingLevel: 1}, &docs.TextRun{Content: "two\n"}),
+			image,
+			doctest.Paragraph(42, "NORMAL_TEXT", nil, &docs.TextRun{Content: "lang: go\n", TextStyle: courier}),
+			doctest.Paragraph(51, "NORMAL_TEXT", nil, &docs.TextRun{Content: "x := 1\n", TextStyle: courier}),
+		}},
+		Lists: map[string]docs.List{
+			"l": {ListProperties: &docs.ListProperties{NestingLevels: []*docs.NestingLevel{
+				{GlyphSymbol: "●"},
+				{GlyphType: "DECIMAL"},
+			}}},
+		},
+		InlineObjects: map[string]docs.InlineObject{
+			"img": {InlineObjectProperties: &docs.InlineObjectProperties{EmbeddedObject: &docs.EmbeddedObject{
+				Description:     "A cat",
+				ImageProperties: &docs.ImageProperties{},
+			}}},
+		},
+	}
+}
+
+func TestFromGoogleDoc(t *testing.T) {
+	doc := testDocument()
+	out, err := FromGoogleDoc(doc, &Options{ImageURLByObjectID: map[string]string{"img": "images/cat.png"}})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, out))
+	assert.JSONEq(t, `{"pandoc-api-version": [1, 23, 1], "meta": {}, "blocks": [
+		{"t": "Header", "c": [1, ["intro", [], []], [{"t": "Str", "c": "Intro"}]]},
+		{"t": "Para", "c": [
+			{"t": "Str", "c": "See"}, {"t": "Space"},
+			{"t": "Link", "c": [["", [], []], [{"t": "Strong", "c": [{"t": "Str", "c": "above"}]}], ["#intro", ""]]},
+			{"t": "Space"}, {"t": "Str", "c": "for"}, {"t": "Space"}, {"t": "Str", "c": "more"}]},
+		{"t": "BulletList", "c": [[
+			{"t": "Plain", "c": [{"t": "Str", "c": "one"}]},
+			{"t": "OrderedList", "c": [[1, {"t": "Decimal"}, {"t": "Period"}], [[{"t": "Plain", "c": [{"t": "Str", "c": "two"}]}]]]}
+		]]},
+		{"t": "Para", "c": [{"t": "Image", "c": [["", [], []], [{"t": "Str", "c": "A"}, {"t": "Space"}, {"t": "Str", "c": "cat"}], ["images/cat.png", ""]]}]},
+		{"t": "CodeBlock", "c": [["", ["go"], []], "x := 1"]}
+	]}`, buf.String())
+
+	// the document can be read back
+	d, err := Read(&buf)
+	require.NoError(t, err)
+	assert.Len(t, d.Blocks, 5)
+}
+
+func TestMacros(t *testing.T) {
+	doc := &docs.Document{Body: &docs.Body{Content: []*docs.StructuralElement{
+		doctest.Paragraph(1, "NORMAL_TEXT", nil, &docs.TextRun{Content: "\\newcommand{\\R1}{\\mathbb{R}}\n"}),
+		doctest.Paragraph(30, "NORMAL_TEXT", nil, &docs.TextRun{Content: "where x \\in \\R1\n"}),
+	}}}
+	out, err := FromGoogleDoc(doc, &Options{})
+	require.NoError(t, err)
+
+	// the definition is dropped and the macro is expanded
+	require.Len(t, out.Blocks, 1)
+	buf, err := json.Marshal(out.Blocks[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"t": "Para", "c": [
+		{"t": "Str", "c": "where"}, {"t": "Space"}, {"t": "Str", "c": "x"}, {"t": "Space"},
+		{"t": "Math", "c": [{"t": "InlineMath"}, "\\in"]}, {"t": "Space"},
+		{"t": "Math", "c": [{"t": "InlineMath"}, "\\mathbb{R}"]}]}`, string(buf))
+}
+
+func TestToGoogleDoc(t *testing.T) {
+	doc := testDocument()
+	urls := map[string]string{"img": "images/cat.png"}
+	expected, err := html.FromGoogleDoc(doc, &html.Options{ImageURLByObjectID: urls})
+	require.NoError(t, err)
+
+	// converting to pandoc and back gives the same html
+	d, err := FromGoogleDoc(doc, &Options{ImageURLByObjectID: urls})
+	require.NoError(t, err)
+	roundTrip, err := ToGoogleDoc(d, doc, urls)
+	require.NoError(t, err)
+	actual, err := html.FromGoogleDoc(roundTrip, &html.Options{ImageURLByObjectID: urls, StyleRules: RoleRules})
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	// the original image and heading are kept
+	assert.Contains(t, roundTrip.InlineObjects, "img")
+	assert.Equal(t, "h.1", roundTrip.Body.Content[0].Paragraph.ParagraphStyle.HeadingId)
+}
+
+func TestToGoogleDocRoles(t *testing.T) {
+	d := &Document{Blocks: []*Element{
+		newElement("Div", Attr{Classes: []string{googledoc.CalloutRole}}, []*Element{para(words("Take care"))}),
+		para([]*Element{
+			newElement("Span", Attr{Classes: []string{"mark"}}, words("marked")),
+			space(),
+			newElement("Image", Attr{}, words("new"), []string{"images/new.png", ""}),
+		}),
+		newElement("BulletList", [][]*Element{
+			{plain([]*Element{str("☒"), space(), str("done")})},
+			{plain([]*Element{str("☐"), space(), str("todo")})},
+		}),
+	}}
+	urls := make(map[string]string)
+	doc, err := ToGoogleDoc(d, &docs.Document{}, urls)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pandoc.image.1": "images/new.png"}, urls)
+
+	out, err := html.FromGoogleDoc(doc, &html.Options{ImageURLByObjectID: urls, StyleRules: RoleRules})
+	require.NoError(t, err)
+	assert.Contains(t, out, `<aside class="callout">`)
+	assert.Contains(t, out, `<mark>marked</mark>`)
+	assert.Contains(t, out, `<img src="images/new.png" alt="new">`)
+	assert.Contains(t, out, `<input type="checkbox" disabled checked> done`)
+}
+
+func TestMeta(t *testing.T) {
+	draft := true
+	settings := googledoc.Settings{Title: "On *Cats*", Tags: []string{"cats", "dogs"}, Draft: &draft}
+	meta, err := Meta(&settings, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	require.NoError(t, err)
+
+	buf, err := json.Marshal(meta["date"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"t": "MetaInlines", "c": [{"t": "Str", "c": "2021-03-04"}]}`, string(buf))
+
+	// a filter might change the title
+	meta["title"] = newElement("MetaInlines", []*Element{str("On"), space(), wrap("Emph", words("Dogs"))})
+	var updated googledoc.Settings
+	require.NoError(t, ApplyMeta(meta, &updated))
+	assert.Equal(t, "On Dogs", updated.Title)
+	assert.Equal(t, settings.Tags, updated.Tags)
+	assert.Equal(t, &draft, updated.Draft)
+}
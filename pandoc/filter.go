@@ -0,0 +1,57 @@
+package pandoc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// FilterPandocVersion is the version of pandoc that filters are told they
+// are being run by, in the PANDOC_VERSION environment variable
+const FilterPandocVersion = "3.1"
+
+// Filter runs a pandoc filter on a document, in the same way as pandoc
+// does: the document is written to the standard input of the program as
+// JSON, the name of the output format is passed as its only argument, and
+// the filtered document is read from its standard output. The path is the
+// path to the program, which may contain spaces, and is not split into
+// words.
+func Filter(ctx context.Context, d *Document, path, format string) (*Document, error) {
+	if path == "" {
+		return nil, errors.New("filter path is empty")
+	}
+
+	var in, out bytes.Buffer
+	err := Write(&in, d)
+	if err != nil {
+		return nil, err
+	}
+
+	// like pandoc, look for the filter in the working directory before
+	// looking for it on the PATH
+	name := path
+	if _, err := os.Stat(path); err == nil && !strings.ContainsRune(path, filepath.Separator) {
+		name = "." + string(filepath.Separator) + path
+	}
+
+	cmd := exec.CommandContext(ctx, name, format)
+	cmd.Stdin = &in
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "PANDOC_VERSION="+FilterPandocVersion)
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("error running filter %q: %w", path, err)
+	}
+
+	filtered, err := Read(&out)
+	if err != nil {
+		return nil, fmt.Errorf("error reading output of filter %q: %w", path, err)
+	}
+	return filtered, nil
+}
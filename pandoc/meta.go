@@ -0,0 +1,199 @@
+package pandoc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"gopkg.in/yaml.v3"
+)
+
+// Meta converts settings to pandoc metadata, with the same keys as in
+// markdown front matter. The date is the date on which the document was
+// last modified, and is omitted if it is zero.
+func Meta(settings *googledoc.Settings, modified time.Time) (map[string]*Element, error) {
+	// go through yaml so that the keys follow the settings struct
+	buf, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling settings: %w", err)
+	}
+	var values map[string]interface{}
+	err = yaml.Unmarshal(buf, &values)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling settings: %w", err)
+	}
+
+	meta := make(map[string]*Element)
+	for k, v := range values {
+		meta[k] = metaValue(v)
+	}
+	if !modified.IsZero() {
+		meta["date"] = newElement("MetaInlines", nonNil(words(modified.Format("2006-01-02"))))
+	}
+	return meta, nil
+}
+
+// metaValue converts a value decoded from yaml to a metadata value
+func metaValue(v interface{}) *Element {
+	switch v := v.(type) {
+	case bool:
+		return newElement("MetaBool", v)
+	case []interface{}:
+		items := []*Element{}
+		for _, item := range v {
+			items = append(items, metaValue(item))
+		}
+		return newElement("MetaList", items)
+	case map[string]interface{}:
+		m := make(map[string]*Element)
+		for k, item := range v {
+			m[k] = metaValue(item)
+		}
+		return newElement("MetaMap", m)
+	}
+	return newElement("MetaInlines", nonNil(words(fmt.Sprint(v))))
+}
+
+// ApplyMeta updates settings from pandoc metadata, such as after a filter
+// has changed the title. Keys that are not settings are ignored.
+func ApplyMeta(meta map[string]*Element, settings *googledoc.Settings) error {
+	values := make(map[string]interface{})
+	for k, v := range meta {
+		value, err := fromMetaValue(v)
+		if err != nil {
+			return fmt.Errorf("error in metadata %q: %w", k, err)
+		}
+		values[k] = value
+	}
+
+	// go through yaml so that the keys follow the settings struct
+	buf, err := yaml.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("error marshalling metadata: %w", err)
+	}
+	var updated googledoc.Settings
+	err = yaml.Unmarshal(buf, &updated)
+	if err != nil {
+		return fmt.Errorf("error converting metadata to settings: %w", err)
+	}
+	*settings = updated
+	return nil
+}
+
+// fromMetaValue converts a metadata value to a value for yaml
+func fromMetaValue(e *Element) (interface{}, error) {
+	switch e.Type {
+	case "MetaBool":
+		var b bool
+		err := e.Decode(&b)
+		return b, err
+	case "MetaString":
+		var s string
+		err := e.Decode(&s)
+		return s, err
+	case "MetaInlines", "MetaBlocks":
+		var elems []*Element
+		err := e.Decode(&elems)
+		if err != nil {
+			return nil, err
+		}
+		return stringify(elems), nil
+	case "MetaList":
+		var items []*Element
+		err := e.Decode(&items)
+		if err != nil {
+			return nil, err
+		}
+		var values []interface{}
+		for _, item := range items {
+			v, err := fromMetaValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case "MetaMap":
+		var m map[string]*Element
+		err := e.Decode(&m)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]interface{})
+		for k, item := range m {
+			v, err := fromMetaValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values[k] = v
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unknown metadata type %s", e.Type)
+}
+
+// stringify gets the text of some elements, leaving out notes
+func stringify(elems []*Element) string {
+	var b strings.Builder
+	for _, e := range elems {
+		// walk the decoded json, since text can be nested anywhere
+		var v interface{}
+		buf, err := json.Marshal(e)
+		if err == nil && json.Unmarshal(buf, &v) == nil {
+			stringifyValue(&b, v)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// stringifyValue writes the text in a decoded element or list of elements
+func stringifyValue(b *strings.Builder, v interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			stringifyValue(b, item)
+		}
+	case map[string]interface{}:
+		content := v["c"]
+		switch v["t"] {
+		case "Str":
+			s, _ := content.(string)
+			b.WriteString(s)
+		case "Space", "SoftBreak", "LineBreak":
+			b.WriteString(" ")
+		case "Code", "Math", "CodeBlock":
+			if fields, ok := content.([]interface{}); ok && len(fields) == 2 {
+				s, _ := fields[1].(string)
+				if v["t"] == "CodeBlock" {
+					s = " " + s + " "
+				}
+				b.WriteString(s)
+			}
+		case "Quoted":
+			if fields, ok := content.([]interface{}); ok && len(fields) == 2 {
+				open, close := quotes(fields[0])
+				b.WriteString(open)
+				stringifyValue(b, fields[1])
+				b.WriteString(close)
+			}
+		case "Note", "RawInline", "RawBlock":
+		case "Para", "Plain", "Header", "Div", "BlockQuote", "BulletList", "OrderedList", "LineBlock":
+			// blocks are separated by spaces
+			b.WriteString(" ")
+			stringifyValue(b, content)
+			b.WriteString(" ")
+		default:
+			stringifyValue(b, content)
+		}
+	}
+}
+
+// quotes gets the quotation marks for a decoded QuoteType
+func quotes(quoteType interface{}) (open, close string) {
+	if m, ok := quoteType.(map[string]interface{}); ok && m["t"] == "SingleQuote" {
+		return "‘", "’"
+	}
+	return "“", "”"
+}
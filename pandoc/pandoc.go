@@ -0,0 +1,168 @@
+// Package pandoc converts google docs to and from the JSON form of the
+// pandoc document tree, which pandoc filters read and write
+package pandoc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// APIVersion is the version of pandoc-types that we write, which is the
+// one used by pandoc 3
+var APIVersion = []int{1, 23, 1}
+
+// Document is a pandoc document
+type Document struct {
+	APIVersion []int               `json:"pandoc-api-version"`
+	Meta       map[string]*Element `json:"meta"`
+	Blocks     []*Element          `json:"blocks"`
+}
+
+// Element is a block, an inline, or a metadata value. Pandoc encodes each
+// of these as an object with the name of its constructor and its fields,
+// which are an array if there is more than one of them.
+type Element struct {
+	Type    string          `json:"t"`
+	Content json.RawMessage `json:"c,omitempty"`
+}
+
+// Attr holds the identifier, classes, and other attributes of an element
+type Attr struct {
+	ID         string
+	Classes    []string
+	Attributes [][2]string
+}
+
+// MarshalJSON encodes attributes as an array, as pandoc does
+func (a Attr) MarshalJSON() ([]byte, error) {
+	classes := a.Classes
+	if classes == nil {
+		classes = []string{}
+	}
+	attrs := a.Attributes
+	if attrs == nil {
+		attrs = [][2]string{}
+	}
+	return json.Marshal([]interface{}{a.ID, classes, attrs})
+}
+
+// UnmarshalJSON decodes attributes from an array
+func (a *Attr) UnmarshalJSON(buf []byte) error {
+	return decodeFields(buf, &a.ID, &a.Classes, &a.Attributes)
+}
+
+// HasClass determines whether an element has a class
+func (a Attr) HasClass(class string) bool {
+	for _, c := range a.Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Get gets the value of an attribute, or an empty string if it is not set
+func (a Attr) Get(key string) string {
+	for _, kv := range a.Attributes {
+		if kv[0] == key {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+// Read reads a document in pandoc's JSON format
+func Read(r io.Reader) (*Document, error) {
+	var d Document
+	err := json.NewDecoder(r).Decode(&d)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding pandoc json: %w", err)
+	}
+	if len(d.APIVersion) < 2 || d.APIVersion[0] != APIVersion[0] || d.APIVersion[1] != APIVersion[1] {
+		return nil, fmt.Errorf("unsupported pandoc api version %v, expected %d.%d", d.APIVersion, APIVersion[0], APIVersion[1])
+	}
+	return &d, nil
+}
+
+// Write writes a document in pandoc's JSON format
+func Write(w io.Writer, d *Document) error {
+	err := json.NewEncoder(w).Encode(d)
+	if err != nil {
+		return fmt.Errorf("error encoding pandoc json: %w", err)
+	}
+	return nil
+}
+
+// newElement creates an element from its fields
+func newElement(t string, fields ...interface{}) *Element {
+	var content interface{} = fields
+	switch len(fields) {
+	case 0:
+		return &Element{Type: t}
+	case 1:
+		content = fields[0]
+	}
+	buf, err := json.Marshal(content)
+	if err != nil {
+		// fields are always strings, numbers, attributes, and elements
+		panic(fmt.Sprintf("error encoding %s: %v", t, err))
+	}
+	return &Element{Type: t, Content: buf}
+}
+
+// Decode decodes the fields of an element into the given pointers
+func (e *Element) Decode(fields ...interface{}) error {
+	err := decodeFields(e.Content, fields...)
+	if err != nil {
+		return fmt.Errorf("error decoding %s: %w", e.Type, err)
+	}
+	return nil
+}
+
+// decodeFields decodes one value, or an array of values if there is more than one
+func decodeFields(buf []byte, fields ...interface{}) error {
+	if len(fields) == 1 {
+		return json.Unmarshal(buf, fields[0])
+	}
+	var parts []json.RawMessage
+	err := json.Unmarshal(buf, &parts)
+	if err != nil {
+		return err
+	}
+	if len(parts) != len(fields) {
+		return errors.New("wrong number of fields")
+	}
+	for i, part := range parts {
+		if err := json.Unmarshal(part, fields[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tag is a constructor with no fields, such as AlignDefault or InlineMath
+func tag(t string) *Element {
+	return &Element{Type: t}
+}
+
+// Constructors for the elements that we write
+
+func str(s string) *Element             { return newElement("Str", s) }
+func space() *Element                   { return tag("Space") }
+func lineBreak() *Element               { return tag("LineBreak") }
+func para(inlines []*Element) *Element  { return newElement("Para", nonNil(inlines)) }
+func plain(inlines []*Element) *Element { return newElement("Plain", nonNil(inlines)) }
+func wrap(t string, inlines []*Element) *Element {
+	return newElement(t, nonNil(inlines))
+}
+
+// nonNil replaces a nil slice of elements with an empty one, which pandoc
+// requires in place of null
+func nonNil(elems []*Element) []*Element {
+	if elems == nil {
+		return []*Element{}
+	}
+	return elems
+}
@@ -0,0 +1,946 @@
+package pandoc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/alexflint/doc-publisher/googledoc"
+	"github.com/alexflint/doc-publisher/markdown"
+	"google.golang.org/api/docs/v1"
+)
+
+// named styles for paragraphs in callouts and epigraphs, which google docs
+// has no styles for, and which RoleRules assigns roles to
+const (
+	calloutStyle  = "CALLOUT"
+	epigraphStyle = "EPIGRAPH"
+)
+
+// codeFont is the font for code blocks and inline code
+const codeFont = "Courier New"
+
+// background colors for highlighted text and spoilers
+var (
+	highlightColor = &docs.OptionalColor{Color: &docs.Color{RgbColor: &docs.RgbColor{Red: 1, Green: 1}}}
+	spoilerColor   = &docs.OptionalColor{Color: &docs.Color{RgbColor: &docs.RgbColor{}}}
+)
+
+// RoleRules are the style rules for documents produced by ToGoogleDoc. They
+// give roles to the paragraphs and text that came from callouts, epigraphs,
+// highlights, and spoilers. Use WithRoleRules to combine them with the rules
+// that the source document was converted with.
+var RoleRules = mustParseStyleRules(`
+rules:
+  - named_style: CALLOUT
+    block: callout
+  - named_style: EPIGRAPH
+    block: epigraph
+  - background: "#ffff00"
+    inline: highlight
+  - background: "#000000"
+    inline: spoiler
+`)
+
+// WithRoleRules combines RoleRules with other style rules, which may be
+// nil, for converting documents produced by ToGoogleDoc. RoleRules take
+// precedence, and the monospace fonts of the other rules are kept.
+func WithRoleRules(rules *googledoc.StyleRules) *googledoc.StyleRules {
+	if rules == nil {
+		return RoleRules
+	}
+	return &googledoc.StyleRules{
+		Rules:     append(append([]*googledoc.StyleRule(nil), RoleRules.Rules...), rules.Rules...),
+		Monospace: rules.Monospace,
+	}
+}
+
+func mustParseStyleRules(src string) *googledoc.StyleRules {
+	rules, err := googledoc.ParseStyleRules([]byte(src))
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// ToGoogleDoc converts a pandoc document, such as the output of a filter,
+// back to a google doc that the other converters can export. Everything
+// except the content is copied from the source document that the pandoc
+// document came from. Images that were in the source document keep their
+// inline objects, and new images are added to imageURLByObjectID.
+//
+// The round trip through pandoc loses what pandoc cannot represent. Lists,
+// footnotes, and inline objects are rebuilt from the pandoc document, so
+// lists keep only whether they are bulleted, numbered, or checklists, and
+// images that a filter adds have no size. Fonts, colors, and sizes of
+// text are lost except where they gave text a role, and raw blocks and
+// inlines are dropped. Comments and suggestions cannot survive at all.
+func ToGoogleDoc(d *Document, src *docs.Document, imageURLByObjectID map[string]string) (*docs.Document, error) {
+	doc := *src
+	doc.Body = &docs.Body{}
+	doc.Footnotes = make(map[string]docs.Footnote)
+	doc.InlineObjects = make(map[string]docs.InlineObject)
+	doc.Lists = make(map[string]docs.List)
+
+	b := builder{
+		src:          src,
+		doc:          &doc,
+		urls:         imageURLByObjectID,
+		objectsByURL: make(map[string][]string),
+		headingIDs:   make(map[string]string),
+		levels:       make(map[string]map[int64]bool),
+	}
+
+	// images are matched to the objects in the source document in order
+	var sectionBreak bool
+	if src.Body != nil {
+		sectionBreak = len(src.Body.Content) > 0 && src.Body.Content[0].SectionBreak != nil
+		forEachObject(src.Body.Content, func(id string) {
+			if url, ok := imageURLByObjectID[id]; ok {
+				b.objectsByURL[url] = append(b.objectsByURL[url], id)
+			}
+		})
+		for id, anchor := range markdown.HeadingAnchors(src.Body.Content) {
+			b.headingIDs[anchor] = id
+		}
+	}
+
+	// the body starts with a section break if that of the source document did
+	if sectionBreak {
+		doc.Body.Content = []*docs.StructuralElement{{StartIndex: 0, EndIndex: 1, SectionBreak: &docs.SectionBreak{}}}
+	}
+	b.index = 1
+	content, err := b.blocks(d.Blocks, blockContext{})
+	if err != nil {
+		return nil, fmt.Errorf("error converting pandoc document to google doc: %w", err)
+	}
+	doc.Body.Content = append(doc.Body.Content, content...)
+
+	// links to headings can only be resolved once all headings are known
+	for _, link := range b.links {
+		if id, ok := b.headingIDs[strings.TrimPrefix(link.Url, "#")]; ok {
+			link.HeadingId = id
+			link.Url = ""
+		}
+	}
+	return &doc, nil
+}
+
+// forEachObject calls a function for each inline object in some content,
+// in the order in which they appear
+func forEachObject(content []*docs.StructuralElement, f func(id string)) {
+	for _, elem := range content {
+		switch {
+		case elem.Paragraph != nil:
+			for _, el := range elem.Paragraph.Elements {
+				if el.InlineObjectElement != nil {
+					f(el.InlineObjectElement.InlineObjectId)
+				}
+			}
+		case elem.Table != nil:
+			for _, row := range elem.Table.TableRows {
+				for _, c := range row.TableCells {
+					forEachObject(c.Content, f)
+				}
+			}
+		}
+	}
+}
+
+type builder struct {
+	src          *docs.Document
+	doc          *docs.Document
+	urls         map[string]string         // image URLs by inline object ID
+	objectsByURL map[string][]string       // inline objects in the source document not yet used, by image URL
+	headingIDs   map[string]string         // heading IDs by identifier
+	levels       map[string]map[int64]bool // nesting levels that have been set up, by list ID
+	links        []*docs.Link              // links to identifiers, to be resolved at the end
+	images       int                       // number of images not in the source document
+	headings     int                       // number of headings not in the source document
+	index        int64                     // current position in the segment being built, in UTF-16 code units
+}
+
+// blockContext holds what applies to all of the paragraphs in a sequence of blocks
+type blockContext struct {
+	namedStyle string // named style for ordinary paragraphs, such as CALLOUT
+	indent     int    // number of levels of indentation
+	center     bool
+	listID     string       // the list that the blocks are inside, if any
+	level      int64        // nesting level of the list item that the blocks are inside
+	bullet     *docs.Bullet // the bullet for the first paragraph of a list item
+	checklist  bool         // whether the first paragraph of a list item starts with a checkbox
+}
+
+// blocks converts a sequence of blocks to structural elements
+func (b *builder) blocks(elems []*Element, ctx blockContext) ([]*docs.StructuralElement, error) {
+	var out []*docs.StructuralElement
+	for _, e := range elems {
+		content, err := b.block(e, ctx)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, content...)
+
+		// only the first paragraph of a list item has a bullet
+		if len(content) > 0 {
+			ctx.bullet = nil
+			ctx.checklist = false
+		}
+	}
+	return out, nil
+}
+
+func (b *builder) block(e *Element, ctx blockContext) ([]*docs.StructuralElement, error) {
+	one := func(elem *docs.StructuralElement, err error) ([]*docs.StructuralElement, error) {
+		if err != nil {
+			return nil, err
+		}
+		return []*docs.StructuralElement{elem}, nil
+	}
+
+	switch e.Type {
+	case "Plain", "Para":
+		var inlines []*Element
+		if err := e.Decode(&inlines); err != nil {
+			return nil, err
+		}
+		return one(b.paragraph(inlines, paragraphStyle(ctx), ctx))
+	case "LineBlock":
+		var lines [][]*Element
+		if err := e.Decode(&lines); err != nil {
+			return nil, err
+		}
+		var inlines []*Element
+		for i, line := range lines {
+			if i > 0 {
+				inlines = append(inlines, lineBreak())
+			}
+			inlines = append(inlines, line...)
+		}
+		return one(b.paragraph(inlines, paragraphStyle(ctx), ctx))
+	case "CodeBlock":
+		var attr Attr
+		var code string
+		if err := e.Decode(&attr, &code); err != nil {
+			return nil, err
+		}
+		return b.codeBlock(attr, code, ctx), nil
+	case "RawBlock":
+		var format, text string
+		if err := e.Decode(&format, &text); err != nil {
+			return nil, err
+		}
+		log.Printf("warning: ignoring raw %s block", format)
+		return nil, nil
+	case "BlockQuote":
+		var blocks []*Element
+		if err := e.Decode(&blocks); err != nil {
+			return nil, err
+		}
+		ctx.indent++
+		return b.blocks(blocks, ctx)
+	case "OrderedList":
+		var listAttrs []json.RawMessage
+		var items [][]*Element
+		if err := e.Decode(&listAttrs, &items); err != nil {
+			return nil, err
+		}
+		start := int64(1)
+		if len(listAttrs) > 0 {
+			_ = json.Unmarshal(listAttrs[0], &start)
+		}
+		return b.list(items, googledoc.NumberedList, start, ctx)
+	case "BulletList":
+		var items [][]*Element
+		if err := e.Decode(&items); err != nil {
+			return nil, err
+		}
+		kind := googledoc.BulletList
+		if isChecklist(items) {
+			kind = googledoc.Checklist
+		}
+		return b.list(items, kind, 1, ctx)
+	case "DefinitionList":
+		var raw [][2]json.RawMessage
+		if err := e.Decode(&raw); err != nil {
+			return nil, err
+		}
+		var out []*docs.StructuralElement
+		for _, pair := range raw {
+			var term []*Element
+			var definitions [][]*Element
+			if err := decodeFields(pair[0], &term); err != nil {
+				return nil, err
+			}
+			if err := decodeFields(pair[1], &definitions); err != nil {
+				return nil, err
+			}
+			p, err := b.paragraph([]*Element{wrap("Strong", term)}, paragraphStyle(ctx), ctx)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, p)
+			inner := ctx
+			inner.indent++
+			for _, blocks := range definitions {
+				content, err := b.blocks(blocks, inner)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, content...)
+			}
+		}
+		return out, nil
+	case "Header":
+		var level int
+		var attr Attr
+		var inlines []*Element
+		if err := e.Decode(&level, &attr, &inlines); err != nil {
+			return nil, err
+		}
+		style := &docs.ParagraphStyle{NamedStyleType: fmt.Sprintf("HEADING_%d", level)}
+		if level > 6 {
+			style.NamedStyleType = "HEADING_6"
+		}
+		if attr.HasClass("title") {
+			style.NamedStyleType = "TITLE"
+		}
+		style.HeadingId = b.headingID(attr.ID)
+		return one(b.paragraph(inlines, style, blockContext{}))
+	case "HorizontalRule":
+		start := b.index
+		p := &docs.Paragraph{ParagraphStyle: paragraphStyle(ctx)}
+		b.appendElement(p, &docs.ParagraphElement{HorizontalRule: &docs.HorizontalRule{}})
+		b.appendText(p, "\n", docs.TextStyle{})
+		return one(&docs.StructuralElement{StartIndex: start, EndIndex: b.index, Paragraph: p}, nil)
+	case "Table":
+		return b.table(e)
+	case "Figure":
+		var attr Attr
+		var caption []json.RawMessage
+		var blocks []*Element
+		if err := e.Decode(&attr, &caption, &blocks); err != nil {
+			return nil, err
+		}
+		out, err := b.blocks(blocks, ctx)
+		if err != nil {
+			return nil, err
+		}
+		var captionBlocks []*Element
+		if len(caption) == 2 {
+			if err := decodeFields(caption[1], &captionBlocks); err != nil {
+				return nil, err
+			}
+		}
+		content, err := b.blocks(captionBlocks, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return append(out, content...), nil
+	case "Div":
+		var attr Attr
+		var blocks []*Element
+		if err := e.Decode(&attr, &blocks); err != nil {
+			return nil, err
+		}
+		return b.div(attr, blocks, ctx)
+	case "Null":
+		return nil, nil
+	}
+	log.Printf("warning: ignoring pandoc block of unknown type %s", e.Type)
+	return nil, nil
+}
+
+// paragraphStyle gets the style for an ordinary paragraph in a context
+func paragraphStyle(ctx blockContext) *docs.ParagraphStyle {
+	style := &docs.ParagraphStyle{NamedStyleType: "NORMAL_TEXT"}
+	if ctx.namedStyle != "" {
+		style.NamedStyleType = ctx.namedStyle
+	}
+	if ctx.indent > 0 {
+		style.IndentStart = &docs.Dimension{Magnitude: float64(36 * ctx.indent), Unit: "PT"}
+	}
+	if ctx.center {
+		style.Alignment = "CENTER"
+	}
+	return style
+}
+
+// paragraph converts inlines to a paragraph
+func (b *builder) paragraph(inlines []*Element, style *docs.ParagraphStyle, ctx blockContext) (*docs.StructuralElement, error) {
+	start := b.index
+	p := &docs.Paragraph{ParagraphStyle: style}
+
+	var ts docs.TextStyle
+	p.Bullet = ctx.bullet
+	if ctx.checklist {
+		// completed items are struck through
+		if checked, rest, ok := checkbox(inlines); ok {
+			inlines = rest
+			ts.Strikethrough = checked
+		}
+	}
+	err := b.inlines(p, inlines, ts)
+	if err != nil {
+		return nil, err
+	}
+	b.appendText(p, "\n", docs.TextStyle{})
+	return &docs.StructuralElement{StartIndex: start, EndIndex: b.index, Paragraph: p}, nil
+}
+
+// codeBlock converts a code block to paragraphs of monospace text, with
+// the language on the first line as in google docs
+func (b *builder) codeBlock(attr Attr, code string, ctx blockContext) []*docs.StructuralElement {
+	for _, class := range attr.Classes {
+		if class != "sourceCode" {
+			code = "lang: " + class + "\n" + code
+			break
+		}
+	}
+
+	// code in a list item is indented so that it stays in the item
+	if ctx.listID != "" && ctx.indent == 0 {
+		ctx.indent = int(ctx.level) + 1
+	}
+	ctx.namedStyle = ""
+	ctx.center = false
+
+	mono := docs.TextStyle{WeightedFontFamily: &docs.WeightedFontFamily{FontFamily: codeFont, Weight: 400}}
+	var out []*docs.StructuralElement
+	for _, line := range strings.Split(code, "\n") {
+		start := b.index
+		p := &docs.Paragraph{ParagraphStyle: paragraphStyle(ctx)}
+		b.appendText(p, line+"\n", mono)
+		out = append(out, &docs.StructuralElement{StartIndex: start, EndIndex: b.index, Paragraph: p})
+	}
+	return out
+}
+
+// div converts the blocks in a div, according to its classes
+func (b *builder) div(attr Attr, blocks []*Element, ctx blockContext) ([]*docs.StructuralElement, error) {
+	var open, close string
+	switch {
+	case attr.HasClass("spoiler"):
+		open, close = "[spoiler]", "[/spoiler]"
+	case attr.HasClass("details"):
+		open, close = "[details: "+attr.Get("summary")+"]", "[/details]"
+	case attr.HasClass(googledoc.CalloutRole):
+		ctx.namedStyle = calloutStyle
+	case attr.HasClass(googledoc.EpigraphRole):
+		ctx.namedStyle = epigraphStyle
+	case attr.HasClass("subtitle"):
+		ctx.namedStyle = "SUBTITLE"
+	case attr.HasClass("center"):
+		ctx.center = true
+	}
+
+	var out []*docs.StructuralElement
+	if open != "" {
+		p, err := b.paragraph(words(open), paragraphStyle(blockContext{}), blockContext{})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	content, err := b.blocks(blocks, ctx)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, content...)
+	if close != "" {
+		p, err := b.paragraph(words(close), paragraphStyle(blockContext{}), blockContext{})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// list converts the items of a list. Lists inside list items become deeper
+// nesting levels of the same google docs list.
+func (b *builder) list(items [][]*Element, kind string, start int64, ctx blockContext) ([]*docs.StructuralElement, error) {
+	level := ctx.level + 1
+	if ctx.listID == "" {
+		ctx.listID = fmt.Sprintf("pandoc.list.%d", len(b.doc.Lists)+1)
+		level = 0
+	}
+	if level > 8 {
+		level = 8
+	}
+	b.setLevel(ctx.listID, level, kind, start)
+
+	ctx.level = level
+	ctx.indent = 0
+	var out []*docs.StructuralElement
+	for _, item := range items {
+		itemCtx := ctx
+		itemCtx.bullet = &docs.Bullet{ListId: ctx.listID, NestingLevel: level}
+		itemCtx.checklist = kind == googledoc.Checklist
+		content, err := b.blocks(item, itemCtx)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, content...)
+	}
+	return out, nil
+}
+
+// setLevel sets up a nesting level of a list, if it has not been set up already
+func (b *builder) setLevel(listID string, level int64, kind string, start int64) {
+	if b.levels[listID] == nil {
+		var levels []*docs.NestingLevel
+		for depth := 0; depth < 9; depth++ {
+			levels = append(levels, &docs.NestingLevel{
+				GlyphSymbol: "●",
+				IndentStart: &docs.Dimension{Magnitude: float64(36 * (depth + 1)), Unit: "PT"},
+			})
+		}
+		b.doc.Lists[listID] = docs.List{ListProperties: &docs.ListProperties{NestingLevels: levels}}
+		b.levels[listID] = make(map[int64]bool)
+	}
+	if b.levels[listID][level] {
+		return
+	}
+	b.levels[listID][level] = true
+
+	nl := b.doc.Lists[listID].ListProperties.NestingLevels[level]
+	switch kind {
+	case googledoc.NumberedList:
+		nl.GlyphSymbol = ""
+		nl.GlyphType = "DECIMAL"
+		nl.GlyphFormat = fmt.Sprintf("%%%d.", level)
+		nl.StartNumber = start
+	case googledoc.Checklist:
+		nl.GlyphSymbol = "☐"
+	}
+}
+
+// isChecklist determines whether every item of a list starts with a checkbox
+func isChecklist(items [][]*Element) bool {
+	for _, item := range items {
+		if len(item) == 0 || (item[0].Type != "Plain" && item[0].Type != "Para") {
+			return false
+		}
+		var inlines []*Element
+		if item[0].Decode(&inlines) != nil {
+			return false
+		}
+		if _, _, ok := checkbox(inlines); !ok {
+			return false
+		}
+	}
+	return len(items) > 0
+}
+
+// checkbox determines whether inlines start with a checkbox, as pandoc
+// represents task list items, and returns the inlines after it
+func checkbox(inlines []*Element) (checked bool, rest []*Element, ok bool) {
+	if len(inlines) == 0 || inlines[0].Type != "Str" {
+		return false, inlines, false
+	}
+	var s string
+	if inlines[0].Decode(&s) != nil {
+		return false, inlines, false
+	}
+	switch s {
+	case "☐":
+	case "☒":
+		checked = true
+	default:
+		return false, inlines, false
+	}
+	rest = inlines[1:]
+	if len(rest) > 0 && rest[0].Type == "Space" {
+		rest = rest[1:]
+	}
+	return checked, rest, true
+}
+
+// headingID gets the heading ID for an identifier, which is the ID of the
+// heading in the source document that it came from if there is one
+func (b *builder) headingID(anchor string) string {
+	if id, ok := b.headingIDs[anchor]; ok && anchor != "" {
+		return id
+	}
+	b.headings++
+	id := fmt.Sprintf("h.pandoc.%d", b.headings)
+	if anchor != "" {
+		b.headingIDs[anchor] = id
+	}
+	return id
+}
+
+// table converts a table. Cells that are covered by other cells that span
+// several rows or columns are empty, as in google docs.
+func (b *builder) table(e *Element) ([]*docs.StructuralElement, error) {
+	var attr Attr
+	var caption, colspecs []json.RawMessage
+	var head, foot tableSection
+	var bodies []tableBody
+	if err := e.Decode(&attr, &caption, &colspecs, &head, &bodies, &foot); err != nil {
+		return nil, err
+	}
+	rows := head.rows
+	for _, body := range bodies {
+		rows = append(rows, body.head...)
+		rows = append(rows, body.rows...)
+	}
+	rows = append(rows, foot.rows...)
+
+	start := b.index
+	b.index++ // the table itself occupies one position
+
+	columns := len(colspecs)
+	t := docs.Table{Rows: int64(len(rows))}
+	covered := make(map[[2]int]bool)
+	for i, r := range rows {
+		row := docs.TableRow{StartIndex: b.index}
+		b.index++
+		cells := r.cells
+		for j := 0; j < columns || len(cells) > 0; j++ {
+			var c *tableCell
+			if !covered[[2]int{i, j}] && len(cells) > 0 {
+				c = &cells[0]
+				cells = cells[1:]
+			}
+
+			tc := docs.TableCell{StartIndex: b.index}
+			b.index++
+			var blocks []*Element
+			if c != nil {
+				blocks = c.blocks
+				rowspan, colspan := max64(c.rowspan, 1), max64(c.colspan, 1)
+				tc.TableCellStyle = &docs.TableCellStyle{RowSpan: rowspan, ColumnSpan: colspan}
+				for dr := 0; dr < int(rowspan); dr++ {
+					for dc := 0; dc < int(colspan); dc++ {
+						covered[[2]int{i + dr, j + dc}] = true
+					}
+				}
+			}
+			content, err := b.blocks(blocks, blockContext{})
+			if err != nil {
+				return nil, err
+			}
+			if len(content) == 0 {
+				p, err := b.paragraph(nil, paragraphStyle(blockContext{}), blockContext{})
+				if err != nil {
+					return nil, err
+				}
+				content = append(content, p)
+			}
+			tc.Content = content
+			tc.EndIndex = b.index
+			row.TableCells = append(row.TableCells, &tc)
+		}
+		row.EndIndex = b.index
+		if int64(len(row.TableCells)) > t.Columns {
+			t.Columns = int64(len(row.TableCells))
+		}
+		t.TableRows = append(t.TableRows, &row)
+	}
+	b.index++ // the end of the table occupies one position
+
+	out := []*docs.StructuralElement{{StartIndex: start, EndIndex: b.index, Table: &t}}
+
+	// the caption goes after the table
+	if len(caption) == 2 {
+		var blocks []*Element
+		if err := decodeFields(caption[1], &blocks); err != nil {
+			return nil, err
+		}
+		content, err := b.blocks(blocks, blockContext{})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, content...)
+	}
+	return out, nil
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// tableSection is the head or foot of a table
+type tableSection struct {
+	rows []tableRow
+}
+
+func (s *tableSection) UnmarshalJSON(buf []byte) error {
+	var attr Attr
+	return decodeFields(buf, &attr, &s.rows)
+}
+
+// tableBody is a body of a table, which may have its own header rows
+type tableBody struct {
+	head, rows []tableRow
+}
+
+func (tb *tableBody) UnmarshalJSON(buf []byte) error {
+	var attr Attr
+	var rowHeadColumns int
+	return decodeFields(buf, &attr, &rowHeadColumns, &tb.head, &tb.rows)
+}
+
+type tableRow struct {
+	cells []tableCell
+}
+
+func (r *tableRow) UnmarshalJSON(buf []byte) error {
+	var attr Attr
+	return decodeFields(buf, &attr, &r.cells)
+}
+
+type tableCell struct {
+	rowspan, colspan int64
+	blocks           []*Element
+}
+
+func (c *tableCell) UnmarshalJSON(buf []byte) error {
+	var attr Attr
+	var align Element
+	return decodeFields(buf, &attr, &align, &c.rowspan, &c.colspan, &c.blocks)
+}
+
+// inlines converts inlines to elements of a paragraph, with a text style
+// that applies to all of them
+func (b *builder) inlines(p *docs.Paragraph, elems []*Element, style docs.TextStyle) error {
+	for _, e := range elems {
+		err := b.inline(p, e, style)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *builder) inline(p *docs.Paragraph, e *Element, style docs.TextStyle) error {
+	// styles that wrap other inlines
+	nested := func(set func(*docs.TextStyle)) error {
+		var inlines []*Element
+		if err := e.Decode(&inlines); err != nil {
+			return err
+		}
+		set(&style)
+		return b.inlines(p, inlines, style)
+	}
+
+	switch e.Type {
+	case "Str":
+		var s string
+		if err := e.Decode(&s); err != nil {
+			return err
+		}
+		b.appendText(p, s, style)
+	case "Space", "SoftBreak":
+		b.appendText(p, " ", style)
+	case "LineBreak":
+		// google docs uses vertical tabs for line breaks within a paragraph
+		b.appendText(p, "\v", style)
+	case "Emph":
+		return nested(func(s *docs.TextStyle) { s.Italic = true })
+	case "Strong":
+		return nested(func(s *docs.TextStyle) { s.Bold = true })
+	case "Strikeout":
+		return nested(func(s *docs.TextStyle) { s.Strikethrough = true })
+	case "Underline":
+		return nested(func(s *docs.TextStyle) { s.Underline = true })
+	case "SmallCaps":
+		return nested(func(s *docs.TextStyle) { s.SmallCaps = true })
+	case "Superscript":
+		return nested(func(s *docs.TextStyle) { s.BaselineOffset = "SUPERSCRIPT" })
+	case "Subscript":
+		return nested(func(s *docs.TextStyle) { s.BaselineOffset = "SUBSCRIPT" })
+	case "Quoted":
+		var quoteType json.RawMessage
+		var inlines []*Element
+		if err := e.Decode(&quoteType, &inlines); err != nil {
+			return err
+		}
+		var qt interface{}
+		_ = decodeFields(quoteType, &qt)
+		open, close := quotes(qt)
+		b.appendText(p, open, style)
+		if err := b.inlines(p, inlines, style); err != nil {
+			return err
+		}
+		b.appendText(p, close, style)
+	case "Cite":
+		var citations json.RawMessage
+		var inlines []*Element
+		if err := e.Decode(&citations, &inlines); err != nil {
+			return err
+		}
+		return b.inlines(p, inlines, style)
+	case "Code":
+		var attr Attr
+		var code string
+		if err := e.Decode(&attr, &code); err != nil {
+			return err
+		}
+		style.WeightedFontFamily = &docs.WeightedFontFamily{FontFamily: codeFont, Weight: 400}
+		b.appendText(p, strings.ReplaceAll(code, "\n", " "), style)
+	case "Math":
+		// the other converters recognize latex in text
+		var mathType Element
+		var tex string
+		if err := e.Decode(&mathType, &tex); err != nil {
+			return err
+		}
+		b.appendText(p, tex, style)
+	case "RawInline":
+		var format, text string
+		if err := e.Decode(&format, &text); err != nil {
+			return err
+		}
+		log.Printf("warning: ignoring raw %s inline", format)
+	case "Link":
+		var attr Attr
+		var inlines []*Element
+		var target [2]string
+		if err := e.Decode(&attr, &inlines, &target); err != nil {
+			return err
+		}
+		link := &docs.Link{Url: target[0]}
+		if strings.HasPrefix(target[0], "#") {
+			b.links = append(b.links, link)
+		}
+		style.Link = link
+		return b.inlines(p, inlines, style)
+	case "Image":
+		var attr Attr
+		var alt []*Element
+		var target [2]string
+		if err := e.Decode(&attr, &alt, &target); err != nil {
+			return err
+		}
+		b.image(p, target[0], stringify(alt), target[1])
+	case "Note":
+		var blocks []*Element
+		if err := e.Decode(&blocks); err != nil {
+			return err
+		}
+		return b.footnote(p, blocks)
+	case "Span":
+		var attr Attr
+		var inlines []*Element
+		if err := e.Decode(&attr, &inlines); err != nil {
+			return err
+		}
+		switch {
+		case attr.HasClass("mark"):
+			style.BackgroundColor = highlightColor
+		case attr.HasClass("spoiler"):
+			style.BackgroundColor = spoilerColor
+		case attr.HasClass("smallcaps"):
+			style.SmallCaps = true
+		case attr.HasClass("underline") || attr.HasClass("ul"):
+			style.Underline = true
+		}
+		return b.inlines(p, inlines, style)
+	default:
+		log.Printf("warning: ignoring pandoc inline of unknown type %s", e.Type)
+	}
+	return nil
+}
+
+// image adds an image to a paragraph. Images that were in the source
+// document keep their inline objects, so that their sizes and any links to
+// charts are kept too.
+func (b *builder) image(p *docs.Paragraph, url, alt, title string) {
+	var id string
+	var emb docs.EmbeddedObject
+	if ids := b.objectsByURL[url]; len(ids) > 0 {
+		id = ids[0]
+		b.objectsByURL[url] = ids[1:]
+		if obj := b.src.InlineObjects[id]; obj.InlineObjectProperties != nil && obj.InlineObjectProperties.EmbeddedObject != nil {
+			emb = *obj.InlineObjectProperties.EmbeddedObject
+		}
+	} else {
+		b.images++
+		id = fmt.Sprintf("pandoc.image.%d", b.images)
+		emb.ImageProperties = &docs.ImageProperties{}
+		b.urls[id] = url
+	}
+	emb.Title = title
+	emb.Description = alt
+
+	b.doc.InlineObjects[id] = docs.InlineObject{
+		ObjectId:               id,
+		InlineObjectProperties: &docs.InlineObjectProperties{EmbeddedObject: &emb},
+	}
+	b.appendElement(p, &docs.ParagraphElement{
+		InlineObjectElement: &docs.InlineObjectElement{InlineObjectId: id},
+	})
+}
+
+// footnote adds a footnote with the given content, and a reference to it
+func (b *builder) footnote(p *docs.Paragraph, blocks []*Element) error {
+	id := fmt.Sprintf("pandoc.fn.%d", len(b.doc.Footnotes)+1)
+
+	// footnotes are segments of their own, with their own indices
+	saved := b.index
+	b.index = 0
+	content, err := b.blocks(blocks, blockContext{})
+	if err == nil && len(content) == 0 {
+		var elem *docs.StructuralElement
+		elem, err = b.paragraph(nil, paragraphStyle(blockContext{}), blockContext{})
+		content = append(content, elem)
+	}
+	b.index = saved
+	if err != nil {
+		return fmt.Errorf("error converting footnote: %w", err)
+	}
+
+	b.doc.Footnotes[id] = docs.Footnote{FootnoteId: id, Content: content}
+	b.appendElement(p, &docs.ParagraphElement{
+		FootnoteReference: &docs.FootnoteReference{
+			FootnoteId:     id,
+			FootnoteNumber: strconv.Itoa(len(b.doc.Footnotes)),
+		},
+	})
+	return nil
+}
+
+// appendText adds text to a paragraph, merging it with the previous run of
+// text if that has the same style
+func (b *builder) appendText(p *docs.Paragraph, text string, style docs.TextStyle) {
+	if text == "" {
+		return
+	}
+
+	length := int64(len(utf16.Encode([]rune(text))))
+	if n := len(p.Elements); n > 0 {
+		last := p.Elements[n-1]
+		if last.TextRun != nil && reflect.DeepEqual(last.TextRun.TextStyle, &style) {
+			last.TextRun.Content += text
+			last.EndIndex += length
+			b.index += length
+			return
+		}
+	}
+
+	p.Elements = append(p.Elements, &docs.ParagraphElement{
+		StartIndex: b.index,
+		EndIndex:   b.index + length,
+		TextRun:    &docs.TextRun{Content: text, TextStyle: &style},
+	})
+	b.index += length
+}
+
+// appendElement adds a non-text element to a paragraph. Each such element
+// occupies one position in the document.
+func (b *builder) appendElement(p *docs.Paragraph, e *docs.ParagraphElement) {
+	e.StartIndex = b.index
+	e.EndIndex = b.index + 1
+	b.index++
+	p.Elements = append(p.Elements, e)
+}